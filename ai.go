@@ -6,8 +6,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"text/template"
-	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
 
 	"github.com/flarexio/talkix/auth"
 	"github.com/flarexio/talkix/config"
@@ -25,16 +28,16 @@ Stage 1 (Your Role): Content Generation
 - Focus on providing complete information and analysis based on available tools and data
 - Your response will be passed to a formatting agent, so prioritize content quality over formatting
 
-Stage 2 (After You): LINE Message Formatting  
-- Another AI agent will take your response and format it appropriately for LINE messaging
-- The formatting agent will decide whether to use text format or structured templates based on your content
-- You don't need to worry about LINE-specific formatting or structure
+Stage 2 (After You): Message Formatting
+- Another AI agent will take your response and format it as a chat message
+- The formatting agent will decide whether to use text format or structured cards based on your content
+- You don't need to worry about channel-specific formatting or structure
 
 <UserProfile>
 {{ .UserProfile }}
 </UserProfile>
-If <UserProfile> is (NULL), please prompt the user to bind their account before proceeding.
-
+{{ if .CanBind }}If <UserProfile> is (NULL), please prompt the user to bind their account before proceeding.
+{{ end }}
 Instructions:
 1. Provide helpful and accurate responses to user queries with complete information.
 2. When a tool is available for a query, always use the tool to get the latest information. Do not rely on your own internal knowledge.
@@ -66,38 +69,38 @@ Usage Guidelines:
 - When user needs to bind their account, provide login instructions
 - Always provide helpful and relevant information based on the context
 
-Remember: Your primary focus is on content accuracy and completeness. The formatting agent will handle LINE-specific presentation based on your response content and the tools you used.
+Remember: Your primary focus is on content accuracy and completeness. The formatting agent will handle the presentation based on your response content and the tools you used.
 `
 
-const LINE_SYSTEM_PROMPT = `You are a LINE message formatter. Your role is to process the output from another AI assistant and convert it into structured LINE message format.
+const FORMAT_SYSTEM_PROMPT = `You are a chat message formatter. Your role is to process the output from another AI assistant and convert it into a structured chat message, which is shown on LINE, Slack, Telegram or the web chat.
 
 You will receive the complete conversation flow including:
 1. User messages
 2. Tool calls and their outputs (weather data, place information, etc.)
 3. AI assistant responses
-4. The final AI response that needs LINE formatting
+4. The final AI response that needs formatting
 
 <Messages>
 {{ .Messages }}
 </Messages>
 
-Your task: Format the FINAL AI response in the conversation into a proper LINE message structure.
+Your task: Format the FINAL AI response in the conversation into a proper chat message structure.
 
 Output Requirements:
 - Return a single JSON object (not an array)
-- Must include ALL required fields: "type", "text", "flex", "quickReply"
-- "type" must be either "text" or "flex"
+- Must include ALL required fields: "type", "text", "card", "quickReply"
+- "type" must be either "text" or "card"
 
 Format Rules:
 IF type is "text":
 - Fill "text" field with object containing "text" string
-- Set "flex" to null
+- Set "card" to null
 
-IF type is "flex":
-- Fill "flex" field with object containing "altText", "flex", "templateSpec"
+IF type is "card":
+- Fill "card" field with object containing "altText", "cards", "templateSpec"
 - Set "text" to null
-- For templates: set "flex" to empty string, fill "templateSpec"
-- For custom Flex JSON: set "flex" to JSON string, "templateSpec" to null
+- For templates: set "cards" to null, fill "templateSpec"
+- For custom cards: fill "cards" with one card, or several for a carousel, and set "templateSpec" to null
 
 Template Selection Logic:
 1. Examine tool outputs in the conversation
@@ -172,7 +175,7 @@ Key Points:
 - Tool usage alone doesn't determine template choice - the AI response format matters more
 `
 
-func MainSystemPrompt(prompt string) (llm.PromptTemplate, error) {
+func MainSystemPrompt(prompt string, history session.HistoryOptions) (llm.PromptTemplate, error) {
 	promptTemplate := MAIN_SYSTEM_PROMPT
 	if prompt != "" {
 		promptTemplate = prompt
@@ -198,8 +201,13 @@ func MainSystemPrompt(prompt string) (llm.PromptTemplate, error) {
 			userProfile = string(bs)
 		}
 
+		// The users are only asked to bind their account on the channels
+		// that can bind one.
+		_, canBind := ctx.Value(LoginKey).(*Login)
+
 		values := map[string]any{
 			"UserProfile": userProfile,
+			"CanBind":     canBind,
 		}
 
 		buf := &bytes.Buffer{}
@@ -216,24 +224,19 @@ func MainSystemPrompt(prompt string) (llm.PromptTemplate, error) {
 			return nil, errors.New("session not found in context")
 		}
 
-		if len(s.Conversations) > 0 {
-			latestConv := s.Conversations[len(s.Conversations)-1]
-			history := latestConv.TrimMessages(5)
-
-			msgs = append(msgs, history...)
-		}
+		msgs = append(msgs, s.History(history)...)
 
 		return msgs, nil
 	}, nil
 }
 
-func LineSystemPrompt(prompt string) (llm.PromptTemplate, error) {
-	promptTemplate := LINE_SYSTEM_PROMPT
+func FormatSystemPrompt(prompt string) (llm.PromptTemplate, error) {
+	promptTemplate := FORMAT_SYSTEM_PROMPT
 	if prompt != "" {
 		promptTemplate = prompt
 	}
 
-	tmpl, err := template.New("line_system_prompt").Parse(promptTemplate)
+	tmpl, err := template.New("format_system_prompt").Parse(promptTemplate)
 	if err != nil {
 		return nil, err
 	}
@@ -267,65 +270,79 @@ func LineSystemPrompt(prompt string) (llm.PromptTemplate, error) {
 	}, nil
 }
 
+// NewAIService creates the two-stage AI service. The options are applied to
+// both the main and the formatting LLM after the configured ones.
 func NewAIService(cfg config.Config, tools []llm.Tool, otp *auth.OTPStore,
-	users user.Repository, sessions session.Repository,
+	users user.Repository, sessions session.Repository, threads session.ThreadRepository,
+	summarizer session.Summarizer, opts ...llm.Option,
 ) (Service, error) {
 	// 主要邏輯處理
-	mainPrompt, err := MainSystemPrompt(cfg.LLM.Prompt)
+	history := session.HistoryOptions{
+		Budget:       cfg.LLM.History.BudgetFor(cfg.LLM.Model),
+		MaxTurns:     cfg.LLM.History.Turns,
+		IncludeTools: cfg.LLM.History.IncludeTools,
+	}
+
+	mainPrompt, err := MainSystemPrompt(cfg.LLM.Prompt, history)
 	if err != nil {
 		return nil, err
 	}
 
-	mainLLM, err := llm.NewLLM(cfg.LLM.Model,
+	mainOpts := []llm.Option{
 		llm.WithPrompt(mainPrompt),
 		llm.WithTools(tools),
-	)
+		llm.WithToolErrorPolicy(cfg.LLM.Tools.ErrorPolicy),
+		llm.WithToolExecution(cfg.LLM.Tools.Execution),
+		llm.WithPricing(cfg.LLM.Pricing),
+		llm.WithProviders(cfg.LLM.Providers),
+	}
+
+	mainLLM, err := llm.NewLLM(cfg.LLM.Model, append(mainOpts, opts...)...)
 
 	if err != nil {
 		return nil, err
 	}
 
-	// LINE 格式化 LLM
-	linePrompt, err := LineSystemPrompt(cfg.LLM.Line.Prompt)
+	// 訊息格式化 LLM
+	formatPrompt, err := FormatSystemPrompt(cfg.LLM.Format.Prompt)
 	if err != nil {
 		return nil, err
 	}
 
-	lineLLM, err := llm.NewLLM(cfg.LLM.Line.Model,
-		llm.WithPrompt(linePrompt),
-		llm.WithStructuredOutput(LineMessage{}),
-	)
+	formatOpts := []llm.Option{
+		llm.WithPrompt(formatPrompt),
+		llm.WithStructuredOutput(FormattedMessage{}),
+		llm.WithPricing(cfg.LLM.Pricing),
+		llm.WithProviders(cfg.LLM.Providers),
+	}
+
+	formatLLM, err := llm.NewLLM(cfg.LLM.Format.Model, append(formatOpts, opts...)...)
 
 	if err != nil {
 		return nil, err
 	}
 
-	templates := map[string]*template.Template{
-		"login":        templates.LoginTemplate(cfg.Line.Login.AuthURL),
-		"session_menu": templates.SessionMenuTemplate(),
-		"weather":      templates.WeatherTemplate(),
-		"place":        templates.PlaceTemplate(),
-	}
-
 	return &aiService{
-		cfg:       cfg,
-		mainLLM:   mainLLM,
-		lineLLM:   lineLLM,
-		templates: templates,
-		otp:       otp,
-		users:     users,
-		sessions:  sessions,
+		cfg:        cfg,
+		mainLLM:    mainLLM,
+		formatLLM:  formatLLM,
+		otp:        otp,
+		users:      users,
+		sessions:   sessions,
+		threads:    threads,
+		summarizer: summarizer,
 	}, nil
 }
 
 type aiService struct {
-	cfg       config.Config
-	mainLLM   *llm.LLM
-	lineLLM   *llm.LLM
-	templates map[string]*template.Template
-	otp       *auth.OTPStore
-	users     user.Repository
-	sessions  session.Repository
+	cfg        config.Config
+	mainLLM    *llm.LLM
+	formatLLM  *llm.LLM
+	otp        *auth.OTPStore
+	users      user.Repository
+	sessions   session.Repository
+	threads    session.ThreadRepository
+	summarizer session.Summarizer
 }
 
 func (svc *aiService) Name() string {
@@ -349,19 +366,31 @@ func (svc *aiService) prepareContext(ctx context.Context) (context.Context, erro
 	ctx = context.WithValue(ctx, UserKey, u)
 
 	var s *session.Session
-	if u.SelectedSessionID == "" {
+	if thread, ok := ctx.Value(ThreadKey).(string); ok {
+		found, err := svc.threadSession(u, thread)
+		if err != nil {
+			return nil, err
+		}
+
+		s = found
+
+	} else if u.SelectedSessionID == "" {
 		s = session.NewSession(u.ID)
 		if err := svc.sessions.Save(s); err != nil {
 			return nil, err
 		}
 
-		u.AddSessionID(s.ID)
-		if err := svc.users.Save(u); err != nil {
+		err := saveUser(svc.users, u, func(u *user.User) error {
+			u.AddSessionID(s.ID)
+			return nil
+		})
+
+		if err != nil {
 			return nil, err
 		}
 
 	} else {
-		found, err := svc.sessions.Find(u.SelectedSessionID)
+		found, err := svc.sessions.FindRecent(u.SelectedSessionID, svc.historyLoad())
 		if err != nil {
 			return nil, err
 		}
@@ -373,17 +402,314 @@ func (svc *aiService) prepareContext(ctx context.Context) (context.Context, erro
 	return ctx, nil
 }
 
+// threadSession loads the session of a thread, or starts it for the first
+// message of the thread. The session is listed with the sessions of the user
+// who started it, but not selected.
+func (svc *aiService) threadSession(u *user.User, thread string) (*session.Session, error) {
+	id, err := svc.threads.FindSessionID(thread)
+	switch {
+	case err == nil:
+		s, err := svc.sessions.FindRecent(id, svc.historyLoad())
+		if !errors.Is(err, session.ErrSessionNotFound) {
+			return s, err
+		}
+
+		// The session was deleted, so the thread starts another one.
+
+	case !errors.Is(err, session.ErrThreadNotFound):
+		return nil, err
+	}
+
+	s := session.NewSession(u.ID)
+	if err := svc.sessions.Save(s); err != nil {
+		return nil, err
+	}
+
+	if err := svc.threads.SaveSessionID(thread, s.ID); err != nil {
+		return nil, err
+	}
+
+	err = saveUser(svc.users, u, func(u *user.User) error {
+		u.SessionIDs = append(u.SessionIDs, s.ID)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// historyLoad is the number of conversations loaded with a session. Only the
+// recent conversations are replayed; the earlier ones are covered by the
+// summary.
+func (svc *aiService) historyLoad() int {
+	return max(svc.cfg.LLM.History.LoadCount(), svc.cfg.LLM.Summary.Every)
+}
+
 func (svc *aiService) saveConversation(ctx context.Context, conv *session.Conversation) error {
 	s, ok := ctx.Value(SessionKey).(*session.Session)
 	if !ok {
 		return errors.New("session not found in context")
 	}
 
-	s.AddConversation(conv)
-	return svc.sessions.Save(s)
+	if err := svc.addConversation(s, conv); err != nil {
+		return err
+	}
+
+	u, ok := ctx.Value(UserKey).(*user.User)
+	if !ok {
+		return errors.New("user not found in context")
+	}
+
+	err := saveUser(svc.users, u, func(u *user.User) error {
+		u.AddUsage(conv.Usage, conv.ToolUsage)
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	// A missing summary must not fail the reply; its status records the error.
+	if svc.summarizer != nil {
+		if err := svc.summarizer.Summarize(ctx, s, conv); err != nil {
+			zap.L().Warn("failed to summarize session",
+				zap.String("session", s.ID),
+				zap.Error(err),
+			)
+		}
+	}
+
+	return nil
+}
+
+// maxSaveAttempts bounds how often a save is retried when it keeps losing
+// the race with other requests of the same user.
+const maxSaveAttempts = 3
+
+// addConversation adds the conversation to the session and saves it. When
+// another request saved the session in between, its recent conversations are
+// read again and the conversation is added to them.
+func (svc *aiService) addConversation(s *session.Session, conv *session.Conversation) error {
+	for attempt := 1; ; attempt++ {
+		s.AddConversation(conv)
+
+		err := svc.sessions.Save(s)
+		if !errors.Is(err, session.ErrConflict) || attempt == maxSaveAttempts {
+			return err
+		}
+
+		fresh, err := svc.sessions.FindRecent(s.ID, svc.historyLoad())
+		if err != nil {
+			return err
+		}
+
+		*s = *fresh
+	}
+}
+
+// saveUser applies the change to the user and saves it. When another request
+// saved the user in between, the user is read again and the change is applied
+// to the fresh copy.
+func saveUser(users user.Repository, u *user.User, change func(u *user.User) error) error {
+	for attempt := 1; ; attempt++ {
+		if err := change(u); err != nil {
+			return err
+		}
+
+		err := users.Save(u)
+		if !errors.Is(err, user.ErrConflict) || attempt == maxSaveAttempts {
+			return err
+		}
+
+		fresh, err := users.Find(u.ID)
+		if err != nil {
+			return err
+		}
+
+		// The profile is not stored, it comes with the request.
+		fresh.Profile = u.Profile
+		fresh.Verified = u.Verified
+
+		*u = *fresh
+	}
+}
+
+// SummaryUsageHook adds the usage of the session summaries to their users.
+func SummaryUsageHook(users user.Repository) session.SummaryHook {
+	return func(result session.SummaryResult) {
+		u, err := users.Find(result.UserID)
+		if err != nil {
+			return
+		}
+
+		err = saveUser(users, u, func(u *user.User) error {
+			u.AddUsage(result.Usage, nil)
+			return nil
+		})
+
+		if err != nil {
+			zap.L().Warn("failed to save summary usage",
+				zap.String("user", result.UserID),
+				zap.Error(err),
+			)
+		}
+	}
 }
 
 func (svc *aiService) ReplyMessage(ctx context.Context, msg Message) (Message, error) {
+	return svc.reply(ctx, msg, nil)
+}
+
+func (svc *aiService) StreamReply(ctx context.Context, msg Message) (<-chan StreamEvent, error) {
+	events := make(chan StreamEvent)
+
+	go func() {
+		defer close(events)
+
+		emit := func(e StreamEvent) {
+			select {
+			case events <- e:
+			case <-ctx.Done():
+			}
+		}
+
+		reply, err := svc.reply(ctx, msg, emit)
+		if err != nil {
+			emit(StreamEvent{Type: StreamEventError, Err: err})
+			return
+		}
+
+		emit(StreamEvent{Type: StreamEventReply, Reply: reply})
+	}()
+
+	return events, nil
+}
+
+// invokeMain runs the main LLM. When emit is not nil, the content generation
+// stage is streamed; the formatting stage always runs as a whole.
+func (svc *aiService) invokeMain(ctx context.Context, msg message.Message, emit func(StreamEvent)) ([]message.Message, error) {
+	if emit == nil {
+		return svc.mainLLM.InvokeMessage(ctx, msg)
+	}
+
+	for e := range svc.mainLLM.InvokeMessageStream(ctx, msg) {
+		switch e.Type {
+		case llm.EventDelta:
+			emit(StreamEvent{Type: StreamEventDelta, Delta: e.Delta})
+
+		case llm.EventToolCall:
+			emit(StreamEvent{Type: StreamEventToolCall, ToolCall: e.ToolCall})
+
+		case llm.EventToolResult:
+			emit(StreamEvent{Type: StreamEventToolResult, ToolResult: e.Message})
+
+		case llm.EventDone:
+			return e.Messages, nil
+
+		case llm.EventError:
+			return nil, e.Err
+		}
+	}
+
+	return nil, ctx.Err()
+}
+
+// maxFileText is the number of bytes of a file passed on to the main LLM.
+const maxFileText = 32 << 10
+
+// humanMessage turns a message into the input of the main LLM. The images are
+// passed on as they are, while a file is read into the text, to be summarized
+// when the message says nothing else.
+func humanMessage(m *TextMessage) message.Message {
+	texts := make([]string, 0)
+	if m.Text != "" {
+		texts = append(texts, m.Text)
+	}
+
+	attachments := make([]message.Attachment, 0, len(m.Media))
+	files := make([]string, 0)
+	for _, a := range m.Media {
+		if a.Type != message.AttachmentFile {
+			attachments = append(attachments, a)
+			continue
+		}
+
+		files = append(files, fileText(a))
+	}
+
+	if len(files) > 0 && len(texts) == 0 {
+		texts = append(texts, "Please summarize the attached file.")
+	}
+
+	return message.HumanMessage(strings.Join(append(texts, files...), "\n\n"), attachments...)
+}
+
+// readable tells whether a file is valid UTF-8 text. The other files, such as
+// PDF or Office documents, are not read.
+func readable(a message.Attachment) bool {
+	return len(a.Data) > 0 && utf8.Valid(a.Data) && bytes.IndexByte(a.Data, 0) < 0
+}
+
+// unreadableFilesText answers a message with files that cannot be read, or
+// is empty when all of them can.
+func unreadableFilesText(m *TextMessage) string {
+	names := make([]string, 0)
+	for _, a := range m.Media {
+		if a.Type == message.AttachmentFile && !readable(a) {
+			names = append(names, fmt.Sprintf("%q", a.Name))
+		}
+	}
+
+	if len(names) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("Sorry, I can only read text files, so I cannot open %s. "+
+		"Please paste the text you would like me to look at.", strings.Join(names, ", "))
+}
+
+// fileText reads a readable file into the text.
+func fileText(a message.Attachment) string {
+	data := a.Data
+
+	var truncated bool
+	if len(data) > maxFileText {
+		data = data[:maxFileText]
+		truncated = true
+	}
+
+	text := strings.ToValidUTF8(string(data), "")
+	if truncated {
+		text += "\n(truncated)"
+	}
+
+	return fmt.Sprintf("<file name=%q>\n%s\n</file>", a.Name, text)
+}
+
+// withoutAttachmentData drops the attachment data before the messages are
+// stored, so that a picture is not sent again with every reply.
+func withoutAttachmentData(msgs []message.Message) []message.Message {
+	stored := make([]message.Message, len(msgs))
+	for i, msg := range msgs {
+		if len(msg.Attachments) > 0 {
+			attachments := make([]message.Attachment, len(msg.Attachments))
+			for j, a := range msg.Attachments {
+				attachments[j] = a.WithoutData()
+			}
+
+			msg.Attachments = attachments
+		}
+
+		stored[i] = msg
+	}
+
+	return stored
+}
+
+func (svc *aiService) reply(ctx context.Context, msg Message, emit func(StreamEvent)) (Message, error) {
 	ctx, err := svc.prepareContext(ctx)
 	if err != nil {
 		return nil, err
@@ -399,7 +725,21 @@ func (svc *aiService) ReplyMessage(ctx context.Context, msg Message) (Message, e
 		return nil, errors.New("invalid message type")
 	}
 
-	msgs, err := svc.mainLLM.Invoke(ctx, m.Text)
+	// The message is not answered without the files it was sent with.
+	if text := unreadableFilesText(m); text != "" {
+		return NewTextMessage(text), nil
+	}
+
+	human := humanMessage(m)
+
+	// In a group the history is shared by its members, so every message
+	// keeps the name of its speaker.
+	chat, isChat := ctx.Value(ChatKey).(*Chat)
+	if isChat {
+		human.Name = chat.Speaker
+	}
+
+	msgs, err := svc.invokeMain(ctx, human, emit)
 	if err != nil {
 		return nil, err
 	}
@@ -410,13 +750,27 @@ func (svc *aiService) ReplyMessage(ctx context.Context, msg Message) (Message, e
 
 	resp := msgs[len(msgs)-1]
 
+	input := m.Text
+	if input == "" {
+		names := make([]string, len(m.Media))
+		for i, a := range m.Media {
+			names[i] = a.String()
+		}
+
+		input = strings.Join(names, " ")
+	}
+
+	if isChat && chat.Speaker != "" {
+		input = chat.Speaker + ": " + input
+	}
+
 	c := session.NewConversation()
-	c.SetIO(m.Text, resp.Content)
-	c.AddMessage(msgs...)
+	c.SetIO(input, resp.Content)
+	c.AddMessage(withoutAttachmentData(msgs)...)
 
 	ctx = context.WithValue(ctx, MessagesKey, msgs)
 
-	msgs, err = svc.lineLLM.Invoke(ctx, m.Text)
+	msgs, err = svc.formatLLM.Invoke(ctx, m.Text)
 	if err != nil {
 		return nil, err
 	}
@@ -425,6 +779,8 @@ func (svc *aiService) ReplyMessage(ctx context.Context, msg Message) (Message, e
 		return nil, errors.New("no messages")
 	}
 
+	c.RecordUsage(msgs...)
+
 	resp = msgs[len(msgs)-1]
 	jsonBytes := []byte(resp.Content)
 
@@ -434,7 +790,7 @@ func (svc *aiService) ReplyMessage(ctx context.Context, msg Message) (Message, e
 		return nil, err
 	}
 
-	var reply LineMessage
+	var reply FormattedMessage
 	if err := json.Unmarshal(jsonBytes, &reply); err != nil {
 		return nil, err
 	}
@@ -449,157 +805,169 @@ func (svc *aiService) ReplyMessage(ctx context.Context, msg Message) (Message, e
 		msg.AddQuickReply(reply.QuickReply...)
 		return msg, nil
 
-	case "flex":
-		if reply.Flex == nil {
-			return nil, errors.New("flex message content is empty")
+	case "card":
+		if reply.Card == nil {
+			return nil, errors.New("card message content is empty")
 		}
 
-		flexMsg := &FlexMessage{
-			AltText:      reply.Flex.AltText,
-			Flex:         reply.Flex.Flex,
-			CreatedAt:    reply.Flex.CreatedAt,
-			QuickReplies: reply.QuickReply,
-		}
-
-		if templateSpec := reply.Flex.TemplateSpec; templateSpec != nil {
-			name := templateSpec.Template
-
-			tmpl, ok := svc.templates[name]
-			if !ok {
-				return nil, errors.New("unknown template: " + name)
+		cards := reply.Card.Cards
+		if spec := reply.Card.TemplateSpec; spec != nil {
+			cards, err = svc.templateCards(ctx, u, spec)
+			if errors.Is(err, errTemplateUnavailable) {
+				// The links of the cards cannot be opened, so the
+				// answer is sent as it is.
+				msg := NewTextMessage(c.Output)
+				msg.AddQuickReply(reply.QuickReply...)
+				return msg, nil
 			}
 
-			var values any
-			switch name {
-			case "session_menu":
-				otp, err := svc.otp.GenerateOTP(u.ID, "list_sessions", nil)
-				if err != nil {
-					return nil, err
-				}
-
-				url := fmt.Sprintf("%s/users/%s/session/list?token=%s", svc.cfg.BaseURL, u.Profile.Username, otp)
-
-				values = templates.SessionMenuValues{
-					ListSessionsURL: url,
-				}
-
-			default:
-				vals, ok := templateSpec.Values[name]
-				if !ok {
-					return nil, errors.New("missing values for template: " + name)
-				}
-
-				values = vals
-			}
-
-			buf := &bytes.Buffer{}
-			if err := tmpl.Execute(buf, values); err != nil {
+			if err != nil {
 				return nil, err
 			}
+		}
 
-			flexMsg.Flex = buf.Bytes()
+		if len(cards) == 0 {
+			return nil, errors.New("card message content is empty")
 		}
 
-		return flexMsg, nil
+		msg := NewCardMessage(reply.Card.AltText, cards...)
+		msg.AddQuickReply(reply.QuickReply...)
+		return msg, nil
 
 	default:
 		return nil, errors.New("unknown message type: " + reply.Type)
 	}
 }
 
-type TemplateSpec struct {
-	Template string         `json:"template"`
-	Values   map[string]any `json:"values"`
-}
-
-type FlexMessageWithTemplate struct {
-	FlexMessage
-	TemplateSpec *TemplateSpec `json:"templateSpec,omitempty"`
-}
+// templateCards builds the cards of the template the formatter chose. The
+// session menu takes no values from the formatter: its links are made here,
+// with one-time passwords. The login links to the binding page of the
+// channel.
+func (svc *aiService) templateCards(ctx context.Context, u *user.User, spec *TemplateSpec) ([]*Card, error) {
+	name := spec.Template
 
-type LineMessage struct {
-	Type       string                   `json:"type"`
-	Text       *TextMessage             `json:"text,omitempty"`
-	Flex       *FlexMessageWithTemplate `json:"flex,omitempty"`
-	QuickReply []string                 `json:"quickReply,omitempty"`
-}
-
-func (msg *LineMessage) UnmarshalJSON(data []byte) error {
-	var raw struct {
-		Type string       `json:"type"`
-		Text *TextMessage `json:"text,omitempty"`
-		Flex *struct {
-			AltText      string `json:"altText"`
-			Flex         string `json:"flex"`
-			TemplateSpec *struct {
-				Template string         `json:"template"`
-				Values   map[string]any `json:"values"`
-			} `json:"templateSpec"`
-		} `json:"flex,omitempty"`
-		QuickReply []string `json:"quickReply,omitempty"`
-	}
-
-	if err := json.Unmarshal(data, &raw); err != nil {
-		return err
+	values, ok := spec.Values[name]
+	if !ok && name != TemplateSessionMenu {
+		return nil, errors.New("missing values for template: " + name)
 	}
 
-	msg.Type = raw.Type
-	msg.QuickReply = raw.QuickReply
+	switch name {
+	case TemplateSessionMenu:
+		v, err := sessionMenuValues(ctx, svc.cfg, svc.otp, u)
+		if err != nil {
+			return nil, err
+		}
 
-	switch raw.Type {
-	case "text":
-		msg.Text = raw.Text
+		return []*Card{SessionMenuCard(v)}, nil
 
-	case "flex":
-		if raw.Flex == nil {
-			return errors.New("flex message content is empty")
+	case TemplateLogin:
+		var v templates.LoginValues
+		if err := decodeValues(values, &v); err != nil {
+			return nil, err
 		}
 
-		flexMsg := &FlexMessageWithTemplate{
-			FlexMessage: FlexMessage{
-				AltText:   raw.Flex.AltText,
-				CreatedAt: time.Now(),
-			},
+		login, ok := ctx.Value(LoginKey).(*Login)
+		if !ok {
+			return nil, errTemplateUnavailable
 		}
 
-		if raw.Flex.Flex == "" && raw.Flex.TemplateSpec == nil {
-			return errors.New("flex message content is empty")
-		}
+		return []*Card{LoginCard(login, v)}, nil
 
-		if raw.Flex.Flex != "" {
-			flexMsg.Flex = json.RawMessage(raw.Flex.Flex)
+	case TemplateWeather:
+		var v templates.WeatherValues
+		if err := decodeValues(values, &v); err != nil {
+			return nil, err
 		}
 
-		if raw.Flex.TemplateSpec != nil {
-			flexMsg.TemplateSpec = &TemplateSpec{
-				Template: raw.Flex.TemplateSpec.Template,
-				Values:   raw.Flex.TemplateSpec.Values,
-			}
+		return []*Card{WeatherCard(v)}, nil
+
+	case TemplatePlace:
+		var v []templates.PlaceValues
+		if err := decodeValues(values, &v); err != nil {
+			return nil, err
 		}
 
-		msg.Flex = flexMsg
+		return PlaceCards(v), nil
+
+	default:
+		return nil, errors.New("unknown template: " + name)
 	}
+}
 
-	return nil
+// errTemplateUnavailable is returned for a template whose links the user
+// cannot open: the session menu without a bound account or in a group chat,
+// or the login on a channel that binds none, such as Slack or Telegram.
+var errTemplateUnavailable = errors.New("template unavailable to the user")
+
+// sessionMenuValues makes the one-time links of the session menu. The pages
+// find the user by the username of the bound account, so a user without one
+// cannot open them and gets no menu. Neither does a group chat: its user is
+// the chat, while the profile is the speaker's, so the links would open the
+// pages of one with the password of the other.
+func sessionMenuValues(ctx context.Context, cfg config.Config, otp *auth.OTPStore, u *user.User) (templates.SessionMenuValues, error) {
+	var v templates.SessionMenuValues
+	if u.Profile == nil || u.Profile.Username == "" {
+		return v, errTemplateUnavailable
+	}
+
+	if _, ok := ctx.Value(ChatKey).(*Chat); ok {
+		return v, errTemplateUnavailable
+	}
+
+	listOTP, err := otp.GenerateOTP(u.ID, "list_sessions", nil)
+	if err != nil {
+		return v, err
+	}
+
+	chatOTP, err := otp.GenerateOTP(u.ID, "web_chat", nil)
+	if err != nil {
+		return v, err
+	}
+
+	v.ListSessionsURL = fmt.Sprintf("%s/users/%s/session/list?token=%s", cfg.BaseURL, u.Profile.Username, listOTP)
+	v.WebChatURL = fmt.Sprintf("%s/users/%s/chat/web?token=%s", cfg.BaseURL, u.Profile.Username, chatOTP)
+	return v, nil
 }
 
-func (msg LineMessage) Name() string {
-	return "LineMessage"
+type TemplateSpec struct {
+	Template string         `json:"template"`
+	Values   map[string]any `json:"values"`
 }
 
-func (msg LineMessage) Description() string {
-	return "A LINE message object that can be either a text message or a Flex Message for rich content display."
+// FormattedCard is one card or a carousel of cards, either built by the
+// formatter or from a template.
+type FormattedCard struct {
+	AltText      string        `json:"altText"`
+	Cards        []*Card       `json:"cards,omitempty"`
+	TemplateSpec *TemplateSpec `json:"templateSpec,omitempty"`
 }
 
-func (msg LineMessage) Schema() map[string]any {
+// FormattedMessage is the answer of the formatter, which every transport
+// renders its own way.
+type FormattedMessage struct {
+	Type       string         `json:"type"`
+	Text       *TextMessage   `json:"text,omitempty"`
+	Card       *FormattedCard `json:"card,omitempty"`
+	QuickReply []string       `json:"quickReply,omitempty"`
+}
+
+func (msg FormattedMessage) Name() string {
+	return "FormattedMessage"
+}
+
+func (msg FormattedMessage) Description() string {
+	return "A chat message object that can be either a text message or cards for rich content display."
+}
+
+func (msg FormattedMessage) Schema() map[string]any {
 	return map[string]any{
 		"type":        "object",
-		"description": "A LINE message object. Only one of 'text' or 'flex' should be present.",
+		"description": "A chat message object. Only one of 'text' or 'card' should be present.",
 		"properties": map[string]any{
 			"type": map[string]any{
 				"type":        "string",
-				"description": "The type of message, either 'text' or 'flex'.",
-				"enum":        []string{"text", "flex"},
+				"description": "The type of message, either 'text' or 'card'.",
+				"enum":        []string{"text", "card"},
 			},
 			"text": map[string]any{
 				"type":        []string{"object", "null"},
@@ -613,42 +981,46 @@ func (msg LineMessage) Schema() map[string]any {
 				"required":             []string{"text"},
 				"additionalProperties": false,
 			},
-			"flex": map[string]any{
+			"card": map[string]any{
 				"type":        []string{"object", "null"},
-				"description": "Flex message object. Only use this when sending a Flex Message.",
+				"description": "Card message object. Only use this when sending cards.",
 				"properties": map[string]any{
 					"altText": map[string]any{
 						"type":        "string",
-						"description": "The altText for the Flex Message.",
+						"description": "The text shown where the cards cannot be.",
 					},
-					"flex": map[string]any{
-						"type":        []string{"string", "null"},
-						"description": "The Flex Message JSON string (leave empty if using templateSpec).",
+					"cards": map[string]any{
+						"type":        []string{"array", "null"},
+						"description": "Custom cards, several of them make a carousel (leave empty if using templateSpec).",
+						"maxItems":    10,
+						"items":       CardSchema,
 					},
 					"templateSpec": map[string]any{
 						"type":        []string{"object", "null"},
-						"description": "Optional template specification for Flex Message.",
+						"description": "Optional template specification for the cards.",
 						"properties": map[string]any{
 							"template": map[string]any{
 								"type":        "string",
 								"description": "The name of the template to use.",
 								"enum": []string{
-									"login",
-									"session_menu",
-									"weather",
-									"place",
+									TemplateLogin,
+									TemplateSessionMenu,
+									TemplateWeather,
+									TemplatePlace,
 								},
 							},
 							"values": map[string]any{
 								"type":        "object",
 								"description": "Key-value pairs required by the template.",
 								"properties": map[string]any{
-									"login":        templates.LoginValuesSchema,
-									"session_menu": templates.SessionMenuValuesSchema,
-									"weather":      templates.WeatherValuesSchema,
-									"place":        templates.PlaceValuesSchema,
+									TemplateLogin:       templates.LoginValuesSchema,
+									TemplateSessionMenu: templates.SessionMenuValuesSchema,
+									TemplateWeather:     templates.WeatherValuesSchema,
+									TemplatePlace:       templates.PlaceValuesSchema,
+								},
+								"required": []string{
+									TemplateLogin, TemplateSessionMenu, TemplateWeather, TemplatePlace,
 								},
-								"required":             []string{"login", "session_menu", "weather", "place"},
 								"additionalProperties": false,
 							},
 						},
@@ -656,7 +1028,7 @@ func (msg LineMessage) Schema() map[string]any {
 						"additionalProperties": false,
 					},
 				},
-				"required":             []string{"altText", "flex", "templateSpec"},
+				"required":             []string{"altText", "cards", "templateSpec"},
 				"additionalProperties": false,
 			},
 			"quickReply": map[string]any{
@@ -670,7 +1042,7 @@ func (msg LineMessage) Schema() map[string]any {
 				},
 			},
 		},
-		"required":             []string{"type", "text", "flex", "quickReply"},
+		"required":             []string{"type", "text", "card", "quickReply"},
 		"additionalProperties": false,
 	}
 }
@@ -3,21 +3,37 @@ package talkix
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/flarexio/talkix/auth"
+	"github.com/flarexio/talkix/cassette"
 	"github.com/flarexio/talkix/config"
 	"github.com/flarexio/talkix/llm"
+	"github.com/flarexio/talkix/llm/message"
+	"github.com/flarexio/talkix/persistence/inmem"
+	"github.com/flarexio/talkix/session"
+	"github.com/flarexio/talkix/user"
 )
 
-func TestLLMWithLineMessage(t *testing.T) {
+func TestLLMWithFormattedMessage(t *testing.T) {
 	assert := assert.New(t)
 
+	if _, ok := os.LookupEnv("OPENAI_API_KEY"); !ok {
+		t.Skip("OPENAI_API_KEY environment variable is not set")
+		return
+	}
+
 	llm, err := llm.NewLLM("openai:gpt-4.1-mini",
-		llm.WithStructuredOutput(LineMessage{}),
+		llm.WithStructuredOutput(FormattedMessage{}),
 	)
 
 	if err != nil {
@@ -34,7 +50,7 @@ func TestLLMWithLineMessage(t *testing.T) {
 
 	resp := msgs[len(msgs)-1]
 
-	var result LineMessage
+	var result FormattedMessage
 	if err := json.Unmarshal([]byte(resp.Content), &result); err != nil {
 		assert.Fail(err.Error())
 		return
@@ -47,6 +63,11 @@ func TestLLMWithLineMessage(t *testing.T) {
 func TestLLMWithTools(t *testing.T) {
 	assert := assert.New(t)
 
+	if _, ok := os.LookupEnv("OPENAI_API_KEY"); !ok {
+		t.Skip("OPENAI_API_KEY environment variable is not set")
+		return
+	}
+
 	apiKey, ok := os.LookupEnv("WEATHER_API_KEY")
 	if !ok {
 		t.Skip("WEATHER_API_KEY environment variable is not set")
@@ -84,3 +105,1030 @@ func TestLLMWithTools(t *testing.T) {
 	result := resp.Content
 	assert.NotEmpty(result, "Expected result to not be empty")
 }
+
+func TestAIServiceReplyMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newWeatherServer()
+	defer server.Close()
+
+	usage := message.Usage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120}
+
+	mainScript := llm.RegisterFakeScript("main",
+		// First conversation: query the weather tool, then answer.
+		llm.Response{
+			Message: message.AIMessage("", message.ToolCall{
+				Name: "get_weather",
+				Arguments: map[string]any{
+					"latitude":  24.1477,
+					"longitude": 120.6736,
+				},
+			}),
+			Usage: usage,
+		},
+		llm.Response{
+			Message: message.AIMessage("台中目前多雲，氣溫 30.5°C，濕度 70%。"),
+			Usage:   usage,
+		},
+		// Second conversation: a plain answer.
+		llm.Response{
+			Message: message.AIMessage("不客氣！"),
+			Usage:   usage,
+		},
+	)
+
+	lineScript := llm.RegisterFakeScript("line",
+		llm.Response{
+			Message: llm.FakeJSON(map[string]any{
+				"type": "card",
+				"text": nil,
+				"card": map[string]any{
+					"altText": "台中天氣",
+					"cards":   nil,
+					"templateSpec": map[string]any{
+						"template": "weather",
+						"values": map[string]any{
+							"login":        nil,
+							"session_menu": nil,
+							"place":        nil,
+							"weather": map[string]any{
+								"Location":    "台中",
+								"Condition":   "多雲",
+								"IconURL":     "https://openweathermap.org/img/wn/04d@2x.png",
+								"Temperature": "30.5°C",
+								"FeelsLike":   "35.2°C",
+								"Humidity":    "70%",
+								"WindSpeed":   "2.5 m/s",
+								"LastUpdated": "2025-06-15 23:06",
+								"ExtraInfo":   "",
+							},
+						},
+					},
+				},
+				"quickReply": []string{"🌤️ 明天天氣", "📍 其他城市"},
+			}).Message,
+			Usage: usage,
+		},
+		llm.Response{
+			Message: llm.FakeJSON(map[string]any{
+				"type":       "text",
+				"text":       map[string]any{"text": "不客氣！"},
+				"card":       nil,
+				"quickReply": []string{"❓ 更多資訊"},
+			}).Message,
+			Usage: usage,
+		},
+	)
+
+	llm.RegisterFakeScript("summary",
+		llm.Response{Message: message.AIMessage("詢問台中天氣"), Usage: usage},
+		llm.Response{Message: message.AIMessage("詢問台中天氣並致謝"), Usage: usage},
+	)
+
+	summaryGen, err := session.NewLLMSummaryGenerator("fake:summary")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	var cfg config.Config
+	cfg.LLM.Model = "fake:main"
+	cfg.LLM.Format.Model = "fake:line"
+
+	tools := []llm.Tool{
+		NewWeatherTool(config.WeatherAPIConfig{
+			APIKey:  "test",
+			BaseURL: server.URL,
+			Timeout: 10 * time.Second,
+		}),
+	}
+
+	users, err := inmem.NewUserRepository()
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	sessions := inmem.NewSessionRepository()
+
+	summarizer := session.NewSyncSummarizer(summaryGen, sessions, SummaryUsageHook(users))
+
+	svc, err := NewAIService(cfg, tools, auth.NewOTPStore(), users, sessions, inmem.NewThreadRepository(), summarizer)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	ctx := context.WithValue(context.Background(), UserKey, &user.User{
+		ID:       "U1234",
+		Verified: true,
+		Profile:  &user.UserProfile{Username: "alice"},
+	})
+
+	reply, err := svc.ReplyMessage(ctx, NewTextMessage("台中天氣如何？"))
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	cardMsg, ok := reply.(*CardMessage)
+	if !ok {
+		assert.Fail("expected CardMessage type")
+		return
+	}
+
+	assert.Equal("台中天氣", cardMsg.AltText)
+	if assert.Len(cardMsg.Cards, 1) {
+		card := cardMsg.Cards[0]
+		assert.Equal("台中", card.Title)
+		assert.Contains(card.Rows, Row{Key: "溫度", Value: "30.5°C"})
+		assert.Equal("https://openweathermap.org/img/wn/04d@2x.png", card.Image.URL)
+	}
+	assert.Equal([]string{"🌤️ 明天天氣", "📍 其他城市"}, cardMsg.QuickReply())
+
+	// The tool result was fed back to the main LLM.
+	requests := mainScript.Requests()
+	if !assert.Len(requests, 2) {
+		return
+	}
+
+	toolMsg := requests[1].Messages[len(requests[1].Messages)-1]
+	assert.Equal(message.RoleTool, toolMsg.Role)
+	assert.Contains(toolMsg.Content, `"temperature":30.5`)
+	assert.Contains(requests[0].Messages[0].Content, `"username":"alice"`)
+
+	// The formatter saw the final answer of the main LLM.
+	requests = lineScript.Requests()
+	if !assert.Len(requests, 1) {
+		return
+	}
+
+	assert.Contains(requests[0].Messages[0].Content, "台中目前多雲")
+
+	reply, err = svc.ReplyMessage(ctx, NewTextMessage("謝謝"))
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("text", reply.Type())
+	assert.Equal("不客氣！", reply.Content())
+
+	// The second conversation carries the history of the first one.
+	requests = mainScript.Requests()
+	if !assert.Len(requests, 3) {
+		return
+	}
+
+	history := requests[2].Messages
+	assert.Equal("台中天氣如何？", history[1].Content)
+	assert.Equal("謝謝", history[len(history)-1].Content)
+
+	u, err := users.Find("U1234")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	s, err := sessions.Find(u.SelectedSessionID)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Len(s.Conversations, 2)
+	assert.Equal("詢問台中天氣並致謝", s.Summary.Text)
+	assert.Equal(session.SummaryReady, s.Summary.Status)
+
+	// 3 main + 2 line completions, plus 2 for the summaries
+	assert.Equal(int64(5*120), s.Usage.TotalTokens)
+	assert.Equal(int64(7*120), s.TotalUsage().TotalTokens)
+	assert.Equal(s.TotalUsage(), u.Usage)
+	assert.Contains(u.ToolUsage, "get_weather")
+
+	assert.Zero(mainScript.Remaining())
+	assert.Zero(lineScript.Remaining())
+}
+
+// racingSessions saves another conversation right before the first save, as
+// if two messages of the same user overlapped.
+type racingSessions struct {
+	session.Repository
+	race func()
+}
+
+func (repo *racingSessions) Save(s *session.Session) error {
+	if race := repo.race; race != nil {
+		repo.race = nil
+		race()
+	}
+
+	return repo.Repository.Save(s)
+}
+
+type racingUsers struct {
+	user.Repository
+	race func()
+}
+
+func (repo *racingUsers) Save(u *user.User) error {
+	if race := repo.race; race != nil {
+		repo.race = nil
+		race()
+	}
+
+	return repo.Repository.Save(u)
+}
+
+func TestAIServiceReplyMergesConcurrentSaves(t *testing.T) {
+	assert := assert.New(t)
+
+	usage := message.Usage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120}
+
+	mainScript := llm.RegisterFakeScript("main-race",
+		llm.Response{Message: message.AIMessage("不客氣！"), Usage: usage},
+	)
+
+	lineScript := llm.RegisterFakeScript("line-race",
+		llm.Response{
+			Message: llm.FakeJSON(map[string]any{
+				"type": "text",
+				"text": map[string]any{"text": "不客氣！"},
+				"card": nil,
+			}).Message,
+			Usage: usage,
+		},
+	)
+
+	var cfg config.Config
+	cfg.LLM.Model = "fake:main-race"
+	cfg.LLM.Format.Model = "fake:line-race"
+
+	users, err := inmem.NewUserRepository()
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	sessions := inmem.NewSessionRepository()
+
+	s := session.NewSession("U1234")
+	if err := sessions.Save(s); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	u := &user.User{ID: "U1234"}
+	u.AddSessionID(s.ID)
+	if err := users.Save(u); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	// The reply of another message is saved while this one is generated.
+	other := session.NewConversation()
+	other.SetIO("早安", "早安！")
+	other.Usage = usage
+
+	racingSessions := &racingSessions{Repository: sessions, race: func() {
+		s, err := sessions.Find(s.ID)
+		if assert.NoError(err) {
+			s.AddConversation(other)
+			assert.NoError(sessions.Save(s))
+		}
+	}}
+
+	racingUsers := &racingUsers{Repository: users, race: func() {
+		u, err := users.Find(u.ID)
+		if assert.NoError(err) {
+			u.AddUsage(usage, nil)
+			assert.NoError(users.Save(u))
+		}
+	}}
+
+	svc, err := NewAIService(cfg, nil, auth.NewOTPStore(), racingUsers, racingSessions, inmem.NewThreadRepository(), nil)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	ctx := context.WithValue(context.Background(), UserKey, &user.User{
+		ID:      "U1234",
+		Profile: &user.UserProfile{Username: "alice"},
+	})
+
+	reply, err := svc.ReplyMessage(ctx, NewTextMessage("謝謝"))
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("不客氣！", reply.Content())
+
+	found, err := sessions.Find(s.ID)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	// Neither conversation was lost.
+	if assert.Len(found.Conversations, 2) {
+		assert.Equal("早安", found.Conversations[0].Input)
+		assert.Equal("謝謝", found.Conversations[1].Input)
+	}
+
+	// The other conversation, plus the main and the line completions
+	assert.Equal(int64(3*120), found.Usage.TotalTokens)
+
+	foundUser, err := users.Find(u.ID)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal(int64(3*120), foundUser.Usage.TotalTokens)
+
+	assert.Zero(mainScript.Remaining())
+	assert.Zero(lineScript.Remaining())
+}
+
+func TestAIServiceReplyWithAttachments(t *testing.T) {
+	assert := assert.New(t)
+
+	mainScript := llm.RegisterFakeScript("main-media",
+		llm.FakeText("A cat on a sofa, and a shopping list."),
+	)
+
+	llm.RegisterFakeScript("line-media",
+		llm.FakeJSON(map[string]any{
+			"type": "text",
+			"text": map[string]any{"text": "A cat on a sofa, and a shopping list."},
+			"card": nil,
+		}),
+	)
+
+	var cfg config.Config
+	cfg.LLM.Model = "fake:main-media"
+	cfg.LLM.Format.Model = "fake:line-media"
+
+	users, err := inmem.NewUserRepository()
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	sessions := inmem.NewSessionRepository()
+
+	svc, err := NewAIService(cfg, nil, auth.NewOTPStore(), users, sessions, inmem.NewThreadRepository(), nil)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	ctx := context.WithValue(context.Background(), UserKey, &user.User{ID: "U1234"})
+
+	image := message.Attachment{
+		Type:     message.AttachmentImage,
+		MIMEType: "image/png",
+		Data:     []byte("png"),
+	}
+
+	file := message.Attachment{
+		Type:     message.AttachmentFile,
+		MIMEType: "text/plain",
+		Name:     "list.txt",
+		Data:     []byte("milk\neggs"),
+	}
+
+	if _, err := svc.ReplyMessage(ctx, NewMediaMessage("", image, file)); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	requests := mainScript.Requests()
+	if !assert.Len(requests, 1) {
+		return
+	}
+
+	// The image is sent as it is, while the file is read into the text.
+	msgs := requests[0].Messages
+	human := msgs[len(msgs)-1]
+	assert.Equal(message.RoleHuman, human.Role)
+	assert.Contains(human.Content, "Please summarize the attached file.")
+	assert.Contains(human.Content, "<file name=\"list.txt\">\nmilk\neggs\n</file>")
+	assert.Equal([]message.Attachment{image}, human.Attachments)
+
+	u, err := users.Find("U1234")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	s, err := sessions.Find(u.SelectedSessionID)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	if !assert.Len(s.Conversations, 1) {
+		return
+	}
+
+	// The image data is not stored with the history.
+	conv := s.Conversations[0]
+	assert.Equal("[image] [file: list.txt]", conv.Input)
+
+	for _, msg := range conv.Messages {
+		for _, a := range msg.Attachments {
+			assert.Empty(a.Data)
+		}
+	}
+}
+
+func TestAIServiceReplyWithUnreadableFile(t *testing.T) {
+	assert := assert.New(t)
+
+	mainScript := llm.RegisterFakeScript("main-pdf")
+	lineScript := llm.RegisterFakeScript("line-pdf")
+
+	var cfg config.Config
+	cfg.LLM.Model = "fake:main-pdf"
+	cfg.LLM.Format.Model = "fake:line-pdf"
+
+	users, err := inmem.NewUserRepository()
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	svc, err := NewAIService(cfg, nil, auth.NewOTPStore(), users, inmem.NewSessionRepository(), inmem.NewThreadRepository(), nil)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	ctx := context.WithValue(context.Background(), UserKey, &user.User{ID: "U1234"})
+
+	pdf := message.Attachment{
+		Type:     message.AttachmentFile,
+		MIMEType: "application/pdf",
+		Name:     "report.pdf",
+		Data:     []byte("%PDF-1.7\x00\xff"),
+	}
+
+	reply, err := svc.ReplyMessage(ctx, NewMediaMessage("", pdf))
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	// The file is turned down before the LLMs are asked about it.
+	text, ok := reply.(*TextMessage)
+	if assert.True(ok) {
+		assert.Contains(text.Text, `cannot open "report.pdf"`)
+	}
+
+	assert.Empty(mainScript.Requests())
+	assert.Empty(lineScript.Requests())
+}
+
+func TestAIServiceReplyInGroup(t *testing.T) {
+	assert := assert.New(t)
+
+	mainScript := llm.RegisterFakeScript("main-group",
+		llm.FakeText("Noon works for everyone."),
+	)
+
+	llm.RegisterFakeScript("line-group",
+		llm.FakeJSON(map[string]any{
+			"type": "text",
+			"text": map[string]any{"text": "Noon works for everyone."},
+			"card": nil,
+		}),
+	)
+
+	var cfg config.Config
+	cfg.LLM.Model = "fake:main-group"
+	cfg.LLM.Format.Model = "fake:line-group"
+
+	users, err := inmem.NewUserRepository()
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	sessions := inmem.NewSessionRepository()
+
+	svc, err := NewAIService(cfg, nil, auth.NewOTPStore(), users, sessions, inmem.NewThreadRepository(), nil)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	ctx := context.WithValue(context.Background(), UserKey, &user.User{ID: "C1234"})
+	ctx = context.WithValue(ctx, ChatKey, &Chat{
+		ID:        "C1234",
+		Type:      ChatGroup,
+		Speaker:   "Alice",
+		Mentioned: true,
+	})
+
+	if _, err := svc.ReplyMessage(ctx, NewTextMessage("When shall we meet?")); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	requests := mainScript.Requests()
+	if !assert.Len(requests, 1) {
+		return
+	}
+
+	msgs := requests[0].Messages
+	human := msgs[len(msgs)-1]
+	assert.Equal("Alice", human.Name)
+	assert.Equal("Alice: When shall we meet?", human.SpokenContent())
+
+	// The session belongs to the group, not to the speaker.
+	u, err := users.Find("C1234")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	s, err := sessions.Find(u.SelectedSessionID)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	if !assert.Len(s.Conversations, 1) {
+		return
+	}
+
+	assert.Equal("Alice: When shall we meet?", s.Conversations[0].Input)
+}
+
+func TestAIServiceReplyInThread(t *testing.T) {
+	assert := assert.New(t)
+
+	llm.RegisterFakeScript("main-thread",
+		llm.FakeText("Noon works for me."),
+		llm.FakeText("Then noon it is."),
+	)
+
+	formatted := func(text string) llm.Response {
+		return llm.FakeJSON(map[string]any{
+			"type": "text",
+			"text": map[string]any{"text": text},
+			"card": nil,
+		})
+	}
+
+	llm.RegisterFakeScript("line-thread",
+		formatted("Noon works for me."),
+		formatted("Then noon it is."),
+	)
+
+	var cfg config.Config
+	cfg.LLM.Model = "fake:main-thread"
+	cfg.LLM.Format.Model = "fake:line-thread"
+
+	users, err := inmem.NewUserRepository()
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	sessions := inmem.NewSessionRepository()
+
+	svc, err := NewAIService(cfg, nil, auth.NewOTPStore(), users, sessions, inmem.NewThreadRepository(), nil)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	thread := "slack:T1:C1:100.1"
+
+	for _, speaker := range []string{"U1", "U2"} {
+		ctx := context.WithValue(context.Background(), UserKey, &user.User{ID: "slack:T1:" + speaker})
+		ctx = context.WithValue(ctx, ThreadKey, thread)
+		ctx = context.WithValue(ctx, ChatKey, &Chat{
+			ID:        "slack:T1:C1",
+			Type:      ChatChannel,
+			Speaker:   speaker,
+			Mentioned: true,
+		})
+
+		if _, err := svc.ReplyMessage(ctx, NewTextMessage("Lunch at noon?")); err != nil {
+			assert.Fail(err.Error())
+			return
+		}
+	}
+
+	// The thread keeps one session, listed with the user who started it
+	// but not selected.
+	first, err := users.Find("slack:T1:U1")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	if !assert.Len(first.SessionIDs, 1) {
+		return
+	}
+
+	assert.Empty(first.SelectedSessionID)
+
+	s, err := sessions.Find(first.SessionIDs[0])
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	if !assert.Len(s.Conversations, 2) {
+		return
+	}
+
+	assert.Equal("U1: Lunch at noon?", s.Conversations[0].Input)
+	assert.Equal("U2: Lunch at noon?", s.Conversations[1].Input)
+
+	// Each speaker is accounted for their own usage.
+	second, err := users.Find("slack:T1:U2")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Empty(second.SessionIDs)
+	assert.Equal(first.Usage, second.Usage)
+}
+
+func TestAIServiceReplySessionMenu(t *testing.T) {
+	assert := assert.New(t)
+
+	llm.RegisterFakeScript("main-menu",
+		llm.FakeText("Here are your conversations."),
+		llm.FakeText("Here are your conversations."),
+		llm.FakeText("Here are your conversations."),
+	)
+
+	menu := llm.FakeJSON(map[string]any{
+		"type": "card",
+		"text": nil,
+		"card": map[string]any{
+			"altText": "Sessions",
+			"cards":   nil,
+			"templateSpec": map[string]any{
+				"template": "session_menu",
+				"values": map[string]any{
+					"login": nil, "session_menu": nil, "weather": nil, "place": nil,
+				},
+			},
+		},
+		"quickReply": []string{"➕ 新增會話"},
+	})
+
+	llm.RegisterFakeScript("line-menu", menu, menu, menu)
+
+	var cfg config.Config
+	cfg.BaseURL = "https://talkix.example.com"
+	cfg.LLM.Model = "fake:main-menu"
+	cfg.LLM.Format.Model = "fake:line-menu"
+
+	users, err := inmem.NewUserRepository()
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	svc, err := NewAIService(cfg, nil, auth.NewOTPStore(), users, inmem.NewSessionRepository(), inmem.NewThreadRepository(), nil)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	// A Slack user has no bound account to open the pages with, so the
+	// answer comes as text.
+	ctx := context.WithValue(context.Background(), UserKey, &user.User{ID: "slack:T1:U1"})
+
+	reply, err := svc.ReplyMessage(ctx, NewTextMessage("Show my sessions"))
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	text, ok := reply.(*TextMessage)
+	if assert.True(ok) {
+		assert.Equal("Here are your conversations.", text.Text)
+		assert.Equal([]string{"➕ 新增會話"}, text.QuickReply())
+	}
+
+	// A bound user gets the menu with links to their pages.
+	ctx = context.WithValue(context.Background(), UserKey, &user.User{
+		ID:       "U1234",
+		Profile:  &user.UserProfile{ID: "U1234", Username: "alice"},
+		Verified: true,
+	})
+
+	reply, err = svc.ReplyMessage(ctx, NewTextMessage("Show my sessions"))
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	card, ok := reply.(*CardMessage)
+	if !assert.True(ok) || !assert.Len(card.Cards, 1) {
+		return
+	}
+
+	buttons := card.Cards[0].Buttons
+	if assert.Len(buttons, 2) {
+		assert.True(strings.HasPrefix(buttons[0].URL, "https://talkix.example.com/users/alice/session/list?token="))
+		assert.True(strings.HasPrefix(buttons[1].URL, "https://talkix.example.com/users/alice/chat/web?token="))
+	}
+
+	// In a group, the user is the chat while the profile is the speaker's,
+	// so there is no menu whose links would open.
+	ctx = context.WithValue(context.Background(), UserKey, &user.User{
+		ID:      "C1234",
+		Profile: &user.UserProfile{ID: "U1234", Username: "alice"},
+	})
+	ctx = context.WithValue(ctx, ChatKey, &Chat{
+		ID:        "C1234",
+		Type:      ChatGroup,
+		Speaker:   "Alice",
+		Mentioned: true,
+	})
+
+	reply, err = svc.ReplyMessage(ctx, NewTextMessage("Show my sessions"))
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	text, ok = reply.(*TextMessage)
+	if assert.True(ok) {
+		assert.Equal("Here are your conversations.", text.Text)
+	}
+}
+
+// newOpenAIServer stands in for the OpenAI chat completions API and answers
+// the main, LINE formatting and summary LLMs of the AI service.
+func newOpenAIServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Role    string `json:"role"`
+				Content any    `json:"content"`
+			} `json:"messages"`
+			ResponseFormat any `json:"response_format"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var msg map[string]any
+		switch {
+		case req.ResponseFormat != nil:
+			msg = map[string]any{
+				"role":    "assistant",
+				"content": `{"type":"text","text":{"text":"台中目前多雲，氣溫 30.5°C。"},"card":null,"quickReply":["🌤️ 明天天氣"]}`,
+			}
+
+		case strings.Contains(fmt.Sprint(req.Messages[0].Content), "摘要"):
+			msg = map[string]any{
+				"role":    "assistant",
+				"content": "詢問台中天氣",
+			}
+
+		case req.Messages[len(req.Messages)-1].Role == "tool":
+			msg = map[string]any{
+				"role":    "assistant",
+				"content": "台中目前多雲，氣溫 30.5°C。",
+			}
+
+		default:
+			msg = map[string]any{
+				"role":    "assistant",
+				"content": "",
+				"tool_calls": []map[string]any{
+					{
+						"id":   "call_1",
+						"type": "function",
+						"function": map[string]any{
+							"name":      "get_weather",
+							"arguments": `{"latitude":24.1477,"longitude":120.6736}`,
+						},
+					},
+				},
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":      "chatcmpl-test",
+			"object":  "chat.completion",
+			"created": time.Now().Unix(),
+			"model":   "gpt-4.1-mini",
+			"choices": []map[string]any{
+				{"index": 0, "message": msg, "finish_reason": "stop"},
+			},
+			"usage": map[string]any{
+				"prompt_tokens":     100,
+				"completion_tokens": 20,
+				"total_tokens":      120,
+			},
+		})
+	}))
+}
+
+func TestAIServiceWithCassette(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	openAIServer := newOpenAIServer()
+	weatherServer := newWeatherServer()
+
+	var cfg config.Config
+	cfg.LLM.Model = "openai:gpt-4.1-mini"
+	cfg.LLM.Format.Model = "openai:gpt-4.1-mini"
+	cfg.LLM.Providers = config.ProvidersConfig{
+		"openai": {BaseURL: openAIServer.URL, APIKey: "test"},
+	}
+
+	weatherCfg := config.WeatherAPIConfig{
+		APIKey:  "test",
+		BaseURL: weatherServer.URL,
+		Timeout: 10 * time.Second,
+	}
+
+	replyWith := func(cas *cassette.Cassette) (Message, *session.Session, error) {
+		opts := []llm.Option{
+			llm.WithProviders(cfg.LLM.Providers),
+			llm.WithHTTPClient(cas.Client()),
+		}
+
+		summaryGen, err := session.NewLLMSummaryGenerator("openai:gpt-4.1-mini", opts...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		tools := []llm.Tool{
+			NewWeatherToolWithTransport(weatherCfg, cas.Transport(nil)),
+		}
+
+		users, err := inmem.NewUserRepository()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		sessions := inmem.NewSessionRepository()
+
+		summarizer := session.NewSyncSummarizer(summaryGen, sessions)
+
+		svc, err := NewAIService(cfg, tools, auth.NewOTPStore(), users, sessions, inmem.NewThreadRepository(), summarizer,
+			llm.WithHTTPClient(cas.Client()),
+		)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ctx := context.WithValue(context.Background(), UserKey, &user.User{ID: "U1234"})
+
+		reply, err := svc.ReplyMessage(ctx, NewTextMessage("台中天氣如何？"))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		u, err := users.Find("U1234")
+		if err != nil {
+			return nil, nil, err
+		}
+
+		s, err := sessions.Find(u.SelectedSessionID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return reply, s, nil
+	}
+
+	recorder, err := cassette.New(path, cassette.ModeRecord)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	recorded, recordedSession, err := replyWith(recorder)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("台中目前多雲，氣溫 30.5°C。", recorded.Content())
+
+	// Replay without any server behind the cassette.
+	openAIServer.Close()
+	weatherServer.Close()
+
+	player, err := cassette.New(path, cassette.ModeReplay)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	replayed, replayedSession, err := replyWith(player)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal(recorded.Content(), replayed.Content())
+	assert.Equal(recorded.QuickReply(), replayed.QuickReply())
+	assert.Equal(recordedSession.Summary.Text, replayedSession.Summary.Text)
+	assert.Equal(recordedSession.TotalUsage(), replayedSession.TotalUsage())
+	// 2 main + 1 line + 1 summary completions
+	assert.Equal(int64(4*120), replayedSession.TotalUsage().TotalTokens)
+	assert.Zero(player.Remaining())
+}
+
+func TestMainSystemPromptHistory(t *testing.T) {
+	assert := assert.New(t)
+
+	c := session.NewConversation()
+	c.AddMessage(
+		message.SystemMessage("You are a helpful assistant."),
+		message.HumanMessage("台中天氣如何？"),
+		message.AIMessage("", message.ToolCall{ID: "call_1", Name: "get_weather"}),
+		message.ToolMessage(`{"temperature":30.5}`, "call_1"),
+		message.AIMessage("台中目前 30.5°C。"),
+	)
+
+	s := session.NewSession("U1234")
+	s.Conversations = append(s.Conversations, c)
+
+	ctx := context.WithValue(context.Background(), SessionKey, s)
+
+	for _, includeTools := range []bool{false, true} {
+		prompt, err := MainSystemPrompt("", session.HistoryOptions{IncludeTools: includeTools})
+		if err != nil {
+			assert.Fail(err.Error())
+			return
+		}
+
+		msgs, err := prompt(ctx)
+		if err != nil {
+			assert.Fail(err.Error())
+			return
+		}
+
+		if !includeTools {
+			assert.Len(msgs, 3)
+			continue
+		}
+
+		if !assert.Len(msgs, 5) {
+			return
+		}
+
+		assert.Equal("call_1", msgs[2].ToolCalls[0].ID)
+		assert.Equal("call_1", msgs[3].ToolCallID)
+		assert.Equal("台中目前 30.5°C。", msgs[4].Content)
+	}
+}
+
+func TestMainSystemPromptBinding(t *testing.T) {
+	assert := assert.New(t)
+
+	prompt, err := MainSystemPrompt("", session.HistoryOptions{})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	ctx := context.WithValue(context.Background(), SessionKey, session.NewSession("U1234"))
+	ctx = context.WithValue(ctx, UserKey, &user.User{ID: "U1234"})
+
+	// A channel without a binding flow does not ask to bind the account.
+	msgs, err := prompt(ctx)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.NotContains(msgs[0].Content, "prompt the user to bind their account")
+
+	ctx = context.WithValue(ctx, LoginKey, &Login{Label: "Login with LINE", URL: "https://example.com/login"})
+
+	msgs, err = prompt(ctx)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Contains(msgs[0].Content, "prompt the user to bind their account")
+}
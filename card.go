@@ -0,0 +1,100 @@
+package talkix
+
+// Card is rich content that does not belong to any channel: a title with an
+// image, some text, rows of keys and values, and buttons. Each transport
+// renders the cards its own way.
+type Card struct {
+	Title    string   `json:"title,omitempty"`
+	Subtitle string   `json:"subtitle,omitempty"`
+	Image    *Image   `json:"image,omitempty"`
+	Text     string   `json:"text,omitempty"`
+	Rows     []Row    `json:"rows,omitempty"`
+	Buttons  []Button `json:"buttons,omitempty"`
+	Footer   string   `json:"footer,omitempty"`
+}
+
+type Image struct {
+	URL     string `json:"url"`
+	AltText string `json:"altText,omitempty"`
+}
+
+// Row is a key with its value, shown side by side.
+type Row struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type ButtonStyle string
+
+const (
+	ButtonPrimary   ButtonStyle = "primary"
+	ButtonSecondary ButtonStyle = "secondary"
+	ButtonLink      ButtonStyle = "link"
+)
+
+// Button opens the page at URL, or else sends Text back as a message from
+// the user.
+type Button struct {
+	Label string      `json:"label"`
+	URL   string      `json:"url,omitempty"`
+	Text  string      `json:"text,omitempty"`
+	Style ButtonStyle `json:"style,omitempty"`
+}
+
+// CardSchema describes a card to the formatter, for the replies that none of
+// the templates fit.
+var CardSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"title":    map[string]any{"type": []string{"string", "null"}},
+		"subtitle": map[string]any{"type": []string{"string", "null"}},
+		"image": map[string]any{
+			"type": []string{"object", "null"},
+			"properties": map[string]any{
+				"url":     map[string]any{"type": "string"},
+				"altText": map[string]any{"type": "string"},
+			},
+			"required":             []string{"url", "altText"},
+			"additionalProperties": false,
+		},
+		"text": map[string]any{"type": []string{"string", "null"}},
+		"rows": map[string]any{
+			"type": []string{"array", "null"},
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"key":   map[string]any{"type": "string"},
+					"value": map[string]any{"type": "string"},
+				},
+				"required":             []string{"key", "value"},
+				"additionalProperties": false,
+			},
+		},
+		"buttons": map[string]any{
+			"type": []string{"array", "null"},
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"label": map[string]any{"type": "string"},
+					"url": map[string]any{
+						"type":        []string{"string", "null"},
+						"description": "The page the button opens.",
+					},
+					"text": map[string]any{
+						"type":        []string{"string", "null"},
+						"description": "The message the button sends, when it opens no page.",
+					},
+					"style": map[string]any{
+						"type": []string{"string", "null"},
+						"enum": []any{"primary", "secondary", "link", nil},
+					},
+				},
+				"required":             []string{"label", "url", "text", "style"},
+				"additionalProperties": false,
+			},
+		},
+		"footer": map[string]any{"type": []string{"string", "null"}},
+	},
+	"required":             []string{"title", "subtitle", "image", "text", "rows", "buttons", "footer"},
+	"additionalProperties": false,
+}
@@ -0,0 +1,142 @@
+package talkix
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/flarexio/talkix/templates"
+)
+
+// Template names the formatter can choose from. Each one builds its cards
+// from the values given by the formatter, or by the service.
+const (
+	TemplateLogin       = "login"
+	TemplateSessionMenu = "session_menu"
+	TemplateWeather     = "weather"
+	TemplatePlace       = "place"
+)
+
+func LoginCard(login *Login, v templates.LoginValues) *Card {
+	return &Card{
+		Title: v.Title,
+		Text:  v.Description,
+		Buttons: []Button{
+			{Label: login.Label, URL: login.URL, Style: ButtonPrimary},
+		},
+	}
+}
+
+func SessionMenuCard(v templates.SessionMenuValues) *Card {
+	return &Card{
+		Title:    "🗂️ 會話管理",
+		Subtitle: "管理您的所有對話會話",
+		Buttons: []Button{
+			{Label: "📋 查看所有會話", URL: v.ListSessionsURL, Style: ButtonPrimary},
+			{Label: "💬 在網頁上繼續聊天", URL: v.WebChatURL, Style: ButtonSecondary},
+		},
+		Footer: "⚠️ 僅支援一次性操作",
+	}
+}
+
+func SecureMenuCard(v templates.SecureMenuValues) *Card {
+	return &Card{
+		Title:    "🔐 安全操作選單",
+		Subtitle: "請選擇您要執行的操作",
+		Buttons: []Button{
+			{Label: "👤 查看個人資料", URL: v.ViewProfileURL, Style: ButtonSecondary},
+			{Label: "⚙️ 編輯設定", URL: v.EditSettingsURL, Style: ButtonSecondary},
+			{Label: "🗑️ 刪除資料", URL: v.DeleteDataURL, Style: ButtonSecondary},
+		},
+		Footer: "⚠️ 連結將在 3 分鐘後失效",
+	}
+}
+
+func WeatherCard(v templates.WeatherValues) *Card {
+	card := &Card{
+		Title:    v.Location,
+		Subtitle: v.Condition,
+		Text:     v.ExtraInfo,
+		Rows: []Row{
+			{Key: "溫度", Value: v.Temperature},
+			{Key: "體感", Value: v.FeelsLike},
+			{Key: "濕度", Value: v.Humidity},
+			{Key: "風速", Value: v.WindSpeed},
+		},
+	}
+
+	if v.IconURL != "" {
+		card.Image = &Image{URL: v.IconURL, AltText: v.Condition}
+	}
+
+	if v.LastUpdated != "" {
+		card.Footer = "Last updated " + v.LastUpdated
+	}
+
+	return card
+}
+
+// PlaceCards builds a card for each place, with a link to it on Google Maps.
+func PlaceCards(places []templates.PlaceValues) []*Card {
+	cards := make([]*Card, len(places))
+	for i, p := range places {
+		card := &Card{
+			Title:    p.Name,
+			Subtitle: fmt.Sprintf("%s %.1f", stars(p.Rating), p.Rating),
+			Text:     p.Address,
+		}
+
+		if u := placeURL(p); u != "" {
+			card.Buttons = []Button{
+				{Label: "在地圖上查看", URL: u, Style: ButtonLink},
+			}
+		}
+
+		cards[i] = card
+	}
+
+	return cards
+}
+
+// placeURL links to the place by its ID, or else searches its name and
+// address. A place with neither has no link.
+func placeURL(p templates.PlaceValues) string {
+	if p.PlaceID != "" {
+		return "https://www.google.com/maps/place/?q=place_id:" + url.QueryEscape(p.PlaceID)
+	}
+
+	query := strings.TrimSpace(p.Name + " " + p.Address)
+	if query == "" {
+		return ""
+	}
+
+	return "https://www.google.com/maps/search/?api=1&query=" + url.QueryEscape(query)
+}
+
+// stars shows a rating out of five, rounded to whole stars.
+func stars(rating float64) string {
+	count := int(rating + 0.5)
+
+	s := ""
+	for i := 0; i < 5; i++ {
+		if i < count {
+			s += "★"
+		} else {
+			s += "☆"
+		}
+	}
+
+	return s
+}
+
+// decodeValues reads the values the formatter answered into the values type
+// of a template.
+func decodeValues(values any, v any) error {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
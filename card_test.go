@@ -0,0 +1,35 @@
+package talkix
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix/templates"
+)
+
+func TestPlaceCards(t *testing.T) {
+	assert := assert.New(t)
+
+	cards := PlaceCards([]templates.PlaceValues{
+		{Name: "Din Tai Fung", PlaceID: "ChIJ123", Rating: 4.5, Address: "Taipei"},
+		{Name: "Din Tai Fung", Rating: 4.5, Address: "Taipei"},
+		{Rating: 4.5},
+	})
+
+	if !assert.Len(cards, 3) {
+		return
+	}
+
+	if assert.Len(cards[0].Buttons, 1) {
+		assert.Equal("https://www.google.com/maps/place/?q=place_id:ChIJ123", cards[0].Buttons[0].URL)
+	}
+
+	// Without an ID, the place is searched by its name and address.
+	if assert.Len(cards[1].Buttons, 1) {
+		assert.Equal("https://www.google.com/maps/search/?api=1&query=Din+Tai+Fung+Taipei", cards[1].Buttons[0].URL)
+	}
+
+	// With nothing to search for, there is no link.
+	assert.Empty(cards[2].Buttons)
+}
@@ -0,0 +1,158 @@
+package cassette
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type Mode string
+
+const (
+	// ModeRecord passes the traffic through and appends every interaction
+	// to the cassette file.
+	ModeRecord Mode = "record"
+
+	// ModeReplay serves the interactions of the cassette file without
+	// touching the network or the tools.
+	ModeReplay Mode = "replay"
+)
+
+var ErrInteractionNotFound = errors.New("interaction not found in cassette")
+
+// Cassette holds the HTTP exchanges and tool results of a recording. Each
+// interaction is replayed at most once, in the order it was recorded.
+type Cassette struct {
+	path         string
+	mode         Mode
+	interactions []*Interaction
+	used         []bool
+	sync.Mutex
+}
+
+type Interaction struct {
+	HTTP *HTTPInteraction `json:"http,omitempty"`
+	Tool *ToolInteraction `json:"tool,omitempty"`
+}
+
+type HTTPInteraction struct {
+	Method       string              `json:"method"`
+	URL          string              `json:"url"`
+	RequestBody  string              `json:"request_body,omitempty"`
+	StatusCode   int                 `json:"status_code"`
+	Header       map[string][]string `json:"header,omitempty"`
+	ResponseBody string              `json:"response_body"`
+}
+
+type ToolInteraction struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+	Result    string         `json:"result,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+type file struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// New opens a cassette. In replay mode the file must exist; in record mode
+// it is overwritten as interactions are recorded.
+func New(path string, mode Mode) (*Cassette, error) {
+	c := &Cassette{
+		path:         path,
+		mode:         mode,
+		interactions: make([]*Interaction, 0),
+	}
+
+	switch mode {
+	case ModeRecord:
+		return c, nil
+
+	case ModeReplay:
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var f file
+		if err := json.Unmarshal(bs, &f); err != nil {
+			return nil, err
+		}
+
+		c.interactions = f.Interactions
+		c.used = make([]bool, len(f.Interactions))
+		return c, nil
+
+	default:
+		return nil, errors.New("invalid cassette mode: " + string(mode))
+	}
+}
+
+func (c *Cassette) Mode() Mode {
+	return c.mode
+}
+
+func (c *Cassette) Path() string {
+	return c.path
+}
+
+// Remaining returns the number of interactions that have not been replayed.
+func (c *Cassette) Remaining() int {
+	c.Lock()
+	defer c.Unlock()
+
+	count := 0
+	for _, used := range c.used {
+		if !used {
+			count++
+		}
+	}
+
+	return count
+}
+
+// record appends the interaction and rewrites the file, so that a recording
+// survives a crash of a long-running server.
+func (c *Cassette) record(i *Interaction) error {
+	c.Lock()
+	defer c.Unlock()
+
+	c.interactions = append(c.interactions, i)
+	c.used = append(c.used, true)
+
+	bs, err := json.MarshalIndent(&file{c.interactions}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(c.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	return os.WriteFile(c.path, bs, 0o644)
+}
+
+// match returns the first unused interaction accepted by exact. When none is
+// found, the first unused interaction accepted by loose is taken instead, so
+// that small changes of a request do not break a replay.
+func (c *Cassette) match(exact, loose func(*Interaction) bool) (*Interaction, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	for _, fn := range []func(*Interaction) bool{exact, loose} {
+		for idx, i := range c.interactions {
+			if c.used[idx] || !fn(i) {
+				continue
+			}
+
+			c.used[idx] = true
+			return i, nil
+		}
+	}
+
+	return nil, ErrInteractionNotFound
+}
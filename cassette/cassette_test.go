@@ -0,0 +1,198 @@
+package cassette
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type counterTool struct {
+	calls int
+}
+
+func (t *counterTool) Name() string {
+	return "counter"
+}
+
+func (t *counterTool) Description() string {
+	return "Count the calls"
+}
+
+func (t *counterTool) Parameters() map[string]any {
+	return map[string]any{"type": "object"}
+}
+
+func (t *counterTool) Call(ctx context.Context, params map[string]any) (string, error) {
+	t.calls++
+
+	if fail, _ := params["fail"].(bool); fail {
+		return "", errors.New("counter failed")
+	}
+
+	text, _ := params["text"].(string)
+	return strings.Repeat(text, t.calls), nil
+}
+
+func TestHTTPRecordAndReplay(t *testing.T) {
+	assert := assert.New(t)
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+
+		body, _ := io.ReadAll(r.Body)
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(r.URL.Query().Get("q") + ":" + string(body)))
+	}))
+
+	path := filepath.Join(t.TempDir(), "http.json")
+
+	recorder, err := New(path, ModeRecord)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	client := recorder.Client()
+
+	do := func(client *http.Client, q string, body string) (string, error) {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/echo?appid=secret&q="+q, strings.NewReader(body))
+		if err != nil {
+			return "", err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+
+		bs, err := io.ReadAll(resp.Body)
+		return string(bs), err
+	}
+
+	for _, body := range []string{"first", "second"} {
+		result, err := do(client, "a", body)
+		if err != nil {
+			assert.Fail(err.Error())
+			return
+		}
+
+		assert.Equal("a:"+body, result)
+	}
+
+	server.Close()
+
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.NotContains(string(bs), "secret")
+	assert.Contains(string(bs), "REDACTED")
+
+	player, err := New(path, ModeReplay)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	client = player.Client()
+
+	// The exact request body is preferred over the recording order.
+	result, err := do(client, "a", "second")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("a:second", result)
+
+	result, err = do(client, "a", "changed")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("a:first", result)
+	assert.Zero(player.Remaining())
+
+	_, err = do(client, "a", "first")
+	assert.ErrorIs(err, ErrInteractionNotFound)
+
+	assert.Equal(2, calls)
+}
+
+func TestToolRecordAndReplay(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "tool.json")
+
+	recorder, err := New(path, ModeRecord)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	counter := &counterTool{}
+	tool := recorder.Tool(counter)
+
+	ctx := context.Background()
+
+	result, err := tool.Call(ctx, map[string]any{"text": "a"})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("a", result)
+
+	_, err = tool.Call(ctx, map[string]any{"fail": true})
+	assert.EqualError(err, "counter failed")
+
+	player, err := New(path, ModeReplay)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	counter = &counterTool{}
+	tool = player.Tool(counter)
+
+	assert.Equal("counter", tool.Name())
+
+	_, err = tool.Call(ctx, map[string]any{"fail": true})
+	assert.EqualError(err, "counter failed")
+
+	result, err = tool.Call(ctx, map[string]any{"text": "a"})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("a", result)
+	assert.Zero(counter.calls)
+
+	_, err = tool.Call(ctx, map[string]any{"text": "a"})
+	assert.ErrorIs(err, ErrInteractionNotFound)
+}
+
+func TestInvalidMode(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := New("cassette.json", "rewind")
+	assert.Error(err)
+
+	_, err = New(filepath.Join(t.TempDir(), "missing.json"), ModeReplay)
+	assert.ErrorIs(err, os.ErrNotExist)
+}
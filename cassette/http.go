@@ -0,0 +1,133 @@
+package cassette
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// redactedParams are query parameters that carry credentials and are never
+// written to a cassette.
+var redactedParams = []string{"appid", "key", "api_key", "apikey", "token"}
+
+// Client returns an HTTP client whose traffic goes through the cassette.
+func (c *Cassette) Client() *http.Client {
+	return &http.Client{
+		Transport: c.Transport(nil),
+	}
+}
+
+// Transport wraps base, which defaults to http.DefaultTransport. Request
+// headers are not recorded, so API keys stay out of the cassette.
+func (c *Cassette) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &transport{c, base}
+}
+
+type transport struct {
+	cassette *Cassette
+	base     http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		bs, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		reqBody = bs
+		req.Body = io.NopCloser(bytes.NewReader(bs))
+	}
+
+	reqURL := redactURL(req.URL)
+
+	if t.cassette.mode == ModeReplay {
+		exact := func(i *Interaction) bool {
+			return i.HTTP != nil &&
+				i.HTTP.Method == req.Method &&
+				i.HTTP.URL == reqURL &&
+				i.HTTP.RequestBody == string(reqBody)
+		}
+
+		loose := func(i *Interaction) bool {
+			return i.HTTP != nil &&
+				i.HTTP.Method == req.Method &&
+				i.HTTP.URL == reqURL
+		}
+
+		i, err := t.cassette.match(exact, loose)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s %s", err, req.Method, reqURL)
+		}
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", i.HTTP.StatusCode, http.StatusText(i.HTTP.StatusCode)),
+			StatusCode:    i.HTTP.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header(i.HTTP.Header).Clone(),
+			Body:          io.NopCloser(strings.NewReader(i.HTTP.ResponseBody)),
+			ContentLength: int64(len(i.HTTP.ResponseBody)),
+			Request:       req,
+		}, nil
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+	header.Del("Content-Length")
+
+	err = t.cassette.record(&Interaction{
+		HTTP: &HTTPInteraction{
+			Method:       req.Method,
+			URL:          reqURL,
+			RequestBody:  string(reqBody),
+			StatusCode:   resp.StatusCode,
+			Header:       header,
+			ResponseBody: string(respBody),
+		},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func redactURL(u *url.URL) string {
+	redacted := *u
+	redacted.User = nil
+
+	query := redacted.Query()
+	for _, param := range redactedParams {
+		if query.Has(param) {
+			query.Set(param, "REDACTED")
+		}
+	}
+
+	redacted.RawQuery = query.Encode()
+	return redacted.String()
+}
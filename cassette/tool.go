@@ -0,0 +1,84 @@
+package cassette
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/flarexio/talkix/llm"
+)
+
+// Tool wraps a tool whose results do not travel over an HTTP client that can
+// be replaced, such as the tools of an MCP server.
+func (c *Cassette) Tool(tool llm.Tool) llm.Tool {
+	return &cassetteTool{tool, c}
+}
+
+type cassetteTool struct {
+	llm.Tool
+	cassette *Cassette
+}
+
+func (t *cassetteTool) Call(ctx context.Context, params map[string]any) (string, error) {
+	name := t.Name()
+
+	if t.cassette.mode == ModeReplay {
+		args, err := canonicalJSON(params)
+		if err != nil {
+			return "", err
+		}
+
+		exact := func(i *Interaction) bool {
+			if i.Tool == nil || i.Tool.Name != name {
+				return false
+			}
+
+			recorded, err := canonicalJSON(i.Tool.Arguments)
+			return err == nil && recorded == args
+		}
+
+		loose := func(i *Interaction) bool {
+			return i.Tool != nil && i.Tool.Name == name
+		}
+
+		i, err := t.cassette.match(exact, loose)
+		if err != nil {
+			return "", fmt.Errorf("%w: tool %s", err, name)
+		}
+
+		if i.Tool.Error != "" {
+			return "", errors.New(i.Tool.Error)
+		}
+
+		return i.Tool.Result, nil
+	}
+
+	result, callErr := t.Tool.Call(ctx, params)
+
+	i := &ToolInteraction{
+		Name:      name,
+		Arguments: params,
+		Result:    result,
+	}
+
+	if callErr != nil {
+		i.Error = callErr.Error()
+	}
+
+	if err := t.cassette.record(&Interaction{Tool: i}); err != nil {
+		return "", err
+	}
+
+	return result, callErr
+}
+
+func canonicalJSON(v map[string]any) (string, error) {
+	// encoding/json sorts map keys, so equal arguments encode equally.
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return string(bs), nil
+}
@@ -0,0 +1,48 @@
+package talkix
+
+import (
+	"context"
+	"errors"
+
+	"github.com/flarexio/core/endpoint"
+)
+
+var ErrUnexpectedResponse = errors.New("expected message type in response")
+
+// Replies runs a message through the reply endpoint, the same way for every
+// channel. A group message the bot is not asked about has no replies.
+func Replies(ctx context.Context, replies endpoint.Endpoint, msg Message) ([]Message, error) {
+	resp, err := replies(ctx, msg)
+	if err != nil {
+		if errors.Is(err, ErrNotAddressed) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	reply, ok := resp.(Message)
+	if !ok {
+		return nil, ErrUnexpectedResponse
+	}
+
+	return []Message{reply}, nil
+}
+
+// Renderer turns a reply into the message of a channel.
+type Renderer[T any] func(reply Message) (T, error)
+
+// Render turns the replies into the messages of a channel, in order.
+func Render[T any](replies []Message, render Renderer[T]) ([]T, error) {
+	msgs := make([]T, len(replies))
+	for i, reply := range replies {
+		msg, err := render(reply)
+		if err != nil {
+			return nil, err
+		}
+
+		msgs[i] = msg
+	}
+
+	return msgs, nil
+}
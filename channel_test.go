@@ -0,0 +1,45 @@
+package talkix
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplies(t *testing.T) {
+	assert := assert.New(t)
+
+	echo := func(ctx context.Context, request any) (any, error) {
+		return NewTextMessage("echo: " + request.(Message).Content()), nil
+	}
+
+	replies, err := Replies(context.Background(), echo, NewTextMessage("hello"))
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	if assert.Len(replies, 1) {
+		assert.Equal("echo: hello", replies[0].Content())
+	}
+
+	// A message the bot is not asked about has no replies.
+	ignore := func(ctx context.Context, request any) (any, error) {
+		return nil, ErrNotAddressed
+	}
+
+	replies, err = Replies(context.Background(), ignore, NewTextMessage("hello"))
+	assert.NoError(err)
+	assert.Empty(replies)
+
+	texts, err := Render([]Message{NewTextMessage("a"), NewTextMessage("b")}, func(reply Message) (string, error) {
+		return reply.Content(), nil
+	})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal([]string{"a", "b"}, texts)
+}
@@ -21,13 +21,22 @@ import (
 	"github.com/flarexio/core/policy"
 	"github.com/flarexio/talkix"
 	"github.com/flarexio/talkix/auth"
+	"github.com/flarexio/talkix/cassette"
 	"github.com/flarexio/talkix/config"
+	"github.com/flarexio/talkix/dedup"
+	"github.com/flarexio/talkix/group"
 	"github.com/flarexio/talkix/identity"
 	"github.com/flarexio/talkix/llm"
+	"github.com/flarexio/talkix/persistence/inmem"
 	"github.com/flarexio/talkix/persistence/kv"
+	"github.com/flarexio/talkix/persistence/sqlite"
 	"github.com/flarexio/talkix/session"
+	"github.com/flarexio/talkix/speech"
 	"github.com/flarexio/talkix/transport/http"
 	"github.com/flarexio/talkix/transport/line"
+	"github.com/flarexio/talkix/transport/slack"
+	"github.com/flarexio/talkix/transport/telegram"
+	"github.com/flarexio/talkix/user"
 )
 
 func main() {
@@ -46,6 +55,9 @@ func main() {
 			},
 		},
 		Action: run,
+		Commands: []*cli.Command{
+			richMenuCommand(),
+		},
 	}
 
 	err := cmd.Run(context.Background(), os.Args)
@@ -54,17 +66,35 @@ func main() {
 	}
 }
 
-func run(ctx context.Context, cmd *cli.Command) error {
+// loadConfig reads the config.yaml in the talkix path, which defaults to
+// ~/.flarex/talkix.
+func loadConfig(cmd *cli.Command) (string, config.Config, error) {
+	var cfg config.Config
+
 	path := cmd.String("path")
 	if path == "" {
 		homeDir, err := os.UserHomeDir()
 		if err != nil {
-			return err
+			return "", cfg, err
 		}
 
 		path = filepath.Join(homeDir, ".flarex", "talkix")
 	}
 
+	f, err := os.Open(filepath.Join(path, "config.yaml"))
+	if err != nil {
+		return "", cfg, err
+	}
+	defer f.Close()
+
+	if err := yaml.NewDecoder(f).Decode(&cfg); err != nil {
+		return "", cfg, err
+	}
+
+	return path, cfg, nil
+}
+
+func run(ctx context.Context, cmd *cli.Command) error {
 	log, err := zap.NewDevelopment()
 	if err != nil {
 		return err
@@ -73,37 +103,80 @@ func run(ctx context.Context, cmd *cli.Command) error {
 
 	zap.ReplaceGlobals(log)
 
-	f, err := os.Open(filepath.Join(path, "config.yaml"))
+	path, cfg, err := loadConfig(cmd)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	var cfg config.Config
-	if err := yaml.NewDecoder(f).Decode(&cfg); err != nil {
-		return err
-	}
+	llmOpts := make([]llm.Option, 0)
 
-	session.InitLLM(cfg.LLM.Summary.Model)
+	var cas *cassette.Cassette
+	if cassetteCfg := cfg.LLM.Cassette; cassetteCfg.Mode != "" {
+		cassettePath := cassetteCfg.Path
+		if !filepath.IsAbs(cassettePath) {
+			cassettePath = filepath.Join(path, cassettePath)
+		}
+
+		cas, err = cassette.New(cassettePath, cassette.Mode(cassetteCfg.Mode))
+		if err != nil {
+			return err
+		}
+
+		llmOpts = append(llmOpts, llm.WithHTTPClient(cas.Client()))
+
+		log.Info("cassette enabled",
+			zap.String("mode", cassetteCfg.Mode),
+			zap.String("path", cassettePath),
+		)
+	}
 
 	otp := auth.NewOTPStore()
 
-	opts := badger.DefaultOptions(filepath.Join(path, cfg.LLM.Persistence.Name))
-	if cfg.LLM.Persistence.InMemory {
-		opts = badger.DefaultOptions("").WithInMemory(true)
+	repos, err := openRepositories(path, cfg)
+	if err != nil {
+		return err
 	}
+	defer repos.close()
+
+	users, sessions, groups := repos.users, repos.sessions, repos.groups
 
-	db, err := badger.Open(opts)
+	summaryGen, err := session.NewLLMSummaryGenerator(cfg.LLM.Summary.Model, append([]llm.Option{
+		llm.WithPricing(cfg.LLM.Pricing),
+		llm.WithProviders(cfg.LLM.Providers),
+	}, llmOpts...)...)
 	if err != nil {
 		return err
 	}
-	defer db.Close()
 
-	users := kv.NewUserRepository(db)
-	sessions := kv.NewSessionRepository(db)
+	summaryHook := talkix.SummaryUsageHook(users)
+
+	var summarizer session.Summarizer
+	switch summaryCfg := cfg.LLM.Summary; summaryCfg.Mode {
+	case config.SummaryBackground:
+		queue := session.NewQueueSummarizer(summaryGen, sessions,
+			summaryCfg.Workers, summaryCfg.QueueSize,
+			summaryHook,
+		)
+		defer queue.Close()
+
+		summarizer = queue
+
+	case "", config.SummarySync:
+		summarizer = session.NewSyncSummarizer(summaryGen, sessions, summaryHook)
+
+	default:
+		return errors.New("invalid summary mode: " + string(summaryCfg.Mode))
+	}
+
+	summarizer = session.NewEveryNSummarizer(summarizer, cfg.LLM.Summary.Every)
+
+	weatherTool := talkix.NewWeatherTool(cfg.LLM.Tools.Weather)
+	if cas != nil {
+		weatherTool = talkix.NewWeatherToolWithTransport(cfg.LLM.Tools.Weather, cas.Transport(nil))
+	}
 
 	tools := []llm.Tool{
-		talkix.NewWeatherTool(cfg.LLM.Tools.Weather),
+		weatherTool,
 	}
 
 	for _, server := range cfg.LLM.Tools.MCPServers {
@@ -112,11 +185,18 @@ func run(ctx context.Context, cmd *cli.Command) error {
 			return err
 		}
 
-		tools = append(tools, mcpTools...)
+		for _, tool := range mcpTools {
+			if cas != nil {
+				tool = cas.Tool(tool)
+			}
+
+			tools = append(tools, tool)
+		}
 	}
 
 	svc, err := talkix.NewAIService(cfg, tools, otp,
-		users, sessions,
+		users, sessions, repos.threads, summarizer,
+		llmOpts...,
 	)
 	if err != nil {
 		return err
@@ -124,32 +204,101 @@ func run(ctx context.Context, cmd *cli.Command) error {
 
 	// svc := talkix.NewSimpleService(cfg, otp, users, sessions)
 
+	if model := cfg.LLM.Speech.Model; model != "" {
+		transcriber, err := speech.NewTranscriber(model, cfg.LLM.Providers, nil)
+		if cas != nil {
+			transcriber, err = speech.NewTranscriber(model, cfg.LLM.Providers, cas.Client())
+		}
+
+		if err != nil {
+			return err
+		}
+
+		svc = talkix.TranscribeMiddleware(transcriber)(svc)
+	}
+
 	name := svc.Name()
 	svc = talkix.LoggingMiddleware(name)(svc)
+	svc = talkix.DispatchMiddleware(cfg.Dispatch.Workers, cfg.Dispatch.QueueSize)(svc)
+	svc = talkix.GroupMiddleware(groups)(svc)
+
+	sessionSvc := talkix.NewSessionService(users, sessions)
+	sessionSvc = talkix.SessionLoggingMiddleware()(sessionSvc)
+
+	eventSvc := talkix.NewEventService(users, groups, sessionSvc, svc)
+	eventSvc = talkix.EventLoggingMiddleware()(eventSvc)
 
 	directUser := identity.DirectUserEndpoint(path, cfg.Identity)
 
 	r := gin.Default()
 	{
-		endpoint := talkix.ReplyMessageEndpoint(svc)
+		replyEndpoint := talkix.ReplyMessageEndpoint(svc)
+		eventEndpoint := talkix.HandleEventEndpoint(eventSvc)
 
 		if err := line.Init(cfg); err != nil {
 			return err
 		}
 
-		handler := line.MessageHandler(endpoint, directUser)
+		worker := line.NewWorker(replyEndpoint, eventEndpoint, directUser, cfg.Line.Messaging.Worker)
+		defer worker.Close()
+
+		handler := line.MessageHandler(worker, repos.events)
 
 		r.POST("/webhook/line", handler)
+
+		if cfg.Telegram.Token != "" {
+			if err := telegram.Init(cfg); err != nil {
+				return err
+			}
+
+			bot := telegram.NewBot(replyEndpoint)
+			defer bot.Close()
+
+			switch cfg.Telegram.Mode {
+			case "", config.TelegramWebhook:
+				if err := telegram.SetWebhook(ctx); err != nil {
+					return err
+				}
+
+				r.POST("/webhook/telegram", telegram.WebhookHandler(bot))
+
+			case config.TelegramPolling:
+				pollCtx, cancel := context.WithCancel(ctx)
+				defer cancel()
+
+				go func() {
+					if err := bot.Poll(pollCtx); err != nil {
+						log.Error("telegram polling stopped", zap.Error(err))
+					}
+				}()
+
+			default:
+				return errors.New("invalid telegram mode: " + string(cfg.Telegram.Mode))
+			}
+		}
+
+		if cfg.Slack.BotToken != "" {
+			if err := slack.Init(cfg); err != nil {
+				return err
+			}
+
+			bot := slack.NewBot(replyEndpoint)
+			defer bot.Close()
+
+			r.POST("/webhook/slack/events", slack.EventHandler(bot))
+			r.POST("/webhook/slack/interactions", slack.InteractionHandler(bot))
+		}
 	}
 
-	sessionSvc := talkix.NewSessionService(users, sessions)
-	sessionSvc = talkix.SessionLoggingMiddleware()(sessionSvc)
+	userSvc := talkix.NewUserService(users)
+	userSvc = talkix.UserLoggingMiddleware()(userSvc)
 
 	http.Init(cfg.JWT)
 
 	otpAuth := http.OTPAuthorizator(otp, directUser)
 	{
 		r.GET("/users/:user/session/list", otpAuth("list_sessions"), http.SessionViewHandler())
+		r.GET("/users/:user/chat/web", otpAuth("web_chat"), http.ChatViewHandler())
 	}
 
 	permissionsPath := filepath.Join(path, "permissions.json")
@@ -166,6 +315,12 @@ func run(ctx context.Context, cmd *cli.Command) error {
 			r.GET("/users/:user/sessions", jwtAuth("talkix::sessions.read"), http.ListSessionsHandler(endpoint))
 		}
 
+		// GET /users/:user/sessions/:session/conversations
+		{
+			endpoint := talkix.ListConversationsEndpoint(sessionSvc)
+			r.GET("/users/:user/sessions/:session/conversations", jwtAuth("talkix::sessions.read"), http.ListConversationsHandler(endpoint))
+		}
+
 		// GET /users/:user/sessions/:session
 		{
 			endpoint := talkix.SessionEndpoint(sessionSvc)
@@ -189,6 +344,32 @@ func run(ctx context.Context, cmd *cli.Command) error {
 			endpoint := talkix.DeleteSessionEndpoint(sessionSvc)
 			r.DELETE("/users/:user/sessions/:session", jwtAuth("talkix::sessions.delete"), http.DeleteSessionHandler(endpoint))
 		}
+
+		// GET /users/:user/usage
+		{
+			endpoint := talkix.UsageEndpoint(sessionSvc)
+			r.GET("/users/:user/usage", jwtAuth("talkix::sessions.read"), http.UsageHandler(endpoint))
+		}
+
+		// GET /users
+		{
+			endpoint := talkix.ListUsersEndpoint(userSvc)
+			r.GET("/users", jwtAuth("talkix::users.read", http.Admin), http.ListUsersHandler(endpoint))
+		}
+
+		// POST /users/:user/messages/stream
+		{
+			endpoint := talkix.StreamReplyEndpoint(svc)
+			r.POST("/users/:user/messages/stream", jwtAuth("talkix::messages.create"), http.StreamReplyHandler(endpoint))
+		}
+
+		// GET /users/:user/chat (WebSocket)
+		// POST /users/:user/chat (SSE)
+		{
+			endpoint := talkix.ReplyMessageEndpoint(svc)
+			r.GET("/users/:user/chat", jwtAuth("talkix::messages.create"), http.ChatHandler(endpoint))
+			r.POST("/users/:user/chat", jwtAuth("talkix::messages.create"), http.ChatStreamHandler(endpoint))
+		}
 	}
 
 	go r.Run(":" + strconv.Itoa(cmd.Int("port")))
@@ -202,6 +383,87 @@ func run(ctx context.Context, cmd *cli.Command) error {
 	return nil
 }
 
+type repositories struct {
+	users    user.Repository
+	sessions session.Repository
+	threads  session.ThreadRepository
+	groups   group.Repository
+	events   dedup.Store
+	close    func() error
+}
+
+func openRepositories(path string, cfg config.Config) (*repositories, error) {
+	persistence := cfg.LLM.Persistence
+	dedupTTL := cfg.Line.Messaging.Worker.DedupTTL
+
+	switch persistence.Driver {
+	case config.InMemory:
+		users, err := inmem.NewUserRepository()
+		if err != nil {
+			return nil, err
+		}
+
+		return &repositories{
+			users:    users,
+			sessions: inmem.NewSessionRepository(),
+			threads:  inmem.NewThreadRepository(),
+			groups:   inmem.NewGroupRepository(),
+			events:   inmem.NewDedupStore(dedupTTL),
+			close:    func() error { return nil },
+		}, nil
+
+	case "", config.Badger:
+		opts := badger.DefaultOptions(filepath.Join(path, persistence.Name))
+		if persistence.InMemory {
+			opts = badger.DefaultOptions("").WithInMemory(true)
+		}
+
+		db, err := badger.Open(opts)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions, err := kv.NewSessionRepository(db)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+
+		return &repositories{
+			users:    kv.NewUserRepository(db),
+			sessions: sessions,
+			threads:  kv.NewThreadRepository(db),
+			groups:   kv.NewGroupRepository(db),
+			events:   kv.NewDedupStore(db, dedupTTL),
+			close:    db.Close,
+		}, nil
+
+	case config.SQLite:
+		dbPath := filepath.Join(path, persistence.Name)
+		if persistence.InMemory {
+			dbPath = ""
+		}
+
+		db, err := sqlite.Open(dbPath)
+		if err != nil {
+			return nil, err
+		}
+
+		// The event IDs are short-lived, so they are kept in memory.
+		return &repositories{
+			users:    sqlite.NewUserRepository(db),
+			sessions: sqlite.NewSessionRepository(db),
+			threads:  sqlite.NewThreadRepository(db),
+			groups:   sqlite.NewGroupRepository(db),
+			events:   inmem.NewDedupStore(dedupTTL),
+			close:    db.Close,
+		}, nil
+
+	default:
+		return nil, errors.New("unsupported persistence driver: " + string(persistence.Driver))
+	}
+}
+
 func NewMCPTool(tool mcp.Tool, fn callToolFn) llm.Tool {
 	return &mcpTool{tool, fn}
 }
@@ -242,11 +504,19 @@ func (t *mcpTool) Call(ctx context.Context, params map[string]any) (string, erro
 		return "", err
 	}
 
+	if len(result.Content) == 0 {
+		return "", errors.New("empty tool result")
+	}
+
 	content, ok := result.Content[0].(mcp.TextContent)
 	if !ok {
 		return "", errors.New("unexpected content type")
 	}
 
+	if result.IsError {
+		return "", errors.New(content.Text)
+	}
+
 	return content.Text, nil
 }
 
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/flarexio/talkix/config"
+	"github.com/flarexio/talkix/transport/line"
+)
+
+func richMenuCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "richmenu",
+		Usage: "Manage the LINE rich menus declared in config.yaml",
+		Commands: []*cli.Command{
+			{
+				Name:   "create",
+				Usage:  "Create the rich menus, upload their images and point their aliases at them",
+				Action: createRichMenus,
+			},
+			{
+				Name:   "list",
+				Usage:  "List the rich menus of the bot",
+				Action: listRichMenus,
+			},
+			{
+				Name:      "link",
+				Usage:     "Show a rich menu to a LINE user",
+				ArgsUsage: "<user> <menu>",
+				Action:    linkRichMenu,
+			},
+			{
+				Name:      "unlink",
+				Usage:     "Show the default rich menu to a LINE user again",
+				ArgsUsage: "<user>",
+				Action:    unlinkRichMenu,
+			},
+			{
+				Name:   "delete",
+				Usage:  "Delete the rich menus and their aliases",
+				Action: deleteRichMenus,
+			},
+		},
+	}
+}
+
+// initLine loads the config and connects to the Messaging API.
+func initLine(cmd *cli.Command) (string, config.Config, error) {
+	path, cfg, err := loadConfig(cmd)
+	if err != nil {
+		return "", cfg, err
+	}
+
+	if err := line.Init(cfg); err != nil {
+		return "", cfg, err
+	}
+
+	return path, cfg, nil
+}
+
+func createRichMenus(ctx context.Context, cmd *cli.Command) error {
+	path, cfg, err := initLine(cmd)
+	if err != nil {
+		return err
+	}
+
+	ids, err := line.CreateRichMenus(cfg.Line.RichMenus, path)
+	for alias, id := range ids {
+		fmt.Printf("%s\t%s\n", alias, id)
+	}
+
+	return err
+}
+
+func listRichMenus(ctx context.Context, cmd *cli.Command) error {
+	if _, _, err := initLine(cmd); err != nil {
+		return err
+	}
+
+	menus, err := line.ListRichMenus()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range menus {
+		fmt.Printf("%s\t%s\t%s\n", m.RichMenuId, m.Name, m.ChatBarText)
+	}
+
+	return nil
+}
+
+func linkRichMenu(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 2 {
+		return errors.New("usage: talkix richmenu link <user> <menu>")
+	}
+
+	if _, _, err := initLine(cmd); err != nil {
+		return err
+	}
+
+	return line.LinkRichMenu(cmd.Args().Get(0), cmd.Args().Get(1))
+}
+
+func unlinkRichMenu(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 1 {
+		return errors.New("usage: talkix richmenu unlink <user>")
+	}
+
+	if _, _, err := initLine(cmd); err != nil {
+		return err
+	}
+
+	return line.UnlinkRichMenu(cmd.Args().Get(0))
+}
+
+func deleteRichMenus(ctx context.Context, cmd *cli.Command) error {
+	_, cfg, err := initLine(cmd)
+	if err != nil {
+		return err
+	}
+
+	return line.DeleteRichMenus(cfg.Line.RichMenus)
+}
@@ -10,8 +10,19 @@ type Config struct {
 	BaseURL  string         `yaml:"baseURL"`
 	JWT      JWTConfig      `yaml:"jwt"`
 	Line     LineConfig     `yaml:"line"`
+	Telegram TelegramConfig `yaml:"telegram"`
+	Slack    SlackConfig    `yaml:"slack"`
 	Identity IdentityConfig `yaml:"identity"`
 	LLM      LLMConfig      `yaml:"llm"`
+	Dispatch DispatchConfig `yaml:"dispatch"`
+}
+
+// DispatchConfig bounds the replies in progress. Workers is the number of
+// users answered in parallel, QueueSize the number of messages a user can
+// have waiting for a reply.
+type DispatchConfig struct {
+	Workers   int `yaml:"workers"`
+	QueueSize int `yaml:"queueSize"`
 }
 
 type JWTConfig struct {
@@ -22,13 +33,144 @@ type JWTConfig struct {
 
 type LineConfig struct {
 	Messaging struct {
-		ChannelToken  string `yaml:"channelToken"`
-		ChannelSecret string `yaml:"channelSecret"`
-		WebhookURL    string `yaml:"webhookURL"`
+		ChannelToken  string           `yaml:"channelToken"`
+		ChannelSecret string           `yaml:"channelSecret"`
+		WebhookURL    string           `yaml:"webhookURL"`
+		Worker        LineWorkerConfig `yaml:"worker"`
 	} `yaml:"messaging"`
 	Login struct {
 		AuthURL string `yaml:"authURL"`
 	} `yaml:"login"`
+	RichMenus RichMenusConfig `yaml:"richMenus"`
+}
+
+// RichMenusConfig declares the rich menus of the bot, by the alias they are
+// created under. Guest is the menu of a user without a bound account, and
+// the default menu; Member replaces it once the account is bound.
+type RichMenusConfig struct {
+	Guest  string                    `yaml:"guest"`
+	Member string                    `yaml:"member"`
+	Menus  map[string]RichMenuConfig `yaml:"menus"`
+}
+
+// RichMenuConfig is the layout of a rich menu. Image is a PNG or JPEG file,
+// relative to the talkix path; the size defaults to 2500x1686.
+type RichMenuConfig struct {
+	Name        string         `yaml:"name"`
+	ChatBarText string         `yaml:"chatBarText"`
+	Image       string         `yaml:"image"`
+	Size        RichMenuSize   `yaml:"size"`
+	Selected    bool           `yaml:"selected"`
+	Areas       []RichMenuArea `yaml:"areas"`
+}
+
+type RichMenuSize struct {
+	Width  int64 `yaml:"width"`
+	Height int64 `yaml:"height"`
+}
+
+type RichMenuArea struct {
+	Bounds RichMenuBounds `yaml:"bounds"`
+	Action RichMenuAction `yaml:"action"`
+}
+
+type RichMenuBounds struct {
+	X      int64 `yaml:"x"`
+	Y      int64 `yaml:"y"`
+	Width  int64 `yaml:"width"`
+	Height int64 `yaml:"height"`
+}
+
+// RichMenuAction is what tapping an area does: a message sends Text, a
+// postback sends Data, a uri opens URI, and a richmenuswitch shows Menu.
+type RichMenuAction struct {
+	Type        string `yaml:"type"`
+	Label       string `yaml:"label"`
+	Text        string `yaml:"text"`
+	Data        string `yaml:"data"`
+	DisplayText string `yaml:"displayText"`
+	URI         string `yaml:"uri"`
+	Menu        string `yaml:"menu"`
+}
+
+// LineWorkerConfig controls the background processing of the webhook
+// events. A failed event is retried up to Retries times, waiting Backoff
+// before the first retry and twice as long before each next one. The event
+// IDs are remembered for DedupTTL to skip the redelivered events.
+type LineWorkerConfig struct {
+	Workers   int
+	QueueSize int
+	Retries   int
+	Backoff   time.Duration
+	DedupTTL  time.Duration
+}
+
+func (cfg *LineWorkerConfig) UnmarshalYAML(value *yaml.Node) error {
+	var raw struct {
+		Workers   int    `yaml:"workers"`
+		QueueSize int    `yaml:"queueSize"`
+		Retries   int    `yaml:"retries"`
+		Backoff   string `yaml:"backoff"`
+		DedupTTL  string `yaml:"dedupTTL"`
+	}
+
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+
+	cfg.Workers = raw.Workers
+	cfg.QueueSize = raw.QueueSize
+	cfg.Retries = raw.Retries
+
+	if raw.Backoff != "" {
+		duration, err := time.ParseDuration(raw.Backoff)
+		if err != nil {
+			return err
+		}
+
+		cfg.Backoff = duration
+	}
+
+	if raw.DedupTTL != "" {
+		duration, err := time.ParseDuration(raw.DedupTTL)
+		if err != nil {
+			return err
+		}
+
+		cfg.DedupTTL = duration
+	}
+
+	return nil
+}
+
+type TelegramMode string
+
+const (
+	TelegramWebhook TelegramMode = "webhook"
+	TelegramPolling TelegramMode = "polling"
+)
+
+// TelegramConfig connects a Telegram bot, which is disabled without a
+// token. In webhook mode the updates are posted to WebhookURL and verified
+// with SecretToken, which is then required; in polling mode they are
+// fetched, waiting up to Timeout seconds for each batch. APIURL points to
+// another Bot API server.
+type TelegramConfig struct {
+	Token       string       `yaml:"token"`
+	Mode        TelegramMode `yaml:"mode"`
+	WebhookURL  string       `yaml:"webhookURL"`
+	SecretToken string       `yaml:"secretToken"`
+	Timeout     int          `yaml:"timeout"`
+	APIURL      string       `yaml:"apiURL"`
+}
+
+// SlackConfig connects a Slack app, which is disabled without a bot token.
+// The events and the interactions are verified with the SigningSecret.
+// APIURL points to another Web API server.
+type SlackConfig struct {
+	BotToken      string `yaml:"botToken"`
+	SigningSecret string `yaml:"signingSecret"`
+	APIURL        string `yaml:"apiURL"`
 }
 
 type IdentityConfig struct {
@@ -42,20 +184,126 @@ type LLMConfig struct {
 	Model       string           `yaml:"model"`
 	Prompt      string           `yaml:"prompt"`
 	Summary     SummaryLLMConfig `yaml:"summary"`
-	Line        LineLLMConfig    `yaml:"line"`
+	Format      FormatLLMConfig  `yaml:"format"`
 	Persistence Persistence      `yaml:"persistence"`
 	Tools       ToolsConfig      `yaml:"tools"`
+	Providers   ProvidersConfig  `yaml:"providers"`
+	Pricing     PricingConfig    `yaml:"pricing"`
+	Cassette    CassetteConfig   `yaml:"cassette"`
+	History     HistoryConfig    `yaml:"history"`
+	Speech      SpeechConfig     `yaml:"speech"`
 }
 
-type SummaryLLMConfig struct {
+// UnmarshalYAML also reads the formatter from "line", its deprecated name
+// from when it only served LINE. The "format" key wins when both are set.
+func (cfg *LLMConfig) UnmarshalYAML(value *yaml.Node) error {
+	type plain LLMConfig
+
+	raw := struct {
+		plain `yaml:",inline"`
+		Line  *FormatLLMConfig `yaml:"line"`
+	}{plain: plain(*cfg)}
+
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+
+	*cfg = LLMConfig(raw.plain)
+
+	if raw.Line != nil && cfg.Format == (FormatLLMConfig{}) {
+		cfg.Format = *raw.Line
+	}
+
+	return nil
+}
+
+// SpeechConfig selects the model that transcribes voice messages, e.g.
+// "openai:whisper-1". Voice messages are not transcribed without one.
+type SpeechConfig struct {
 	Model string `yaml:"model"`
 }
 
-type LineLLMConfig struct {
+// HistoryConfig controls the previous turns replayed to the main LLM. The
+// budget is an estimated token count, which can be set per model. Load is
+// the number of recent conversations read from the repository per reply.
+type HistoryConfig struct {
+	Turns        int            `yaml:"turns"`
+	IncludeTools bool           `yaml:"includeTools"`
+	Budget       int            `yaml:"budget"`
+	Budgets      map[string]int `yaml:"perModel"`
+	Load         int            `yaml:"load"`
+}
+
+const DefaultHistoryLoad = 50
+
+// LoadCount defaults to the turn limit, or to DefaultHistoryLoad without one.
+func (cfg HistoryConfig) LoadCount() int {
+	if cfg.Load > 0 {
+		return cfg.Load
+	}
+
+	if cfg.Turns > 0 {
+		return cfg.Turns
+	}
+
+	return DefaultHistoryLoad
+}
+
+func (cfg HistoryConfig) BudgetFor(model string) int {
+	if budget, ok := cfg.Budgets[model]; ok {
+		return budget
+	}
+
+	return cfg.Budget
+}
+
+// CassetteConfig records or replays the traffic of the LLMs and tools. The
+// mode is either "record" or "replay"; an empty mode disables it.
+type CassetteConfig struct {
+	Mode string `yaml:"mode"`
+	Path string `yaml:"path"`
+}
+
+type SummaryMode string
+
+const (
+	SummarySync       SummaryMode = "sync"
+	SummaryBackground SummaryMode = "background"
+)
+
+// SummaryLLMConfig selects how session summaries are generated. With every
+// set to N, the summary is only updated every N conversations.
+type SummaryLLMConfig struct {
+	Model     string      `yaml:"model"`
+	Mode      SummaryMode `yaml:"mode"`
+	Every     int         `yaml:"every"`
+	Workers   int         `yaml:"workers"`
+	QueueSize int         `yaml:"queueSize"`
+}
+
+// FormatLLMConfig selects the formatter, which turns the replies into text or
+// cards for every channel.
+type FormatLLMConfig struct {
 	Model  string `yaml:"model"`
 	Prompt string `yaml:"prompt"`
 }
 
+type ProvidersConfig map[string]ProviderConfig
+
+type ProviderConfig struct {
+	BaseURL string `yaml:"baseURL"`
+	APIKey  string `yaml:"apiKey"`
+}
+
+// PricingConfig maps a model, e.g. "openai:gpt-4.1-mini", to its price.
+type PricingConfig map[string]ModelPrice
+
+// ModelPrice is given in USD per million tokens.
+type ModelPrice struct {
+	Input  float64 `yaml:"input"`
+	Output float64 `yaml:"output"`
+}
+
 type PersistenceDriver string
 
 const (
@@ -75,8 +323,29 @@ type Persistence struct {
 }
 
 type ToolsConfig struct {
-	MCPServers map[string]MCPServerConfig `yaml:"mcpServers"`
-	Weather    WeatherAPIConfig           `yaml:"weather"`
+	MCPServers  map[string]MCPServerConfig `yaml:"mcpServers"`
+	Weather     WeatherAPIConfig           `yaml:"weather"`
+	ErrorPolicy ToolErrorPolicy            `yaml:"errorPolicy"`
+	Execution   ToolExecutionConfig        `yaml:"execution"`
+}
+
+type GiveUpAction string
+
+const (
+	GiveUpReport GiveUpAction = "report"
+	GiveUpAbort  GiveUpAction = "abort"
+)
+
+type ToolErrorPolicy struct {
+	Retries     int                        `yaml:"retries"`
+	MaxFailures int                        `yaml:"maxFailures"`
+	GiveUp      GiveUpAction               `yaml:"giveUp"`
+	Tools       map[string]ToolRetryLimits `yaml:"perTool"`
+}
+
+type ToolRetryLimits struct {
+	Retries     int `yaml:"retries"`
+	MaxFailures int `yaml:"maxFailures"`
 }
 
 type TransportType string
@@ -131,3 +400,44 @@ func (cfg *WeatherAPIConfig) UnmarshalYAML(value *yaml.Node) error {
 
 	return nil
 }
+
+type ToolExecutionConfig struct {
+	Concurrency int
+	Timeout     time.Duration
+	Timeouts    map[string]time.Duration
+}
+
+func (cfg *ToolExecutionConfig) UnmarshalYAML(value *yaml.Node) error {
+	var raw struct {
+		Concurrency int               `yaml:"concurrency"`
+		Timeout     string            `yaml:"timeout"`
+		Timeouts    map[string]string `yaml:"perTool"`
+	}
+
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+
+	cfg.Concurrency = raw.Concurrency
+
+	if raw.Timeout != "" {
+		duration, err := time.ParseDuration(raw.Timeout)
+		if err != nil {
+			return err
+		}
+
+		cfg.Timeout = duration
+	}
+
+	cfg.Timeouts = make(map[string]time.Duration)
+	for name, timeout := range raw.Timeouts {
+		duration, err := time.ParseDuration(timeout)
+		if err != nil {
+			return err
+		}
+
+		cfg.Timeouts[name] = duration
+	}
+
+	return nil
+}
@@ -7,4 +7,38 @@ const (
 	SessionKey  ContextKey = "session"
 	OTPKey      ContextKey = "otp"
 	MessagesKey ContextKey = "messages"
+	ChatKey     ContextKey = "chat"
+
+	// ThreadKey holds the thread a message was sent in, which keeps a
+	// session of its own instead of the one the user selected. The thread
+	// is a string unique across the channels.
+	ThreadKey ContextKey = "thread"
+
+	// LoginKey holds the Login of a channel that can bind the account of
+	// its users. Without one, the users are not asked to bind it.
+	LoginKey ContextKey = "login"
+)
+
+type ChatType string
+
+const (
+	ChatGroup   ChatType = "group"
+	ChatRoom    ChatType = "room"
+	ChatChannel ChatType = "channel"
 )
+
+// Chat is the group, room or channel a message was sent in. The user in the
+// context owns the sessions of the chat: the chat itself, or the speaker in
+// a thread, see ThreadKey.
+type Chat struct {
+	ID        string
+	Type      ChatType
+	Speaker   string
+	Mentioned bool
+}
+
+// Login is the button that opens the page where a user binds their account.
+type Login struct {
+	Label string
+	URL   string
+}
@@ -0,0 +1,19 @@
+package dedup
+
+import "time"
+
+// DefaultTTL is how long an event ID is remembered. LINE gives up
+// redelivering a webhook well within a day.
+const DefaultTTL = 24 * time.Hour
+
+// Store remembers the IDs of the webhook events that were received, so that
+// an event delivered again is processed only once.
+type Store interface {
+	// Add records the event ID and reports false when it was recorded
+	// before and has not expired yet.
+	Add(id string) (added bool, err error)
+
+	// Remove forgets the event ID, so that a later delivery of the event is
+	// processed again.
+	Remove(id string) error
+}
@@ -0,0 +1,137 @@
+package talkix
+
+import (
+	"context"
+	"errors"
+
+	"github.com/flarexio/talkix/user"
+)
+
+const (
+	DefaultDispatchWorkers   = 8
+	DefaultDispatchQueueSize = 5
+)
+
+// DispatchMiddleware serializes the replies of each user, so that the
+// messages of a user are answered one at a time and in the order they
+// arrived, while the workers answer different users in parallel.
+//
+// Every user has a queue of the given size, see KeyedQueue. A message that
+// does not fit is rejected with ErrQueueFull instead of waiting, so that a
+// flood of messages from one user never holds up the others. A queued
+// message is dropped when its context is done before its turn comes.
+func DispatchMiddleware(workers int, size int) ServiceMiddleware {
+	if workers <= 0 {
+		workers = DefaultDispatchWorkers
+	}
+
+	if size <= 0 {
+		size = DefaultDispatchQueueSize
+	}
+
+	return func(next Service) Service {
+		d := &dispatcher{
+			next:    next,
+			workers: make(chan struct{}, workers),
+		}
+
+		d.queue = NewKeyedQueue(size, d.work)
+		return d
+	}
+}
+
+type dispatcher struct {
+	next    Service
+	workers chan struct{}
+	queue   *KeyedQueue[string, func()]
+}
+
+func (d *dispatcher) Name() string {
+	return d.next.Name()
+}
+
+func (d *dispatcher) ReplyMessage(ctx context.Context, msg Message) (Message, error) {
+	type result struct {
+		reply Message
+		err   error
+	}
+
+	done := make(chan result, 1)
+
+	err := d.dispatch(ctx, func() {
+		if err := ctx.Err(); err != nil {
+			done <- result{nil, err}
+			return
+		}
+
+		reply, err := d.next.ReplyMessage(ctx, msg)
+		done <- result{reply, err}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case r := <-done:
+		return r.reply, r.err
+
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// StreamReply returns as soon as the message is queued. The user keeps its
+// turn until the stream is over.
+func (d *dispatcher) StreamReply(ctx context.Context, msg Message) (<-chan StreamEvent, error) {
+	events := make(chan StreamEvent)
+
+	err := d.dispatch(ctx, func() {
+		defer close(events)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		in, err := d.next.StreamReply(ctx, msg)
+		if err != nil {
+			select {
+			case events <- StreamEvent{Type: StreamEventError, Err: err}:
+			case <-ctx.Done():
+			}
+
+			return
+		}
+
+		for e := range in {
+			select {
+			case events <- e:
+			case <-ctx.Done():
+			}
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// dispatch appends the job to the queue of the user in the context.
+func (d *dispatcher) dispatch(ctx context.Context, job func()) error {
+	u, ok := ctx.Value(UserKey).(*user.User)
+	if !ok {
+		return errors.New("user not found in context")
+	}
+
+	return d.queue.Enqueue(u.ID, job)
+}
+
+// work runs a job of a user once a worker is free.
+func (d *dispatcher) work(userID string, job func()) {
+	d.workers <- struct{}{}
+	defer func() { <-d.workers }()
+
+	job()
+}
@@ -0,0 +1,198 @@
+package talkix
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix/user"
+)
+
+// funcService answers with a function, so that the tests control when and
+// how a reply finishes.
+type funcService struct {
+	reply func(ctx context.Context, msg Message) (Message, error)
+}
+
+func (svc *funcService) Name() string {
+	return "func"
+}
+
+func (svc *funcService) ReplyMessage(ctx context.Context, msg Message) (Message, error) {
+	return svc.reply(ctx, msg)
+}
+
+func (svc *funcService) StreamReply(ctx context.Context, msg Message) (<-chan StreamEvent, error) {
+	events := make(chan StreamEvent, 1)
+
+	reply, err := svc.reply(ctx, msg)
+	if err != nil {
+		events <- StreamEvent{Type: StreamEventError, Err: err}
+	} else {
+		events <- StreamEvent{Type: StreamEventReply, Reply: reply}
+	}
+
+	close(events)
+	return events, nil
+}
+
+func userContext(id string) context.Context {
+	return context.WithValue(context.Background(), UserKey, &user.User{ID: id})
+}
+
+func TestDispatchMiddleware(t *testing.T) {
+	assert := assert.New(t)
+
+	var (
+		mu       sync.Mutex
+		active   = make(map[string]int)
+		overlaps int
+		parallel int
+		maxUsers int
+		replies  = make(map[string][]string)
+	)
+
+	next := &funcService{func(ctx context.Context, msg Message) (Message, error) {
+		u := ctx.Value(UserKey).(*user.User)
+
+		mu.Lock()
+		active[u.ID]++
+		if active[u.ID] > 1 {
+			overlaps++
+		}
+
+		parallel++
+		maxUsers = max(maxUsers, parallel)
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		active[u.ID]--
+		parallel--
+		replies[u.ID] = append(replies[u.ID], msg.Content())
+		mu.Unlock()
+
+		return NewTextMessage(msg.Content()), nil
+	}}
+
+	svc := DispatchMiddleware(4, 10)(next)
+
+	var wg sync.WaitGroup
+	for _, id := range []string{"U1", "U2", "U3"} {
+		ctx := userContext(id)
+
+		// The messages of a user arrive one after another, but each is
+		// handled on its own goroutine, like separate webhook requests.
+		for i := range 5 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				_, err := svc.ReplyMessage(ctx, NewTextMessage(fmt.Sprintf("%d", i)))
+				assert.NoError(err)
+			}()
+
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	wg.Wait()
+
+	assert.Zero(overlaps)
+	assert.Greater(maxUsers, 1)
+
+	for _, id := range []string{"U1", "U2", "U3"} {
+		assert.Equal([]string{"0", "1", "2", "3", "4"}, replies[id])
+	}
+}
+
+func TestDispatchMiddlewareQueueFull(t *testing.T) {
+	assert := assert.New(t)
+
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+
+	next := &funcService{func(ctx context.Context, msg Message) (Message, error) {
+		started <- struct{}{}
+		<-release
+		return msg, nil
+	}}
+
+	svc := DispatchMiddleware(1, 2)(next)
+	d := svc.(*dispatcher)
+
+	ctx := userContext("U1")
+
+	errs := make(chan error, 3)
+	send := func(text string) {
+		_, err := svc.ReplyMessage(ctx, NewTextMessage(text))
+		errs <- err
+	}
+
+	go send("running")
+	<-started
+
+	go send("queued 1")
+	go send("queued 2")
+
+	assert.Eventually(func() bool {
+		d.queue.Lock()
+		defer d.queue.Unlock()
+
+		return len(d.queue.queues["U1"]) == 2
+	}, time.Second, time.Millisecond)
+
+	_, err := svc.ReplyMessage(ctx, NewTextMessage("rejected"))
+	assert.ErrorIs(err, ErrQueueFull)
+
+	// A message that is given up before its turn is dropped; the only
+	// worker is still busy with the first user.
+	otherCtx, cancel := context.WithCancel(userContext("U2"))
+	cancel()
+
+	_, err = svc.ReplyMessage(otherCtx, NewTextMessage("other"))
+	assert.ErrorIs(err, context.Canceled)
+
+	close(release)
+
+	for range 3 {
+		assert.NoError(<-errs)
+	}
+
+	assert.Eventually(func() bool {
+		d.queue.Lock()
+		defer d.queue.Unlock()
+
+		return len(d.queue.queues) == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestDispatchMiddlewareStreamReply(t *testing.T) {
+	assert := assert.New(t)
+
+	next := &funcService{func(ctx context.Context, msg Message) (Message, error) {
+		return NewTextMessage("reply: " + msg.Content()), nil
+	}}
+
+	svc := DispatchMiddleware(1, 1)(next)
+
+	events, err := svc.StreamReply(userContext("U1"), NewTextMessage("hello"))
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	var replies []string
+	for e := range events {
+		if assert.Equal(StreamEventReply, e.Type) {
+			replies = append(replies, e.Reply.Content())
+		}
+	}
+
+	assert.Equal([]string{"reply: hello"}, replies)
+}
@@ -3,9 +3,12 @@ package talkix
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/flarexio/core/endpoint"
+	"github.com/flarexio/talkix/llm/message"
 	"github.com/flarexio/talkix/session"
+	"github.com/flarexio/talkix/user"
 )
 
 type ReplyMessageRequest = Message
@@ -24,21 +27,79 @@ func ReplyMessageEndpoint(service Service) endpoint.Endpoint {
 	}
 }
 
+func StreamReplyEndpoint(service Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (any, error) {
+		req := request.(ReplyMessageRequest)
+
+		return service.StreamReply(ctx, req)
+	}
+}
+
+type HandleEventRequest = Event
+type HandleEventResponse = []Message
+
+func HandleEventEndpoint(service EventService) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (any, error) {
+		req, ok := request.(HandleEventRequest)
+		if !ok {
+			return nil, errors.New("invalid request type")
+		}
+
+		return service.HandleEvent(ctx, req)
+	}
+}
+
+// SessionInfo is a session as it is listed: without its conversations,
+// which are paged through ListConversations, but with their number.
+type SessionInfo struct {
+	ID                string
+	UserID            string
+	Summary           session.Summary
+	Usage             message.Usage
+	ToolUsage         message.UsageByTool
+	CreatedAt         time.Time
+	ConversationCount int
+}
+
+func NewSessionInfo(s *session.Session) *SessionInfo {
+	return &SessionInfo{
+		ID:                s.ID,
+		UserID:            s.UserID,
+		Summary:           s.Summary,
+		Usage:             s.Usage,
+		ToolUsage:         s.ToolUsage,
+		CreatedAt:         s.CreatedAt,
+		ConversationCount: s.ConversationCount(),
+	}
+}
+
 type ListSessionsResponse struct {
-	Sessions          []*session.Session `json:"sessions"`
-	SelectedSessionID string             `json:"selected_session_id"`
+	Sessions          []*SessionInfo `json:"sessions"`
+	SelectedSessionID string         `json:"selected_session_id"`
+	NextCursor        string         `json:"next_cursor,omitempty"`
 }
 
 func ListSessionsEndpoint(service SessionService) endpoint.Endpoint {
 	return func(ctx context.Context, request any) (any, error) {
-		sessions, selectedSessionID, err := service.ListSessions(ctx)
+		query, ok := request.(session.SessionQuery)
+		if !ok {
+			return nil, errors.New("invalid request type")
+		}
+
+		page, selectedSessionID, err := service.ListSessions(ctx, query)
 		if err != nil {
 			return nil, err
 		}
 
+		sessions := make([]*SessionInfo, len(page.Sessions))
+		for i, s := range page.Sessions {
+			sessions[i] = NewSessionInfo(s)
+		}
+
 		resp := &ListSessionsResponse{
 			Sessions:          sessions,
 			SelectedSessionID: selectedSessionID,
+			NextCursor:        page.NextCursor,
 		}
 
 		return resp, nil
@@ -56,6 +117,32 @@ func SessionEndpoint(service SessionService) endpoint.Endpoint {
 	}
 }
 
+type ListConversationsResponse struct {
+	Conversations []*session.Conversation `json:"conversations"`
+	NextCursor    string                  `json:"next_cursor,omitempty"`
+}
+
+func ListConversationsEndpoint(service SessionService) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (any, error) {
+		query, ok := request.(session.ConversationQuery)
+		if !ok {
+			return nil, errors.New("invalid request type")
+		}
+
+		page, err := service.ListConversations(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		resp := &ListConversationsResponse{
+			Conversations: page.Conversations,
+			NextCursor:    page.NextCursor,
+		}
+
+		return resp, nil
+	}
+}
+
 func CreateSessionEndpoint(service SessionService) endpoint.Endpoint {
 	return func(ctx context.Context, request any) (any, error) {
 		session, err := service.CreateSession(ctx)
@@ -90,3 +177,35 @@ func DeleteSessionEndpoint(service SessionService) endpoint.Endpoint {
 		return nil, err
 	}
 }
+
+func UsageEndpoint(service SessionService) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (any, error) {
+		return service.Usage(ctx)
+	}
+}
+
+type ListUsersResponse struct {
+	Users      []*user.User `json:"users"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+func ListUsersEndpoint(service UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (any, error) {
+		query, ok := request.(user.Query)
+		if !ok {
+			return nil, errors.New("invalid request type")
+		}
+
+		page, err := service.ListUsers(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		resp := &ListUsersResponse{
+			Users:      page.Users,
+			NextCursor: page.NextCursor,
+		}
+
+		return resp, nil
+	}
+}
@@ -0,0 +1,252 @@
+package talkix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/flarexio/talkix/group"
+	"github.com/flarexio/talkix/templates"
+	"github.com/flarexio/talkix/user"
+)
+
+type EventType string
+
+const (
+	EventFollow   EventType = "follow"
+	EventUnfollow EventType = "unfollow"
+	EventPostback EventType = "postback"
+	EventJoin     EventType = "join"
+	EventLeave    EventType = "leave"
+)
+
+// The postback actions, given as the "action" field of the postback data,
+// e.g. "action=switch_session&session=<id>".
+const (
+	ActionNewSession    = "new_session"
+	ActionSwitchSession = "switch_session"
+	ActionMessage       = "message"
+	ActionGroupTrigger  = "group_trigger"
+)
+
+const (
+	welcomeText      = "Hi, thanks for adding me as a friend! Ask me anything, e.g. the weather or places nearby."
+	bindingTitle     = "Bind Your Account"
+	bindingText      = "Log in to bind your account, so that I can use your profile."
+	groupWelcomeText = "Hi everyone, thanks for inviting me! Mention me when you need me."
+)
+
+var (
+	ErrUnknownEvent  = errors.New("unknown event type")
+	ErrUnknownAction = errors.New("unknown postback action")
+	ErrNotInChat     = errors.New("action only available in a group or room")
+)
+
+// Event is something that happened in a chat other than a message. ChatID is
+// the group or room of a join or leave event; Data and Params are the
+// postback data and the values picked with a postback action.
+type Event struct {
+	Type      EventType
+	ChatID    string
+	Data      string
+	Params    map[string]string
+	Timestamp time.Time
+}
+
+type EventService interface {
+	HandleEvent(ctx context.Context, e Event) (replies []Message, err error)
+}
+
+type EventServiceMiddleware func(EventService) EventService
+
+// NewEventService handles the chat events. A postback either runs a session
+// or group action, or is answered by the reply service like a message.
+func NewEventService(users user.Repository, groups group.Repository,
+	sessions SessionService, replies Service,
+) EventService {
+	return &eventService{
+		users:    users,
+		groups:   groups,
+		sessions: sessions,
+		replies:  replies,
+	}
+}
+
+type eventService struct {
+	users    user.Repository
+	groups   group.Repository
+	sessions SessionService
+	replies  Service
+}
+
+func (svc *eventService) HandleEvent(ctx context.Context, e Event) ([]Message, error) {
+	switch e.Type {
+	case EventFollow:
+		return svc.follow(ctx)
+
+	case EventUnfollow:
+		return nil, svc.setInactive(ctx, true)
+
+	case EventPostback:
+		return svc.postback(ctx, e)
+
+	case EventJoin:
+		return svc.join(e.ChatID)
+
+	case EventLeave:
+		// Nothing can be sent to a chat that was left.
+		return nil, nil
+
+	default:
+		return nil, ErrUnknownEvent
+	}
+}
+
+// follow welcomes the user, and asks to bind the account unless it is bound
+// already or the channel cannot bind one.
+func (svc *eventService) follow(ctx context.Context) ([]Message, error) {
+	if err := svc.setInactive(ctx, false); err != nil {
+		return nil, err
+	}
+
+	replies := []Message{NewTextMessage(welcomeText)}
+
+	login, ok := ctx.Value(LoginKey).(*Login)
+	if u, _ := ctx.Value(UserKey).(*user.User); u.Verified || !ok {
+		return replies, nil
+	}
+
+	binding := NewCardMessage(bindingTitle, LoginCard(login, templates.LoginValues{
+		Title:       bindingTitle,
+		Description: bindingText,
+	}))
+
+	return append(replies, binding), nil
+}
+
+// join stores the default settings of a group the first time the bot joins
+// it, and keeps the settings of a group it joins again.
+func (svc *eventService) join(chatID string) ([]Message, error) {
+	_, err := svc.groups.Find(chatID)
+	switch {
+	case errors.Is(err, group.ErrGroupNotFound):
+		if err := svc.groups.Save(group.NewSettings(chatID)); err != nil {
+			return nil, err
+		}
+
+	case err != nil:
+		return nil, err
+	}
+
+	return []Message{NewTextMessage(groupWelcomeText)}, nil
+}
+
+func (svc *eventService) setInactive(ctx context.Context, inactive bool) error {
+	userCtx, ok := ctx.Value(UserKey).(*user.User)
+	if !ok {
+		return errors.New("user not found in context")
+	}
+
+	u, err := svc.users.Find(userCtx.ID)
+	if err != nil {
+		if !errors.Is(err, user.ErrUserNotFound) {
+			return err
+		}
+
+		u = &user.User{ID: userCtx.ID}
+	}
+
+	return saveUser(svc.users, u, func(u *user.User) error {
+		u.Inactive = inactive
+		return nil
+	})
+}
+
+func (svc *eventService) postback(ctx context.Context, e Event) ([]Message, error) {
+	data, err := url.ParseQuery(e.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch action := data.Get("action"); action {
+	case ActionNewSession:
+		if _, err := svc.sessions.CreateSession(ctx); err != nil {
+			return nil, err
+		}
+
+		return []Message{NewTextMessage("Started a new conversation.")}, nil
+
+	case ActionSwitchSession:
+		if err := svc.sessions.SwitchSession(ctx, data.Get("session")); err != nil {
+			return nil, err
+		}
+
+		return []Message{NewTextMessage("Switched to the conversation.")}, nil
+
+	case ActionMessage:
+		text := data.Get("text")
+		if text == "" {
+			return nil, errors.New("postback message without text")
+		}
+
+		msg := NewTextMessage(text)
+		msg.SetTimestamp(e.Timestamp)
+
+		reply, err := svc.replies.ReplyMessage(ctx, msg)
+		if err != nil {
+			return nil, err
+		}
+
+		return []Message{reply}, nil
+
+	case ActionGroupTrigger:
+		return svc.setTrigger(ctx, data)
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAction, action)
+	}
+}
+
+// setTrigger changes when the bot answers in a group, e.g.
+// "action=group_trigger&mode=keyword&keywords=bot,help".
+func (svc *eventService) setTrigger(ctx context.Context, data url.Values) ([]Message, error) {
+	chat, ok := ctx.Value(ChatKey).(*Chat)
+	if !ok {
+		return nil, ErrNotInChat
+	}
+
+	settings, err := findGroup(svc.groups, chat.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	trigger := group.Trigger(data.Get("mode"))
+
+	var keywords []string
+	if k := data.Get("keywords"); k != "" {
+		keywords = strings.Split(k, ",")
+	}
+
+	if err := settings.SetTrigger(trigger, keywords...); err != nil {
+		return nil, err
+	}
+
+	if err := svc.groups.Save(settings); err != nil {
+		return nil, err
+	}
+
+	var text string
+	switch settings.Trigger {
+	case group.TriggerMention:
+		text = "I will answer when I am mentioned."
+	case group.TriggerKeyword:
+		text = "I will answer messages with: " + strings.Join(settings.Keywords, ", ")
+	case group.TriggerAll:
+		text = "I will answer every message."
+	}
+
+	return []Message{NewTextMessage(text)}, nil
+}
@@ -0,0 +1,221 @@
+package talkix
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix/group"
+	"github.com/flarexio/talkix/persistence/inmem"
+	"github.com/flarexio/talkix/user"
+)
+
+func newTestEventService(t *testing.T) (EventService, user.Repository, group.Repository) {
+	users, err := inmem.NewUserRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessions := NewSessionService(users, inmem.NewSessionRepository())
+
+	replies := &funcService{func(ctx context.Context, msg Message) (Message, error) {
+		return NewTextMessage("reply: " + msg.Content()), nil
+	}}
+
+	groups := inmem.NewGroupRepository()
+
+	return NewEventService(users, groups, sessions, replies), users, groups
+}
+
+func TestEventServiceFollow(t *testing.T) {
+	assert := assert.New(t)
+
+	svc, users, _ := newTestEventService(t)
+
+	login := &Login{Label: "Login with LINE", URL: "https://example.com/login"}
+	ctx := context.WithValue(userContext("U1234"), LoginKey, login)
+
+	replies, err := svc.HandleEvent(ctx, Event{Type: EventUnfollow})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Empty(replies)
+
+	u, err := users.Find("U1234")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.True(u.Inactive)
+
+	// Following again welcomes the user and asks to bind the account.
+	replies, err = svc.HandleEvent(ctx, Event{Type: EventFollow})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	if assert.Len(replies, 2) {
+		assert.Equal("text", replies[0].Type())
+
+		card := replies[1].(*CardMessage)
+		assert.Equal([]Button{{Label: "Login with LINE", URL: "https://example.com/login", Style: ButtonPrimary}},
+			card.Cards[0].Buttons)
+	}
+
+	u, err = users.Find("U1234")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.False(u.Inactive)
+
+	// A bound user is only welcomed.
+	verified := context.WithValue(context.Background(), UserKey, &user.User{ID: "U1234", Verified: true})
+
+	replies, err = svc.HandleEvent(verified, Event{Type: EventFollow})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Len(replies, 1)
+
+	// So is a user on a channel that cannot bind the account.
+	replies, err = svc.HandleEvent(userContext("telegram:1234"), Event{Type: EventFollow})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Len(replies, 1)
+}
+
+func TestEventServicePostback(t *testing.T) {
+	assert := assert.New(t)
+
+	svc, users, _ := newTestEventService(t)
+
+	ctx := userContext("U1234")
+
+	if _, err := svc.HandleEvent(ctx, Event{Type: EventFollow}); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	_, err := svc.HandleEvent(ctx, Event{Type: EventPostback, Data: "action=new_session"})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	u, err := users.Find("U1234")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	if !assert.Len(u.SessionIDs, 1) {
+		return
+	}
+
+	first := u.SessionIDs[0]
+
+	if _, err := svc.HandleEvent(ctx, Event{Type: EventPostback, Data: "action=new_session"}); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	_, err = svc.HandleEvent(ctx, Event{Type: EventPostback, Data: "action=switch_session&session=" + first})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	u, err = users.Find("U1234")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal(first, u.SelectedSessionID)
+
+	// A message postback is answered like a message.
+	replies, err := svc.HandleEvent(ctx, Event{Type: EventPostback, Data: "action=message&text=weather%20tomorrow"})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	if assert.Len(replies, 1) {
+		assert.Equal("reply: weather tomorrow", replies[0].Content())
+	}
+
+	_, err = svc.HandleEvent(ctx, Event{Type: EventPostback, Data: "action=dance"})
+	assert.ErrorIs(err, ErrUnknownAction)
+}
+
+func TestEventServiceGroup(t *testing.T) {
+	assert := assert.New(t)
+
+	svc, _, groups := newTestEventService(t)
+
+	replies, err := svc.HandleEvent(context.Background(), Event{Type: EventJoin, ChatID: "C1234"})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Len(replies, 1)
+
+	settings, err := groups.Find("C1234")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal(group.TriggerMention, settings.Trigger)
+
+	// The trigger is only changed from within the group.
+	_, err = svc.HandleEvent(userContext("U1234"), Event{Type: EventPostback, Data: "action=group_trigger&mode=all"})
+	assert.ErrorIs(err, ErrNotInChat)
+
+	ctx := context.WithValue(userContext("C1234"), ChatKey, &Chat{ID: "C1234", Type: ChatGroup, Speaker: "Alice"})
+
+	_, err = svc.HandleEvent(ctx, Event{Type: EventPostback, Data: "action=group_trigger&mode=keyword&keywords=bot,%20help"})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	settings, err = groups.Find("C1234")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal(group.TriggerKeyword, settings.Trigger)
+	assert.Equal([]string{"bot", "help"}, settings.Keywords)
+
+	_, err = svc.HandleEvent(ctx, Event{Type: EventPostback, Data: "action=group_trigger&mode=keyword"})
+	assert.ErrorIs(err, group.ErrNoKeywords)
+
+	// Joining again keeps the settings.
+	if _, err := svc.HandleEvent(context.Background(), Event{Type: EventJoin, ChatID: "C1234"}); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	settings, err = groups.Find("C1234")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal(group.TriggerKeyword, settings.Trigger)
+}
@@ -0,0 +1,77 @@
+package talkix
+
+import (
+	"context"
+	"errors"
+
+	"github.com/flarexio/talkix/group"
+)
+
+// ErrNotAddressed is returned for a group message the bot does not answer.
+var ErrNotAddressed = errors.New("message not addressed to the bot")
+
+// GroupMiddleware passes on only the group messages that are addressed to
+// the bot, as decided by the settings of the group. The messages of a single
+// user are always passed on.
+func GroupMiddleware(groups group.Repository) ServiceMiddleware {
+	return func(next Service) Service {
+		return &groupMiddleware{
+			groups: groups,
+			next:   next,
+		}
+	}
+}
+
+type groupMiddleware struct {
+	groups group.Repository
+	next   Service
+}
+
+func (mw *groupMiddleware) Name() string {
+	return mw.next.Name()
+}
+
+func (mw *groupMiddleware) ReplyMessage(ctx context.Context, msg Message) (Message, error) {
+	if err := mw.addressed(ctx, msg); err != nil {
+		return nil, err
+	}
+
+	return mw.next.ReplyMessage(ctx, msg)
+}
+
+func (mw *groupMiddleware) StreamReply(ctx context.Context, msg Message) (<-chan StreamEvent, error) {
+	if err := mw.addressed(ctx, msg); err != nil {
+		return nil, err
+	}
+
+	return mw.next.StreamReply(ctx, msg)
+}
+
+func (mw *groupMiddleware) addressed(ctx context.Context, msg Message) error {
+	chat, ok := ctx.Value(ChatKey).(*Chat)
+	if !ok {
+		return nil
+	}
+
+	settings, err := findGroup(mw.groups, chat.ID)
+	if err != nil {
+		return err
+	}
+
+	if !settings.Triggered(msg.Content(), chat.Mentioned) {
+		return ErrNotAddressed
+	}
+
+	return nil
+}
+
+// findGroup returns the settings of a group, the defaults for a group that
+// has none stored.
+func findGroup(groups group.Repository, id string) (*group.Settings, error) {
+	settings, err := groups.Find(id)
+	if errors.Is(err, group.ErrGroupNotFound) {
+		return group.NewSettings(id), nil
+	}
+
+	return settings, err
+}
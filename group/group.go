@@ -0,0 +1,88 @@
+package group
+
+import (
+	"strings"
+	"time"
+)
+
+// Trigger decides which messages of a group the bot answers.
+type Trigger string
+
+const (
+	TriggerMention Trigger = "mention"
+	TriggerKeyword Trigger = "keyword"
+	TriggerAll     Trigger = "all"
+)
+
+// Settings are kept per group or room. The bot answers a mention in any
+// mode; in keyword mode also a message that contains one of the keywords.
+type Settings struct {
+	ID        string    `json:"id"`
+	Trigger   Trigger   `json:"trigger"`
+	Keywords  []string  `json:"keywords,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NewSettings answers only the mentions.
+func NewSettings(id string) *Settings {
+	now := time.Now()
+
+	return &Settings{
+		ID:        id,
+		Trigger:   TriggerMention,
+		Keywords:  make([]string, 0),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// SetTrigger changes the mode; the keywords are only kept in keyword mode.
+func (s *Settings) SetTrigger(trigger Trigger, keywords ...string) error {
+	kept := make([]string, 0, len(keywords))
+	for _, keyword := range keywords {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			kept = append(kept, keyword)
+		}
+	}
+
+	switch trigger {
+	case TriggerMention, TriggerAll:
+		kept = kept[:0]
+
+	case TriggerKeyword:
+		if len(kept) == 0 {
+			return ErrNoKeywords
+		}
+
+	default:
+		return ErrInvalidTrigger
+	}
+
+	s.Trigger = trigger
+	s.Keywords = kept
+	s.UpdatedAt = time.Now()
+	return nil
+}
+
+// Triggered tells whether a message is addressed to the bot.
+func (s *Settings) Triggered(text string, mentioned bool) bool {
+	if mentioned {
+		return true
+	}
+
+	switch s.Trigger {
+	case TriggerAll:
+		return true
+
+	case TriggerKeyword:
+		text = strings.ToLower(text)
+		for _, keyword := range s.Keywords {
+			if strings.Contains(text, strings.ToLower(keyword)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
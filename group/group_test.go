@@ -0,0 +1,43 @@
+package group
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettingsTriggered(t *testing.T) {
+	assert := assert.New(t)
+
+	s := NewSettings("C1234")
+
+	assert.True(s.Triggered("what time is it?", true))
+	assert.False(s.Triggered("what time is it?", false))
+
+	if err := s.SetTrigger(TriggerKeyword, " Talkix ", ""); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal([]string{"Talkix"}, s.Keywords)
+	assert.True(s.Triggered("hey talkix, what time is it?", false))
+	assert.False(s.Triggered("what time is it?", false))
+
+	if err := s.SetTrigger(TriggerAll); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Empty(s.Keywords)
+	assert.True(s.Triggered("what time is it?", false))
+}
+
+func TestSettingsSetTriggerInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	s := NewSettings("C1234")
+
+	assert.ErrorIs(s.SetTrigger(TriggerKeyword, " "), ErrNoKeywords)
+	assert.ErrorIs(s.SetTrigger("sometimes"), ErrInvalidTrigger)
+	assert.Equal(TriggerMention, s.Trigger)
+}
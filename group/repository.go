@@ -0,0 +1,14 @@
+package group
+
+import "errors"
+
+var (
+	ErrGroupNotFound  = errors.New("group not found")
+	ErrInvalidTrigger = errors.New("invalid trigger")
+	ErrNoKeywords     = errors.New("keyword trigger requires keywords")
+)
+
+type Repository interface {
+	Find(id string) (*Settings, error)
+	Save(s *Settings) error
+}
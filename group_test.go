@@ -0,0 +1,65 @@
+package talkix
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix/group"
+	"github.com/flarexio/talkix/persistence/inmem"
+)
+
+func TestGroupMiddleware(t *testing.T) {
+	assert := assert.New(t)
+
+	groups := inmem.NewGroupRepository()
+
+	next := &funcService{func(ctx context.Context, msg Message) (Message, error) {
+		return NewTextMessage("reply: " + msg.Content()), nil
+	}}
+
+	svc := GroupMiddleware(groups)(next)
+
+	chatContext := func(mentioned bool) context.Context {
+		return context.WithValue(userContext("C1234"), ChatKey, &Chat{
+			ID:        "C1234",
+			Type:      ChatGroup,
+			Speaker:   "Alice",
+			Mentioned: mentioned,
+		})
+	}
+
+	// A single user is always answered.
+	_, err := svc.ReplyMessage(userContext("U1234"), NewTextMessage("hello"))
+	assert.NoError(err)
+
+	// A group without settings only answers its mentions.
+	_, err = svc.ReplyMessage(chatContext(false), NewTextMessage("hello"))
+	assert.ErrorIs(err, ErrNotAddressed)
+
+	reply, err := svc.ReplyMessage(chatContext(true), NewTextMessage("hello"))
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("reply: hello", reply.Content())
+
+	settings := group.NewSettings("C1234")
+	if err := settings.SetTrigger(group.TriggerKeyword, "bot"); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	if err := groups.Save(settings); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	_, err = svc.ReplyMessage(chatContext(false), NewTextMessage("Hey Bot, what's the weather?"))
+	assert.NoError(err)
+
+	_, err = svc.StreamReply(chatContext(false), NewTextMessage("lunch anyone?"))
+	assert.ErrorIs(err, ErrNotAddressed)
+}
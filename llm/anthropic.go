@@ -0,0 +1,380 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/flarexio/talkix/llm/message"
+)
+
+const (
+	DefaultAnthropicBaseURL   = "https://api.anthropic.com"
+	DefaultAnthropicMaxTokens = 4096
+
+	anthropicVersion = "2023-06-01"
+)
+
+func init() {
+	RegisterProvider("anthropic", NewAnthropicProvider)
+}
+
+// NewAnthropicProvider talks to the Anthropic Messages API. The API key falls
+// back to the ANTHROPIC_API_KEY environment variable.
+func NewAnthropicProvider(model string, opts ProviderOptions) (Provider, error) {
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultAnthropicBaseURL
+	}
+
+	apiKey := opts.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("ANTHROPIC_API_KEY")
+	}
+
+	client := opts.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &anthropicProvider{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		client:  client,
+	}, nil
+}
+
+type anthropicProvider struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+type anthropicRequest struct {
+	Model      string               `json:"model"`
+	MaxTokens  int                  `json:"max_tokens"`
+	System     string               `json:"system,omitempty"`
+	Messages   []anthropicMessage   `json:"messages"`
+	Tools      []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice *anthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+
+	Source *anthropicSource `json:"source,omitempty"`
+}
+
+// anthropicSource is the image of an image block, either base64 encoded or
+// by URL.
+type anthropicSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type anthropicResponse struct {
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int64 `json:"input_tokens"`
+		OutputTokens int64 `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+type anthropicError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *anthropicProvider) Complete(ctx context.Context, req *Request) (*Response, error) {
+	body, err := convertToAnthropicRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	bs, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/messages", bytes.NewReader(bs))
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Api-Key", p.apiKey)
+	httpReq.Header.Set("Anthropic-Version", anthropicVersion)
+
+	httpResp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		var apiErr anthropicError
+		if err := json.Unmarshal(data, &apiErr); err == nil && apiErr.Error.Message != "" {
+			return nil, fmt.Errorf("anthropic API returned status %d: %s", httpResp.StatusCode, apiErr.Error.Message)
+		}
+
+		return nil, fmt.Errorf("anthropic API returned status %d", httpResp.StatusCode)
+	}
+
+	var resp anthropicResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+
+	msg, err := convertFromAnthropicResponse(resp, req.Schema)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Response{
+		Message: msg,
+		Usage: message.Usage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+		},
+	}, nil
+}
+
+func convertToAnthropicRequest(req *Request) (*anthropicRequest, error) {
+	if err := checkToolResults(req.Messages); err != nil {
+		return nil, err
+	}
+
+	body := &anthropicRequest{
+		Model:     req.Model,
+		MaxTokens: DefaultAnthropicMaxTokens,
+		Messages:  make([]anthropicMessage, 0),
+	}
+
+	systems := make([]string, 0)
+	for _, msg := range req.Messages {
+		var (
+			role  string
+			block []anthropicBlock
+		)
+
+		switch msg.Role {
+		case message.RoleSystem:
+			systems = append(systems, msg.Content)
+			continue
+
+		case message.RoleHuman:
+			role = "user"
+			block = anthropicUserBlocks(msg)
+
+		case message.RoleAI:
+			role = "assistant"
+			block = make([]anthropicBlock, 0)
+
+			if msg.Content != "" {
+				block = append(block, anthropicBlock{Type: "text", Text: msg.Content})
+			}
+
+			for _, tc := range msg.ToolCalls {
+				// A tool use always has an input, if only an empty one.
+				args := tc.Arguments
+				if args == nil {
+					args = make(map[string]any)
+				}
+
+				input, err := json.Marshal(args)
+				if err != nil {
+					return nil, err
+				}
+
+				block = append(block, anthropicBlock{
+					Type:  "tool_use",
+					ID:    tc.ID,
+					Name:  tc.Name,
+					Input: input,
+				})
+			}
+
+		case message.RoleTool:
+			role = "user"
+			block = []anthropicBlock{
+				{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Content, IsError: msg.IsError},
+			}
+
+		default:
+			return nil, errors.New("unknown message role: " + string(msg.Role))
+		}
+
+		// Anthropic expects alternating turns, so consecutive messages of
+		// the same role (e.g. several tool results) are merged.
+		if n := len(body.Messages); n > 0 && body.Messages[n-1].Role == role {
+			body.Messages[n-1].Content = append(body.Messages[n-1].Content, block...)
+			continue
+		}
+
+		body.Messages = append(body.Messages, anthropicMessage{
+			Role:    role,
+			Content: block,
+		})
+	}
+
+	body.System = strings.Join(systems, "\n\n")
+
+	if len(body.Messages) == 0 {
+		// A prompt consisting of system messages only still needs a user turn.
+		body.Messages = append(body.Messages, anthropicMessage{
+			Role: "user",
+			Content: []anthropicBlock{
+				{Type: "text", Text: "Please respond according to the instructions."},
+			},
+		})
+	}
+
+	for _, tool := range req.Tools {
+		body.Tools = append(body.Tools, anthropicTool{
+			Name:        tool.Name(),
+			Description: tool.Description(),
+			InputSchema: tool.Parameters(),
+		})
+	}
+
+	// Anthropic has no response format parameter, so structured output is
+	// requested as a tool whose input is the expected JSON object.
+	if schema := req.Schema; schema != nil {
+		body.Tools = append(body.Tools, anthropicTool{
+			Name:        schema.Name(),
+			Description: schema.Description(),
+			InputSchema: schema.Schema(),
+		})
+
+		if len(req.Tools) > 0 {
+			body.ToolChoice = &anthropicToolChoice{Type: "any"}
+		} else {
+			body.ToolChoice = &anthropicToolChoice{Type: "tool", Name: schema.Name()}
+		}
+	}
+
+	return body, nil
+}
+
+// anthropicUserBlocks sends the images of a message as image blocks. The
+// other attachments, and the images that are no longer available, are named
+// in the text.
+func anthropicUserBlocks(msg message.Message) []anthropicBlock {
+	texts := make([]string, 0)
+	if msg.Content != "" || len(msg.Attachments) == 0 {
+		texts = append(texts, msg.SpokenContent())
+	}
+
+	images := make([]anthropicBlock, 0)
+	for _, a := range msg.Attachments {
+		var source *anthropicSource
+		switch {
+		case a.Type != message.AttachmentImage:
+
+		case a.URL != "":
+			source = &anthropicSource{Type: "url", URL: a.URL}
+
+		case len(a.Data) > 0:
+			source = &anthropicSource{
+				Type:      "base64",
+				MediaType: a.MIMEType,
+				Data:      base64.StdEncoding.EncodeToString(a.Data),
+			}
+		}
+
+		if source == nil {
+			texts = append(texts, a.String())
+			continue
+		}
+
+		images = append(images, anthropicBlock{Type: "image", Source: source})
+	}
+
+	blocks := make([]anthropicBlock, 0, len(images)+1)
+	if len(texts) > 0 {
+		blocks = append(blocks, anthropicBlock{Type: "text", Text: strings.Join(texts, "\n")})
+	}
+
+	return append(blocks, images...)
+}
+
+func convertFromAnthropicResponse(resp anthropicResponse, schema Schema) (message.Message, error) {
+	m := message.Message{
+		Role: message.RoleAI,
+	}
+
+	texts := make([]string, 0)
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			texts = append(texts, block.Text)
+
+		case "tool_use":
+			if schema != nil && block.Name == schema.Name() {
+				// The structured output replaces any surrounding prose.
+				return message.Message{
+					Role:    message.RoleAI,
+					Content: string(block.Input),
+				}, nil
+			}
+
+			var args map[string]any
+			if len(block.Input) > 0 {
+				if err := json.Unmarshal(block.Input, &args); err != nil {
+					return message.Message{}, err
+				}
+			}
+
+			m.ToolCalls = append(m.ToolCalls, message.ToolCall{
+				ID:        block.ID,
+				Name:      block.Name,
+				Arguments: args,
+			})
+		}
+	}
+
+	m.Content = strings.Join(texts, "")
+
+	return m, nil
+}
@@ -0,0 +1,176 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix/config"
+	"github.com/flarexio/talkix/llm/message"
+)
+
+func TestAnthropicProviderWithStructuredOutput(t *testing.T) {
+	assert := assert.New(t)
+
+	var received anthropicRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/v1/messages", r.URL.Path)
+		assert.Equal("test-key", r.Header.Get("X-Api-Key"))
+
+		json.NewDecoder(r.Body).Decode(&received)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"content": [
+				{"type": "tool_use", "id": "toolu_1", "name": "example", "input": {"city": "Paris"}}
+			],
+			"stop_reason": "tool_use"
+		}`))
+	}))
+	defer server.Close()
+
+	llm, err := NewLLM("anthropic:claude-sonnet-4-0",
+		WithStructuredOutput(example{}),
+		WithProviders(config.ProvidersConfig{
+			"anthropic": {BaseURL: server.URL, APIKey: "test-key"},
+		}),
+	)
+
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	msgs, err := llm.Invoke(ctx, "What is the capital of France?")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("claude-sonnet-4-0", received.Model)
+	assert.Equal("You are a helpful assistant.", received.System)
+	assert.Len(received.Messages, 1)
+	assert.Equal("tool", received.ToolChoice.Type)
+	assert.Equal("example", received.ToolChoice.Name)
+
+	resp := msgs[len(msgs)-1]
+
+	var result example
+	if err := json.Unmarshal([]byte(resp.Content), &result); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("Paris", result.City)
+}
+
+func TestConvertToAnthropicRequest(t *testing.T) {
+	assert := assert.New(t)
+
+	req := &Request{
+		Model: "claude-sonnet-4-0",
+		Messages: []message.Message{
+			message.SystemMessage("You are a helpful assistant."),
+			message.HumanMessage("What is the weather in Paris and London?"),
+			message.AIMessage("",
+				message.ToolCall{ID: "toolu_1", Name: "get_weather", Arguments: map[string]any{"city": "Paris"}},
+				message.ToolCall{ID: "toolu_2", Name: "get_weather", Arguments: map[string]any{"city": "London"}},
+				message.ToolCall{ID: "toolu_3", Name: "get_time"},
+			),
+			message.ToolMessage("sunny", "toolu_1"),
+			message.ToolMessage("rainy", "toolu_2"),
+			message.ToolErrorMessage(`{"error":{"type":"call_failed"}}`, "toolu_3"),
+		},
+	}
+
+	body, err := convertToAnthropicRequest(req)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("You are a helpful assistant.", body.System)
+	assert.Len(body.Messages, 3)
+
+	assistant := body.Messages[1]
+	assert.Equal("assistant", assistant.Role)
+	if !assert.Len(assistant.Content, 3) {
+		return
+	}
+
+	assert.Equal("tool_use", assistant.Content[0].Type)
+	assert.JSONEq(`{"city": "Paris"}`, string(assistant.Content[0].Input))
+
+	// A tool without arguments still sends its input, which is required.
+	bs, err := json.Marshal(assistant.Content[2])
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.JSONEq(`{"type": "tool_use", "id": "toolu_3", "name": "get_time", "input": {}}`, string(bs))
+
+	results := body.Messages[2]
+	assert.Equal("user", results.Role)
+	assert.Len(results.Content, 3)
+	assert.Equal("toolu_2", results.Content[1].ToolUseID)
+	assert.Equal("rainy", results.Content[1].Content)
+	assert.False(results.Content[1].IsError)
+
+	// A failed call is marked as an error for the model.
+	bs, err = json.Marshal(results.Content[2])
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.JSONEq(`{"type": "tool_result", "tool_use_id": "toolu_3", "content": "{\"error\":{\"type\":\"call_failed\"}}", "is_error": true}`, string(bs))
+}
+
+func TestConvertToAnthropicRequestWithImages(t *testing.T) {
+	assert := assert.New(t)
+
+	req := &Request{
+		Model: "claude-sonnet-4-0",
+		Messages: []message.Message{
+			message.HumanMessage("",
+				message.Attachment{Type: message.AttachmentImage, MIMEType: "image/png", Data: []byte("png")},
+				message.Attachment{Type: message.AttachmentImage, URL: "https://example.com/cat.jpg"},
+			),
+		},
+	}
+
+	body, err := convertToAnthropicRequest(req)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	if !assert.Len(body.Messages, 1) {
+		return
+	}
+
+	blocks := body.Messages[0].Content
+	if !assert.Len(blocks, 2) {
+		return
+	}
+
+	assert.Equal("image", blocks[0].Type)
+	assert.Equal(&anthropicSource{Type: "base64", MediaType: "image/png", Data: "cG5n"}, blocks[0].Source)
+	assert.Equal(&anthropicSource{Type: "url", URL: "https://example.com/cat.jpg"}, blocks[1].Source)
+}
+
+func TestNewLLMWithUnknownProvider(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := NewLLM("unknown:model")
+	assert.Error(err)
+
+	_, err = NewLLM("gpt-4.1-mini")
+	assert.Error(err)
+}
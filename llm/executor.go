@@ -0,0 +1,145 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/flarexio/talkix/config"
+	"github.com/flarexio/talkix/llm/message"
+)
+
+const (
+	DefaultToolConcurrency = 4
+	DefaultToolTimeout     = 30 * time.Second
+)
+
+func WithToolExecution(cfg config.ToolExecutionConfig) Option {
+	return &llmWithToolExecution{cfg}
+}
+
+type llmWithToolExecution struct {
+	cfg config.ToolExecutionConfig
+}
+
+func (opt *llmWithToolExecution) Apply(llm *LLM) error {
+	if llm == nil {
+		return errors.New("llm cannot be nil")
+	}
+
+	if opt.cfg.Concurrency < 0 {
+		return errors.New("tool concurrency cannot be negative")
+	}
+
+	llm.execution = opt.cfg
+	return nil
+}
+
+func (llm *LLM) toolTimeout(name string) time.Duration {
+	if timeout, ok := llm.execution.Timeouts[name]; ok && timeout > 0 {
+		return timeout
+	}
+
+	if timeout := llm.execution.Timeout; timeout > 0 {
+		return timeout
+	}
+
+	return DefaultToolTimeout
+}
+
+// toolFailures counts failed calls per tool within one invocation. It is
+// shared by the workers that run the tool calls of a turn concurrently.
+type toolFailures struct {
+	counts map[string]int
+	sync.Mutex
+}
+
+func newToolFailures() *toolFailures {
+	return &toolFailures{
+		counts: make(map[string]int),
+	}
+}
+
+func (f *toolFailures) Get(name string) int {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.counts[name]
+}
+
+func (f *toolFailures) Inc(name string) int {
+	f.Lock()
+	defer f.Unlock()
+
+	f.counts[name]++
+	return f.counts[name]
+}
+
+// callTools runs the tool calls of one turn on a bounded pool of workers and
+// returns the tool messages in the order of the calls.
+func (llm *LLM) callTools(ctx context.Context, toolCalls []message.ToolCall, failures *toolFailures) ([]message.Message, error) {
+	concurrency := llm.execution.Concurrency
+	if concurrency == 0 {
+		concurrency = DefaultToolConcurrency
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]message.Message, len(toolCalls))
+	errs := make([]error, len(toolCalls))
+
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for i, toolCall := range toolCalls {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+
+			result, err := llm.callTool(ctx, toolCall, failures)
+			if err != nil {
+				errs[i] = err
+				cancel() // no need to finish the others
+				return
+			}
+
+			results[i] = result
+		}()
+	}
+
+	wg.Wait()
+
+	// Prefer the error that caused the cancellation over the cancellations.
+	var firstErr error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		if !errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return results, nil
+}
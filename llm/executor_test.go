@@ -0,0 +1,141 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix/config"
+	"github.com/flarexio/talkix/llm/message"
+)
+
+type sleepTool struct {
+	running atomic.Int32
+	peak    atomic.Int32
+}
+
+func (t *sleepTool) Name() string {
+	return "sleep"
+}
+
+func (t *sleepTool) Description() string {
+	return "Sleep for the given milliseconds and return the given text"
+}
+
+func (t *sleepTool) Parameters() map[string]any {
+	return map[string]any{"type": "object"}
+}
+
+func (t *sleepTool) Call(ctx context.Context, params map[string]any) (string, error) {
+	running := t.running.Add(1)
+	defer t.running.Add(-1)
+
+	for {
+		peak := t.peak.Load()
+		if running <= peak || t.peak.CompareAndSwap(peak, running) {
+			break
+		}
+	}
+
+	ms, _ := params["ms"].(float64)
+	text, _ := params["text"].(string)
+
+	select {
+	case <-time.After(time.Duration(ms) * time.Millisecond):
+		return text, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func sleepCall(id string, ms float64, text string) message.ToolCall {
+	return message.ToolCall{
+		ID:        id,
+		Name:      "sleep",
+		Arguments: map[string]any{"ms": ms, "text": text},
+	}
+}
+
+func TestParallelToolCalls(t *testing.T) {
+	assert := assert.New(t)
+
+	tool := &sleepTool{}
+	llm := &LLM{
+		provider: &stubProvider{
+			responses: []message.Message{
+				message.AIMessage("",
+					sleepCall("call_1", 200, "first"),
+					sleepCall("call_2", 50, "second"),
+					sleepCall("call_3", 100, "third"),
+					sleepCall("call_4", 10, "fourth"),
+				),
+				message.AIMessage("Done."),
+			},
+		},
+		tools:    map[string]Tool{"sleep": tool},
+		toolList: []Tool{tool},
+
+		execution: config.ToolExecutionConfig{Concurrency: 2},
+	}
+
+	ctx := context.Background()
+
+	start := time.Now()
+	msgs, err := llm.Invoke(ctx, "Sleep")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Less(time.Since(start), 350*time.Millisecond)
+	assert.Equal(int32(2), tool.peak.Load())
+
+	expected := []string{"first", "second", "third", "fourth"}
+	for i, text := range expected {
+		assert.Equal(text, msgs[3+i].Content)
+		assert.Equal(fmt.Sprintf("call_%d", i+1), msgs[3+i].ToolCallID)
+	}
+}
+
+func TestToolTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	tool := &sleepTool{}
+	llm := &LLM{
+		provider: &stubProvider{
+			responses: []message.Message{
+				message.AIMessage("", sleepCall("call_1", 1000, "slow")),
+				message.AIMessage("Done."),
+			},
+		},
+		tools:    map[string]Tool{"sleep": tool},
+		toolList: []Tool{tool},
+
+		execution: config.ToolExecutionConfig{
+			Timeout: time.Second,
+			Timeouts: map[string]time.Duration{
+				"sleep": 50 * time.Millisecond,
+			},
+		},
+	}
+
+	ctx := context.Background()
+	msgs, err := llm.Invoke(ctx, "Sleep")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	toolErr, err := decodeToolError(msgs[3].Content)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal(ToolErrorCallFailed, toolErr.Type)
+	assert.Contains(toolErr.Message, "deadline exceeded")
+}
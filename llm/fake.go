@@ -0,0 +1,146 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/flarexio/talkix/llm/message"
+)
+
+func init() {
+	RegisterProvider("fake", NewFakeProvider)
+}
+
+var (
+	fakeScripts   = make(map[string]*FakeScript)
+	fakeScriptsMu sync.Mutex
+)
+
+// RegisterFakeScript registers the completions that a "fake:<name>" model
+// replays in order. Registering a name again replaces the previous script.
+func RegisterFakeScript(name string, responses ...Response) *FakeScript {
+	script := &FakeScript{
+		responses: responses,
+		requests:  make([]Request, 0),
+	}
+
+	fakeScriptsMu.Lock()
+	defer fakeScriptsMu.Unlock()
+
+	fakeScripts[name] = script
+	return script
+}
+
+// FakeScript replays scripted completions and records the requests it
+// received, so that tests can run without network access.
+type FakeScript struct {
+	responses []Response
+	requests  []Request
+	next      int
+	sync.Mutex
+}
+
+func (s *FakeScript) Requests() []Request {
+	s.Lock()
+	defer s.Unlock()
+
+	requests := make([]Request, len(s.requests))
+	copy(requests, s.requests)
+	return requests
+}
+
+func (s *FakeScript) Remaining() int {
+	s.Lock()
+	defer s.Unlock()
+
+	return len(s.responses) - s.next
+}
+
+func FakeText(content string) Response {
+	return Response{
+		Message: message.AIMessage(content),
+	}
+}
+
+func FakeJSON(v any) Response {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return FakeText(string(bs))
+}
+
+func FakeToolCalls(toolCalls ...message.ToolCall) Response {
+	return Response{
+		Message: message.AIMessage("", toolCalls...),
+	}
+}
+
+func NewFakeProvider(model string, opts ProviderOptions) (Provider, error) {
+	fakeScriptsMu.Lock()
+	script, ok := fakeScripts[model]
+	fakeScriptsMu.Unlock()
+
+	if !ok {
+		return nil, errors.New("fake script not registered: " + model)
+	}
+
+	return &fakeProvider{script}, nil
+}
+
+type fakeProvider struct {
+	script *FakeScript
+}
+
+func (p *fakeProvider) Complete(ctx context.Context, req *Request) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s := p.script
+
+	s.Lock()
+	defer s.Unlock()
+
+	recorded := *req
+	recorded.Messages = make([]message.Message, len(req.Messages))
+	copy(recorded.Messages, req.Messages)
+
+	s.requests = append(s.requests, recorded)
+
+	if s.next >= len(s.responses) {
+		return nil, errors.New("fake script exhausted")
+	}
+
+	resp := s.responses[s.next]
+	s.next++
+
+	msg := resp.Message
+	msg.Role = message.RoleAI
+
+	if len(msg.ToolCalls) > 0 {
+		toolCalls := make([]message.ToolCall, len(msg.ToolCalls))
+		for i, tc := range msg.ToolCalls {
+			if tc.ID == "" {
+				tc.ID = fmt.Sprintf("call_%d_%d", s.next, i+1)
+			}
+
+			toolCalls[i] = tc
+		}
+
+		msg.ToolCalls = toolCalls
+	}
+
+	if req.Schema != nil && len(msg.ToolCalls) == 0 && !json.Valid([]byte(msg.Content)) {
+		return nil, errors.New("fake response is not valid JSON for structured output")
+	}
+
+	return &Response{
+		Message: msg,
+		Usage:   resp.Usage,
+	}, nil
+}
@@ -0,0 +1,159 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix/llm/message"
+)
+
+func TestFakeLLM(t *testing.T) {
+	assert := assert.New(t)
+
+	script := RegisterFakeScript("capital",
+		FakeText("The capital of France is Paris."),
+	)
+
+	llm, err := NewLLM("fake:capital")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	msgs, err := llm.Invoke(ctx, "What is the capital of France?")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	resp := msgs[len(msgs)-1]
+	assert.Equal(message.RoleAI, resp.Role)
+	assert.Equal("The capital of France is Paris.", resp.Content)
+	assert.Equal("fake:capital", resp.Model)
+
+	requests := script.Requests()
+	if !assert.Len(requests, 1) {
+		return
+	}
+
+	last := requests[0].Messages[len(requests[0].Messages)-1]
+	assert.Equal("What is the capital of France?", last.Content)
+	assert.Zero(script.Remaining())
+}
+
+func TestFakeLLMWithStructuredOutput(t *testing.T) {
+	assert := assert.New(t)
+
+	RegisterFakeScript("structured",
+		FakeJSON(example{City: "Paris"}),
+	)
+
+	llm, err := NewLLM("fake:structured",
+		WithStructuredOutput(example{}),
+	)
+
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	msgs, err := llm.Invoke(ctx, "What is the capital of France?")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	var result example
+	if err := json.Unmarshal([]byte(msgs[len(msgs)-1].Content), &result); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("Paris", result.City)
+
+	RegisterFakeScript("structured", FakeText("Paris"))
+
+	llm, err = NewLLM("fake:structured",
+		WithStructuredOutput(example{}),
+	)
+
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	_, err = llm.Invoke(ctx, "What is the capital of France?")
+	assert.ErrorContains(err, "not valid JSON")
+}
+
+func TestFakeLLMWithTools(t *testing.T) {
+	assert := assert.New(t)
+
+	script := RegisterFakeScript("tools",
+		FakeToolCalls(message.ToolCall{
+			Name:      "echo",
+			Arguments: map[string]any{"text": "hello"},
+		}),
+		FakeText("The tool said hello."),
+	)
+
+	llm, err := NewLLM("fake:tools",
+		WithTools([]Tool{echoTool{}}),
+	)
+
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	msgs, err := llm.Invoke(ctx, "Say hello")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	if !assert.Len(msgs, 5) {
+		return
+	}
+
+	toolCall := msgs[2].ToolCalls[0]
+	assert.NotEmpty(toolCall.ID)
+
+	assert.Equal(message.RoleTool, msgs[3].Role)
+	assert.Equal("hello", msgs[3].Content)
+	assert.Equal(toolCall.ID, msgs[3].ToolCallID)
+	assert.Equal("The tool said hello.", msgs[4].Content)
+
+	requests := script.Requests()
+	if !assert.Len(requests, 2) {
+		return
+	}
+
+	assert.Len(requests[0].Tools, 1)
+	assert.Len(requests[1].Messages, 4)
+}
+
+func TestFakeLLMScriptExhausted(t *testing.T) {
+	assert := assert.New(t)
+
+	RegisterFakeScript("empty")
+
+	llm, err := NewLLM("fake:empty")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	_, err = llm.Invoke(ctx, "Hello")
+	assert.ErrorContains(err, "exhausted")
+
+	_, err = NewLLM("fake:unregistered")
+	assert.ErrorContains(err, "not registered")
+}
@@ -2,28 +2,22 @@ package llm
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"strings"
-
-	"github.com/openai/openai-go"
+	"net/http"
 
+	"github.com/flarexio/talkix/config"
 	"github.com/flarexio/talkix/llm/message"
 )
 
 func NewLLM(model string, opts ...Option) (*LLM, error) {
-	client := openai.NewClient()
-
-	model, ok := strings.CutPrefix(model, "openai:")
-	if !ok {
-		return nil, errors.New("model must start with 'openai:'")
+	name, model, err := parseModel(model)
+	if err != nil {
+		return nil, err
 	}
 
 	llm := &LLM{
-		client: client,
-		body: openai.ChatCompletionNewParams{
-			Model: openai.ChatModel(model),
-		},
+		id:    name + ":" + model,
+		model: model,
 	}
 
 	for _, opt := range opts {
@@ -33,14 +27,33 @@ func NewLLM(model string, opts ...Option) (*LLM, error) {
 		}
 	}
 
+	providerOpts := llm.providers[name]
+	providerOpts.HTTPClient = llm.httpClient
+
+	provider, err := newProvider(name, model, providerOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	llm.provider = provider
+
 	return llm, nil
 }
 
 type LLM struct {
-	client openai.Client
-	body   openai.ChatCompletionNewParams
-	prompt PromptTemplate
-	tools  map[string]Tool
+	provider   Provider
+	providers  map[string]ProviderOptions
+	httpClient *http.Client
+	id         string
+	model      string
+	prompt     PromptTemplate
+	schema     Schema
+	tools      map[string]Tool
+	toolList   []Tool
+
+	errorPolicy config.ToolErrorPolicy
+	execution   config.ToolExecutionConfig
+	pricing     config.PricingConfig
 }
 
 type Option interface {
@@ -79,19 +92,7 @@ func (opt *llmWithStructuredOutput) Apply(llm *LLM) error {
 		return errors.New("llm cannot be nil")
 	}
 
-	schemaParam := openai.ResponseFormatJSONSchemaJSONSchemaParam{
-		Name:        opt.schema.Name(),
-		Description: openai.String(opt.schema.Description()),
-		Schema:      opt.schema.Schema(),
-		Strict:      openai.Bool(true),
-	}
-
-	llm.body.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
-		OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
-			JSONSchema: schemaParam,
-		},
-	}
-
+	llm.schema = opt.schema
 	return nil
 }
 
@@ -109,27 +110,109 @@ func (opt *llmWithTools) Apply(llm *LLM) error {
 	}
 
 	toolMap := make(map[string]Tool)
-
-	tools := make([]openai.ChatCompletionToolParam, len(opt.tools))
-	for i, tool := range opt.tools {
-		tools[i] = openai.ChatCompletionToolParam{
-			Function: openai.FunctionDefinitionParam{
-				Name:        tool.Name(),
-				Description: openai.String(tool.Description()),
-				Parameters:  openai.FunctionParameters(tool.Parameters()),
-			},
-		}
-
+	for _, tool := range opt.tools {
 		toolMap[tool.Name()] = tool
 	}
 
-	llm.body.Tools = tools
 	llm.tools = toolMap
+	llm.toolList = opt.tools
 
 	return nil
 }
 
+// WithPricing sets the price table used to estimate the cost of completions.
+func WithPricing(cfg config.PricingConfig) Option {
+	return &llmWithPricing{cfg}
+}
+
+type llmWithPricing struct {
+	cfg config.PricingConfig
+}
+
+func (opt *llmWithPricing) Apply(llm *LLM) error {
+	if llm == nil {
+		return errors.New("llm cannot be nil")
+	}
+
+	llm.pricing = opt.cfg
+	return nil
+}
+
+func (llm *LLM) usage(usage message.Usage) *message.Usage {
+	if price, ok := llm.pricing[llm.id]; ok {
+		usage.Cost = (float64(usage.PromptTokens)*price.Input +
+			float64(usage.CompletionTokens)*price.Output) / 1_000_000
+	}
+
+	return &usage
+}
+
+// WithProviders supplies the endpoint settings for each provider prefix, so
+// that e.g. "ollama:" and "compat:" models can reach their local servers.
+func WithProviders(cfg config.ProvidersConfig) Option {
+	return &llmWithProviders{cfg}
+}
+
+type llmWithProviders struct {
+	cfg config.ProvidersConfig
+}
+
+func (opt *llmWithProviders) Apply(llm *LLM) error {
+	if llm == nil {
+		return errors.New("llm cannot be nil")
+	}
+
+	providers := make(map[string]ProviderOptions)
+	for name, cfg := range opt.cfg {
+		providers[name] = ProviderOptions{
+			BaseURL: cfg.BaseURL,
+			APIKey:  cfg.APIKey,
+		}
+	}
+
+	llm.providers = providers
+	return nil
+}
+
+// WithHTTPClient sets the HTTP client used by the provider, e.g. to record or
+// replay its traffic.
+func WithHTTPClient(client *http.Client) Option {
+	return &llmWithHTTPClient{client}
+}
+
+type llmWithHTTPClient struct {
+	client *http.Client
+}
+
+func (opt *llmWithHTTPClient) Apply(llm *LLM) error {
+	if llm == nil {
+		return errors.New("llm cannot be nil")
+	}
+
+	llm.httpClient = opt.client
+	return nil
+}
+
 func (llm *LLM) Invoke(ctx context.Context, msg string) ([]message.Message, error) {
+	return llm.InvokeMessage(ctx, message.HumanMessage(msg))
+}
+
+// InvokeMessage is Invoke with a human message of its own, e.g. one that
+// carries attachments.
+func (llm *LLM) InvokeMessage(ctx context.Context, msg message.Message) ([]message.Message, error) {
+	msgs, err := llm.buildMessages(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return llm.InvokeWithMessages(ctx, msgs)
+}
+
+func (llm *LLM) InvokeWithMessages(ctx context.Context, msgs []message.Message) ([]message.Message, error) {
+	return llm.run(ctx, msgs, nil)
+}
+
+func (llm *LLM) buildMessages(ctx context.Context, msg message.Message) ([]message.Message, error) {
 	msgs := []message.Message{
 		message.SystemMessage("You are a helpful assistant."),
 	}
@@ -143,178 +226,91 @@ func (llm *LLM) Invoke(ctx context.Context, msg string) ([]message.Message, erro
 		msgs = messages
 	}
 
-	if msg != "" {
-		msgs = append(msgs, message.HumanMessage(msg))
+	if msg.Content != "" || len(msg.Attachments) > 0 {
+		msgs = append(msgs, msg)
 	}
 
-	return llm.InvokeWithMessages(ctx, msgs)
+	return msgs, nil
 }
 
-func (llm *LLM) InvokeWithMessages(ctx context.Context, msgs []message.Message) ([]message.Message, error) {
-	messages, err := convertToOpenAIMessages(msgs)
-	if err != nil {
-		return nil, err
+// run executes the tool loop. When emit is not nil, content deltas, tool calls
+// and tool results are reported through it as they happen.
+func (llm *LLM) run(ctx context.Context, msgs []message.Message, emit func(Event)) ([]message.Message, error) {
+	messages := make([]message.Message, len(msgs))
+	copy(messages, msgs)
+
+	req := &Request{
+		Model:  llm.model,
+		Tools:  llm.toolList,
+		Schema: llm.schema,
 	}
 
-	body := llm.body
-	body.Messages = messages
+	failures := newToolFailures()
 
 	maxIterations := 10
 	for i := 0; i < maxIterations; i++ {
-		body.Messages = messages
+		req.Messages = messages
 
-		completion, err := llm.client.Chat.Completions.New(ctx, body)
+		resp, err := llm.complete(ctx, req, emit)
 		if err != nil {
 			return nil, err
 		}
 
-		if len(completion.Choices) == 0 {
-			return nil, errors.New("no choices returned from LLM")
-		}
-
-		choice := completion.Choices[0]
-
-		if toolsCalls := choice.Message.ToolCalls; len(toolsCalls) > 0 {
-			messages = append(messages, choice.Message.ToParam())
-
-			for _, toolCall := range toolsCalls {
-				toolName := toolCall.Function.Name
-				tool, ok := llm.tools[toolName]
-				if !ok {
-					return nil, errors.New("unknown tool called: " + toolName)
-				}
+		msg := resp.Message
+		msg.Model = llm.id
+		msg.Usage = llm.usage(resp.Usage)
 
-				var params map[string]any
-				if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &params); err != nil {
-					return nil, err
-				}
+		messages = append(messages, msg)
 
-				// Call the tool with the parameters
-				result, err := tool.Call(ctx, params)
-				if err != nil {
-					return nil, err
+		if toolCalls := msg.ToolCalls; len(toolCalls) > 0 {
+			if emit != nil {
+				for _, toolCall := range toolCalls {
+					emit(Event{Type: EventToolCall, ToolCall: &toolCall})
 				}
-
-				messages = append(messages, openai.ToolMessage(result, toolCall.ID))
 			}
 
-			continue // re-evaluate with updated messages
-		}
-
-		messages = append(messages, choice.Message.ToParam())
-
-		msgs := make([]message.Message, len(messages))
-		for i, msg := range messages {
-			m, err := convertToMessage(msg)
+			results, err := llm.callTools(ctx, toolCalls, failures)
 			if err != nil {
 				return nil, err
 			}
 
-			msgs[i] = m
-		}
-
-		return msgs, nil
-	}
-
-	return nil, errors.New("max iterations reached without valid response")
-}
-
-func convertToOpenAIMessages(msgs []message.Message) ([]openai.ChatCompletionMessageParamUnion, error) {
-	messages := make([]openai.ChatCompletionMessageParamUnion, len(msgs))
-	for i, msg := range msgs {
-		var m openai.ChatCompletionMessageParamUnion
-
-		switch msg.Role {
-		case message.RoleSystem:
-			m = openai.SystemMessage(msg.Content)
-
-		case message.RoleHuman:
-			m = openai.UserMessage(msg.Content)
-
-		case message.RoleAI:
-			m = openai.AssistantMessage(msg.Content)
-
-			toolCalls := make([]openai.ChatCompletionMessageToolCallParam, len(msg.ToolCalls))
-			for j, tc := range msg.ToolCalls {
-				bs, err := json.Marshal(tc.Arguments)
-				if err != nil {
-					return nil, err
-				}
-
-				toolCall := openai.ChatCompletionMessageToolCallParam{
-					ID: tc.ID,
-					Function: openai.ChatCompletionMessageToolCallFunctionParam{
-						Name:      tc.Name,
-						Arguments: string(bs),
-					},
+			if emit != nil {
+				for _, result := range results {
+					emit(Event{Type: EventToolResult, Message: &result})
 				}
-
-				toolCalls[j] = toolCall
 			}
 
-		case message.RoleTool:
-			m = openai.ToolMessage(msg.Content, msg.ToolCallID)
+			messages = append(messages, results...)
 
-		default:
-			continue // skip unknown roles
+			continue // re-evaluate with updated messages
 		}
 
-		messages[i] = m
+		return messages, nil
 	}
 
-	return messages, nil
+	return nil, errors.New("max iterations reached without valid response")
 }
 
-func convertToMessage(msg openai.ChatCompletionMessageParamUnion) (message.Message, error) {
-	var m message.Message
-
-	switch {
-	case msg.OfSystem != nil:
-		m = message.Message{
-			Role:    message.RoleSystem,
-			Content: msg.OfSystem.Content.OfString.Value,
-		}
-
-	case msg.OfUser != nil:
-		m = message.Message{
-			Role:    message.RoleHuman,
-			Content: msg.OfUser.Content.OfString.Value,
-		}
-
-	case msg.OfAssistant != nil:
-		m = message.Message{
-			Role:    message.RoleAI,
-			Content: msg.OfAssistant.Content.OfString.Value,
-		}
-
-		toolCalls := make([]message.ToolCall, len(msg.OfAssistant.ToolCalls))
-		for i, tc := range msg.OfAssistant.ToolCalls {
-			args := make(map[string]any)
-
-			err := json.Unmarshal([]byte(tc.Function.Arguments), &args)
-			if err != nil {
-				return message.Message{}, err
-			}
-
-			toolCalls[i] = message.ToolCall{
-				ID:        tc.ID,
-				Name:      tc.Function.Name,
-				Arguments: args,
-			}
-		}
+func (llm *LLM) complete(ctx context.Context, req *Request, emit func(Event)) (*Response, error) {
+	if emit == nil {
+		return llm.provider.Complete(ctx, req)
+	}
 
-		m.ToolCalls = toolCalls
+	if p, ok := llm.provider.(StreamingProvider); ok {
+		return p.CompleteStream(ctx, req, func(delta string) {
+			emit(Event{Type: EventDelta, Delta: delta})
+		})
+	}
 
-	case msg.OfTool != nil:
-		m = message.Message{
-			Role:       message.RoleTool,
-			Content:    msg.OfTool.Content.OfString.Value,
-			ToolCallID: msg.OfTool.ToolCallID,
-		}
+	// Providers without streaming support deliver the content in one piece.
+	resp, err := llm.provider.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
 
-	default:
-		return message.Message{}, errors.New("unknown message type")
+	if content := resp.Message.Content; content != "" {
+		emit(Event{Type: EventDelta, Delta: content})
 	}
 
-	return m, nil
+	return resp, nil
 }
@@ -3,6 +3,7 @@ package llm
 import (
 	"context"
 	"encoding/json"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -11,6 +12,11 @@ import (
 func TestLLM(t *testing.T) {
 	assert := assert.New(t)
 
+	if _, ok := os.LookupEnv("OPENAI_API_KEY"); !ok {
+		t.Skip("OPENAI_API_KEY environment variable is not set")
+		return
+	}
+
 	llm, err := NewLLM("openai:gpt-4.1-mini")
 	if err != nil {
 		assert.Fail(err.Error())
@@ -58,6 +64,11 @@ func (e example) Schema() map[string]any {
 func TestLLMWithStructuredOutput(t *testing.T) {
 	assert := assert.New(t)
 
+	if _, ok := os.LookupEnv("OPENAI_API_KEY"); !ok {
+		t.Skip("OPENAI_API_KEY environment variable is not set")
+		return
+	}
+
 	llm, err := NewLLM("openai:gpt-4.1-mini",
 		WithStructuredOutput(example{}),
 	)
@@ -1,6 +1,7 @@
 package message
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 )
@@ -34,6 +35,20 @@ type Message struct {
 	Content    string     `json:"content"`
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string     `json:"tool_call_id,omitempty"`
+
+	// IsError marks a tool message that reports the failure of its call
+	// instead of a result.
+	IsError bool `json:"is_error,omitempty"`
+
+	// Name is the speaker of a human message in a group chat.
+	Name string `json:"name,omitempty"`
+
+	// Attachments are the media sent along with a human message.
+	Attachments []Attachment `json:"attachments,omitempty"`
+
+	// Model and Usage are set on AI messages produced by a completion.
+	Model string `json:"model,omitempty"`
+	Usage *Usage `json:"usage,omitempty"`
 }
 
 func (m *Message) PrettyFormat() string {
@@ -41,6 +56,10 @@ func (m *Message) PrettyFormat() string {
 
 	output += fmt.Sprintf("--- %sMessage ---\n", m.Role)
 
+	if m.Name != "" {
+		output += fmt.Sprintf("Name: %s\n", m.Name)
+	}
+
 	if m.ToolCallID != "" {
 		output += fmt.Sprintf("Tool Call ID: %s\n", m.ToolCallID)
 	}
@@ -57,6 +76,10 @@ func (m *Message) PrettyFormat() string {
 		}
 	}
 
+	for _, a := range m.Attachments {
+		output += a.String() + "\n"
+	}
+
 	if len(m.ToolCalls) > 0 {
 		output += "Tool Calls:\n"
 		for _, tc := range m.ToolCalls {
@@ -75,10 +98,71 @@ func (m *Message) PrettyFormat() string {
 	return output
 }
 
+type AttachmentType string
+
+const (
+	AttachmentImage AttachmentType = "image"
+	AttachmentAudio AttachmentType = "audio"
+	AttachmentFile  AttachmentType = "file"
+)
+
+// Attachment is media sent with a message, either inline as Data or by URL.
+// The data is not kept in the history; a replayed attachment without data or
+// URL only shows up as its String.
+type Attachment struct {
+	Type     AttachmentType `json:"type"`
+	MIMEType string         `json:"mime_type,omitempty"`
+	Name     string         `json:"name,omitempty"`
+	URL      string         `json:"url,omitempty"`
+	Data     []byte         `json:"data,omitempty"`
+}
+
+// Source returns the URL of the attachment, or its data as a data URL. It is
+// empty when the attachment has neither.
+func (a Attachment) Source() string {
+	if a.URL != "" {
+		return a.URL
+	}
+
+	if len(a.Data) == 0 {
+		return ""
+	}
+
+	return "data:" + a.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(a.Data)
+}
+
+// WithoutData returns the attachment as it is stored in the history.
+func (a Attachment) WithoutData() Attachment {
+	a.Data = nil
+	return a
+}
+
+func (a Attachment) String() string {
+	if a.Name != "" {
+		return fmt.Sprintf("[%s: %s]", a.Type, a.Name)
+	}
+
+	return fmt.Sprintf("[%s]", a.Type)
+}
+
 type ToolCall struct {
 	ID        string         `json:"id"`
 	Name      string         `json:"name"`
 	Arguments map[string]any `json:"args"`
+
+	// RawArguments keeps the arguments as produced by the model when they
+	// could not be parsed as a JSON object.
+	RawArguments string `json:"raw_args,omitempty"`
+}
+
+// SpokenContent prefixes the content with the speaker, so that the turns of
+// the different members of a group can be told apart.
+func (m Message) SpokenContent() string {
+	if m.Name == "" {
+		return m.Content
+	}
+
+	return m.Name + ": " + m.Content
 }
 
 func SystemMessage(content string) Message {
@@ -88,10 +172,11 @@ func SystemMessage(content string) Message {
 	}
 }
 
-func HumanMessage(content string) Message {
+func HumanMessage(content string, attachments ...Attachment) Message {
 	return Message{
-		Role:    RoleHuman,
-		Content: content,
+		Role:        RoleHuman,
+		Content:     content,
+		Attachments: attachments,
 	}
 }
 
@@ -102,3 +187,18 @@ func AIMessage(content string, toolCalls ...ToolCall) Message {
 		ToolCalls: toolCalls,
 	}
 }
+
+func ToolMessage(content string, toolCallID string) Message {
+	return Message{
+		Role:       RoleTool,
+		Content:    content,
+		ToolCallID: toolCallID,
+	}
+}
+
+// ToolErrorMessage reports the failure of a tool call to the model.
+func ToolErrorMessage(content string, toolCallID string) Message {
+	m := ToolMessage(content, toolCallID)
+	m.IsError = true
+	return m
+}
@@ -0,0 +1,42 @@
+package message
+
+// Usage is the token consumption of one or more completions together with
+// the cost estimated from the configured price table.
+type Usage struct {
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+func (u *Usage) Add(other Usage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+	u.Cost += other.Cost
+}
+
+func (u Usage) Sub(other Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens - other.PromptTokens,
+		CompletionTokens: u.CompletionTokens - other.CompletionTokens,
+		TotalTokens:      u.TotalTokens - other.TotalTokens,
+		Cost:             u.Cost - other.Cost,
+	}
+}
+
+func (u Usage) IsZero() bool {
+	return u == Usage{}
+}
+
+// UsageByTool breaks usage down by the tools whose results were part of the
+// prompt of a completion.
+type UsageByTool map[string]Usage
+
+func (m UsageByTool) Add(other UsageByTool) {
+	for name, usage := range other {
+		total := m[name]
+		total.Add(usage)
+		m[name] = total
+	}
+}
@@ -0,0 +1,326 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/flarexio/talkix/llm/message"
+)
+
+const DefaultOllamaBaseURL = "http://localhost:11434/v1"
+
+func init() {
+	RegisterProvider("openai", NewOpenAIProvider)
+	RegisterProvider("ollama", NewOllamaProvider)
+	RegisterProvider("compat", NewCompatProvider)
+}
+
+// NewOpenAIProvider talks to the OpenAI API. The API key and base URL fall
+// back to the OPENAI_API_KEY and OPENAI_BASE_URL environment variables.
+func NewOpenAIProvider(model string, opts ProviderOptions) (Provider, error) {
+	reqOpts := make([]option.RequestOption, 0)
+
+	if opts.BaseURL != "" {
+		reqOpts = append(reqOpts, option.WithBaseURL(opts.BaseURL))
+	}
+
+	if opts.APIKey != "" {
+		reqOpts = append(reqOpts, option.WithAPIKey(opts.APIKey))
+	}
+
+	if opts.HTTPClient != nil {
+		reqOpts = append(reqOpts, option.WithHTTPClient(opts.HTTPClient))
+	}
+
+	return &openAIProvider{
+		client: openai.NewClient(reqOpts...),
+	}, nil
+}
+
+// NewOllamaProvider talks to the OpenAI-compatible endpoint of an Ollama
+// server, which listens on localhost by default and ignores the API key.
+func NewOllamaProvider(model string, opts ProviderOptions) (Provider, error) {
+	if opts.BaseURL == "" {
+		opts.BaseURL = DefaultOllamaBaseURL
+	}
+
+	if opts.APIKey == "" {
+		opts.APIKey = "ollama"
+	}
+
+	return NewOpenAIProvider(model, opts)
+}
+
+// NewCompatProvider talks to any other OpenAI-compatible server, such as vLLM
+// or the llama.cpp server. The base URL has to be configured explicitly.
+func NewCompatProvider(model string, opts ProviderOptions) (Provider, error) {
+	if opts.BaseURL == "" {
+		return nil, errors.New("compat provider requires a base URL")
+	}
+
+	if opts.APIKey == "" {
+		opts.APIKey = "compat"
+	}
+
+	return NewOpenAIProvider(model, opts)
+}
+
+type openAIProvider struct {
+	client openai.Client
+}
+
+func (p *openAIProvider) Complete(ctx context.Context, req *Request) (*Response, error) {
+	body, err := newOpenAIParams(req)
+	if err != nil {
+		return nil, err
+	}
+
+	completion, err := p.client.Chat.Completions.New(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return newOpenAIResponse(completion)
+}
+
+func (p *openAIProvider) CompleteStream(ctx context.Context, req *Request, onDelta func(delta string)) (*Response, error) {
+	body, err := newOpenAIParams(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body.StreamOptions = openai.ChatCompletionStreamOptionsParam{
+		IncludeUsage: openai.Bool(true),
+	}
+
+	stream := p.client.Chat.Completions.NewStreaming(ctx, body)
+	defer stream.Close()
+
+	acc := openai.ChatCompletionAccumulator{}
+	for stream.Next() {
+		chunk := stream.Current()
+		acc.AddChunk(chunk)
+
+		if len(chunk.Choices) > 0 {
+			if delta := chunk.Choices[0].Delta.Content; delta != "" {
+				onDelta(delta)
+			}
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+
+	return newOpenAIResponse(&acc.ChatCompletion)
+}
+
+func newOpenAIParams(req *Request) (openai.ChatCompletionNewParams, error) {
+	messages, err := convertToOpenAIMessages(req.Messages)
+	if err != nil {
+		return openai.ChatCompletionNewParams{}, err
+	}
+
+	body := openai.ChatCompletionNewParams{
+		Model:    openai.ChatModel(req.Model),
+		Messages: messages,
+	}
+
+	if len(req.Tools) > 0 {
+		tools := make([]openai.ChatCompletionToolParam, len(req.Tools))
+		for i, tool := range req.Tools {
+			tools[i] = openai.ChatCompletionToolParam{
+				Function: openai.FunctionDefinitionParam{
+					Name:        tool.Name(),
+					Description: openai.String(tool.Description()),
+					Parameters:  openai.FunctionParameters(tool.Parameters()),
+				},
+			}
+		}
+
+		body.Tools = tools
+	}
+
+	if schema := req.Schema; schema != nil {
+		schemaParam := openai.ResponseFormatJSONSchemaJSONSchemaParam{
+			Name:        schema.Name(),
+			Description: openai.String(schema.Description()),
+			Schema:      schema.Schema(),
+			Strict:      openai.Bool(true),
+		}
+
+		body.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
+				JSONSchema: schemaParam,
+			},
+		}
+	}
+
+	return body, nil
+}
+
+func newOpenAIResponse(completion *openai.ChatCompletion) (*Response, error) {
+	if len(completion.Choices) == 0 {
+		return nil, errors.New("no choices returned from LLM")
+	}
+
+	msg, err := convertToMessage(completion.Choices[0].Message)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := completion.Usage
+
+	return &Response{
+		Message: msg,
+		Usage: message.Usage{
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+			TotalTokens:      usage.TotalTokens,
+		},
+	}, nil
+}
+
+// openAIContentParts sends the images of a message as image parts. The other
+// attachments, and the images that are no longer available, are named in the
+// text.
+func openAIContentParts(msg message.Message) []openai.ChatCompletionContentPartUnionParam {
+	texts := make([]string, 0)
+	if msg.Content != "" {
+		texts = append(texts, msg.SpokenContent())
+	}
+
+	images := make([]openai.ChatCompletionContentPartUnionParam, 0)
+	for _, a := range msg.Attachments {
+		source := a.Source()
+		if a.Type != message.AttachmentImage || source == "" {
+			texts = append(texts, a.String())
+			continue
+		}
+
+		images = append(images, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{
+			URL: source,
+		}))
+	}
+
+	parts := make([]openai.ChatCompletionContentPartUnionParam, 0, len(images)+1)
+	if len(texts) > 0 {
+		parts = append(parts, openai.TextContentPart(strings.Join(texts, "\n")))
+	}
+
+	return append(parts, images...)
+}
+
+func convertToOpenAIMessages(msgs []message.Message) ([]openai.ChatCompletionMessageParamUnion, error) {
+	if err := checkToolResults(msgs); err != nil {
+		return nil, err
+	}
+
+	messages := make([]openai.ChatCompletionMessageParamUnion, 0, len(msgs))
+	for _, msg := range msgs {
+		var m openai.ChatCompletionMessageParamUnion
+
+		switch msg.Role {
+		case message.RoleSystem:
+			m = openai.SystemMessage(msg.Content)
+
+		case message.RoleHuman:
+			if len(msg.Attachments) == 0 {
+				m = openai.UserMessage(msg.SpokenContent())
+				break
+			}
+
+			m = openai.UserMessage(openAIContentParts(msg))
+
+		case message.RoleAI:
+			m = openai.AssistantMessage(msg.Content)
+
+			if len(msg.ToolCalls) == 0 {
+				break
+			}
+
+			toolCalls := make([]openai.ChatCompletionMessageToolCallParam, len(msg.ToolCalls))
+			for j, tc := range msg.ToolCalls {
+				args := tc.RawArguments
+				if args == "" {
+					arguments := tc.Arguments
+					if arguments == nil {
+						arguments = make(map[string]any)
+					}
+
+					bs, err := json.Marshal(arguments)
+					if err != nil {
+						return nil, err
+					}
+
+					args = string(bs)
+				}
+
+				toolCalls[j] = openai.ChatCompletionMessageToolCallParam{
+					ID: tc.ID,
+					Function: openai.ChatCompletionMessageToolCallFunctionParam{
+						Name:      tc.Name,
+						Arguments: args,
+					},
+				}
+			}
+
+			m.OfAssistant.ToolCalls = toolCalls
+
+			// A tool call turn usually has no text, which must then be
+			// omitted instead of being sent as an empty string.
+			if msg.Content == "" {
+				m.OfAssistant.Content = openai.ChatCompletionAssistantMessageParamContentUnion{}
+			}
+
+		case message.RoleTool:
+			m = openai.ToolMessage(msg.Content, msg.ToolCallID)
+
+		default:
+			return nil, errors.New("unknown message role: " + string(msg.Role))
+		}
+
+		messages = append(messages, m)
+	}
+
+	return messages, nil
+}
+
+func convertToMessage(msg openai.ChatCompletionMessage) (message.Message, error) {
+	m := message.Message{
+		Role:    message.RoleAI,
+		Content: msg.Content,
+	}
+
+	if len(msg.ToolCalls) == 0 {
+		return m, nil
+	}
+
+	toolCalls := make([]message.ToolCall, len(msg.ToolCalls))
+	for i, tc := range msg.ToolCalls {
+		toolCall := message.ToolCall{
+			ID:   tc.ID,
+			Name: tc.Function.Name,
+		}
+
+		// Invalid arguments are kept as they are, so that the tool loop
+		// can report the problem back to the model.
+		args := make(map[string]any)
+		if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
+			toolCall.RawArguments = tc.Function.Arguments
+		} else {
+			toolCall.Arguments = args
+		}
+
+		toolCalls[i] = toolCall
+	}
+
+	m.ToolCalls = toolCalls
+
+	return m, nil
+}
@@ -0,0 +1,225 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/flarexio/talkix/llm/message"
+)
+
+type openAIRoundTripTestSuite struct {
+	suite.Suite
+	history []message.Message
+}
+
+func (suite *openAIRoundTripTestSuite) SetupTest() {
+	suite.history = []message.Message{
+		message.SystemMessage("You are a helpful assistant."),
+		message.HumanMessage("What is the weather in Taipei and Tokyo?"),
+		message.AIMessage("",
+			message.ToolCall{
+				ID:        "call_1",
+				Name:      "get_weather",
+				Arguments: map[string]any{"city": "Taipei"},
+			},
+			message.ToolCall{
+				ID:           "call_2",
+				Name:         "get_weather",
+				RawArguments: `{"city": "Tokyo"`,
+			},
+		),
+		message.ToolMessage(`{"temperature":30}`, "call_1"),
+		message.ToolMessage(`{"error":{"type":"invalid_arguments"}}`, "call_2"),
+		message.AIMessage("Taipei is 30°C. Which Tokyo did you mean?"),
+	}
+}
+
+func (suite *openAIRoundTripTestSuite) encode(msgs []message.Message) []map[string]any {
+	params, err := convertToOpenAIMessages(msgs)
+	if err != nil {
+		suite.FailNow(err.Error())
+	}
+
+	bs, err := json.Marshal(params)
+	if err != nil {
+		suite.FailNow(err.Error())
+	}
+
+	var result []map[string]any
+	if err := json.Unmarshal(bs, &result); err != nil {
+		suite.FailNow(err.Error())
+	}
+
+	return result
+}
+
+func (suite *openAIRoundTripTestSuite) TestRoles() {
+	encoded := suite.encode(suite.history)
+	suite.Len(encoded, len(suite.history))
+
+	roles := make([]string, len(encoded))
+	for i, m := range encoded {
+		roles[i], _ = m["role"].(string)
+	}
+
+	suite.Equal([]string{"system", "user", "assistant", "tool", "tool", "assistant"}, roles)
+}
+
+func (suite *openAIRoundTripTestSuite) TestToolCallLinkage() {
+	encoded := suite.encode(suite.history)
+
+	toolCalls, ok := encoded[2]["tool_calls"].([]any)
+	if !suite.True(ok) || !suite.Len(toolCalls, 2) {
+		return
+	}
+
+	for i, tc := range toolCalls {
+		id := tc.(map[string]any)["id"]
+		suite.Equal(id, encoded[3+i]["tool_call_id"])
+	}
+
+	// A tool call turn without text omits the content.
+	suite.NotContains(encoded[2], "content")
+}
+
+func (suite *openAIRoundTripTestSuite) TestAssistantRoundTrip() {
+	params, err := convertToOpenAIMessages(suite.history[2:3])
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	bs, err := json.Marshal(params[0])
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	var completion openai.ChatCompletionMessage
+	if err := json.Unmarshal(bs, &completion); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	msg, err := convertToMessage(completion)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(suite.history[2], msg)
+}
+
+func (suite *openAIRoundTripTestSuite) TestUnknownRole() {
+	msgs := append(suite.history, message.Message{Role: "narrator", Content: "Meanwhile..."})
+
+	_, err := convertToOpenAIMessages(msgs)
+	suite.ErrorContains(err, "unknown message role")
+}
+
+func (suite *openAIRoundTripTestSuite) TestOrphanToolResult() {
+	msgs := []message.Message{
+		message.HumanMessage("What is the weather?"),
+		message.ToolMessage(`{"temperature":30}`, "call_1"),
+	}
+
+	_, err := convertToOpenAIMessages(msgs)
+	suite.ErrorContains(err, "without a matching tool call")
+}
+
+func (suite *openAIRoundTripTestSuite) TestMissingToolResult() {
+	msgs := append([]message.Message{}, suite.history[:4]...)
+	msgs = append(msgs, message.HumanMessage("And Tokyo?"))
+
+	_, err := convertToOpenAIMessages(msgs)
+	suite.ErrorContains(err, "without a result: call_2")
+}
+
+func (suite *openAIRoundTripTestSuite) TestAttachments() {
+	msgs := []message.Message{
+		message.HumanMessage("What is this?",
+			message.Attachment{Type: message.AttachmentImage, MIMEType: "image/png", Data: []byte("png")},
+			message.Attachment{Type: message.AttachmentImage, MIMEType: "image/png"},
+		),
+	}
+
+	encoded := suite.encode(msgs)
+
+	parts, ok := encoded[0]["content"].([]any)
+	if !suite.True(ok) || !suite.Len(parts, 2) {
+		return
+	}
+
+	// An image that is no longer available is named in the text.
+	text := parts[0].(map[string]any)
+	suite.Equal("What is this?\n[image]", text["text"])
+
+	image := parts[1].(map[string]any)
+	suite.Equal("image_url", image["type"])
+	suite.Equal("data:image/png;base64,cG5n", image["image_url"].(map[string]any)["url"])
+}
+
+// TestReplayHistory sends a stored history with tool context to a stub of
+// the chat completions API and checks what arrives on the wire.
+func (suite *openAIRoundTripTestSuite) TestReplayHistory() {
+	var received struct {
+		Messages []map[string]any `json:"messages"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"id": "chatcmpl-test",
+			"object": "chat.completion",
+			"model": "gpt-4.1-mini",
+			"choices": [{
+				"index": 0,
+				"finish_reason": "stop",
+				"message": {"role": "assistant", "content": "You asked about Taipei and Tokyo."}
+			}]
+		}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewOpenAIProvider("gpt-4.1-mini", ProviderOptions{
+		BaseURL: server.URL,
+		APIKey:  "test",
+	})
+
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	msgs := append(suite.history, message.HumanMessage("What did I ask?"))
+
+	ctx := context.Background()
+	resp, err := provider.Complete(ctx, &Request{
+		Model:    "gpt-4.1-mini",
+		Messages: msgs,
+	})
+
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal("You asked about Taipei and Tokyo.", resp.Message.Content)
+	suite.Len(received.Messages, len(msgs))
+	suite.Equal("call_2", received.Messages[4]["tool_call_id"])
+}
+
+func TestOpenAIRoundTripTestSuite(t *testing.T) {
+	suite.Run(t, new(openAIRoundTripTestSuite))
+}
@@ -0,0 +1,116 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/flarexio/talkix/llm/message"
+)
+
+// Provider sends a single completion request to an LLM backend. The tool loop
+// lives in LLM, so a provider only has to translate messages, tools and the
+// structured output schema into its own wire format.
+type Provider interface {
+	Complete(ctx context.Context, req *Request) (*Response, error)
+}
+
+// StreamingProvider is implemented by providers that can deliver the content
+// of a completion incrementally. The final response is still returned in full.
+type StreamingProvider interface {
+	Provider
+	CompleteStream(ctx context.Context, req *Request, onDelta func(delta string)) (*Response, error)
+}
+
+type Request struct {
+	Model    string
+	Messages []message.Message
+	Tools    []Tool
+	Schema   Schema
+}
+
+type Response struct {
+	Message message.Message
+	Usage   message.Usage
+}
+
+type ProviderOptions struct {
+	BaseURL    string
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+type ProviderFactory func(model string, opts ProviderOptions) (Provider, error)
+
+var (
+	providers   = make(map[string]ProviderFactory)
+	providersMu sync.RWMutex
+)
+
+// RegisterProvider makes a provider available under the given model prefix,
+// e.g. "openai" for "openai:gpt-4.1-mini".
+func RegisterProvider(name string, factory ProviderFactory) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	providers[name] = factory
+}
+
+func newProvider(name string, model string, opts ProviderOptions) (Provider, error) {
+	providersMu.RLock()
+	factory, ok := providers[name]
+	providersMu.RUnlock()
+
+	if !ok {
+		return nil, errors.New("unsupported provider: " + name)
+	}
+
+	return factory(model, opts)
+}
+
+func parseModel(model string) (provider string, name string, err error) {
+	provider, name, ok := strings.Cut(model, ":")
+	if !ok || provider == "" || name == "" {
+		return "", "", errors.New("model must be in the form '<provider>:<model>'")
+	}
+
+	return provider, name, nil
+}
+
+// checkToolResults verifies that every tool result answers a tool call of the
+// preceding assistant message, and that every tool call is answered before
+// the conversation goes on. Providers reject histories that break either.
+func checkToolResults(msgs []message.Message) error {
+	pending := make(map[string]bool)
+
+	for _, msg := range msgs {
+		if msg.Role == message.RoleTool {
+			if !pending[msg.ToolCallID] {
+				return errors.New("tool result without a matching tool call: " + msg.ToolCallID)
+			}
+
+			delete(pending, msg.ToolCallID)
+			continue
+		}
+
+		for id := range pending {
+			return errors.New("tool call without a result: " + id)
+		}
+
+		if msg.Role != message.RoleAI {
+			continue
+		}
+
+		for _, tc := range msg.ToolCalls {
+			if tc.ID == "" {
+				return errors.New("tool call without an ID: " + tc.Name)
+			}
+
+			pending[tc.ID] = true
+		}
+	}
+
+	return nil
+}
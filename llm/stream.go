@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"context"
+
+	"github.com/flarexio/talkix/llm/message"
+)
+
+type EventType string
+
+const (
+	EventDelta      EventType = "delta"
+	EventToolCall   EventType = "tool_call"
+	EventToolResult EventType = "tool_result"
+	EventDone       EventType = "done"
+	EventError      EventType = "error"
+)
+
+// Event is emitted while a streaming invocation is running. The last event on
+// the channel is always either EventDone, carrying the full message history,
+// or EventError.
+type Event struct {
+	Type     EventType
+	Delta    string
+	ToolCall *message.ToolCall
+	Message  *message.Message
+	Messages []message.Message
+	Err      error
+}
+
+func (llm *LLM) InvokeStream(ctx context.Context, msg string) <-chan Event {
+	return llm.InvokeMessageStream(ctx, message.HumanMessage(msg))
+}
+
+func (llm *LLM) InvokeMessageStream(ctx context.Context, msg message.Message) <-chan Event {
+	msgs, err := llm.buildMessages(ctx, msg)
+	if err != nil {
+		events := make(chan Event, 1)
+		events <- Event{Type: EventError, Err: err}
+		close(events)
+		return events
+	}
+
+	return llm.InvokeWithMessagesStream(ctx, msgs)
+}
+
+func (llm *LLM) InvokeWithMessagesStream(ctx context.Context, msgs []message.Message) <-chan Event {
+	events := make(chan Event)
+
+	go func() {
+		defer close(events)
+
+		emit := func(e Event) {
+			select {
+			case events <- e:
+			case <-ctx.Done():
+			}
+		}
+
+		messages, err := llm.run(ctx, msgs, emit)
+		if err != nil {
+			emit(Event{Type: EventError, Err: err})
+			return
+		}
+
+		emit(Event{Type: EventDone, Messages: messages})
+	}()
+
+	return events
+}
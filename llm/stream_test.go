@@ -0,0 +1,77 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix/llm/message"
+)
+
+type stubProvider struct {
+	responses []message.Message
+}
+
+func (p *stubProvider) Complete(ctx context.Context, req *Request) (*Response, error) {
+	msg := p.responses[0]
+	p.responses = p.responses[1:]
+
+	return &Response{Message: msg}, nil
+}
+
+type echoTool struct{}
+
+func (t echoTool) Name() string {
+	return "echo"
+}
+
+func (t echoTool) Description() string {
+	return "Echo the given text"
+}
+
+func (t echoTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"text": map[string]any{"type": "string"},
+		},
+	}
+}
+
+func (t echoTool) Call(ctx context.Context, params map[string]any) (string, error) {
+	text, _ := params["text"].(string)
+	return text, nil
+}
+
+func TestInvokeStream(t *testing.T) {
+	assert := assert.New(t)
+
+	llm := &LLM{
+		provider: &stubProvider{
+			responses: []message.Message{
+				message.AIMessage("", message.ToolCall{
+					ID:        "call_1",
+					Name:      "echo",
+					Arguments: map[string]any{"text": "hello"},
+				}),
+				message.AIMessage("The tool said hello."),
+			},
+		},
+		tools:    map[string]Tool{"echo": echoTool{}},
+		toolList: []Tool{echoTool{}},
+	}
+
+	ctx := context.Background()
+
+	types := make([]EventType, 0)
+	var last Event
+	for e := range llm.InvokeStream(ctx, "Say hello") {
+		types = append(types, e.Type)
+		last = e
+	}
+
+	assert.Equal([]EventType{EventToolCall, EventToolResult, EventDelta, EventDone}, types)
+	assert.Len(last.Messages, 5)
+	assert.Equal("The tool said hello.", last.Messages[4].Content)
+}
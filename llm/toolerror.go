@@ -0,0 +1,186 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/flarexio/talkix/config"
+	"github.com/flarexio/talkix/llm/message"
+)
+
+var ErrToolGaveUp = errors.New("tool failed too many times")
+
+type ToolErrorType string
+
+const (
+	ToolErrorCallFailed       ToolErrorType = "call_failed"
+	ToolErrorUnknownTool      ToolErrorType = "unknown_tool"
+	ToolErrorInvalidArguments ToolErrorType = "invalid_arguments"
+	ToolErrorUnavailable      ToolErrorType = "tool_unavailable"
+)
+
+// ToolError is returned to the model as the content of a tool message, so
+// that it can correct its call or answer without the tool.
+type ToolError struct {
+	Type     ToolErrorType `json:"type"`
+	Tool     string        `json:"tool"`
+	Message  string        `json:"message"`
+	Attempts int           `json:"attempts,omitempty"`
+}
+
+func (e *ToolError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.Tool, e.Type, e.Message)
+}
+
+func (e *ToolError) Content() string {
+	bs, err := json.Marshal(map[string]any{"error": e})
+	if err != nil {
+		return e.Error()
+	}
+
+	return string(bs)
+}
+
+const (
+	DefaultToolMaxFailures = 3
+	toolRetryBackoff       = 500 * time.Millisecond
+)
+
+func WithToolErrorPolicy(policy config.ToolErrorPolicy) Option {
+	return &llmWithToolErrorPolicy{policy}
+}
+
+type llmWithToolErrorPolicy struct {
+	policy config.ToolErrorPolicy
+}
+
+func (opt *llmWithToolErrorPolicy) Apply(llm *LLM) error {
+	if llm == nil {
+		return errors.New("llm cannot be nil")
+	}
+
+	switch opt.policy.GiveUp {
+	case "", config.GiveUpReport, config.GiveUpAbort:
+	default:
+		return errors.New("invalid give up action: " + string(opt.policy.GiveUp))
+	}
+
+	llm.errorPolicy = opt.policy
+	return nil
+}
+
+// limits returns the retries for a single call and the number of failed calls
+// after which the tool is given up for the rest of the invocation.
+func (llm *LLM) limits(name string) (retries int, maxFailures int) {
+	policy := llm.errorPolicy
+
+	retries = policy.Retries
+	maxFailures = policy.MaxFailures
+
+	if limits, ok := policy.Tools[name]; ok {
+		retries = limits.Retries
+		maxFailures = limits.MaxFailures
+	}
+
+	if maxFailures <= 0 {
+		maxFailures = DefaultToolMaxFailures
+	}
+
+	return retries, maxFailures
+}
+
+// callTool runs a single tool call and always produces a tool message. Errors
+// are only returned when the invocation has to be aborted, either because the
+// context is done or because the error policy says to give up.
+func (llm *LLM) callTool(ctx context.Context, toolCall message.ToolCall, failures *toolFailures) (message.Message, error) {
+	result, toolErr := llm.tryTool(ctx, toolCall, failures)
+	if toolErr == nil {
+		return message.ToolMessage(result, toolCall.ID), nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return message.Message{}, err
+	}
+
+	count := failures.Get(toolCall.Name)
+	if toolErr.Type != ToolErrorUnavailable {
+		count = failures.Inc(toolCall.Name)
+	}
+
+	_, maxFailures := llm.limits(toolCall.Name)
+	if count >= maxFailures && llm.errorPolicy.GiveUp == config.GiveUpAbort {
+		return message.Message{}, fmt.Errorf("%w: %w", ErrToolGaveUp, toolErr)
+	}
+
+	return message.ToolErrorMessage(toolErr.Content(), toolCall.ID), nil
+}
+
+func (llm *LLM) tryTool(ctx context.Context, toolCall message.ToolCall, failures *toolFailures) (string, *ToolError) {
+	name := toolCall.Name
+
+	tool, ok := llm.tools[name]
+	if !ok {
+		return "", &ToolError{
+			Type:    ToolErrorUnknownTool,
+			Tool:    name,
+			Message: "unknown tool called: " + name,
+		}
+	}
+
+	if toolCall.Arguments == nil && toolCall.RawArguments != "" {
+		return "", &ToolError{
+			Type:    ToolErrorInvalidArguments,
+			Tool:    name,
+			Message: "arguments must be a valid JSON object: " + toolCall.RawArguments,
+		}
+	}
+
+	retries, maxFailures := llm.limits(name)
+	if failures.Get(name) >= maxFailures {
+		return "", &ToolError{
+			Type:    ToolErrorUnavailable,
+			Tool:    name,
+			Message: "the tool has failed too many times and is unavailable; answer without it",
+		}
+	}
+
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt) * toolRetryBackoff):
+			case <-ctx.Done():
+				return "", &ToolError{
+					Type:     ToolErrorCallFailed,
+					Tool:     name,
+					Message:  ctx.Err().Error(),
+					Attempts: attempt,
+				}
+			}
+		}
+
+		var result string
+		result, err = llm.invokeTool(ctx, tool, toolCall.Arguments)
+		if err == nil {
+			return result, nil
+		}
+	}
+
+	return "", &ToolError{
+		Type:     ToolErrorCallFailed,
+		Tool:     name,
+		Message:  err.Error(),
+		Attempts: retries + 1,
+	}
+}
+
+func (llm *LLM) invokeTool(ctx context.Context, tool Tool, params map[string]any) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, llm.toolTimeout(tool.Name()))
+	defer cancel()
+
+	// Call the tool with the parameters
+	return tool.Call(ctx, params)
+}
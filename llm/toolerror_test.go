@@ -0,0 +1,173 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix/config"
+	"github.com/flarexio/talkix/llm/message"
+)
+
+type flakyTool struct {
+	calls int
+	fails int
+}
+
+func (t *flakyTool) Name() string {
+	return "flaky"
+}
+
+func (t *flakyTool) Description() string {
+	return "A tool that fails a number of times before it succeeds"
+}
+
+func (t *flakyTool) Parameters() map[string]any {
+	return map[string]any{"type": "object"}
+}
+
+func (t *flakyTool) Call(ctx context.Context, params map[string]any) (string, error) {
+	t.calls++
+	if t.calls <= t.fails {
+		return "", errors.New("status 429")
+	}
+
+	return "ok", nil
+}
+
+func newToolErrorLLM(tool Tool, policy config.ToolErrorPolicy, responses ...message.Message) *LLM {
+	return &LLM{
+		provider: &stubProvider{responses: responses},
+		tools:    map[string]Tool{tool.Name(): tool},
+		toolList: []Tool{tool},
+
+		errorPolicy: policy,
+	}
+}
+
+func decodeToolError(content string) (*ToolError, error) {
+	var result struct {
+		Error *ToolError `json:"error"`
+	}
+
+	if err := json.Unmarshal([]byte(content), &result); err != nil {
+		return nil, err
+	}
+
+	if result.Error == nil {
+		return nil, errors.New("no tool error")
+	}
+
+	return result.Error, nil
+}
+
+func TestToolErrorIsReportedToModel(t *testing.T) {
+	assert := assert.New(t)
+
+	tool := &flakyTool{fails: 1}
+	llm := newToolErrorLLM(tool, config.ToolErrorPolicy{},
+		message.AIMessage("",
+			message.ToolCall{ID: "call_1", Name: "flaky", Arguments: map[string]any{}},
+			message.ToolCall{ID: "call_2", Name: "missing", Arguments: map[string]any{}},
+			message.ToolCall{ID: "call_3", Name: "flaky", RawArguments: "{invalid"},
+		),
+		message.AIMessage("Sorry, the tools are not available."),
+	)
+
+	ctx := context.Background()
+	msgs, err := llm.Invoke(ctx, "Call the tools")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Len(msgs, 7)
+
+	expected := []ToolErrorType{
+		ToolErrorCallFailed,
+		ToolErrorUnknownTool,
+		ToolErrorInvalidArguments,
+	}
+
+	for i, typ := range expected {
+		toolMsg := msgs[3+i]
+		assert.Equal(message.RoleTool, toolMsg.Role)
+		assert.True(toolMsg.IsError)
+
+		toolErr, err := decodeToolError(toolMsg.Content)
+		if err != nil {
+			assert.Fail(err.Error())
+			return
+		}
+
+		assert.Equal(typ, toolErr.Type)
+	}
+}
+
+func TestToolErrorRetries(t *testing.T) {
+	assert := assert.New(t)
+
+	tool := &flakyTool{fails: 1}
+	policy := config.ToolErrorPolicy{
+		Tools: map[string]config.ToolRetryLimits{
+			"flaky": {Retries: 1},
+		},
+	}
+
+	llm := newToolErrorLLM(tool, policy,
+		message.AIMessage("", message.ToolCall{ID: "call_1", Name: "flaky", Arguments: map[string]any{}}),
+		message.AIMessage("Done."),
+	)
+
+	ctx := context.Background()
+	msgs, err := llm.Invoke(ctx, "Call the tool")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal(2, tool.calls)
+	assert.Equal("ok", msgs[3].Content)
+	assert.False(msgs[3].IsError)
+}
+
+func TestToolErrorGiveUp(t *testing.T) {
+	assert := assert.New(t)
+
+	call := message.AIMessage("", message.ToolCall{ID: "call_1", Name: "flaky", Arguments: map[string]any{}})
+
+	// report: the tool is disabled once it reaches the failure limit
+	tool := &flakyTool{fails: 10}
+	llm := newToolErrorLLM(tool, config.ToolErrorPolicy{MaxFailures: 1},
+		call, call, message.AIMessage("Done."),
+	)
+
+	ctx := context.Background()
+	msgs, err := llm.Invoke(ctx, "Call the tool")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal(1, tool.calls)
+
+	toolErr, err := decodeToolError(msgs[5].Content)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal(ToolErrorUnavailable, toolErr.Type)
+
+	// abort: the whole invocation fails
+	tool = &flakyTool{fails: 10}
+	llm = newToolErrorLLM(tool, config.ToolErrorPolicy{MaxFailures: 1, GiveUp: config.GiveUpAbort},
+		call, message.AIMessage("Done."),
+	)
+
+	_, err = llm.Invoke(ctx, "Call the tool")
+	assert.ErrorIs(err, ErrToolGaveUp)
+}
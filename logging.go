@@ -6,6 +6,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/flarexio/talkix/session"
+	"github.com/flarexio/talkix/user"
 )
 
 func LoggingMiddleware(name string) ServiceMiddleware {
@@ -51,6 +52,47 @@ func (mw *loggingMiddleware) ReplyMessage(ctx context.Context, msg Message) (Mes
 	return reply, nil
 }
 
+func (mw *loggingMiddleware) StreamReply(ctx context.Context, msg Message) (<-chan StreamEvent, error) {
+	log := mw.log.With(
+		zap.String("action", "stream_reply"),
+		zap.String("content", msg.Content()),
+		zap.Time("timestamp", msg.Timestamp()),
+	)
+
+	log.Info("streaming reply")
+
+	events, err := mw.next.StreamReply(ctx, msg)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	out := make(chan StreamEvent)
+	go func() {
+		defer close(out)
+
+		for e := range events {
+			switch e.Type {
+			case StreamEventToolCall:
+				log.Info("tool called", zap.String("tool", e.ToolCall.Name))
+
+			case StreamEventReply:
+				log.Info("message replied", zap.String("reply_content", e.Reply.Content()))
+
+			case StreamEventError:
+				log.Error(e.Err.Error())
+			}
+
+			select {
+			case out <- e:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return out, nil
+}
+
 func SessionLoggingMiddleware() SessionServiceMiddleware {
 	return func(next SessionService) SessionService {
 		log := zap.L().With(
@@ -71,22 +113,24 @@ type sessionLoggingMiddleware struct {
 	next SessionService
 }
 
-func (mw *sessionLoggingMiddleware) ListSessions(ctx context.Context) ([]*session.Session, string, error) {
+func (mw *sessionLoggingMiddleware) ListSessions(ctx context.Context, query session.SessionQuery) (*session.SessionPage, string, error) {
 	log := mw.log.With(
 		zap.String("action", "list_sessions"),
+		zap.String("order_by", string(query.OrderBy)),
+		zap.String("cursor", query.Cursor),
 	)
 
-	sessions, selectedSessionID, err := mw.next.ListSessions(ctx)
+	page, selectedSessionID, err := mw.next.ListSessions(ctx, query)
 	if err != nil {
 		log.Error(err.Error())
 		return nil, "", err
 	}
 
 	log.Info("sessions listed",
-		zap.Int("count", len(sessions)),
+		zap.Int("count", len(page.Sessions)),
 		zap.String("selected_session", selectedSessionID))
 
-	return sessions, selectedSessionID, nil
+	return page, selectedSessionID, nil
 }
 
 func (mw *sessionLoggingMiddleware) Session(ctx context.Context, sessionID string) (*session.Session, error) {
@@ -105,6 +149,23 @@ func (mw *sessionLoggingMiddleware) Session(ctx context.Context, sessionID strin
 	return session, nil
 }
 
+func (mw *sessionLoggingMiddleware) ListConversations(ctx context.Context, query session.ConversationQuery) (*session.ConversationPage, error) {
+	log := mw.log.With(
+		zap.String("action", "list_conversations"),
+		zap.String("session", query.SessionID),
+		zap.String("cursor", query.Cursor),
+	)
+
+	page, err := mw.next.ListConversations(ctx, query)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("conversations listed", zap.Int("count", len(page.Conversations)))
+	return page, nil
+}
+
 func (mw *sessionLoggingMiddleware) CreateSession(ctx context.Context) (*session.Session, error) {
 	log := mw.log.With(
 		zap.String("action", "create_session"),
@@ -151,3 +212,94 @@ func (mw *sessionLoggingMiddleware) DeleteSession(ctx context.Context, sessionID
 	log.Info("session deleted")
 	return nil
 }
+
+func (mw *sessionLoggingMiddleware) Usage(ctx context.Context) (*UsageReport, error) {
+	log := mw.log.With(
+		zap.String("action", "usage"),
+	)
+
+	report, err := mw.next.Usage(ctx)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("usage retrieved",
+		zap.Int64("total_tokens", report.Usage.TotalTokens),
+		zap.Float64("cost", report.Usage.Cost))
+
+	return report, nil
+}
+
+func UserLoggingMiddleware() UserServiceMiddleware {
+	return func(next UserService) UserService {
+		log := zap.L().With(
+			zap.String("service", "user"),
+		)
+
+		log.Info("user service initialized")
+
+		return &userLoggingMiddleware{
+			log:  log,
+			next: next,
+		}
+	}
+}
+
+type userLoggingMiddleware struct {
+	log  *zap.Logger
+	next UserService
+}
+
+func (mw *userLoggingMiddleware) ListUsers(ctx context.Context, query user.Query) (*user.Page, error) {
+	log := mw.log.With(
+		zap.String("action", "list_users"),
+		zap.String("cursor", query.Cursor),
+	)
+
+	page, err := mw.next.ListUsers(ctx, query)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("users listed", zap.Int("count", len(page.Users)))
+	return page, nil
+}
+
+func EventLoggingMiddleware() EventServiceMiddleware {
+	return func(next EventService) EventService {
+		log := zap.L().With(
+			zap.String("service", "event"),
+		)
+
+		log.Info("event service initialized")
+
+		return &eventLoggingMiddleware{
+			log:  log,
+			next: next,
+		}
+	}
+}
+
+type eventLoggingMiddleware struct {
+	log  *zap.Logger
+	next EventService
+}
+
+func (mw *eventLoggingMiddleware) HandleEvent(ctx context.Context, e Event) ([]Message, error) {
+	log := mw.log.With(
+		zap.String("action", "handle_event"),
+		zap.String("type", string(e.Type)),
+		zap.Time("timestamp", e.Timestamp),
+	)
+
+	replies, err := mw.next.HandleEvent(ctx, e)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("event handled", zap.Int("replies", len(replies)))
+	return replies, nil
+}
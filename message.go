@@ -3,6 +3,8 @@ package talkix
 import (
 	"encoding/json"
 	"time"
+
+	"github.com/flarexio/talkix/llm/message"
 )
 
 type Message interface {
@@ -12,6 +14,7 @@ type Message interface {
 	SetTimestamp(time.Time)
 	QuickReply() []string
 	AddQuickReply(...string)
+	Attachments() []message.Attachment
 }
 
 func NewTextMessage(text string) Message {
@@ -22,16 +25,26 @@ func NewTextMessage(text string) Message {
 	}
 }
 
+// NewMediaMessage creates a message that carries attachments, with an
+// optional caption.
+func NewMediaMessage(text string, attachments ...message.Attachment) Message {
+	m := NewTextMessage(text).(*TextMessage)
+	m.AddAttachment(attachments...)
+	return m
+}
+
 type TextMessage struct {
 	Text         string
 	CreatedAt    time.Time
 	QuickReplies []string
+	Media        []message.Attachment
 }
 
 func (m *TextMessage) UnmarshalJSON(data []byte) error {
 	var raw struct {
-		Text      string `json:"text"`
-		Timestamp int64  `json:"timestamp"`
+		Text        string               `json:"text"`
+		Attachments []message.Attachment `json:"attachments"`
+		Timestamp   int64                `json:"timestamp"`
 	}
 
 	if err := json.Unmarshal(data, &raw); err != nil {
@@ -39,6 +52,7 @@ func (m *TextMessage) UnmarshalJSON(data []byte) error {
 	}
 
 	m.Text = raw.Text
+	m.Media = raw.Attachments
 
 	m.CreatedAt = time.Now()
 	if raw.Timestamp > 0 {
@@ -48,6 +62,22 @@ func (m *TextMessage) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+func (m *TextMessage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type        string               `json:"type"`
+		Text        string               `json:"text"`
+		Attachments []message.Attachment `json:"attachments,omitempty"`
+		QuickReply  []string             `json:"quickReply,omitempty"`
+		Timestamp   int64                `json:"timestamp"`
+	}{
+		Type:        m.Type(),
+		Text:        m.Text,
+		Attachments: m.Media,
+		QuickReply:  m.QuickReplies,
+		Timestamp:   m.CreatedAt.UnixMilli(),
+	})
+}
+
 func (m *TextMessage) Type() string {
 	return "text"
 }
@@ -72,26 +102,38 @@ func (m *TextMessage) AddQuickReply(reply ...string) {
 	m.QuickReplies = append(m.QuickReplies, reply...)
 }
 
-func NewFlexMessage(alt string, flex json.RawMessage) Message {
-	return &FlexMessage{
-		AltText:   alt,
-		Flex:      flex,
-		CreatedAt: time.Now(),
+func (m *TextMessage) Attachments() []message.Attachment {
+	return m.Media
+}
+
+func (m *TextMessage) AddAttachment(attachments ...message.Attachment) {
+	m.Media = append(m.Media, attachments...)
+}
+
+// NewCardMessage creates a message of one card, or a carousel of several.
+// The alt text is shown where the cards cannot be.
+func NewCardMessage(alt string, cards ...*Card) Message {
+	return &CardMessage{
+		AltText:      alt,
+		Cards:        cards,
+		CreatedAt:    time.Now(),
+		QuickReplies: make([]string, 0),
 	}
 }
 
-type FlexMessage struct {
+type CardMessage struct {
 	AltText      string
-	Flex         json.RawMessage
+	Cards        []*Card
 	CreatedAt    time.Time
 	QuickReplies []string
 }
 
-func (m *FlexMessage) UnmarshalJSON(data []byte) error {
+func (m *CardMessage) UnmarshalJSON(data []byte) error {
 	var raw struct {
-		AltText   string `json:"altText"`
-		Flex      string `json:"flex"`
-		Timestamp int64  `json:"timestamp"`
+		AltText    string   `json:"altText"`
+		Cards      []*Card  `json:"cards"`
+		QuickReply []string `json:"quickReply"`
+		Timestamp  int64    `json:"timestamp"`
 	}
 
 	if err := json.Unmarshal(data, &raw); err != nil {
@@ -99,10 +141,8 @@ func (m *FlexMessage) UnmarshalJSON(data []byte) error {
 	}
 
 	m.AltText = raw.AltText
-
-	if raw.Flex != "" {
-		m.Flex = json.RawMessage(raw.Flex)
-	}
+	m.Cards = raw.Cards
+	m.QuickReplies = raw.QuickReply
 
 	m.CreatedAt = time.Now()
 	if raw.Timestamp > 0 {
@@ -112,26 +152,46 @@ func (m *FlexMessage) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-func (m *FlexMessage) Type() string {
-	return "flex"
+func (m *CardMessage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type       string   `json:"type"`
+		AltText    string   `json:"altText"`
+		Cards      []*Card  `json:"cards"`
+		QuickReply []string `json:"quickReply,omitempty"`
+		Timestamp  int64    `json:"timestamp"`
+	}{
+		Type:       m.Type(),
+		AltText:    m.AltText,
+		Cards:      m.Cards,
+		QuickReply: m.QuickReplies,
+		Timestamp:  m.CreatedAt.UnixMilli(),
+	})
 }
 
-func (m *FlexMessage) Content() string {
+func (m *CardMessage) Type() string {
+	return "card"
+}
+
+func (m *CardMessage) Content() string {
 	return m.AltText
 }
 
-func (m *FlexMessage) Timestamp() time.Time {
+func (m *CardMessage) Timestamp() time.Time {
 	return m.CreatedAt
 }
 
-func (m *FlexMessage) SetTimestamp(t time.Time) {
+func (m *CardMessage) SetTimestamp(t time.Time) {
 	m.CreatedAt = t
 }
 
-func (m *FlexMessage) QuickReply() []string {
+func (m *CardMessage) QuickReply() []string {
 	return m.QuickReplies
 }
 
-func (m *FlexMessage) AddQuickReply(reply ...string) {
+func (m *CardMessage) AddQuickReply(reply ...string) {
 	m.QuickReplies = append(m.QuickReplies, reply...)
 }
+
+func (m *CardMessage) Attachments() []message.Attachment {
+	return nil
+}
@@ -0,0 +1,59 @@
+package inmem
+
+import (
+	"sync"
+	"time"
+
+	"github.com/flarexio/talkix/dedup"
+)
+
+func NewDedupStore(ttl time.Duration) dedup.Store {
+	if ttl <= 0 {
+		ttl = dedup.DefaultTTL
+	}
+
+	return &dedupStore{
+		ttl:  ttl,
+		seen: make(map[string]time.Time),
+	}
+}
+
+type dedupStore struct {
+	ttl   time.Duration
+	seen  map[string]time.Time // expiry by event ID
+	swept time.Time
+	sync.Mutex
+}
+
+func (s *dedupStore) Add(id string) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+
+	// The expired IDs are dropped at most once per TTL.
+	if now.Sub(s.swept) >= s.ttl {
+		for id, expiry := range s.seen {
+			if !now.Before(expiry) {
+				delete(s.seen, id)
+			}
+		}
+
+		s.swept = now
+	}
+
+	if expiry, ok := s.seen[id]; ok && now.Before(expiry) {
+		return false, nil
+	}
+
+	s.seen[id] = now.Add(s.ttl)
+	return true, nil
+}
+
+func (s *dedupStore) Remove(id string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.seen, id)
+	return nil
+}
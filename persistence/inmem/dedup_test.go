@@ -0,0 +1,35 @@
+package inmem
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupStore(t *testing.T) {
+	assert := assert.New(t)
+
+	store := NewDedupStore(20 * time.Millisecond)
+
+	added, err := store.Add("event-1")
+	assert.NoError(err)
+	assert.True(added)
+
+	added, err = store.Add("event-1")
+	assert.NoError(err)
+	assert.False(added)
+
+	assert.NoError(store.Remove("event-1"))
+
+	added, err = store.Add("event-1")
+	assert.NoError(err)
+	assert.True(added)
+
+	// The ID is forgotten after the TTL.
+	time.Sleep(30 * time.Millisecond)
+
+	added, err = store.Add("event-1")
+	assert.NoError(err)
+	assert.True(added)
+}
@@ -0,0 +1,45 @@
+package inmem
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/flarexio/talkix/group"
+)
+
+func NewGroupRepository() group.Repository {
+	return &groupRepository{
+		groups: make(map[string]*group.Settings),
+	}
+}
+
+type groupRepository struct {
+	groups map[string]*group.Settings
+	sync.RWMutex
+}
+
+func (repo *groupRepository) Find(id string) (*group.Settings, error) {
+	repo.RLock()
+	defer repo.RUnlock()
+
+	s, ok := repo.groups[id]
+	if !ok {
+		return nil, group.ErrGroupNotFound
+	}
+
+	return cloneSettings(s), nil
+}
+
+func (repo *groupRepository) Save(s *group.Settings) error {
+	repo.Lock()
+	defer repo.Unlock()
+
+	repo.groups[s.ID] = cloneSettings(s)
+	return nil
+}
+
+func cloneSettings(s *group.Settings) *group.Settings {
+	c := *s
+	c.Keywords = slices.Clone(s.Keywords)
+	return &c
+}
@@ -1,6 +1,10 @@
 package inmem
 
 import (
+	"errors"
+	"maps"
+	"slices"
+	"sort"
 	"sync"
 
 	"github.com/flarexio/talkix/session"
@@ -25,17 +29,81 @@ func (repo *sessionRepository) Find(id string) (*session.Session, error) {
 	if !ok {
 		return nil, session.ErrSessionNotFound
 	}
-	return s, nil
+	return cloneSession(s), nil
+}
+
+func (repo *sessionRepository) FindRecent(id string, n int) (*session.Session, error) {
+	repo.RLock()
+	defer repo.RUnlock()
+
+	s, ok := repo.sessions[id]
+	if !ok {
+		return nil, session.ErrSessionNotFound
+	}
+
+	partial := cloneSession(s)
+
+	offset := max(len(s.Conversations)-n, 0)
+	if offset > 0 {
+		partial.Conversations = partial.Conversations[offset:]
+		partial.ConversationOffset = offset
+	}
+
+	return partial, nil
 }
 
 func (repo *sessionRepository) Save(s *session.Session) error {
 	repo.Lock()
 	defer repo.Unlock()
 
-	repo.sessions[s.ID] = s
+	var version int
+
+	stored, ok := repo.sessions[s.ID]
+	if ok {
+		version = stored.Version
+	}
+
+	if s.Version != version {
+		return session.ErrConflict
+	}
+
+	full := cloneSession(s)
+	full.Version++
+
+	// The summary is owned by UpdateSummary.
+	if ok {
+		full.Summary = stored.Summary
+	}
+
+	// A partially loaded session is merged with the stored conversations.
+	if offset := s.ConversationOffset; offset > 0 {
+		if !ok || len(stored.Conversations) < offset {
+			return session.ErrInvalidOffset
+		}
+
+		full.Conversations = append(
+			stored.Conversations[:offset:offset],
+			s.Conversations...,
+		)
+		full.ConversationOffset = 0
+	}
+
+	repo.sessions[s.ID] = full
+
+	s.Version = full.Version
+	s.Summary = full.Summary
 	return nil
 }
 
+// cloneSession copies a session, so that the callers never share the stored
+// one and its version.
+func cloneSession(s *session.Session) *session.Session {
+	c := *s
+	c.Conversations = slices.Clone(s.Conversations)
+	c.ToolUsage = maps.Clone(s.ToolUsage)
+	return &c
+}
+
 func (repo *sessionRepository) Delete(id string) error {
 	repo.Lock()
 	defer repo.Unlock()
@@ -48,3 +116,169 @@ func (repo *sessionRepository) Delete(id string) error {
 	delete(repo.sessions, id)
 	return nil
 }
+
+func (repo *sessionRepository) UpdateSummary(id string, update func(summary *session.Summary)) error {
+	repo.Lock()
+	defer repo.Unlock()
+
+	s, ok := repo.sessions[id]
+	if !ok {
+		return session.ErrSessionNotFound
+	}
+
+	update(&s.Summary)
+	return nil
+}
+
+func (repo *sessionRepository) ListByUser(query session.SessionQuery) (*session.SessionPage, error) {
+	var cursor *session.Cursor
+	if query.Cursor != "" {
+		c, err := session.ParseCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		cursor = &c
+	}
+
+	repo.RLock()
+	defer repo.RUnlock()
+
+	sessions := make([]*session.Session, 0)
+	for _, s := range repo.sessions {
+		if s.UserID == query.UserID {
+			sessions = append(sessions, cloneSession(s))
+		}
+	}
+
+	var (
+		less  func(a, b *session.Session) bool
+		after func(s *session.Session) bool
+		key   func(s *session.Session) session.Cursor
+	)
+
+	switch query.OrderBy {
+	case session.OrderByActivity:
+		less = func(a, b *session.Session) bool {
+			ta, tb := a.LastActivity(), b.LastActivity()
+			if !ta.Equal(tb) {
+				return ta.After(tb)
+			}
+
+			return a.ID > b.ID
+		}
+
+		after = func(s *session.Session) bool {
+			return cursor.Before(s.LastActivity(), s.ID)
+		}
+
+		key = func(s *session.Session) session.Cursor {
+			return session.Cursor{Time: s.LastActivity(), ID: s.ID}
+		}
+
+	case "", session.OrderByID:
+		less = func(a, b *session.Session) bool {
+			return a.ID < b.ID
+		}
+
+		after = func(s *session.Session) bool {
+			return s.ID > cursor.ID
+		}
+
+		key = func(s *session.Session) session.Cursor {
+			return session.Cursor{ID: s.ID}
+		}
+
+	default:
+		return nil, errors.New("invalid session order: " + string(query.OrderBy))
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return less(sessions[i], sessions[j])
+	})
+
+	page := &session.SessionPage{
+		Sessions: make([]*session.Session, 0),
+	}
+
+	size := session.PageSize(query.Limit)
+	for _, s := range sessions {
+		if cursor != nil && !after(s) {
+			continue
+		}
+
+		if len(page.Sessions) == size {
+			last := page.Sessions[size-1]
+			page.NextCursor = key(last).String()
+			break
+		}
+
+		page.Sessions = append(page.Sessions, s)
+	}
+
+	// The conversations are left out once the page is cut, which needs
+	// their activity.
+	for _, s := range page.Sessions {
+		s.ConversationOffset = len(s.Conversations)
+		s.Conversations = make([]*session.Conversation, 0)
+	}
+
+	return page, nil
+}
+
+func (repo *sessionRepository) ListConversations(query session.ConversationQuery) (*session.ConversationPage, error) {
+	var cursor *session.Cursor
+	if query.Cursor != "" {
+		c, err := session.ParseCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		cursor = &c
+	}
+
+	repo.RLock()
+	defer repo.RUnlock()
+
+	s, ok := repo.sessions[query.SessionID]
+	if !ok {
+		return nil, session.ErrSessionNotFound
+	}
+
+	convs := make([]*session.Conversation, 0)
+	for _, conv := range s.Conversations {
+		if query.Contains(conv.CreatedAt) {
+			convs = append(convs, conv)
+		}
+	}
+
+	sort.SliceStable(convs, func(i, j int) bool {
+		a, b := convs[i], convs[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+
+		return a.ID < b.ID
+	})
+
+	page := &session.ConversationPage{
+		Conversations: make([]*session.Conversation, 0),
+	}
+
+	size := session.PageSize(query.Limit)
+	for _, conv := range convs {
+		if cursor != nil && !cursor.After(conv.CreatedAt, conv.ID) {
+			continue
+		}
+
+		if len(page.Conversations) == size {
+			last := page.Conversations[size-1]
+			page.NextCursor = session.Cursor{Time: last.CreatedAt, ID: last.ID}.String()
+			break
+		}
+
+		page.Conversations = append(page.Conversations, conv)
+	}
+
+	return page, nil
+}
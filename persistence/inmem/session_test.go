@@ -1,7 +1,9 @@
 package inmem
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 
@@ -65,6 +67,343 @@ func (suite *sessionRepoTestSuite) TestSave() {
 	suite.Len(session.Conversations, 1)
 }
 
+func (suite *sessionRepoTestSuite) TestUpdateSummary() {
+	s := suite.session
+
+	err := suite.sessions.UpdateSummary(s.ID, func(summary *session.Summary) {
+		summary.Text = "Asked about the capital of France."
+		summary.Status = session.SummaryReady
+		summary.Usage.Add(message.Usage{TotalTokens: 100})
+	})
+
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// A save with a stale summary keeps the stored one.
+	stale := *s
+	stale.Summary = session.Summary{Status: session.SummaryPending}
+	if err := suite.sessions.Save(&stale); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	found, err := suite.sessions.Find(s.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal("Asked about the capital of France.", found.Summary.Text)
+	suite.Equal(session.SummaryReady, found.Summary.Status)
+	suite.Equal(int64(100), found.TotalUsage().TotalTokens)
+
+	err = suite.sessions.UpdateSummary("missing", func(summary *session.Summary) {})
+	suite.ErrorIs(err, session.ErrSessionNotFound)
+}
+
+func (suite *sessionRepoTestSuite) TestFindRecent() {
+	s := suite.session
+
+	for i := 0; i < 5; i++ {
+		conv := session.NewConversation()
+		conv.AddMessage(message.HumanMessage(fmt.Sprintf("Question %d", i)))
+		s.AddConversation(conv)
+	}
+
+	if err := suite.sessions.Save(s); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	ids := make([]string, 0)
+	for _, conv := range s.Conversations {
+		ids = append(ids, conv.ID)
+	}
+
+	recent, err := suite.sessions.FindRecent(s.ID, 2)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if !suite.Len(recent.Conversations, 2) {
+		return
+	}
+
+	suite.Equal(3, recent.ConversationOffset)
+	suite.Equal(5, recent.ConversationCount())
+	suite.Equal(ids[3], recent.Conversations[0].ID)
+
+	// Saving a partially loaded session keeps the earlier conversations.
+	conv := session.NewConversation()
+	conv.AddMessage(message.HumanMessage("Question 5"))
+	recent.AddConversation(conv)
+	ids = append(ids, conv.ID)
+
+	if err := suite.sessions.Save(recent); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	meta, err := suite.sessions.FindRecent(s.ID, 0)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Empty(meta.Conversations)
+	suite.Equal(6, meta.ConversationCount())
+
+	if err := suite.sessions.Save(meta); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	found, err := suite.sessions.Find(s.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	foundIDs := make([]string, 0)
+	for _, conv := range found.Conversations {
+		foundIDs = append(foundIDs, conv.ID)
+	}
+
+	suite.Equal(ids, foundIDs)
+	suite.Zero(found.ConversationOffset)
+}
+
+func (suite *sessionRepoTestSuite) TestSaveConflict() {
+	first, err := suite.sessions.FindRecent(suite.session.ID, 0)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	second, err := suite.sessions.FindRecent(suite.session.ID, 0)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	first.AddConversation(session.NewConversation())
+	if err := suite.sessions.Save(first); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(second.Version+1, first.Version)
+
+	// The second copy was loaded before the first one was saved.
+	second.AddConversation(session.NewConversation())
+	err = suite.sessions.Save(second)
+	suite.ErrorIs(err, session.ErrConflict)
+
+	found, err := suite.sessions.Find(suite.session.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Len(found.Conversations, 1)
+	suite.Equal(first.Version, found.Version)
+
+	// A summary update does not conflict with the saves.
+	err = suite.sessions.UpdateSummary(found.ID, func(summary *session.Summary) {
+		summary.Text = "summary"
+	})
+
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	found.AddConversation(session.NewConversation())
+	suite.NoError(suite.sessions.Save(found))
+}
+
+func (suite *sessionRepoTestSuite) saveListSessions() []*session.Session {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	sessions := make([]*session.Session, 3)
+	for i := range sessions {
+		s := session.NewSession("list-user")
+		s.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		sessions[i] = s
+	}
+
+	// The first session has the most recent activity, the second none.
+	for i := 1; i <= 5; i++ {
+		conv := session.NewConversation()
+		conv.AddMessage(message.HumanMessage("Hello"))
+		conv.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		sessions[0].AddConversation(conv)
+	}
+
+	conv := session.NewConversation()
+	conv.AddMessage(message.HumanMessage("Hello"))
+	conv.CreatedAt = base.Add(150 * time.Minute)
+	sessions[2].AddConversation(conv)
+
+	for _, s := range sessions {
+		if err := suite.sessions.Save(s); err != nil {
+			suite.FailNow(err.Error())
+		}
+	}
+
+	return sessions
+}
+
+func (suite *sessionRepoTestSuite) TestListByUser() {
+	sessions := suite.saveListSessions()
+
+	page, err := suite.sessions.ListByUser(session.SessionQuery{
+		UserID: "list-user",
+		Limit:  2,
+	})
+
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if !suite.Len(page.Sessions, 2) {
+		return
+	}
+
+	suite.Equal(sessions[0].ID, page.Sessions[0].ID)
+	suite.Equal(sessions[1].ID, page.Sessions[1].ID)
+	suite.Empty(page.Sessions[0].Conversations)
+	suite.Equal(5, page.Sessions[0].ConversationCount())
+	suite.NotEmpty(page.NextCursor)
+
+	page, err = suite.sessions.ListByUser(session.SessionQuery{
+		UserID: "list-user",
+		Cursor: page.NextCursor,
+		Limit:  2,
+	})
+
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if suite.Len(page.Sessions, 1) {
+		suite.Equal(sessions[2].ID, page.Sessions[0].ID)
+	}
+
+	suite.Empty(page.NextCursor)
+
+	// The most recently active first.
+	var ids []string
+
+	query := session.SessionQuery{
+		UserID:  "list-user",
+		OrderBy: session.OrderByActivity,
+		Limit:   1,
+	}
+
+	for {
+		page, err := suite.sessions.ListByUser(query)
+		if err != nil {
+			suite.Fail(err.Error())
+			return
+		}
+
+		for _, s := range page.Sessions {
+			ids = append(ids, s.ID)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+
+		query.Cursor = page.NextCursor
+	}
+
+	suite.Equal([]string{sessions[0].ID, sessions[2].ID, sessions[1].ID}, ids)
+
+	// New activity moves a session to the front.
+	conv := session.NewConversation()
+	conv.CreatedAt = sessions[1].CreatedAt.Add(24 * time.Hour)
+	sessions[1].AddConversation(conv)
+
+	if err := suite.sessions.Save(sessions[1]); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	page, err = suite.sessions.ListByUser(session.SessionQuery{
+		UserID:  "list-user",
+		OrderBy: session.OrderByActivity,
+	})
+
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if suite.Len(page.Sessions, 3) {
+		suite.Equal(sessions[1].ID, page.Sessions[0].ID)
+	}
+
+	_, err = suite.sessions.ListByUser(session.SessionQuery{
+		UserID: "list-user",
+		Cursor: "not a cursor",
+	})
+
+	suite.ErrorIs(err, session.ErrInvalidCursor)
+}
+
+func (suite *sessionRepoTestSuite) TestListConversations() {
+	sessions := suite.saveListSessions()
+	base := sessions[0].CreatedAt
+
+	query := session.ConversationQuery{
+		SessionID: sessions[0].ID,
+		Since:     base.Add(2 * time.Hour),
+		Until:     base.Add(5 * time.Hour),
+		Limit:     2,
+	}
+
+	page, err := suite.sessions.ListConversations(query)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if !suite.Len(page.Conversations, 2) {
+		return
+	}
+
+	suite.Equal(sessions[0].Conversations[1].ID, page.Conversations[0].ID)
+	suite.Equal(sessions[0].Conversations[2].ID, page.Conversations[1].ID)
+	suite.NotEmpty(page.NextCursor)
+
+	query.Cursor = page.NextCursor
+
+	page, err = suite.sessions.ListConversations(query)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if suite.Len(page.Conversations, 1) {
+		suite.Equal(sessions[0].Conversations[3].ID, page.Conversations[0].ID)
+	}
+
+	suite.Empty(page.NextCursor)
+
+	_, err = suite.sessions.ListConversations(session.ConversationQuery{
+		SessionID: "missing",
+	})
+
+	suite.ErrorIs(err, session.ErrSessionNotFound)
+}
+
 func TestSessionRepoTestSuite(t *testing.T) {
 	suite.Run(t, new(sessionRepoTestSuite))
 }
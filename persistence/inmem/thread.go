@@ -0,0 +1,38 @@
+package inmem
+
+import (
+	"sync"
+
+	"github.com/flarexio/talkix/session"
+)
+
+func NewThreadRepository() session.ThreadRepository {
+	return &threadRepository{
+		threads: make(map[string]string),
+	}
+}
+
+type threadRepository struct {
+	threads map[string]string
+	sync.RWMutex
+}
+
+func (repo *threadRepository) FindSessionID(thread string) (string, error) {
+	repo.RLock()
+	defer repo.RUnlock()
+
+	id, ok := repo.threads[thread]
+	if !ok {
+		return "", session.ErrThreadNotFound
+	}
+
+	return id, nil
+}
+
+func (repo *threadRepository) SaveSessionID(thread string, sessionID string) error {
+	repo.Lock()
+	defer repo.Unlock()
+
+	repo.threads[thread] = sessionID
+	return nil
+}
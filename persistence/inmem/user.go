@@ -1,6 +1,9 @@
 package inmem
 
 import (
+	"maps"
+	"slices"
+	"sort"
 	"sync"
 
 	"github.com/flarexio/talkix/user"
@@ -21,17 +24,70 @@ func (repo *userRepository) Find(id string) (*user.User, error) {
 	repo.RLock()
 	defer repo.RUnlock()
 
-	s, ok := repo.users[id]
+	u, ok := repo.users[id]
 	if !ok {
 		return nil, user.ErrUserNotFound
 	}
-	return s, nil
+	return cloneUser(u), nil
 }
 
 func (repo *userRepository) Save(u *user.User) error {
 	repo.Lock()
 	defer repo.Unlock()
 
-	repo.users[u.ID] = u
+	var version int
+	if stored, ok := repo.users[u.ID]; ok {
+		version = stored.Version
+	}
+
+	if u.Version != version {
+		return user.ErrConflict
+	}
+
+	stored := cloneUser(u)
+	stored.Version++
+
+	repo.users[u.ID] = stored
+
+	u.Version = stored.Version
 	return nil
 }
+
+// cloneUser copies a user, so that the callers never share the stored one
+// and its version.
+func cloneUser(u *user.User) *user.User {
+	c := *u
+	c.SessionIDs = slices.Clone(u.SessionIDs)
+	c.ToolUsage = maps.Clone(u.ToolUsage)
+	return &c
+}
+
+func (repo *userRepository) List(query user.Query) (*user.Page, error) {
+	repo.RLock()
+	defer repo.RUnlock()
+
+	ids := make([]string, 0, len(repo.users))
+	for id := range repo.users {
+		if id > query.Cursor {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+
+	page := &user.Page{
+		Users: make([]*user.User, 0),
+	}
+
+	size := user.PageSize(query.Limit)
+	if len(ids) > size {
+		ids = ids[:size]
+		page.NextCursor = ids[size-1]
+	}
+
+	for _, id := range ids {
+		page.Users = append(page.Users, cloneUser(repo.users[id]))
+	}
+
+	return page, nil
+}
@@ -0,0 +1,62 @@
+package kv
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"github.com/flarexio/talkix/dedup"
+)
+
+// NewDedupStore records the event IDs as keys that Badger expires after the
+// TTL.
+func NewDedupStore(db *badger.DB, ttl time.Duration) dedup.Store {
+	if ttl <= 0 {
+		ttl = dedup.DefaultTTL
+	}
+
+	return &dedupStore{db, ttl}
+}
+
+type dedupStore struct {
+	db  *badger.DB
+	ttl time.Duration
+}
+
+func (s *dedupStore) Add(id string) (bool, error) {
+	var added bool
+
+	err := s.db.Update(func(txn *badger.Txn) error {
+		key := []byte("webhook_event:" + id)
+
+		_, err := txn.Get(key)
+		if err == nil {
+			return nil
+		}
+
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		added = true
+		return txn.SetEntry(badger.NewEntry(key, nil).WithTTL(s.ttl))
+	})
+
+	if err != nil {
+		// Another delivery of the event was recorded at the same time.
+		if errors.Is(err, badger.ErrConflict) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return added, nil
+}
+
+func (s *dedupStore) Remove(id string) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte("webhook_event:" + id))
+	})
+}
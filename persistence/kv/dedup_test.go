@@ -0,0 +1,50 @@
+package kv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupStore(t *testing.T) {
+	assert := assert.New(t)
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true))
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+	defer db.Close()
+
+	store := NewDedupStore(db, time.Hour)
+
+	added, err := store.Add("event-1")
+	assert.NoError(err)
+	assert.True(added)
+
+	added, err = store.Add("event-1")
+	assert.NoError(err)
+	assert.False(added)
+
+	// The ID is stored with the TTL.
+	err = db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte("webhook_event:event-1"))
+		if err != nil {
+			return err
+		}
+
+		expiresAt := time.Unix(int64(item.ExpiresAt()), 0)
+		assert.WithinDuration(time.Now().Add(time.Hour), expiresAt, time.Minute)
+		return nil
+	})
+
+	assert.NoError(err)
+
+	assert.NoError(store.Remove("event-1"))
+
+	added, err = store.Add("event-1")
+	assert.NoError(err)
+	assert.True(added)
+}
@@ -0,0 +1,54 @@
+package kv
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"github.com/flarexio/talkix/group"
+)
+
+func NewGroupRepository(db *badger.DB) group.Repository {
+	return &groupRepository{db}
+}
+
+type groupRepository struct {
+	db *badger.DB
+}
+
+func (repo *groupRepository) Find(id string) (*group.Settings, error) {
+	var s *group.Settings
+
+	err := repo.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte("group:" + id))
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &s)
+		})
+	})
+
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil, group.ErrGroupNotFound
+		}
+
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (repo *groupRepository) Save(s *group.Settings) error {
+	val, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	return repo.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("group:"+s.ID), val)
+	})
+}
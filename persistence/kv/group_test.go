@@ -0,0 +1,53 @@
+package kv
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix/group"
+)
+
+func TestGroupRepository(t *testing.T) {
+	assert := assert.New(t)
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true))
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+	defer db.Close()
+
+	groups := NewGroupRepository(db)
+
+	_, err = groups.Find("C1234")
+	assert.ErrorIs(err, group.ErrGroupNotFound)
+
+	s := group.NewSettings("C1234")
+	if err := groups.Save(s); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	if err := s.SetTrigger(group.TriggerKeyword, "talkix", "助理"); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	if err := groups.Save(s); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	found, err := groups.Find("C1234")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal(group.TriggerKeyword, found.Trigger)
+	assert.Equal([]string{"talkix", "助理"}, found.Keywords)
+	assert.True(s.CreatedAt.Equal(found.CreatedAt))
+	assert.True(s.UpdatedAt.Equal(found.UpdatedAt))
+}
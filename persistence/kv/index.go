@@ -0,0 +1,177 @@
+package kv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// The index keys have empty values; everything a listing needs to seek and
+// to sort is part of the key:
+//
+//	user_session:<user>:<session>
+//	user_activity:<user>:<time>:<session>
+//	session_conversation:<session>:<time>:<conversation>
+const (
+	userSessionPrefix         = "user_session:"
+	userActivityPrefix        = "user_activity:"
+	sessionConversationPrefix = "session_conversation:"
+
+	indexVersionKey = "meta:index_version"
+	indexVersion    = "2"
+)
+
+// timeKey formats a time with a fixed width, so that the keys sort by time.
+func timeKey(t time.Time) string {
+	if t.IsZero() {
+		return fmt.Sprintf("%020d", 0)
+	}
+
+	return fmt.Sprintf("%020d", t.UnixNano())
+}
+
+func parseTimeKey(key string) (time.Time, error) {
+	var nanos int64
+	if _, err := fmt.Sscanf(key, "%d", &nanos); err != nil {
+		return time.Time{}, err
+	}
+
+	if nanos == 0 {
+		return time.Time{}, nil
+	}
+
+	return time.Unix(0, nanos), nil
+}
+
+func userSessionKey(userID, sessionID string) []byte {
+	return []byte(userSessionPrefix + userID + ":" + sessionID)
+}
+
+func userActivityKey(userID string, t time.Time, sessionID string) []byte {
+	return []byte(userActivityPrefix + userID + ":" + timeKey(t) + ":" + sessionID)
+}
+
+func sessionConversationKey(sessionID string, t time.Time, conversationID string) []byte {
+	return []byte(sessionConversationPrefix + sessionID + ":" + timeKey(t) + ":" + conversationID)
+}
+
+// splitTimeKey splits "<time>:<id>", the rest of a key after its prefix.
+func splitTimeKey(rest string) (time.Time, string, error) {
+	tk, id, ok := strings.Cut(rest, ":")
+	if !ok {
+		return time.Time{}, "", errors.New("invalid index key")
+	}
+
+	t, err := parseTimeKey(tk)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+
+	return t, id, nil
+}
+
+// legacySession is a session stored before the index, with the IDs of all
+// its conversations.
+type legacySession struct {
+	Session
+	ConversationIDs []string `json:"conversation_ids"`
+}
+
+// buildIndexes writes the index keys of the sessions stored before the
+// listings existed, and moves their conversation IDs into the index. It
+// runs again whenever indexVersion changes.
+func buildIndexes(db *badger.DB) error {
+	type entry struct {
+		key []byte
+		val []byte
+	}
+
+	var (
+		entries []entry
+		done    bool
+	)
+
+	err := db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(indexVersionKey))
+		switch {
+		case err == nil:
+			if err := item.Value(func(val []byte) error {
+				done = string(val) == indexVersion
+				return nil
+			}); err != nil || done {
+				return err
+			}
+
+		case !errors.Is(err, badger.ErrKeyNotFound):
+			return err
+		}
+
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte("session:")
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			var ls *legacySession
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &ls)
+			}); err != nil {
+				return err
+			}
+
+			ss := &ls.Session
+
+			if ls.ConversationIDs != nil {
+				ss.ConversationCount = len(ls.ConversationIDs)
+				ss.LastActiveAt = ss.CreatedAt
+			}
+
+			for _, id := range ls.ConversationIDs {
+				conv, err := getConversation(txn, id)
+				if err != nil {
+					return err
+				}
+
+				entries = append(entries, entry{sessionConversationKey(ss.ID, conv.CreatedAt, conv.ID), nil})
+				ss.LastActiveAt = conv.CreatedAt
+			}
+
+			val, err := json.Marshal(ss)
+			if err != nil {
+				return err
+			}
+
+			entries = append(entries,
+				entry{[]byte("session:" + ss.ID), val},
+				entry{userSessionKey(ss.UserID, ss.ID), nil},
+				entry{userActivityKey(ss.UserID, ss.LastActiveAt, ss.ID), nil},
+			)
+		}
+
+		return nil
+	})
+
+	if err != nil || done {
+		return err
+	}
+
+	wb := db.NewWriteBatch()
+	defer wb.Cancel()
+
+	for _, e := range entries {
+		if err := wb.Set(e.key, e.val); err != nil {
+			return err
+		}
+	}
+
+	if err := wb.Set([]byte(indexVersionKey), []byte(indexVersion)); err != nil {
+		return err
+	}
+
+	return wb.Flush()
+}
@@ -1,34 +1,43 @@
 package kv
 
 import (
+	"encoding/json"
 	"time"
 
+	"github.com/flarexio/talkix/llm/message"
 	"github.com/flarexio/talkix/session"
 )
 
 func NewSession(s *session.Session) *Session {
-	conversationIDs := make([]string, len(s.Conversations))
-	for i, conv := range s.Conversations {
-		conversationIDs[i] = conv.ID
-	}
-
 	return &Session{
-		ID:              s.ID,
-		UserID:          s.UserID,
-		Summary:         s.Summary,
-		ConversationIDs: conversationIDs,
-		CreatedAt:       s.CreatedAt,
+		ID:                s.ID,
+		UserID:            s.UserID,
+		Summary:           Summary(s.Summary),
+		ConversationCount: s.ConversationCount(),
+		Usage:             s.Usage,
+		ToolUsage:         s.ToolUsage,
+		CreatedAt:         s.CreatedAt,
+		LastActiveAt:      s.LastActivity(),
+		Version:           s.Version,
 
 		conversations: s.Conversations,
 	}
 }
 
+// Session only holds the metadata of a session. Its conversations are
+// stored under their own keys and listed in order by the
+// session_conversation index, so that the recent ones can be read without
+// the others.
 type Session struct {
-	ID              string    `json:"id"`
-	UserID          string    `json:"user_id"`
-	Summary         string    `json:"summary"`
-	ConversationIDs []string  `json:"conversation_ids"`
-	CreatedAt       time.Time `json:"created_at"`
+	ID                string              `json:"id"`
+	UserID            string              `json:"user_id"`
+	Summary           Summary             `json:"summary"`
+	ConversationCount int                 `json:"conversation_count"`
+	Usage             message.Usage       `json:"usage"`
+	ToolUsage         message.UsageByTool `json:"tool_usage"`
+	CreatedAt         time.Time           `json:"created_at"`
+	LastActiveAt      time.Time           `json:"last_active_at"`
+	Version           int                 `json:"version"`
 
 	conversations []*session.Conversation `json:"-"`
 }
@@ -37,8 +46,35 @@ func (s *Session) reconstitute(convs []*session.Conversation) *session.Session {
 	return &session.Session{
 		ID:            s.ID,
 		UserID:        s.UserID,
-		Summary:       s.Summary,
+		Summary:       session.Summary(s.Summary),
 		Conversations: convs,
+		Usage:         s.Usage,
+		ToolUsage:     s.ToolUsage,
 		CreatedAt:     s.CreatedAt,
+		Version:       s.Version,
+	}
+}
+
+type Summary session.Summary
+
+// UnmarshalJSON also reads sessions stored before the summary had a status,
+// when it was a plain string.
+func (s *Summary) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*s = Summary{
+			Text:   text,
+			Status: session.SummaryReady,
+		}
+
+		return nil
 	}
+
+	var summary session.Summary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return err
+	}
+
+	*s = Summary(summary)
+	return nil
 }
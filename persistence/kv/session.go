@@ -1,16 +1,23 @@
 package kv
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"slices"
+	"strings"
 
 	"github.com/dgraph-io/badger/v4"
 
 	"github.com/flarexio/talkix/session"
 )
 
-func NewSessionRepository(db *badger.DB) session.Repository {
-	return &sessionRepository{db}
+func NewSessionRepository(db *badger.DB) (session.Repository, error) {
+	if err := buildIndexes(db); err != nil {
+		return nil, err
+	}
+
+	return &sessionRepository{db}, nil
 }
 
 type sessionRepository struct {
@@ -18,75 +25,153 @@ type sessionRepository struct {
 }
 
 func (repo *sessionRepository) Find(id string) (*session.Session, error) {
+	return repo.find(id, -1)
+}
+
+func (repo *sessionRepository) FindRecent(id string, n int) (*session.Session, error) {
+	return repo.find(id, max(n, 0))
+}
+
+func (repo *sessionRepository) find(id string, n int) (*session.Session, error) {
 	var s *session.Session
 
 	err := repo.db.View(func(txn *badger.Txn) error {
-		key := []byte("session:" + id)
-
-		item, err := txn.Get(key)
+		found, err := findSession(txn, id, n)
 		if err != nil {
 			return err
 		}
 
-		var ss *Session
+		s = found
+		return nil
+	})
 
-		if err := item.Value(func(val []byte) error {
-			return json.Unmarshal(val, &ss)
-		}); err != nil {
-			return err
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil, session.ErrSessionNotFound
 		}
 
-		convs := make([]*session.Conversation, len(ss.ConversationIDs))
-		for i, id := range ss.ConversationIDs {
-			key := []byte("conversation:" + id)
-			convItem, err := txn.Get(key)
-			if err != nil {
-				return err
-			}
+		return nil, err
+	}
 
-			var conv *session.Conversation
-			if err := convItem.Value(func(val []byte) error {
-				return json.Unmarshal(val, &conv)
-			}); err != nil {
-				return err
-			}
+	return s, nil
+}
+
+// findSession loads the session with its last n conversations, or with all
+// of them for a negative n.
+func findSession(txn *badger.Txn, id string, n int) (*session.Session, error) {
+	ss, err := getSession(txn, id)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(sessionConversationPrefix + id + ":")
+	opts.PrefetchValues = false
 
-			convs[i] = conv
+	var ids []string
+	if n < 0 {
+		ids = make([]string, 0, ss.ConversationCount)
+
+		it := txn.NewIterator(opts)
+		for it.Rewind(); it.Valid(); it.Next() {
+			ids = append(ids, conversationIDOf(it.Item().Key()))
 		}
+		it.Close()
 
-		// reconstitute converts the data model back to the domain model
-		s = ss.reconstitute(convs)
-		return nil
-	})
+	} else if n > 0 {
+		// The last n, so iterate backwards from the end of the prefix.
+		opts.Reverse = true
 
-	if err != nil {
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return nil, session.ErrSessionNotFound
+		ids = make([]string, 0, n)
+
+		it := txn.NewIterator(opts)
+		for it.Seek(append(opts.Prefix, 0xff)); it.Valid() && len(ids) < n; it.Next() {
+			ids = append(ids, conversationIDOf(it.Item().Key()))
 		}
+		it.Close()
 
-		return nil, err
+		slices.Reverse(ids)
 	}
 
+	convs := make([]*session.Conversation, len(ids))
+	for i, id := range ids {
+		conv, err := getConversation(txn, id)
+		if err != nil {
+			return nil, err
+		}
+
+		convs[i] = conv
+	}
+
+	// reconstitute converts the data model back to the domain model
+	s := ss.reconstitute(convs)
+	s.ConversationOffset = max(ss.ConversationCount-len(convs), 0)
 	return s, nil
 }
 
+// conversationIDOf returns the conversation ID at the end of an index key.
+func conversationIDOf(key []byte) string {
+	k := string(key)
+	return k[strings.LastIndexByte(k, ':')+1:]
+}
+
 func (repo *sessionRepository) Save(s *session.Session) error {
 	ss := NewSession(s) // convert domain to data model
 
-	return repo.db.Update(func(txn *badger.Txn) error {
-		for _, conv := range ss.conversations {
-			key := []byte("conversation:" + conv.ID)
+	err := repo.db.Update(func(txn *badger.Txn) error {
+		stored, err := getSession(txn, ss.ID)
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
 
-			val, err := json.Marshal(&conv)
-			if err != nil {
+		var version int
+		if stored != nil {
+			version = stored.Version
+		}
+
+		if ss.Version != version {
+			return session.ErrConflict
+		}
+
+		ss.Version++
+
+		// The conversations that were not loaded are kept as they are.
+		if offset := s.ConversationOffset; offset > 0 {
+			if stored == nil || offset > stored.ConversationCount {
+				return session.ErrInvalidOffset
+			}
+
+			if len(ss.conversations) == 0 {
+				ss.LastActiveAt = stored.LastActiveAt
+			}
+		}
+
+		if stored != nil {
+			// The summary is owned by UpdateSummary.
+			ss.Summary = stored.Summary
+
+			if !stored.LastActiveAt.Equal(ss.LastActiveAt) || stored.UserID != ss.UserID {
+				key := userActivityKey(stored.UserID, stored.LastActiveAt, stored.ID)
+				if err := txn.Delete(key); err != nil {
+					return err
+				}
+			}
+		} else {
+			if err := txn.Set(userSessionKey(ss.UserID, ss.ID), nil); err != nil {
 				return err
 			}
+		}
 
-			if err := txn.Set(key, val); err != nil {
+		for _, conv := range ss.conversations {
+			if err := saveConversation(txn, ss.ID, conv); err != nil {
 				return err
 			}
 		}
 
+		if err := txn.Set(userActivityKey(ss.UserID, ss.LastActiveAt, ss.ID), nil); err != nil {
+			return err
+		}
+
 		key := []byte("session:" + ss.ID)
 
 		val, err := json.Marshal(&ss)
@@ -96,27 +181,86 @@ func (repo *sessionRepository) Save(s *session.Session) error {
 
 		return txn.Set(key, val)
 	})
+
+	if err != nil {
+		// Badger detects a concurrent commit between the read and the write.
+		if errors.Is(err, badger.ErrConflict) {
+			return session.ErrConflict
+		}
+
+		return err
+	}
+
+	s.Version = ss.Version
+	s.Summary = session.Summary(ss.Summary)
+	return nil
+}
+
+// saveConversation only writes a conversation that is new or has changed
+// since it was stored.
+func saveConversation(txn *badger.Txn, sessionID string, conv *session.Conversation) error {
+	key := []byte("conversation:" + conv.ID)
+
+	val, err := json.Marshal(&conv)
+	if err != nil {
+		return err
+	}
+
+	item, err := txn.Get(key)
+	switch {
+	case err == nil:
+		unchanged := false
+		if err := item.Value(func(stored []byte) error {
+			unchanged = bytes.Equal(stored, val)
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		if unchanged {
+			return nil
+		}
+
+	case !errors.Is(err, badger.ErrKeyNotFound):
+		return err
+	}
+
+	if err := txn.Set(key, val); err != nil {
+		return err
+	}
+
+	return txn.Set(sessionConversationKey(sessionID, conv.CreatedAt, conv.ID), nil)
 }
 
 func (repo *sessionRepository) Delete(id string) error {
 	return repo.db.Update(func(txn *badger.Txn) error {
 		key := []byte("session:" + id)
 
-		item, err := txn.Get(key)
+		s, err := getSession(txn, id)
 		if err != nil {
 			return err
 		}
 
-		var s *Session
+		keys := [][]byte{
+			userSessionKey(s.UserID, s.ID),
+			userActivityKey(s.UserID, s.LastActiveAt, s.ID),
+		}
 
-		if err := item.Value(func(val []byte) error {
-			return json.Unmarshal(val, &s)
-		}); err != nil {
-			return err
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(sessionConversationPrefix + id + ":")
+		opts.PrefetchValues = false
+
+		it := txn.NewIterator(opts)
+		for it.Rewind(); it.Valid(); it.Next() {
+			indexKey := it.Item().KeyCopy(nil)
+			keys = append(keys,
+				indexKey,
+				[]byte("conversation:"+conversationIDOf(indexKey)),
+			)
 		}
+		it.Close()
 
-		for _, id := range s.ConversationIDs {
-			key := []byte("conversation:" + id)
+		for _, key := range keys {
 			if err := txn.Delete(key); err != nil {
 				return err
 			}
@@ -125,3 +269,275 @@ func (repo *sessionRepository) Delete(id string) error {
 		return txn.Delete(key)
 	})
 }
+
+// maxUpdateAttempts bounds how often UpdateSummary runs again after a
+// concurrent commit to the session, such as a Save of its next conversation.
+const maxUpdateAttempts = 10
+
+// UpdateSummary runs the update again on the stored summary when the session
+// was written between the read and the commit, since the summary does not
+// depend on the rest of the session.
+func (repo *sessionRepository) UpdateSummary(id string, update func(summary *session.Summary)) error {
+	var err error
+	for attempt := 1; attempt <= maxUpdateAttempts; attempt++ {
+		err = repo.updateSummary(id, update)
+		if !errors.Is(err, badger.ErrConflict) {
+			return err
+		}
+	}
+
+	return session.ErrConflict
+}
+
+func (repo *sessionRepository) updateSummary(id string, update func(summary *session.Summary)) error {
+	err := repo.db.Update(func(txn *badger.Txn) error {
+		ss, err := getSession(txn, id)
+		if err != nil {
+			return err
+		}
+
+		summary := session.Summary(ss.Summary)
+		update(&summary)
+		ss.Summary = Summary(summary)
+
+		val, err := json.Marshal(&ss)
+		if err != nil {
+			return err
+		}
+
+		return txn.Set([]byte("session:"+id), val)
+	})
+
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return session.ErrSessionNotFound
+	}
+
+	return err
+}
+
+func (repo *sessionRepository) ListByUser(query session.SessionQuery) (*session.SessionPage, error) {
+	var cursor *session.Cursor
+	if query.Cursor != "" {
+		c, err := session.ParseCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		cursor = &c
+	}
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+
+	var (
+		prefix string
+		seek   []byte
+		parse  func(rest string) (session.Cursor, error)
+	)
+
+	switch query.OrderBy {
+	case session.OrderByActivity:
+		// The most recent first, so iterate backwards from the end of the
+		// prefix.
+		prefix = userActivityPrefix + query.UserID + ":"
+		opts.Reverse = true
+
+		seek = []byte(prefix + "\xff")
+		if cursor != nil {
+			seek = userActivityKey(query.UserID, cursor.Time, cursor.ID)
+		}
+
+		parse = func(rest string) (session.Cursor, error) {
+			t, id, err := splitTimeKey(rest)
+			return session.Cursor{Time: t, ID: id}, err
+		}
+
+	case "", session.OrderByID:
+		prefix = userSessionPrefix + query.UserID + ":"
+
+		seek = []byte(prefix)
+		if cursor != nil {
+			seek = userSessionKey(query.UserID, cursor.ID)
+		}
+
+		parse = func(rest string) (session.Cursor, error) {
+			return session.Cursor{ID: rest}, nil
+		}
+
+	default:
+		return nil, errors.New("invalid session order: " + string(query.OrderBy))
+	}
+
+	opts.Prefix = []byte(prefix)
+
+	page := &session.SessionPage{
+		Sessions: make([]*session.Session, 0),
+	}
+
+	size := session.PageSize(query.Limit)
+
+	err := repo.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		var keys []session.Cursor
+		for it.Seek(seek); it.Valid(); it.Next() {
+			key := it.Item().KeyCopy(nil)
+			if cursor != nil && string(key) == string(seek) {
+				continue
+			}
+
+			c, err := parse(strings.TrimPrefix(string(key), prefix))
+			if err != nil {
+				return err
+			}
+
+			if len(keys) == size {
+				page.NextCursor = keys[size-1].String()
+				break
+			}
+
+			keys = append(keys, c)
+		}
+
+		for _, key := range keys {
+			s, err := findSession(txn, key.ID, 0)
+			if err != nil {
+				return err
+			}
+
+			page.Sessions = append(page.Sessions, s)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+func (repo *sessionRepository) ListConversations(query session.ConversationQuery) (*session.ConversationPage, error) {
+	var cursor *session.Cursor
+	if query.Cursor != "" {
+		c, err := session.ParseCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		cursor = &c
+	}
+
+	prefix := sessionConversationPrefix + query.SessionID + ":"
+
+	seek := []byte(prefix)
+	switch {
+	case cursor != nil:
+		seek = sessionConversationKey(query.SessionID, cursor.Time, cursor.ID)
+
+	case !query.Since.IsZero():
+		seek = []byte(prefix + timeKey(query.Since))
+	}
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(prefix)
+	opts.PrefetchValues = false
+
+	page := &session.ConversationPage{
+		Conversations: make([]*session.Conversation, 0),
+	}
+
+	size := session.PageSize(query.Limit)
+
+	err := repo.db.View(func(txn *badger.Txn) error {
+		if _, err := txn.Get([]byte("session:" + query.SessionID)); err != nil {
+			return err
+		}
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		var keys []session.Cursor
+		for it.Seek(seek); it.Valid(); it.Next() {
+			key := it.Item().KeyCopy(nil)
+			if cursor != nil && string(key) == string(seek) {
+				continue
+			}
+
+			t, id, err := splitTimeKey(strings.TrimPrefix(string(key), prefix))
+			if err != nil {
+				return err
+			}
+
+			if !query.Contains(t) {
+				if !query.Until.IsZero() && !t.Before(query.Until) {
+					break
+				}
+
+				continue
+			}
+
+			if len(keys) == size {
+				page.NextCursor = keys[size-1].String()
+				break
+			}
+
+			keys = append(keys, session.Cursor{Time: t, ID: id})
+		}
+
+		for _, key := range keys {
+			conv, err := getConversation(txn, key.ID)
+			if err != nil {
+				return err
+			}
+
+			page.Conversations = append(page.Conversations, conv)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil, session.ErrSessionNotFound
+		}
+
+		return nil, err
+	}
+
+	return page, nil
+}
+
+func getSession(txn *badger.Txn, id string) (*Session, error) {
+	item, err := txn.Get([]byte("session:" + id))
+	if err != nil {
+		return nil, err
+	}
+
+	var ss *Session
+	if err := item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &ss)
+	}); err != nil {
+		return nil, err
+	}
+
+	return ss, nil
+}
+
+func getConversation(txn *badger.Txn, id string) (*session.Conversation, error) {
+	item, err := txn.Get([]byte("conversation:" + id))
+	if err != nil {
+		return nil, err
+	}
+
+	var conv *session.Conversation
+	if err := item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &conv)
+	}); err != nil {
+		return nil, err
+	}
+
+	return conv, nil
+}
@@ -1,9 +1,15 @@
 package kv
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 
 	"github.com/flarexio/talkix/llm/message"
@@ -24,7 +30,11 @@ func (suite *sessionRepoTestSuite) SetupTest() {
 		return
 	}
 
-	sessions := NewSessionRepository(db)
+	sessions, err := NewSessionRepository(db)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
 
 	s := session.NewSession("test-user")
 	sessions.Save(s)
@@ -73,6 +83,653 @@ func (suite *sessionRepoTestSuite) TestSave() {
 	suite.Len(session.Conversations, 1)
 }
 
+func (suite *sessionRepoTestSuite) TestUpdateSummary() {
+	s := suite.session
+
+	err := suite.sessions.UpdateSummary(s.ID, func(summary *session.Summary) {
+		summary.Text = "Asked about the capital of France."
+		summary.Status = session.SummaryReady
+		summary.Usage.Add(message.Usage{TotalTokens: 100})
+	})
+
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// A save with a stale summary keeps the stored one.
+	s.Summary = session.Summary{Status: session.SummaryPending}
+	if err := suite.sessions.Save(s); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	found, err := suite.sessions.Find(s.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal("Asked about the capital of France.", found.Summary.Text)
+	suite.Equal(session.SummaryReady, found.Summary.Status)
+	suite.Equal(int64(100), found.TotalUsage().TotalTokens)
+
+	err = suite.sessions.UpdateSummary("missing", func(summary *session.Summary) {})
+	suite.ErrorIs(err, session.ErrSessionNotFound)
+}
+
+func (suite *sessionRepoTestSuite) TestUpdateSummaryConcurrentSave() {
+	id := suite.session.ID
+
+	const n = 20
+
+	var wg sync.WaitGroup
+
+	// The conversations are saved while the summaries are updated, both
+	// writing the session key.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < n; {
+			s, err := suite.sessions.FindRecent(id, 0)
+			if err != nil {
+				suite.Fail(err.Error())
+				return
+			}
+
+			s.AddConversation(session.NewConversation())
+
+			err = suite.sessions.Save(s)
+			if errors.Is(err, session.ErrConflict) {
+				continue
+			}
+
+			if err != nil {
+				suite.Fail(err.Error())
+				return
+			}
+
+			i++
+		}
+	}()
+
+	// The summaries of a session are updated one at a time, as a worker of
+	// the summarizer does.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for range n {
+			err := suite.sessions.UpdateSummary(id, func(summary *session.Summary) {
+				summary.Usage.Add(message.Usage{TotalTokens: 1})
+			})
+
+			suite.NoError(err)
+		}
+	}()
+
+	wg.Wait()
+
+	found, err := suite.sessions.FindRecent(id, 0)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(n, found.ConversationCount())
+	suite.Equal(int64(n), found.Summary.Usage.TotalTokens)
+}
+
+func (suite *sessionRepoTestSuite) TestLegacySummary() {
+	var ss Session
+	if err := json.Unmarshal([]byte(`{"id":"s1","summary":"舊的摘要"}`), &ss); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	s := ss.reconstitute(nil)
+	suite.Equal("舊的摘要", s.Summary.Text)
+	suite.Equal(session.SummaryReady, s.Summary.Status)
+}
+
+func (suite *sessionRepoTestSuite) TestFindRecent() {
+	s := suite.session
+
+	for i := 0; i < 5; i++ {
+		conv := session.NewConversation()
+		conv.AddMessage(message.HumanMessage(fmt.Sprintf("Question %d", i)))
+		s.AddConversation(conv)
+	}
+
+	if err := suite.sessions.Save(s); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	ids := make([]string, 0)
+	for _, conv := range s.Conversations {
+		ids = append(ids, conv.ID)
+	}
+
+	recent, err := suite.sessions.FindRecent(s.ID, 2)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if !suite.Len(recent.Conversations, 2) {
+		return
+	}
+
+	suite.Equal(3, recent.ConversationOffset)
+	suite.Equal(5, recent.ConversationCount())
+	suite.Equal(ids[3], recent.Conversations[0].ID)
+
+	// Saving a partially loaded session keeps the earlier conversations.
+	conv := session.NewConversation()
+	conv.AddMessage(message.HumanMessage("Question 5"))
+	recent.AddConversation(conv)
+	ids = append(ids, conv.ID)
+
+	if err := suite.sessions.Save(recent); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	meta, err := suite.sessions.FindRecent(s.ID, 0)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Empty(meta.Conversations)
+	suite.Equal(6, meta.ConversationCount())
+
+	if err := suite.sessions.Save(meta); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	found, err := suite.sessions.Find(s.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	foundIDs := make([]string, 0)
+	for _, conv := range found.Conversations {
+		foundIDs = append(foundIDs, conv.ID)
+	}
+
+	suite.Equal(ids, foundIDs)
+	suite.Zero(found.ConversationOffset)
+}
+
+func (suite *sessionRepoTestSuite) TestSaveConflict() {
+	first, err := suite.sessions.FindRecent(suite.session.ID, 0)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	second, err := suite.sessions.FindRecent(suite.session.ID, 0)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	first.AddConversation(session.NewConversation())
+	if err := suite.sessions.Save(first); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(second.Version+1, first.Version)
+
+	// The second copy was loaded before the first one was saved.
+	second.AddConversation(session.NewConversation())
+	err = suite.sessions.Save(second)
+	suite.ErrorIs(err, session.ErrConflict)
+
+	found, err := suite.sessions.Find(suite.session.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Len(found.Conversations, 1)
+	suite.Equal(first.Version, found.Version)
+
+	// A summary update does not conflict with the saves.
+	err = suite.sessions.UpdateSummary(found.ID, func(summary *session.Summary) {
+		summary.Text = "summary"
+	})
+
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	found.AddConversation(session.NewConversation())
+	suite.NoError(suite.sessions.Save(found))
+}
+
+func (suite *sessionRepoTestSuite) saveListSessions() []*session.Session {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	sessions := make([]*session.Session, 3)
+	for i := range sessions {
+		s := session.NewSession("list-user")
+		s.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		sessions[i] = s
+	}
+
+	// The first session has the most recent activity, the second none.
+	for i := 1; i <= 5; i++ {
+		conv := session.NewConversation()
+		conv.AddMessage(message.HumanMessage("Hello"))
+		conv.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		sessions[0].AddConversation(conv)
+	}
+
+	conv := session.NewConversation()
+	conv.AddMessage(message.HumanMessage("Hello"))
+	conv.CreatedAt = base.Add(150 * time.Minute)
+	sessions[2].AddConversation(conv)
+
+	for _, s := range sessions {
+		if err := suite.sessions.Save(s); err != nil {
+			suite.FailNow(err.Error())
+		}
+	}
+
+	return sessions
+}
+
+func (suite *sessionRepoTestSuite) TestListByUser() {
+	sessions := suite.saveListSessions()
+
+	page, err := suite.sessions.ListByUser(session.SessionQuery{
+		UserID: "list-user",
+		Limit:  2,
+	})
+
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if !suite.Len(page.Sessions, 2) {
+		return
+	}
+
+	suite.Equal(sessions[0].ID, page.Sessions[0].ID)
+	suite.Equal(sessions[1].ID, page.Sessions[1].ID)
+	suite.Empty(page.Sessions[0].Conversations)
+	suite.Equal(5, page.Sessions[0].ConversationCount())
+	suite.NotEmpty(page.NextCursor)
+
+	page, err = suite.sessions.ListByUser(session.SessionQuery{
+		UserID: "list-user",
+		Cursor: page.NextCursor,
+		Limit:  2,
+	})
+
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if suite.Len(page.Sessions, 1) {
+		suite.Equal(sessions[2].ID, page.Sessions[0].ID)
+	}
+
+	suite.Empty(page.NextCursor)
+
+	// The most recently active first.
+	var ids []string
+
+	query := session.SessionQuery{
+		UserID:  "list-user",
+		OrderBy: session.OrderByActivity,
+		Limit:   1,
+	}
+
+	for {
+		page, err := suite.sessions.ListByUser(query)
+		if err != nil {
+			suite.Fail(err.Error())
+			return
+		}
+
+		for _, s := range page.Sessions {
+			ids = append(ids, s.ID)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+
+		query.Cursor = page.NextCursor
+	}
+
+	suite.Equal([]string{sessions[0].ID, sessions[2].ID, sessions[1].ID}, ids)
+
+	// New activity moves a session to the front.
+	conv := session.NewConversation()
+	conv.CreatedAt = sessions[1].CreatedAt.Add(24 * time.Hour)
+	sessions[1].AddConversation(conv)
+
+	if err := suite.sessions.Save(sessions[1]); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	page, err = suite.sessions.ListByUser(session.SessionQuery{
+		UserID:  "list-user",
+		OrderBy: session.OrderByActivity,
+	})
+
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if suite.Len(page.Sessions, 3) {
+		suite.Equal(sessions[1].ID, page.Sessions[0].ID)
+	}
+
+	_, err = suite.sessions.ListByUser(session.SessionQuery{
+		UserID: "list-user",
+		Cursor: "not a cursor",
+	})
+
+	suite.ErrorIs(err, session.ErrInvalidCursor)
+}
+
+func (suite *sessionRepoTestSuite) TestListConversations() {
+	sessions := suite.saveListSessions()
+	base := sessions[0].CreatedAt
+
+	query := session.ConversationQuery{
+		SessionID: sessions[0].ID,
+		Since:     base.Add(2 * time.Hour),
+		Until:     base.Add(5 * time.Hour),
+		Limit:     2,
+	}
+
+	page, err := suite.sessions.ListConversations(query)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if !suite.Len(page.Conversations, 2) {
+		return
+	}
+
+	suite.Equal(sessions[0].Conversations[1].ID, page.Conversations[0].ID)
+	suite.Equal(sessions[0].Conversations[2].ID, page.Conversations[1].ID)
+	suite.NotEmpty(page.NextCursor)
+
+	query.Cursor = page.NextCursor
+
+	page, err = suite.sessions.ListConversations(query)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if suite.Len(page.Conversations, 1) {
+		suite.Equal(sessions[0].Conversations[3].ID, page.Conversations[0].ID)
+	}
+
+	suite.Empty(page.NextCursor)
+
+	_, err = suite.sessions.ListConversations(session.ConversationQuery{
+		SessionID: "missing",
+	})
+
+	suite.ErrorIs(err, session.ErrSessionNotFound)
+}
+
 func TestSessionRepoTestSuite(t *testing.T) {
 	suite.Run(t, new(sessionRepoTestSuite))
 }
+
+func TestBuildIndexes(t *testing.T) {
+	assert := assert.New(t)
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true))
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+	defer db.Close()
+
+	// A session stored before the listings, without index keys.
+	s := session.NewSession("legacy-user")
+
+	conv := session.NewConversation()
+	conv.AddMessage(message.HumanMessage("Hello"))
+	s.AddConversation(conv)
+
+	err = db.Update(func(txn *badger.Txn) error {
+		val, err := json.Marshal(conv)
+		if err != nil {
+			return err
+		}
+
+		if err := txn.Set([]byte("conversation:"+conv.ID), val); err != nil {
+			return err
+		}
+
+		val, err = json.Marshal(map[string]any{
+			"id":               s.ID,
+			"user_id":          s.UserID,
+			"summary":          "舊的摘要",
+			"conversation_ids": []string{conv.ID},
+			"created_at":       s.CreatedAt,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if err := txn.Set([]byte("session:"+s.ID), val); err != nil {
+			return err
+		}
+
+		// The first index version kept the conversation IDs in the session.
+		return txn.Set([]byte(indexVersionKey), []byte("1"))
+	})
+
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	sessions, err := NewSessionRepository(db)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	for _, order := range []session.SessionOrder{session.OrderByID, session.OrderByActivity} {
+		page, err := sessions.ListByUser(session.SessionQuery{
+			UserID:  "legacy-user",
+			OrderBy: order,
+		})
+
+		if err != nil {
+			assert.Fail(err.Error())
+			return
+		}
+
+		if assert.Len(page.Sessions, 1) {
+			assert.Equal(s.ID, page.Sessions[0].ID)
+			assert.Equal(1, page.Sessions[0].ConversationCount())
+		}
+	}
+
+	convPage, err := sessions.ListConversations(session.ConversationQuery{SessionID: s.ID})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Len(convPage.Conversations, 1)
+
+	recent, err := sessions.FindRecent(s.ID, 1)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Len(recent.Conversations, 1)
+	assert.Zero(recent.ConversationOffset)
+
+	// Saving the session again replaces its activity key, so it is still
+	// listed once.
+	conv = session.NewConversation()
+	conv.CreatedAt = conv.CreatedAt.Add(time.Hour)
+	s.AddConversation(conv)
+
+	if err := sessions.Save(s); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	page, err := sessions.ListByUser(session.SessionQuery{
+		UserID:  "legacy-user",
+		OrderBy: session.OrderByActivity,
+	})
+
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Len(page.Sessions, 1)
+}
+
+func TestSaveWritesChangedConversationsOnly(t *testing.T) {
+	assert := assert.New(t)
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true))
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+	defer db.Close()
+
+	sessions, err := NewSessionRepository(db)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	s := session.NewSession("test-user")
+	for i := 0; i < 3; i++ {
+		conv := session.NewConversation()
+		conv.AddMessage(message.HumanMessage(fmt.Sprintf("Question %d", i)))
+		s.AddConversation(conv)
+	}
+
+	if err := sessions.Save(s); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	versions := func() []uint64 {
+		versions := make([]uint64, len(s.Conversations))
+		db.View(func(txn *badger.Txn) error {
+			for i, conv := range s.Conversations {
+				item, err := txn.Get([]byte("conversation:" + conv.ID))
+				if err != nil {
+					return err
+				}
+
+				versions[i] = item.Version()
+			}
+
+			return nil
+		})
+
+		return versions
+	}
+
+	before := versions()
+
+	s.Conversations[2].SetIO("Question 2", "Answer 2")
+	if err := sessions.Save(s); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	after := versions()
+
+	assert.Equal(before[0], after[0])
+	assert.Equal(before[1], after[1])
+	assert.NotEqual(before[2], after[2])
+}
+
+// BenchmarkReply loads the recent conversations of a session, adds one and
+// saves it, as a reply does. Its latency should not grow with the number of
+// stored conversations.
+func BenchmarkReply(b *testing.B) {
+	for _, count := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("conversations=%d", count), func(b *testing.B) {
+			opts := badger.DefaultOptions("").WithInMemory(true).WithLogger(nil)
+			db, err := badger.Open(opts)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer db.Close()
+
+			sessions, err := NewSessionRepository(db)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			s := session.NewSession("bench-user")
+			for i := 0; i < count; i++ {
+				s.AddConversation(newBenchConversation(i))
+			}
+
+			if err := sessions.Save(s); err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				recent, err := sessions.FindRecent(s.ID, 10)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				recent.AddConversation(newBenchConversation(count + i))
+
+				if err := sessions.Save(recent); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func newBenchConversation(i int) *session.Conversation {
+	question := fmt.Sprintf("What is the weather in city %d?", i)
+	answer := fmt.Sprintf("It is sunny in city %d.", i)
+
+	conv := session.NewConversation()
+	conv.AddMessage(
+		message.SystemMessage("You are a helpful assistant."),
+		message.HumanMessage(question),
+		message.AIMessage(answer),
+	)
+	conv.SetIO(question, answer)
+	return conv
+}
@@ -0,0 +1,49 @@
+package kv
+
+import (
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"github.com/flarexio/talkix/session"
+)
+
+func NewThreadRepository(db *badger.DB) session.ThreadRepository {
+	return &threadRepository{db}
+}
+
+type threadRepository struct {
+	db *badger.DB
+}
+
+func (repo *threadRepository) FindSessionID(thread string) (string, error) {
+	var id string
+
+	err := repo.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte("thread:" + thread))
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			id = string(val)
+			return nil
+		})
+	})
+
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return "", session.ErrThreadNotFound
+		}
+
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (repo *threadRepository) SaveSessionID(thread string, sessionID string) error {
+	return repo.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("thread:"+thread), []byte(sessionID))
+	})
+}
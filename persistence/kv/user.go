@@ -45,14 +45,106 @@ func (repo *userRepository) Find(id string) (*user.User, error) {
 }
 
 func (repo *userRepository) Save(u *user.User) error {
-	return repo.db.Update(func(txn *badger.Txn) error {
+	stored := *u
+	stored.Version++
+
+	err := repo.db.Update(func(txn *badger.Txn) error {
 		key := []byte("user:" + u.ID)
 
-		val, err := json.Marshal(&u)
+		var version int
+
+		item, err := txn.Get(key)
+		switch {
+		case err == nil:
+			if err := item.Value(func(val []byte) error {
+				var v struct {
+					Version int `json:"version"`
+				}
+
+				if err := json.Unmarshal(val, &v); err != nil {
+					return err
+				}
+
+				version = v.Version
+				return nil
+			}); err != nil {
+				return err
+			}
+
+		case !errors.Is(err, badger.ErrKeyNotFound):
+			return err
+		}
+
+		if u.Version != version {
+			return user.ErrConflict
+		}
+
+		val, err := json.Marshal(&stored)
 		if err != nil {
 			return err
 		}
 
 		return txn.Set(key, val)
 	})
+
+	if err != nil {
+		// Badger detects a concurrent commit between the read and the write.
+		if errors.Is(err, badger.ErrConflict) {
+			return user.ErrConflict
+		}
+
+		return err
+	}
+
+	u.Version = stored.Version
+	return nil
+}
+
+func (repo *userRepository) List(query user.Query) (*user.Page, error) {
+	prefix := "user:"
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(prefix)
+
+	seek := []byte(prefix + query.Cursor)
+
+	page := &user.Page{
+		Users: make([]*user.User, 0),
+	}
+
+	size := user.PageSize(query.Limit)
+
+	err := repo.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(seek); it.Valid(); it.Next() {
+			item := it.Item()
+			if query.Cursor != "" && string(item.Key()) == string(seek) {
+				continue
+			}
+
+			if len(page.Users) == size {
+				page.NextCursor = page.Users[size-1].ID
+				break
+			}
+
+			var u *user.User
+			if err := item.Value(func(val []byte) error {
+				return json.Unmarshal(val, &u)
+			}); err != nil {
+				return err
+			}
+
+			page.Users = append(page.Users, u)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
 }
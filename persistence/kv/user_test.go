@@ -0,0 +1,108 @@
+package kv
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix/user"
+)
+
+func TestListUsers(t *testing.T) {
+	assert := assert.New(t)
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true))
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+	defer db.Close()
+
+	users := NewUserRepository(db)
+
+	for i := range 5 {
+		u := &user.User{ID: fmt.Sprintf("user-%d", i)}
+		if err := users.Save(u); err != nil {
+			assert.Fail(err.Error())
+			return
+		}
+	}
+
+	var ids []string
+
+	query := user.Query{Limit: 2}
+	for {
+		page, err := users.List(query)
+		if err != nil {
+			assert.Fail(err.Error())
+			return
+		}
+
+		assert.LessOrEqual(len(page.Users), 2)
+
+		for _, u := range page.Users {
+			ids = append(ids, u.ID)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+
+		query.Cursor = page.NextCursor
+	}
+
+	assert.Equal([]string{"user-0", "user-1", "user-2", "user-3", "user-4"}, ids)
+}
+
+func TestUserConflict(t *testing.T) {
+	assert := assert.New(t)
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true))
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+	defer db.Close()
+
+	users := NewUserRepository(db)
+
+	if err := users.Save(&user.User{ID: "test-user"}); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	first, err := users.Find("test-user")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	second, err := users.Find("test-user")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	first.AddSessionID("session-1")
+	if err := users.Save(first); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal(2, first.Version)
+
+	second.AddSessionID("session-2")
+	err = users.Save(second)
+	assert.ErrorIs(err, user.ErrConflict)
+
+	found, err := users.Find("test-user")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal([]string{"session-1"}, found.SessionIDs)
+	assert.Equal(2, found.Version)
+}
@@ -0,0 +1,77 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/flarexio/talkix/group"
+)
+
+func NewGroupRepository(db *sql.DB) group.Repository {
+	return &groupRepository{db}
+}
+
+type groupRepository struct {
+	db *sql.DB
+}
+
+func (repo *groupRepository) Find(id string) (*group.Settings, error) {
+	s := &group.Settings{ID: id}
+
+	var trigger, keywords, createdAt, updatedAt string
+
+	err := repo.db.QueryRow(`
+		SELECT trigger_mode, keywords, created_at, updated_at
+		FROM group_settings WHERE id = ?`, id,
+	).Scan(&trigger, &keywords, &createdAt, &updatedAt)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, group.ErrGroupNotFound
+		}
+
+		return nil, err
+	}
+
+	s.Trigger = group.Trigger(trigger)
+
+	if err := json.Unmarshal([]byte(keywords), &s.Keywords); err != nil {
+		return nil, err
+	}
+
+	if s.CreatedAt, err = parseTime(createdAt); err != nil {
+		return nil, err
+	}
+
+	if s.UpdatedAt, err = parseTime(updatedAt); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (repo *groupRepository) Save(s *group.Settings) error {
+	keywords := s.Keywords
+	if keywords == nil {
+		keywords = make([]string, 0)
+	}
+
+	bs, err := json.Marshal(keywords)
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.db.Exec(`
+		INSERT INTO group_settings (id, trigger_mode, keywords, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			trigger_mode = excluded.trigger_mode,
+			keywords = excluded.keywords,
+			updated_at = excluded.updated_at`,
+		s.ID, string(s.Trigger), string(bs),
+		formatTime(s.CreatedAt), formatTime(s.UpdatedAt),
+	)
+
+	return err
+}
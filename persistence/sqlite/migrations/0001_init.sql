@@ -0,0 +1,74 @@
+-- Usage columns are kept flat, so that tokens and costs can be summed with
+-- plain SQL. The usage by tool is stored as a JSON object.
+
+CREATE TABLE users (
+    id                  TEXT PRIMARY KEY,
+    selected_session_id TEXT NOT NULL DEFAULT '',
+    prompt_tokens       INTEGER NOT NULL DEFAULT 0,
+    completion_tokens   INTEGER NOT NULL DEFAULT 0,
+    total_tokens        INTEGER NOT NULL DEFAULT 0,
+    cost                REAL NOT NULL DEFAULT 0,
+    tool_usage          TEXT NOT NULL DEFAULT '{}'
+);
+
+-- The sessions a user can switch between, in the order they were created.
+CREATE TABLE user_sessions (
+    user_id    TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    session_id TEXT NOT NULL,
+    position   INTEGER NOT NULL,
+    PRIMARY KEY (user_id, session_id)
+);
+
+CREATE TABLE sessions (
+    id                        TEXT PRIMARY KEY,
+    user_id                   TEXT NOT NULL,
+    summary_text              TEXT NOT NULL DEFAULT '',
+    summary_status            TEXT NOT NULL DEFAULT '',
+    summary_error             TEXT NOT NULL DEFAULT '',
+    summary_prompt_tokens     INTEGER NOT NULL DEFAULT 0,
+    summary_completion_tokens INTEGER NOT NULL DEFAULT 0,
+    summary_total_tokens      INTEGER NOT NULL DEFAULT 0,
+    summary_cost              REAL NOT NULL DEFAULT 0,
+    summary_updated_at        TEXT NOT NULL DEFAULT '',
+    prompt_tokens             INTEGER NOT NULL DEFAULT 0,
+    completion_tokens         INTEGER NOT NULL DEFAULT 0,
+    total_tokens              INTEGER NOT NULL DEFAULT 0,
+    cost                      REAL NOT NULL DEFAULT 0,
+    tool_usage                TEXT NOT NULL DEFAULT '{}',
+    created_at                TEXT NOT NULL
+);
+
+CREATE INDEX sessions_user_id ON sessions (user_id);
+
+CREATE TABLE conversations (
+    id                TEXT PRIMARY KEY,
+    session_id        TEXT NOT NULL REFERENCES sessions (id) ON DELETE CASCADE,
+    position          INTEGER NOT NULL,
+    input             TEXT NOT NULL DEFAULT '',
+    output            TEXT NOT NULL DEFAULT '',
+    format            TEXT,
+    prompt_tokens     INTEGER NOT NULL DEFAULT 0,
+    completion_tokens INTEGER NOT NULL DEFAULT 0,
+    total_tokens      INTEGER NOT NULL DEFAULT 0,
+    cost              REAL NOT NULL DEFAULT 0,
+    tool_usage        TEXT NOT NULL DEFAULT '{}',
+    created_at        TEXT NOT NULL
+);
+
+CREATE INDEX conversations_session_id ON conversations (session_id, position);
+CREATE INDEX conversations_created_at ON conversations (created_at);
+
+CREATE TABLE messages (
+    conversation_id   TEXT NOT NULL REFERENCES conversations (id) ON DELETE CASCADE,
+    position          INTEGER NOT NULL,
+    role              TEXT NOT NULL,
+    content           TEXT NOT NULL DEFAULT '',
+    tool_calls        TEXT,
+    tool_call_id      TEXT NOT NULL DEFAULT '',
+    model             TEXT NOT NULL DEFAULT '',
+    prompt_tokens     INTEGER,
+    completion_tokens INTEGER,
+    total_tokens      INTEGER,
+    cost              REAL,
+    PRIMARY KEY (conversation_id, position)
+);
@@ -0,0 +1,6 @@
+-- The revision of a row, compared and incremented by every save, so that
+-- concurrent saves of the same user or session do not overwrite each other.
+
+ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE sessions ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
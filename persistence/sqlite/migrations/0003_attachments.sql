@@ -0,0 +1,3 @@
+-- The attachments of a human message as a JSON array, without their data.
+
+ALTER TABLE messages ADD COLUMN attachments TEXT;
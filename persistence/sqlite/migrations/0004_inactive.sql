@@ -0,0 +1,3 @@
+-- Set while the user has unfollowed the bot.
+
+ALTER TABLE users ADD COLUMN inactive INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,10 @@
+-- The settings of the groups and rooms the bot is in. The keywords are a
+-- JSON array.
+
+CREATE TABLE group_settings (
+    id           TEXT PRIMARY KEY,
+    trigger_mode TEXT NOT NULL,
+    keywords     TEXT NOT NULL DEFAULT '[]',
+    created_at   TEXT NOT NULL,
+    updated_at   TEXT NOT NULL
+);
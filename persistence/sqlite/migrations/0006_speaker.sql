@@ -0,0 +1,3 @@
+-- The speaker of a human message in a group chat.
+
+ALTER TABLE messages ADD COLUMN name TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,7 @@
+-- The session each thread of a channel keeps. The thread is forgotten with
+-- its session, and starts a new one with its next message.
+
+CREATE TABLE threads (
+    id         TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL REFERENCES sessions (id) ON DELETE CASCADE
+);
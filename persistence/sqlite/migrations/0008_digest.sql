@@ -0,0 +1,4 @@
+-- The digest of a conversation as it was last saved, so that a session only
+-- rewrites the conversations that have changed since.
+
+ALTER TABLE conversations ADD COLUMN digest TEXT NOT NULL DEFAULT '';
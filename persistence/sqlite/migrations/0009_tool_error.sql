@@ -0,0 +1,3 @@
+-- Whether a tool message reports the failure of its call.
+
+ALTER TABLE messages ADD COLUMN is_error INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,127 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/flarexio/talkix/llm/message"
+)
+
+func marshalToolUsage(usage message.UsageByTool) (string, error) {
+	if usage == nil {
+		usage = make(message.UsageByTool)
+	}
+
+	bs, err := json.Marshal(usage)
+	if err != nil {
+		return "", err
+	}
+
+	return string(bs), nil
+}
+
+func unmarshalToolUsage(data string) (message.UsageByTool, error) {
+	usage := make(message.UsageByTool)
+	if data == "" {
+		return usage, nil
+	}
+
+	if err := json.Unmarshal([]byte(data), &usage); err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+// messageRow is a message as stored in the messages table. The usage columns
+// are null for messages that were not produced by a completion.
+type messageRow struct {
+	ConversationID   string
+	Position         int
+	Role             string
+	Content          string
+	Name             string
+	ToolCalls        sql.NullString
+	ToolCallID       string
+	IsError          bool
+	Attachments      sql.NullString
+	Model            string
+	PromptTokens     sql.NullInt64
+	CompletionTokens sql.NullInt64
+	TotalTokens      sql.NullInt64
+	Cost             sql.NullFloat64
+}
+
+func newMessageRow(conversationID string, position int, m message.Message) (*messageRow, error) {
+	row := &messageRow{
+		ConversationID: conversationID,
+		Position:       position,
+		Role:           string(m.Role),
+		Content:        m.Content,
+		Name:           m.Name,
+		ToolCallID:     m.ToolCallID,
+		IsError:        m.IsError,
+		Model:          m.Model,
+	}
+
+	if len(m.ToolCalls) > 0 {
+		bs, err := json.Marshal(m.ToolCalls)
+		if err != nil {
+			return nil, err
+		}
+
+		row.ToolCalls = sql.NullString{String: string(bs), Valid: true}
+	}
+
+	if len(m.Attachments) > 0 {
+		bs, err := json.Marshal(m.Attachments)
+		if err != nil {
+			return nil, err
+		}
+
+		row.Attachments = sql.NullString{String: string(bs), Valid: true}
+	}
+
+	if u := m.Usage; u != nil {
+		row.PromptTokens = sql.NullInt64{Int64: u.PromptTokens, Valid: true}
+		row.CompletionTokens = sql.NullInt64{Int64: u.CompletionTokens, Valid: true}
+		row.TotalTokens = sql.NullInt64{Int64: u.TotalTokens, Valid: true}
+		row.Cost = sql.NullFloat64{Float64: u.Cost, Valid: true}
+	}
+
+	return row, nil
+}
+
+func (row *messageRow) reconstitute() (message.Message, error) {
+	m := message.Message{
+		Role:       message.Role(row.Role),
+		Content:    row.Content,
+		Name:       row.Name,
+		ToolCallID: row.ToolCallID,
+		IsError:    row.IsError,
+		Model:      row.Model,
+	}
+
+	if row.ToolCalls.Valid {
+		if err := json.Unmarshal([]byte(row.ToolCalls.String), &m.ToolCalls); err != nil {
+			return m, err
+		}
+	}
+
+	if row.Attachments.Valid {
+		if err := json.Unmarshal([]byte(row.Attachments.String), &m.Attachments); err != nil {
+			return m, err
+		}
+	}
+
+	if row.TotalTokens.Valid {
+		m.Usage = &message.Usage{
+			PromptTokens:     row.PromptTokens.Int64,
+			CompletionTokens: row.CompletionTokens.Int64,
+			TotalTokens:      row.TotalTokens.Int64,
+			Cost:             row.Cost.Float64,
+		}
+	}
+
+	return m, nil
+}
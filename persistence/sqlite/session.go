@@ -0,0 +1,626 @@
+package sqlite
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/flarexio/talkix/llm/message"
+	"github.com/flarexio/talkix/session"
+)
+
+func NewSessionRepository(db *sql.DB) session.Repository {
+	return &sessionRepository{db}
+}
+
+type sessionRepository struct {
+	db *sql.DB
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func (repo *sessionRepository) Find(id string) (*session.Session, error) {
+	s, err := findSession(repo.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	convs, err := repo.findConversations(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Conversations = convs
+	return s, nil
+}
+
+func (repo *sessionRepository) FindRecent(id string, n int) (*session.Session, error) {
+	s, err := findSession(repo.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int
+	err = repo.db.QueryRow(`SELECT COUNT(*) FROM conversations WHERE session_id = ?`, id).Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := max(count-max(n, 0), 0)
+
+	convs, err := repo.queryConversations(`WHERE session_id = ? AND position >= ? ORDER BY position`, id, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Conversations = convs
+	s.ConversationOffset = offset
+	return s, nil
+}
+
+func findSession(q queryer, id string) (*session.Session, error) {
+	s := &session.Session{
+		ID:            id,
+		Conversations: make([]*session.Conversation, 0),
+	}
+
+	var (
+		summary          = &s.Summary
+		summaryStatus    string
+		summaryUpdatedAt string
+		toolUsage        string
+		createdAt        string
+	)
+
+	err := q.QueryRow(`
+		SELECT user_id,
+			summary_text, summary_status, summary_error,
+			summary_prompt_tokens, summary_completion_tokens, summary_total_tokens, summary_cost,
+			summary_updated_at,
+			prompt_tokens, completion_tokens, total_tokens, cost,
+			tool_usage, created_at, version
+		FROM sessions WHERE id = ?`, id,
+	).Scan(
+		&s.UserID,
+		&summary.Text, &summaryStatus, &summary.Error,
+		&summary.Usage.PromptTokens, &summary.Usage.CompletionTokens, &summary.Usage.TotalTokens, &summary.Usage.Cost,
+		&summaryUpdatedAt,
+		&s.Usage.PromptTokens, &s.Usage.CompletionTokens, &s.Usage.TotalTokens, &s.Usage.Cost,
+		&toolUsage, &createdAt, &s.Version,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, session.ErrSessionNotFound
+		}
+
+		return nil, err
+	}
+
+	summary.Status = session.SummaryStatus(summaryStatus)
+
+	if summary.UpdatedAt, err = parseTime(summaryUpdatedAt); err != nil {
+		return nil, err
+	}
+
+	if s.ToolUsage, err = unmarshalToolUsage(toolUsage); err != nil {
+		return nil, err
+	}
+
+	if s.CreatedAt, err = parseTime(createdAt); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (repo *sessionRepository) findConversations(sessionID string) ([]*session.Conversation, error) {
+	return repo.queryConversations(`WHERE session_id = ? ORDER BY position`, sessionID)
+}
+
+// queryConversations selects the conversations with the clause and loads
+// their messages.
+func (repo *sessionRepository) queryConversations(clause string, args ...any) ([]*session.Conversation, error) {
+	rows, err := repo.db.Query(`
+		SELECT id, input, output, format,
+			prompt_tokens, completion_tokens, total_tokens, cost,
+			tool_usage, created_at
+		FROM conversations `+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	convs := make([]*session.Conversation, 0)
+	index := make(map[string]*session.Conversation)
+
+	for rows.Next() {
+		conv := &session.Conversation{
+			Messages: make([]message.Message, 0),
+		}
+
+		var (
+			format    sql.NullString
+			toolUsage string
+			createdAt string
+		)
+
+		if err := rows.Scan(
+			&conv.ID, &conv.Input, &conv.Output, &format,
+			&conv.Usage.PromptTokens, &conv.Usage.CompletionTokens, &conv.Usage.TotalTokens, &conv.Usage.Cost,
+			&toolUsage, &createdAt,
+		); err != nil {
+			return nil, err
+		}
+
+		if format.Valid {
+			conv.Format = json.RawMessage(format.String)
+		}
+
+		if conv.ToolUsage, err = unmarshalToolUsage(toolUsage); err != nil {
+			return nil, err
+		}
+
+		if conv.CreatedAt, err = parseTime(createdAt); err != nil {
+			return nil, err
+		}
+
+		convs = append(convs, conv)
+		index[conv.ID] = conv
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows.Close()
+
+	if len(convs) == 0 {
+		return convs, nil
+	}
+
+	placeholders := make([]string, len(convs))
+	ids := make([]any, len(convs))
+	for i, conv := range convs {
+		placeholders[i] = "?"
+		ids[i] = conv.ID
+	}
+
+	msgRows, err := repo.db.Query(`
+		SELECT conversation_id, position, role, content, name,
+			tool_calls, tool_call_id, is_error, attachments, model,
+			prompt_tokens, completion_tokens, total_tokens, cost
+		FROM messages
+		WHERE conversation_id IN (`+strings.Join(placeholders, ", ")+`)
+		ORDER BY conversation_id, position`, ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer msgRows.Close()
+
+	for msgRows.Next() {
+		var row messageRow
+		if err := msgRows.Scan(
+			&row.ConversationID, &row.Position, &row.Role, &row.Content, &row.Name,
+			&row.ToolCalls, &row.ToolCallID, &row.IsError, &row.Attachments, &row.Model,
+			&row.PromptTokens, &row.CompletionTokens, &row.TotalTokens, &row.Cost,
+		); err != nil {
+			return nil, err
+		}
+
+		m, err := row.reconstitute()
+		if err != nil {
+			return nil, err
+		}
+
+		conv := index[row.ConversationID]
+		conv.Messages = append(conv.Messages, m)
+	}
+
+	if err := msgRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return convs, nil
+}
+
+func (repo *sessionRepository) Save(s *session.Session) error {
+	toolUsage, err := marshalToolUsage(s.ToolUsage)
+	if err != nil {
+		return err
+	}
+
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkVersion(tx, "sessions", s.ID, s.Version); err != nil {
+		if errors.Is(err, errVersionMismatch) {
+			return session.ErrConflict
+		}
+
+		return err
+	}
+
+	version := s.Version + 1
+
+	// The summary is owned by UpdateSummary, so it is only written when the
+	// session is created.
+	summary := s.Summary
+
+	_, err = tx.Exec(`
+		INSERT INTO sessions (id, user_id,
+			summary_text, summary_status, summary_error,
+			summary_prompt_tokens, summary_completion_tokens, summary_total_tokens, summary_cost,
+			summary_updated_at,
+			prompt_tokens, completion_tokens, total_tokens, cost,
+			tool_usage, created_at, version)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			user_id = excluded.user_id,
+			prompt_tokens = excluded.prompt_tokens,
+			completion_tokens = excluded.completion_tokens,
+			total_tokens = excluded.total_tokens,
+			cost = excluded.cost,
+			tool_usage = excluded.tool_usage,
+			version = excluded.version`,
+		s.ID, s.UserID,
+		summary.Text, string(summary.Status), summary.Error,
+		summary.Usage.PromptTokens, summary.Usage.CompletionTokens, summary.Usage.TotalTokens, summary.Usage.Cost,
+		formatTime(summary.UpdatedAt),
+		s.Usage.PromptTokens, s.Usage.CompletionTokens, s.Usage.TotalTokens, s.Usage.Cost,
+		toolUsage, formatTime(s.CreatedAt), version,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	// The conversations that were not loaded are kept as they are.
+	if offset := s.ConversationOffset; offset > 0 {
+		var count int
+		err := tx.QueryRow(`SELECT COUNT(*) FROM conversations WHERE session_id = ?`, s.ID).Scan(&count)
+		if err != nil {
+			return err
+		}
+
+		if offset > count {
+			return session.ErrInvalidOffset
+		}
+	}
+
+	for i, conv := range s.Conversations {
+		if err := saveConversation(tx, s.ID, s.ConversationOffset+i, conv); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.Version = version
+	return nil
+}
+
+// saveConversation only writes a conversation that is new or has changed
+// since it was stored, which its digest tells.
+func saveConversation(tx *sql.Tx, sessionID string, position int, conv *session.Conversation) error {
+	data, err := json.Marshal(&conv)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+
+	var (
+		storedSessionID string
+		storedPosition  int
+		storedDigest    string
+	)
+
+	err = tx.QueryRow(`SELECT session_id, position, digest FROM conversations WHERE id = ?`, conv.ID).
+		Scan(&storedSessionID, &storedPosition, &storedDigest)
+	switch {
+	case err == nil:
+		if storedSessionID == sessionID && storedPosition == position && storedDigest == digest {
+			return nil
+		}
+
+	case !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+
+	toolUsage, err := marshalToolUsage(conv.ToolUsage)
+	if err != nil {
+		return err
+	}
+
+	var format sql.NullString
+	if len(conv.Format) > 0 {
+		format = sql.NullString{String: string(conv.Format), Valid: true}
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO conversations (id, session_id, position, input, output, format,
+			prompt_tokens, completion_tokens, total_tokens, cost,
+			tool_usage, created_at, digest)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			session_id = excluded.session_id,
+			position = excluded.position,
+			input = excluded.input,
+			output = excluded.output,
+			format = excluded.format,
+			prompt_tokens = excluded.prompt_tokens,
+			completion_tokens = excluded.completion_tokens,
+			total_tokens = excluded.total_tokens,
+			cost = excluded.cost,
+			tool_usage = excluded.tool_usage,
+			digest = excluded.digest`,
+		conv.ID, sessionID, position, conv.Input, conv.Output, format,
+		conv.Usage.PromptTokens, conv.Usage.CompletionTokens, conv.Usage.TotalTokens, conv.Usage.Cost,
+		toolUsage, formatTime(conv.CreatedAt), digest,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM messages WHERE conversation_id = ?`, conv.ID); err != nil {
+		return err
+	}
+
+	for i, m := range conv.Messages {
+		row, err := newMessageRow(conv.ID, i, m)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO messages (conversation_id, position, role, content, name,
+				tool_calls, tool_call_id, is_error, attachments, model,
+				prompt_tokens, completion_tokens, total_tokens, cost)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			row.ConversationID, row.Position, row.Role, row.Content, row.Name,
+			row.ToolCalls, row.ToolCallID, row.IsError, row.Attachments, row.Model,
+			row.PromptTokens, row.CompletionTokens, row.TotalTokens, row.Cost,
+		)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (repo *sessionRepository) Delete(id string) error {
+	// The conversations and their messages are deleted by cascade.
+	result, err := repo.db.Exec(`DELETE FROM sessions WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return session.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+func (repo *sessionRepository) UpdateSummary(id string, update func(summary *session.Summary)) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	s, err := findSession(tx, id)
+	if err != nil {
+		return err
+	}
+
+	summary := s.Summary
+	update(&summary)
+
+	_, err = tx.Exec(`
+		UPDATE sessions SET
+			summary_text = ?,
+			summary_status = ?,
+			summary_error = ?,
+			summary_prompt_tokens = ?,
+			summary_completion_tokens = ?,
+			summary_total_tokens = ?,
+			summary_cost = ?,
+			summary_updated_at = ?
+		WHERE id = ?`,
+		summary.Text, string(summary.Status), summary.Error,
+		summary.Usage.PromptTokens, summary.Usage.CompletionTokens, summary.Usage.TotalTokens, summary.Usage.Cost,
+		formatTime(summary.UpdatedAt),
+		id,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lastActivity is the time of the last conversation of a session, or the
+// creation time of a session without conversations.
+const lastActivity = `COALESCE(
+	(SELECT MAX(c.created_at) FROM conversations c WHERE c.session_id = s.id),
+	s.created_at)`
+
+func (repo *sessionRepository) ListByUser(query session.SessionQuery) (*session.SessionPage, error) {
+	var cursor *session.Cursor
+	if query.Cursor != "" {
+		c, err := session.ParseCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		cursor = &c
+	}
+
+	size := session.PageSize(query.Limit)
+
+	var (
+		stmt string
+		args = []any{query.UserID}
+	)
+
+	switch query.OrderBy {
+	case session.OrderByActivity:
+		stmt = `SELECT id, activity FROM (
+			SELECT s.id, ` + lastActivity + ` AS activity
+			FROM sessions s WHERE s.user_id = ?
+		)`
+
+		if cursor != nil {
+			stmt += ` WHERE (activity, id) < (?, ?)`
+			args = append(args, formatTime(cursor.Time), cursor.ID)
+		}
+
+		stmt += ` ORDER BY activity DESC, id DESC LIMIT ?`
+
+	case "", session.OrderByID:
+		stmt = `SELECT id, '' FROM sessions WHERE user_id = ?`
+
+		if cursor != nil {
+			stmt += ` AND id > ?`
+			args = append(args, cursor.ID)
+		}
+
+		stmt += ` ORDER BY id LIMIT ?`
+
+	default:
+		return nil, errors.New("invalid session order: " + string(query.OrderBy))
+	}
+
+	args = append(args, size+1)
+
+	rows, err := repo.db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []session.Cursor
+	for rows.Next() {
+		var (
+			id       string
+			activity string
+		)
+
+		if err := rows.Scan(&id, &activity); err != nil {
+			return nil, err
+		}
+
+		t, err := parseTime(activity)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, session.Cursor{Time: t, ID: id})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows.Close()
+
+	page := &session.SessionPage{
+		Sessions: make([]*session.Session, 0),
+	}
+
+	if len(keys) > size {
+		keys = keys[:size]
+		page.NextCursor = keys[size-1].String()
+	}
+
+	for _, key := range keys {
+		s, err := repo.FindRecent(key.ID, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		page.Sessions = append(page.Sessions, s)
+	}
+
+	return page, nil
+}
+
+func (repo *sessionRepository) ListConversations(query session.ConversationQuery) (*session.ConversationPage, error) {
+	var cursor *session.Cursor
+	if query.Cursor != "" {
+		c, err := session.ParseCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		cursor = &c
+	}
+
+	if _, err := findSession(repo.db, query.SessionID); err != nil {
+		return nil, err
+	}
+
+	size := session.PageSize(query.Limit)
+
+	clause := `WHERE session_id = ?`
+	args := []any{query.SessionID}
+
+	if !query.Since.IsZero() {
+		clause += ` AND created_at >= ?`
+		args = append(args, formatTime(query.Since))
+	}
+
+	if !query.Until.IsZero() {
+		clause += ` AND created_at < ?`
+		args = append(args, formatTime(query.Until))
+	}
+
+	if cursor != nil {
+		clause += ` AND (created_at, id) > (?, ?)`
+		args = append(args, formatTime(cursor.Time), cursor.ID)
+	}
+
+	clause += ` ORDER BY created_at, id LIMIT ?`
+	args = append(args, size+1)
+
+	convs, err := repo.queryConversations(clause, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &session.ConversationPage{
+		Conversations: convs,
+	}
+
+	if len(convs) > size {
+		page.Conversations = convs[:size]
+
+		last := convs[size-1]
+		page.NextCursor = session.Cursor{Time: last.CreatedAt, ID: last.ID}.String()
+	}
+
+	return page, nil
+}
@@ -0,0 +1,592 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/flarexio/talkix/llm/message"
+	"github.com/flarexio/talkix/session"
+)
+
+type sessionRepoTestSuite struct {
+	suite.Suite
+	db       *sql.DB
+	sessions session.Repository
+	session  *session.Session
+}
+
+func (suite *sessionRepoTestSuite) SetupTest() {
+	db, err := Open("")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	sessions := NewSessionRepository(db)
+
+	s := session.NewSession("test-user")
+	sessions.Save(s)
+
+	suite.db = db
+	suite.sessions = sessions
+	suite.session = s
+}
+
+func (suite *sessionRepoTestSuite) TearDownTest() {
+	suite.db.Close()
+}
+
+func (suite *sessionRepoTestSuite) TestFind() {
+	session, err := suite.sessions.Find(suite.session.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(suite.session.ID, session.ID)
+	suite.Equal(suite.session.UserID, session.UserID)
+	suite.Equal(len(suite.session.Conversations), len(session.Conversations))
+	suite.True(suite.session.CreatedAt.Equal(session.CreatedAt))
+}
+
+func (suite *sessionRepoTestSuite) TestSave() {
+	s := suite.session
+
+	conversation := session.NewConversation()
+	conversation.AddMessage(message.SystemMessage("You are a helpful assistant."))
+	conversation.AddMessage(message.HumanMessage("What is the weather in Taipei?", message.Attachment{
+		Type:     message.AttachmentImage,
+		MIMEType: "image/jpeg",
+	}))
+
+	toolCall := message.AIMessage("", message.ToolCall{
+		ID:        "call_1",
+		Name:      "get_weather",
+		Arguments: map[string]any{"city": "Taipei"},
+	}, message.ToolCall{
+		ID:   "call_2",
+		Name: "get_time",
+	})
+	toolCall.Model = "gpt-4.1-mini"
+	toolCall.Usage = &message.Usage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120}
+
+	conversation.AddMessage(toolCall)
+	conversation.AddMessage(message.ToolMessage(`{"temperature":30}`, "call_1"))
+	conversation.AddMessage(message.ToolErrorMessage(`{"error":{"type":"unknown_tool"}}`, "call_2"))
+	conversation.AddMessage(message.AIMessage("It is 30°C in Taipei."))
+	conversation.SetIO(
+		"What is the weather in Taipei?",
+		"It is 30°C in Taipei.",
+	)
+	conversation.SetFormat(json.RawMessage(`{"type":"text"}`))
+	s.AddConversation(conversation)
+
+	err := suite.sessions.Save(suite.session)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// Saving again does not duplicate the conversation.
+	if err := suite.sessions.Save(suite.session); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	found, err := suite.sessions.Find(s.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if !suite.Len(found.Conversations, 1) {
+		return
+	}
+
+	conv := found.Conversations[0]
+	suite.Equal(conversation.Input, conv.Input)
+	suite.Equal(conversation.Output, conv.Output)
+	suite.JSONEq(string(conversation.Format), string(conv.Format))
+	suite.Equal(conversation.Messages, conv.Messages)
+	suite.Equal(conversation.Usage, conv.Usage)
+	suite.Equal(s.Usage, found.Usage)
+	suite.Equal(s.ToolUsage, found.ToolUsage)
+
+	// The messages can be queried with plain SQL.
+	var count int
+	err = suite.db.QueryRow(`
+		SELECT COUNT(*) FROM messages m
+		JOIN conversations c ON c.id = m.conversation_id
+		WHERE c.session_id = ? AND m.role = 'tool'`, s.ID,
+	).Scan(&count)
+
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(2, count)
+}
+
+func (suite *sessionRepoTestSuite) TestSaveSpeaker() {
+	s := suite.session
+
+	human := message.HumanMessage("Where shall we eat?")
+	human.Name = "Alice"
+
+	conversation := session.NewConversation()
+	conversation.AddMessage(human)
+	conversation.AddMessage(message.AIMessage("How about ramen?"))
+	s.AddConversation(conversation)
+
+	if err := suite.sessions.Save(s); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	found, err := suite.sessions.Find(s.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if !suite.Len(found.Conversations, 1) {
+		return
+	}
+
+	messages := found.Conversations[0].Messages
+	if !suite.Len(messages, 2) {
+		return
+	}
+
+	suite.Equal("Alice", messages[0].Name)
+	suite.Empty(messages[1].Name)
+}
+
+func (suite *sessionRepoTestSuite) TestSaveWritesChangedConversationsOnly() {
+	s := suite.session
+	for i := 0; i < 3; i++ {
+		conv := session.NewConversation()
+		conv.AddMessage(message.HumanMessage(fmt.Sprintf("Question %d", i)))
+		s.AddConversation(conv)
+	}
+
+	if err := suite.sessions.Save(s); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// The stored messages are marked, so that the ones written again lose
+	// their mark.
+	if _, err := suite.db.Exec(`UPDATE messages SET model = 'stale'`); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	s.Conversations[2].SetIO("Question 2", "Answer 2")
+	if err := suite.sessions.Save(s); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	models := make([]string, len(s.Conversations))
+	for i, conv := range s.Conversations {
+		err := suite.db.QueryRow(`SELECT model FROM messages WHERE conversation_id = ?`, conv.ID).
+			Scan(&models[i])
+		if err != nil {
+			suite.Fail(err.Error())
+			return
+		}
+	}
+
+	suite.Equal([]string{"stale", "stale", ""}, models)
+}
+
+func (suite *sessionRepoTestSuite) TestDelete() {
+	s := suite.session
+
+	conversation := session.NewConversation()
+	conversation.AddMessage(message.HumanMessage("Hello"))
+	s.AddConversation(conversation)
+
+	if err := suite.sessions.Save(s); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := suite.sessions.Delete(s.ID); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err := suite.sessions.Find(s.ID)
+	suite.ErrorIs(err, session.ErrSessionNotFound)
+
+	var count int
+	if err := suite.db.QueryRow(`SELECT COUNT(*) FROM messages`).Scan(&count); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Zero(count)
+
+	err = suite.sessions.Delete(s.ID)
+	suite.ErrorIs(err, session.ErrSessionNotFound)
+}
+
+func (suite *sessionRepoTestSuite) TestUpdateSummary() {
+	s := suite.session
+
+	err := suite.sessions.UpdateSummary(s.ID, func(summary *session.Summary) {
+		summary.Text = "Asked about the capital of France."
+		summary.Status = session.SummaryReady
+		summary.Usage.Add(message.Usage{TotalTokens: 100})
+	})
+
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// A save with a stale summary keeps the stored one.
+	s.Summary = session.Summary{Status: session.SummaryPending}
+	if err := suite.sessions.Save(s); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	found, err := suite.sessions.Find(s.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal("Asked about the capital of France.", found.Summary.Text)
+	suite.Equal(session.SummaryReady, found.Summary.Status)
+	suite.Equal(int64(100), found.TotalUsage().TotalTokens)
+
+	err = suite.sessions.UpdateSummary("missing", func(summary *session.Summary) {})
+	suite.ErrorIs(err, session.ErrSessionNotFound)
+}
+
+func (suite *sessionRepoTestSuite) TestMigrateTwice() {
+	suite.NoError(Migrate(suite.db))
+
+	var version int
+	if err := suite.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(9, version)
+}
+
+func (suite *sessionRepoTestSuite) TestFindRecent() {
+	s := suite.session
+
+	for i := 0; i < 5; i++ {
+		conv := session.NewConversation()
+		conv.AddMessage(message.HumanMessage(fmt.Sprintf("Question %d", i)))
+		s.AddConversation(conv)
+	}
+
+	if err := suite.sessions.Save(s); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	ids := make([]string, 0)
+	for _, conv := range s.Conversations {
+		ids = append(ids, conv.ID)
+	}
+
+	recent, err := suite.sessions.FindRecent(s.ID, 2)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if !suite.Len(recent.Conversations, 2) {
+		return
+	}
+
+	suite.Equal(3, recent.ConversationOffset)
+	suite.Equal(5, recent.ConversationCount())
+	suite.Equal(ids[3], recent.Conversations[0].ID)
+
+	// Saving a partially loaded session keeps the earlier conversations.
+	conv := session.NewConversation()
+	conv.AddMessage(message.HumanMessage("Question 5"))
+	recent.AddConversation(conv)
+	ids = append(ids, conv.ID)
+
+	if err := suite.sessions.Save(recent); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	meta, err := suite.sessions.FindRecent(s.ID, 0)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Empty(meta.Conversations)
+	suite.Equal(6, meta.ConversationCount())
+
+	if err := suite.sessions.Save(meta); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	found, err := suite.sessions.Find(s.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	foundIDs := make([]string, 0)
+	for _, conv := range found.Conversations {
+		foundIDs = append(foundIDs, conv.ID)
+	}
+
+	suite.Equal(ids, foundIDs)
+	suite.Zero(found.ConversationOffset)
+}
+
+func (suite *sessionRepoTestSuite) TestSaveConflict() {
+	first, err := suite.sessions.FindRecent(suite.session.ID, 0)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	second, err := suite.sessions.FindRecent(suite.session.ID, 0)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	first.AddConversation(session.NewConversation())
+	if err := suite.sessions.Save(first); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(second.Version+1, first.Version)
+
+	// The second copy was loaded before the first one was saved.
+	second.AddConversation(session.NewConversation())
+	err = suite.sessions.Save(second)
+	suite.ErrorIs(err, session.ErrConflict)
+
+	found, err := suite.sessions.Find(suite.session.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Len(found.Conversations, 1)
+	suite.Equal(first.Version, found.Version)
+
+	// A summary update does not conflict with the saves.
+	err = suite.sessions.UpdateSummary(found.ID, func(summary *session.Summary) {
+		summary.Text = "summary"
+	})
+
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	found.AddConversation(session.NewConversation())
+	suite.NoError(suite.sessions.Save(found))
+}
+
+func (suite *sessionRepoTestSuite) saveListSessions() []*session.Session {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	sessions := make([]*session.Session, 3)
+	for i := range sessions {
+		s := session.NewSession("list-user")
+		s.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		sessions[i] = s
+	}
+
+	// The first session has the most recent activity, the second none.
+	for i := 1; i <= 5; i++ {
+		conv := session.NewConversation()
+		conv.AddMessage(message.HumanMessage("Hello"))
+		conv.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		sessions[0].AddConversation(conv)
+	}
+
+	conv := session.NewConversation()
+	conv.AddMessage(message.HumanMessage("Hello"))
+	conv.CreatedAt = base.Add(150 * time.Minute)
+	sessions[2].AddConversation(conv)
+
+	for _, s := range sessions {
+		if err := suite.sessions.Save(s); err != nil {
+			suite.FailNow(err.Error())
+		}
+	}
+
+	return sessions
+}
+
+func (suite *sessionRepoTestSuite) TestListByUser() {
+	sessions := suite.saveListSessions()
+
+	page, err := suite.sessions.ListByUser(session.SessionQuery{
+		UserID: "list-user",
+		Limit:  2,
+	})
+
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if !suite.Len(page.Sessions, 2) {
+		return
+	}
+
+	suite.Equal(sessions[0].ID, page.Sessions[0].ID)
+	suite.Equal(sessions[1].ID, page.Sessions[1].ID)
+	suite.Empty(page.Sessions[0].Conversations)
+	suite.Equal(5, page.Sessions[0].ConversationCount())
+	suite.NotEmpty(page.NextCursor)
+
+	page, err = suite.sessions.ListByUser(session.SessionQuery{
+		UserID: "list-user",
+		Cursor: page.NextCursor,
+		Limit:  2,
+	})
+
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if suite.Len(page.Sessions, 1) {
+		suite.Equal(sessions[2].ID, page.Sessions[0].ID)
+	}
+
+	suite.Empty(page.NextCursor)
+
+	// The most recently active first.
+	var ids []string
+
+	query := session.SessionQuery{
+		UserID:  "list-user",
+		OrderBy: session.OrderByActivity,
+		Limit:   1,
+	}
+
+	for {
+		page, err := suite.sessions.ListByUser(query)
+		if err != nil {
+			suite.Fail(err.Error())
+			return
+		}
+
+		for _, s := range page.Sessions {
+			ids = append(ids, s.ID)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+
+		query.Cursor = page.NextCursor
+	}
+
+	suite.Equal([]string{sessions[0].ID, sessions[2].ID, sessions[1].ID}, ids)
+
+	// New activity moves a session to the front.
+	conv := session.NewConversation()
+	conv.CreatedAt = sessions[1].CreatedAt.Add(24 * time.Hour)
+	sessions[1].AddConversation(conv)
+
+	if err := suite.sessions.Save(sessions[1]); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	page, err = suite.sessions.ListByUser(session.SessionQuery{
+		UserID:  "list-user",
+		OrderBy: session.OrderByActivity,
+	})
+
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if suite.Len(page.Sessions, 3) {
+		suite.Equal(sessions[1].ID, page.Sessions[0].ID)
+	}
+
+	_, err = suite.sessions.ListByUser(session.SessionQuery{
+		UserID: "list-user",
+		Cursor: "not a cursor",
+	})
+
+	suite.ErrorIs(err, session.ErrInvalidCursor)
+}
+
+func (suite *sessionRepoTestSuite) TestListConversations() {
+	sessions := suite.saveListSessions()
+	base := sessions[0].CreatedAt
+
+	query := session.ConversationQuery{
+		SessionID: sessions[0].ID,
+		Since:     base.Add(2 * time.Hour),
+		Until:     base.Add(5 * time.Hour),
+		Limit:     2,
+	}
+
+	page, err := suite.sessions.ListConversations(query)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if !suite.Len(page.Conversations, 2) {
+		return
+	}
+
+	suite.Equal(sessions[0].Conversations[1].ID, page.Conversations[0].ID)
+	suite.Equal(sessions[0].Conversations[2].ID, page.Conversations[1].ID)
+	suite.NotEmpty(page.NextCursor)
+
+	query.Cursor = page.NextCursor
+
+	page, err = suite.sessions.ListConversations(query)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if suite.Len(page.Conversations, 1) {
+		suite.Equal(sessions[0].Conversations[3].ID, page.Conversations[0].ID)
+	}
+
+	suite.Empty(page.NextCursor)
+
+	_, err = suite.sessions.ListConversations(session.ConversationQuery{
+		SessionID: "missing",
+	})
+
+	suite.ErrorIs(err, session.ErrSessionNotFound)
+}
+
+func TestSessionRepoTestSuite(t *testing.T) {
+	suite.Run(t, new(sessionRepoTestSuite))
+}
@@ -0,0 +1,160 @@
+package sqlite
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Open opens the database at the path, or an in-memory database for an
+// empty path, and migrates it to the latest schema.
+func Open(path string) (*sql.DB, error) {
+	dsn := "file:" + path
+	if path == "" {
+		dsn = "file::memory:"
+	}
+
+	dsn += "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	if path != "" {
+		dsn += "&_pragma=journal_mode(WAL)"
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer; one connection also keeps an
+	// in-memory database alive.
+	db.SetMaxOpenConns(1)
+
+	if err := Migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Migrate applies the embedded migrations that have not been applied yet.
+// The version of a migration is the number its file name starts with.
+func Migrate(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		version, err := migrationVersion(name)
+		if err != nil {
+			return err
+		}
+
+		if err := migrate(db, version, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func migrate(db *sql.DB, version int, name string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var count int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM schema_migrations WHERE version = ?`, version).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	script, err := migrations.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(string(script)); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+		version, formatTime(time.Now()))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func migrationVersion(name string) (int, error) {
+	base := path.Base(name)
+	prefix, _, _ := strings.Cut(base, "_")
+	return strconv.Atoi(prefix)
+}
+
+// Times are stored as UTC text with a fixed number of fractional digits,
+// so that they read well and sort correctly in SQL.
+const timeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(timeFormat)
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+var errVersionMismatch = errors.New("version mismatch")
+
+// checkVersion compares the stored version of a row with the one it was
+// loaded at. A missing row is at version 0.
+func checkVersion(tx *sql.Tx, table, id string, version int) error {
+	var stored int
+
+	err := tx.QueryRow(`SELECT version FROM `+table+` WHERE id = ?`, id).Scan(&stored)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if stored != version {
+		return errVersionMismatch
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/flarexio/talkix/session"
+)
+
+func NewThreadRepository(db *sql.DB) session.ThreadRepository {
+	return &threadRepository{db}
+}
+
+type threadRepository struct {
+	db *sql.DB
+}
+
+func (repo *threadRepository) FindSessionID(thread string) (string, error) {
+	var id string
+
+	err := repo.db.QueryRow(`SELECT session_id FROM threads WHERE id = ?`, thread).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", session.ErrThreadNotFound
+		}
+
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (repo *threadRepository) SaveSessionID(thread string, sessionID string) error {
+	_, err := repo.db.Exec(`
+		INSERT INTO threads (id, session_id) VALUES (?, ?)
+		ON CONFLICT (id) DO UPDATE SET session_id = excluded.session_id`,
+		thread, sessionID,
+	)
+
+	return err
+}
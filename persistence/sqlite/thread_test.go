@@ -0,0 +1,56 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix/session"
+)
+
+func TestThreadRepository(t *testing.T) {
+	assert := assert.New(t)
+
+	db, err := Open("")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+	defer db.Close()
+
+	sessions := NewSessionRepository(db)
+	threads := NewThreadRepository(db)
+
+	thread := "slack:T1234:C1234:1700000000.000100"
+
+	_, err = threads.FindSessionID(thread)
+	assert.ErrorIs(err, session.ErrThreadNotFound)
+
+	s := session.NewSession("slack:T1234:U1234")
+	if err := sessions.Save(s); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	if err := threads.SaveSessionID(thread, s.ID); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	id, err := threads.FindSessionID(thread)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal(s.ID, id)
+
+	// The thread is forgotten with its session.
+	if err := sessions.Delete(s.ID); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	_, err = threads.FindSessionID(thread)
+	assert.ErrorIs(err, session.ErrThreadNotFound)
+}
@@ -0,0 +1,185 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/flarexio/talkix/user"
+)
+
+func NewUserRepository(db *sql.DB) user.Repository {
+	return &userRepository{db}
+}
+
+type userRepository struct {
+	db *sql.DB
+}
+
+func (repo *userRepository) Find(id string) (*user.User, error) {
+	u := &user.User{
+		ID:         id,
+		SessionIDs: make([]string, 0),
+	}
+
+	var toolUsage string
+
+	err := repo.db.QueryRow(`
+		SELECT selected_session_id,
+			prompt_tokens, completion_tokens, total_tokens, cost,
+			tool_usage, inactive, version
+		FROM users WHERE id = ?`, id,
+	).Scan(
+		&u.SelectedSessionID,
+		&u.Usage.PromptTokens, &u.Usage.CompletionTokens, &u.Usage.TotalTokens, &u.Usage.Cost,
+		&toolUsage, &u.Inactive, &u.Version,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, user.ErrUserNotFound
+		}
+
+		return nil, err
+	}
+
+	if u.ToolUsage, err = unmarshalToolUsage(toolUsage); err != nil {
+		return nil, err
+	}
+
+	rows, err := repo.db.Query(`
+		SELECT session_id FROM user_sessions
+		WHERE user_id = ? ORDER BY position`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sessionID string
+		if err := rows.Scan(&sessionID); err != nil {
+			return nil, err
+		}
+
+		u.SessionIDs = append(u.SessionIDs, sessionID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+func (repo *userRepository) Save(u *user.User) error {
+	toolUsage, err := marshalToolUsage(u.ToolUsage)
+	if err != nil {
+		return err
+	}
+
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkVersion(tx, "users", u.ID, u.Version); err != nil {
+		if errors.Is(err, errVersionMismatch) {
+			return user.ErrConflict
+		}
+
+		return err
+	}
+
+	version := u.Version + 1
+
+	_, err = tx.Exec(`
+		INSERT INTO users (id, selected_session_id,
+			prompt_tokens, completion_tokens, total_tokens, cost,
+			tool_usage, inactive, version)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			selected_session_id = excluded.selected_session_id,
+			prompt_tokens = excluded.prompt_tokens,
+			completion_tokens = excluded.completion_tokens,
+			total_tokens = excluded.total_tokens,
+			cost = excluded.cost,
+			tool_usage = excluded.tool_usage,
+			inactive = excluded.inactive,
+			version = excluded.version`,
+		u.ID, u.SelectedSessionID,
+		u.Usage.PromptTokens, u.Usage.CompletionTokens, u.Usage.TotalTokens, u.Usage.Cost,
+		toolUsage, u.Inactive, version,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM user_sessions WHERE user_id = ?`, u.ID); err != nil {
+		return err
+	}
+
+	for i, sessionID := range u.SessionIDs {
+		_, err := tx.Exec(`
+			INSERT INTO user_sessions (user_id, session_id, position)
+			VALUES (?, ?, ?)`, u.ID, sessionID, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	u.Version = version
+	return nil
+}
+
+func (repo *userRepository) List(query user.Query) (*user.Page, error) {
+	size := user.PageSize(query.Limit)
+
+	rows, err := repo.db.Query(`
+		SELECT id FROM users
+		WHERE id > ? ORDER BY id LIMIT ?`, query.Cursor, size+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows.Close()
+
+	page := &user.Page{
+		Users: make([]*user.User, 0),
+	}
+
+	if len(ids) > size {
+		ids = ids[:size]
+		page.NextCursor = ids[size-1]
+	}
+
+	for _, id := range ids {
+		u, err := repo.Find(id)
+		if err != nil {
+			return nil, err
+		}
+
+		page.Users = append(page.Users, u)
+	}
+
+	return page, nil
+}
@@ -0,0 +1,158 @@
+package sqlite
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix/llm/message"
+	"github.com/flarexio/talkix/user"
+)
+
+func TestUserRepository(t *testing.T) {
+	assert := assert.New(t)
+
+	db, err := Open("")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+	defer db.Close()
+
+	users := NewUserRepository(db)
+
+	_, err = users.Find("test-user")
+	assert.ErrorIs(err, user.ErrUserNotFound)
+
+	u := &user.User{ID: "test-user"}
+	u.AddSessionID("session-1")
+	u.AddSessionID("session-2")
+	u.AddUsage(
+		message.Usage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120, Cost: 0.01},
+		message.UsageByTool{"get_weather": {TotalTokens: 120}},
+	)
+
+	if err := users.Save(u); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	if err := u.RemoveSessionID("session-1"); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	u.AddSessionID("session-3")
+	u.Inactive = true
+
+	if err := users.Save(u); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	found, err := users.Find(u.ID)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal([]string{"session-2", "session-3"}, found.SessionIDs)
+	assert.Equal("session-3", found.SelectedSessionID)
+	assert.Equal(u.Usage, found.Usage)
+	assert.Equal(u.ToolUsage, found.ToolUsage)
+	assert.True(found.Inactive)
+}
+
+func TestListUsers(t *testing.T) {
+	assert := assert.New(t)
+
+	db, err := Open("")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+	defer db.Close()
+
+	users := NewUserRepository(db)
+
+	for i := range 5 {
+		u := &user.User{ID: fmt.Sprintf("user-%d", i)}
+		if err := users.Save(u); err != nil {
+			assert.Fail(err.Error())
+			return
+		}
+	}
+
+	page, err := users.List(user.Query{Limit: 3})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Len(page.Users, 3)
+	assert.Equal("user-2", page.NextCursor)
+
+	page, err = users.List(user.Query{Cursor: page.NextCursor, Limit: 3})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	if assert.Len(page.Users, 2) {
+		assert.Equal("user-3", page.Users[0].ID)
+	}
+
+	assert.Empty(page.NextCursor)
+}
+
+func TestUserConflict(t *testing.T) {
+	assert := assert.New(t)
+
+	db, err := Open("")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+	defer db.Close()
+
+	users := NewUserRepository(db)
+
+	if err := users.Save(&user.User{ID: "test-user"}); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	first, err := users.Find("test-user")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	second, err := users.Find("test-user")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	first.AddSessionID("session-1")
+	if err := users.Save(first); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal(2, first.Version)
+
+	second.AddSessionID("session-2")
+	err = users.Save(second)
+	assert.ErrorIs(err, user.ErrConflict)
+
+	found, err := users.Find("test-user")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal([]string{"session-1"}, found.SessionIDs)
+	assert.Equal(2, found.Version)
+}
@@ -0,0 +1,102 @@
+package talkix
+
+import (
+	"errors"
+	"sync"
+)
+
+const DefaultKeyedQueueSize = 10
+
+var (
+	ErrQueueFull   = errors.New("too many messages waiting for a reply")
+	ErrQueueClosed = errors.New("queue closed")
+)
+
+// KeyedQueue hands the items of a key to the handler one at a time and in
+// order, and the items of different keys in parallel. The dispatcher queues
+// the replies of each user with it, and the transports that answer right
+// away and reply in the background their updates by chat or thread.
+//
+// Every key has a queue of the given size, which is removed once it runs
+// empty. An item that does not fit is rejected with ErrQueueFull, so that
+// the channel delivers it again later.
+type KeyedQueue[K comparable, T any] struct {
+	handle func(key K, item T)
+	size   int
+	queues map[K]chan T
+	closed bool
+	wg     sync.WaitGroup
+	sync.Mutex
+}
+
+func NewKeyedQueue[K comparable, T any](size int, handle func(key K, item T)) *KeyedQueue[K, T] {
+	if size <= 0 {
+		size = DefaultKeyedQueueSize
+	}
+
+	return &KeyedQueue[K, T]{
+		handle: handle,
+		size:   size,
+		queues: make(map[K]chan T),
+	}
+}
+
+// Enqueue queues an item behind the others of its key. It fails with
+// ErrQueueClosed once the queue is closed.
+func (q *KeyedQueue[K, T]) Enqueue(key K, item T) error {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	queue, ok := q.queues[key]
+	if !ok {
+		queue = make(chan T, q.size)
+		q.queues[key] = queue
+
+		q.wg.Add(1)
+		go q.run(key, queue)
+	}
+
+	select {
+	case queue <- item:
+		return nil
+
+	default:
+		return ErrQueueFull
+	}
+}
+
+// run handles the items of a key, and removes its queue once it is empty.
+func (q *KeyedQueue[K, T]) run(key K, queue chan T) {
+	defer q.wg.Done()
+
+	for {
+		q.Lock()
+
+		var item T
+		select {
+		case item = <-queue:
+
+		default:
+			delete(q.queues, key)
+			q.Unlock()
+			return
+		}
+
+		q.Unlock()
+
+		q.handle(key, item)
+	}
+}
+
+// Close stops taking items and waits for the queued ones to be handled.
+func (q *KeyedQueue[K, T]) Close() {
+	q.Lock()
+	q.closed = true
+	q.Unlock()
+
+	q.wg.Wait()
+}
@@ -0,0 +1,53 @@
+package talkix
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyedQueue(t *testing.T) {
+	assert := assert.New(t)
+
+	release := make(chan struct{})
+
+	var (
+		handled = make(map[string][]int)
+		mu      sync.Mutex
+	)
+
+	q := NewKeyedQueue(2, func(key string, item int) {
+		<-release
+
+		mu.Lock()
+		handled[key] = append(handled[key], item)
+		mu.Unlock()
+	})
+
+	// The first item is taken at once or stays queued, so at most three
+	// items of a key fit while it is blocked.
+	var full bool
+	for i := range 4 {
+		if err := q.Enqueue("a", i); err != nil {
+			assert.ErrorIs(err, ErrQueueFull)
+			full = true
+		}
+	}
+
+	assert.True(full)
+	assert.NoError(q.Enqueue("b", 0))
+
+	close(release)
+	q.Close()
+
+	assert.ErrorIs(q.Enqueue("a", 4), ErrQueueClosed)
+
+	// The items of a key are handled in order.
+	assert.Equal([]int{0}, handled["b"])
+	if assert.GreaterOrEqual(len(handled["a"]), 2) {
+		for i, item := range handled["a"] {
+			assert.Equal(i, item)
+		}
+	}
+}
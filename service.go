@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 
+	"github.com/flarexio/talkix/llm/message"
 	"github.com/flarexio/talkix/session"
 	"github.com/flarexio/talkix/user"
 )
@@ -11,16 +12,25 @@ import (
 type Service interface {
 	Name() string
 	ReplyMessage(ctx context.Context, msg Message) (reply Message, err error)
+	StreamReply(ctx context.Context, msg Message) (events <-chan StreamEvent, err error)
 }
 
 type ServiceMiddleware func(Service) Service
 
 type SessionService interface {
-	ListSessions(ctx context.Context) (sessions []*session.Session, selectedSessionID string, err error)
+	ListSessions(ctx context.Context, query session.SessionQuery) (page *session.SessionPage, selectedSessionID string, err error)
 	Session(ctx context.Context, sessionID string) (*session.Session, error)
+	ListConversations(ctx context.Context, query session.ConversationQuery) (*session.ConversationPage, error)
 	CreateSession(ctx context.Context) (*session.Session, error)
 	SwitchSession(ctx context.Context, sessionID string) error
 	DeleteSession(ctx context.Context, sessionID string) error
+	Usage(ctx context.Context) (*UsageReport, error)
+}
+
+type UsageReport struct {
+	Usage     message.Usage            `json:"usage"`
+	ToolUsage message.UsageByTool      `json:"tool_usage"`
+	Sessions  map[string]message.Usage `json:"sessions"`
 }
 
 type SessionServiceMiddleware func(SessionService) SessionService
@@ -37,7 +47,7 @@ type sessionService struct {
 	sessions session.Repository
 }
 
-func (svc *sessionService) ListSessions(ctx context.Context) ([]*session.Session, string, error) {
+func (svc *sessionService) ListSessions(ctx context.Context, query session.SessionQuery) (*session.SessionPage, string, error) {
 	userCtx, ok := ctx.Value(UserKey).(*user.User)
 	if !ok {
 		return nil, "", errors.New("user not found in context")
@@ -48,17 +58,14 @@ func (svc *sessionService) ListSessions(ctx context.Context) ([]*session.Session
 		return nil, "", errors.New(err.Error())
 	}
 
-	sessions := make([]*session.Session, 0)
-	for _, id := range u.SessionIDs {
-		session, err := svc.sessions.Find(id)
-		if err != nil {
-			return nil, "", errors.New(err.Error())
-		}
+	query.UserID = u.ID
 
-		sessions = append(sessions, session)
+	page, err := svc.sessions.ListByUser(query)
+	if err != nil {
+		return nil, "", errors.New(err.Error())
 	}
 
-	return sessions, u.SelectedSessionID, nil
+	return page, u.SelectedSessionID, nil
 }
 
 func (svc *sessionService) Session(ctx context.Context, sessionID string) (*session.Session, error) {
@@ -79,6 +86,19 @@ func (svc *sessionService) Session(ctx context.Context, sessionID string) (*sess
 	return session, nil
 }
 
+func (svc *sessionService) ListConversations(ctx context.Context, query session.ConversationQuery) (*session.ConversationPage, error) {
+	if _, err := svc.Session(ctx, query.SessionID); err != nil {
+		return nil, err
+	}
+
+	page, err := svc.sessions.ListConversations(query)
+	if err != nil {
+		return nil, errors.New(err.Error())
+	}
+
+	return page, nil
+}
+
 func (svc *sessionService) CreateSession(ctx context.Context) (*session.Session, error) {
 	userCtx, ok := ctx.Value(UserKey).(*user.User)
 	if !ok {
@@ -95,9 +115,12 @@ func (svc *sessionService) CreateSession(ctx context.Context) (*session.Session,
 		return nil, errors.New(err.Error())
 	}
 
-	u.AddSessionID(newSession.ID)
+	err = saveUser(svc.users, u, func(u *user.User) error {
+		u.AddSessionID(newSession.ID)
+		return nil
+	})
 
-	if err := svc.users.Save(u); err != nil {
+	if err != nil {
 		return nil, errors.New(err.Error())
 	}
 
@@ -120,9 +143,10 @@ func (svc *sessionService) SwitchSession(ctx context.Context, sessionID string)
 		return errors.New(err.Error())
 	}
 
-	u.SelectedSessionID = session.ID
-
-	return svc.users.Save(u)
+	return saveUser(svc.users, u, func(u *user.User) error {
+		u.SelectedSessionID = session.ID
+		return nil
+	})
 }
 
 func (svc *sessionService) DeleteSession(ctx context.Context, sessionID string) error {
@@ -145,13 +169,66 @@ func (svc *sessionService) DeleteSession(ctx context.Context, sessionID string)
 		return errors.New("session does not belong to user")
 	}
 
-	if err := u.RemoveSessionID(session.ID); err != nil {
-		return err
-	}
+	err = saveUser(svc.users, u, func(u *user.User) error {
+		return u.RemoveSessionID(session.ID)
+	})
 
-	if err := svc.users.Save(u); err != nil {
+	if err != nil {
 		return err
 	}
 
 	return svc.sessions.Delete(session.ID)
 }
+
+func (svc *sessionService) Usage(ctx context.Context) (*UsageReport, error) {
+	userCtx, ok := ctx.Value(UserKey).(*user.User)
+	if !ok {
+		return nil, errors.New("user not found in context")
+	}
+
+	u, err := svc.users.Find(userCtx.ID)
+	if err != nil {
+		return nil, errors.New(err.Error())
+	}
+
+	report := &UsageReport{
+		Usage:     u.Usage,
+		ToolUsage: u.ToolUsage,
+		Sessions:  make(map[string]message.Usage),
+	}
+
+	// The usage is stored with the session, so its conversations are not
+	// loaded. A session that is gone leaves its usage to the user total.
+	for _, id := range u.SessionIDs {
+		s, err := svc.sessions.FindRecent(id, 0)
+		if err != nil {
+			if errors.Is(err, session.ErrSessionNotFound) {
+				continue
+			}
+
+			return nil, errors.New(err.Error())
+		}
+
+		report.Sessions[s.ID] = s.TotalUsage()
+	}
+
+	return report, nil
+}
+
+type UserService interface {
+	ListUsers(ctx context.Context, query user.Query) (*user.Page, error)
+}
+
+type UserServiceMiddleware func(UserService) UserService
+
+func NewUserService(users user.Repository) UserService {
+	return &userService{users}
+}
+
+type userService struct {
+	users user.Repository
+}
+
+func (svc *userService) ListUsers(ctx context.Context, query user.Query) (*user.Page, error) {
+	return svc.users.List(query)
+}
@@ -0,0 +1,138 @@
+package session
+
+import (
+	"unicode/utf8"
+
+	"github.com/flarexio/talkix/llm/message"
+)
+
+const DefaultHistoryBudget = 4000
+
+type HistoryOptions struct {
+	// Budget is the estimated number of tokens the history may take.
+	Budget int
+
+	// MaxTurns limits the number of turns regardless of the budget; zero
+	// means no limit.
+	MaxTurns int
+
+	// IncludeTools keeps the tool calls and results of a turn as long as
+	// they fit into the budget.
+	IncludeTools bool
+}
+
+// History packs the turns of the session, newest first, into the token
+// budget and returns them in chronological order. When older turns do not
+// fit, the session summary stands in for them.
+func (s *Session) History(opts HistoryOptions) []message.Message {
+	budget := opts.Budget
+	if budget <= 0 {
+		budget = DefaultHistoryBudget
+	}
+
+	var summary []message.Message
+	if text := s.Summary.Text; text != "" {
+		summary = []message.Message{
+			message.SystemMessage("Summary of the earlier conversation:\n" + text),
+		}
+
+		budget -= EstimateTokens(summary...)
+	}
+
+	turns := make([][]message.Message, 0)
+
+	// The conversations that were not loaded are only in the summary.
+	complete := s.ConversationOffset == 0
+
+	for i := len(s.Conversations) - 1; i >= 0; i-- {
+		if opts.MaxTurns > 0 && len(turns) >= opts.MaxTurns {
+			complete = false
+			break
+		}
+
+		c := s.Conversations[i]
+
+		turn := c.lastTurn(opts.IncludeTools)
+		if turn == nil {
+			continue
+		}
+
+		cost := EstimateTokens(turn...)
+		if cost > budget && opts.IncludeTools {
+			// Fall back to the question and answer only.
+			turn = c.lastTurn(false)
+			cost = EstimateTokens(turn...)
+		}
+
+		if cost > budget {
+			complete = false
+			break
+		}
+
+		budget -= cost
+		turns = append(turns, turn)
+	}
+
+	history := make([]message.Message, 0)
+	if !complete {
+		history = append(history, summary...)
+	}
+
+	for i := len(turns) - 1; i >= 0; i-- {
+		history = append(history, turns[i]...)
+	}
+
+	return history
+}
+
+// lastTurn returns the exchange that the conversation was created for. The
+// turns before it are the history that was replayed to the model.
+func (c *Conversation) lastTurn(includeTools bool) []message.Message {
+	turns := c.turns(includeTools)
+	if len(turns) == 0 {
+		return nil
+	}
+
+	return turns[len(turns)-1]
+}
+
+// EstimateTokens roughly estimates the tokens of the messages without a
+// tokenizer: about four characters per token for ASCII text, and one token
+// per character for other scripts such as Chinese.
+func EstimateTokens(msgs ...message.Message) int {
+	tokens := 0
+	for _, m := range msgs {
+		tokens += 4 // role and message framing
+		tokens += estimateText(m.Content)
+
+		for _, tc := range m.ToolCalls {
+			tokens += estimateText(tc.Name)
+			tokens += estimateText(tc.RawArguments)
+
+			for k, v := range tc.Arguments {
+				tokens += estimateText(k)
+
+				if s, ok := v.(string); ok {
+					tokens += estimateText(s)
+				} else {
+					tokens++
+				}
+			}
+		}
+	}
+
+	return tokens
+}
+
+func estimateText(text string) int {
+	ascii, others := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			others++
+		}
+	}
+
+	return (ascii+3)/4 + others
+}
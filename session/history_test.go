@@ -0,0 +1,121 @@
+package session
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix/llm/message"
+)
+
+func newTestSession(n int) *Session {
+	s := NewSession("test-user")
+	s.Summary.Text = "The user asked about the weather in several cities."
+
+	var history []message.Message
+	for i := 1; i <= n; i++ {
+		question := message.HumanMessage(fmt.Sprintf("What is the weather in city %d?", i))
+		call := message.AIMessage("", message.ToolCall{
+			ID:        fmt.Sprintf("call_%d", i),
+			Name:      "get_weather",
+			Arguments: map[string]any{"city": fmt.Sprintf("city %d", i)},
+		})
+		result := message.ToolMessage(`{"temperature":30,"humidity":70,"condition":"sunny"}`, call.ToolCalls[0].ID)
+		answer := message.AIMessage(fmt.Sprintf("It is sunny in city %d.", i))
+
+		// Like the AI service, each conversation starts with the replayed
+		// history, followed by its own turn.
+		c := NewConversation()
+		c.AddMessage(message.SystemMessage("You are a helpful assistant."))
+		c.AddMessage(history...)
+		c.AddMessage(question, call, result, answer)
+
+		s.Conversations = append(s.Conversations, c)
+
+		history = append(history, question, answer)
+	}
+
+	return s
+}
+
+func TestHistoryWalksAllConversations(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newTestSession(8)
+
+	history := s.History(HistoryOptions{})
+	if !assert.Len(history, 16) {
+		return
+	}
+
+	// every conversation contributes its own turn once, oldest first
+	for i := 0; i < 8; i++ {
+		assert.Equal(fmt.Sprintf("What is the weather in city %d?", i+1), history[2*i].Content)
+		assert.Equal(fmt.Sprintf("It is sunny in city %d.", i+1), history[2*i+1].Content)
+	}
+}
+
+func TestHistoryBudgetFallsBackToSummary(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newTestSession(8)
+
+	turn := EstimateTokens(s.Conversations[0].lastTurn(false)...)
+	summary := EstimateTokens(message.SystemMessage("Summary of the earlier conversation:\n" + s.Summary.Text))
+
+	history := s.History(HistoryOptions{Budget: summary + 3*turn})
+	if !assert.Len(history, 7) {
+		return
+	}
+
+	assert.Equal(message.RoleSystem, history[0].Role)
+	assert.Contains(history[0].Content, s.Summary.Text)
+	assert.Equal("What is the weather in city 6?", history[1].Content)
+	assert.Equal("It is sunny in city 8.", history[6].Content)
+
+	history = s.History(HistoryOptions{MaxTurns: 2})
+	assert.Len(history, 5)
+	assert.Equal(message.RoleSystem, history[0].Role)
+}
+
+func TestHistoryIncludeTools(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newTestSession(3)
+
+	history := s.History(HistoryOptions{IncludeTools: true})
+	if !assert.Len(history, 12) {
+		return
+	}
+
+	assert.Equal("call_1", history[1].ToolCalls[0].ID)
+	assert.Equal("call_1", history[2].ToolCallID)
+
+	// the newest turn keeps its tools, older ones drop to the pair
+	withTools := EstimateTokens(s.Conversations[2].lastTurn(true)...)
+	pair := EstimateTokens(s.Conversations[1].lastTurn(false)...)
+
+	history = s.History(HistoryOptions{
+		Budget:       EstimateTokens(message.SystemMessage("Summary of the earlier conversation:\n"+s.Summary.Text)) + withTools + pair,
+		IncludeTools: true,
+	})
+
+	roles := make([]message.Role, len(history))
+	for i, m := range history {
+		roles[i] = m.Role
+	}
+
+	assert.Equal([]message.Role{
+		message.RoleSystem,
+		message.RoleHuman, message.RoleAI,
+		message.RoleHuman, message.RoleAI, message.RoleTool, message.RoleAI,
+	}, roles)
+}
+
+func TestEstimateTokens(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(4+3, EstimateTokens(message.HumanMessage("Hello, world")))
+	assert.Equal(4+6, EstimateTokens(message.HumanMessage("台中天氣如何")))
+}
@@ -0,0 +1,150 @@
+package session
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// PageSize returns the default page size for a missing limit and caps the
+// others.
+func PageSize(limit int) int {
+	if limit <= 0 {
+		return DefaultPageSize
+	}
+
+	return min(limit, MaxPageSize)
+}
+
+type SessionOrder string
+
+const (
+	// OrderByID lists the sessions in the order they were created, as their
+	// IDs are ULIDs.
+	OrderByID SessionOrder = "id"
+
+	// OrderByActivity lists the most recently active sessions first.
+	OrderByActivity SessionOrder = "activity"
+)
+
+type SessionQuery struct {
+	UserID  string
+	OrderBy SessionOrder
+	Cursor  string
+	Limit   int
+}
+
+type SessionPage struct {
+	Sessions   []*Session
+	NextCursor string
+}
+
+// ConversationQuery lists the conversations of a session in chronological
+// order, created within [Since, Until). A zero time leaves that end open.
+type ConversationQuery struct {
+	SessionID string
+	Since     time.Time
+	Until     time.Time
+	Cursor    string
+	Limit     int
+}
+
+// Contains reports whether the time is within the range of the query.
+func (q ConversationQuery) Contains(t time.Time) bool {
+	if !q.Since.IsZero() && t.Before(q.Since) {
+		return false
+	}
+
+	if !q.Until.IsZero() && !t.Before(q.Until) {
+		return false
+	}
+
+	return true
+}
+
+type ConversationPage struct {
+	Conversations []*Conversation
+	NextCursor    string
+}
+
+// Cursor is the position after the last item of a page: its sort time, if
+// the order has one, and its ID. It is passed to clients as an opaque
+// string.
+type Cursor struct {
+	Time time.Time
+	ID   string
+}
+
+// After reports whether an item sorts after the cursor in ascending order.
+func (c Cursor) After(t time.Time, id string) bool {
+	if !t.Equal(c.Time) {
+		return t.After(c.Time)
+	}
+
+	return id > c.ID
+}
+
+// Before reports whether an item sorts before the cursor in ascending order,
+// i.e. after it in descending order.
+func (c Cursor) Before(t time.Time, id string) bool {
+	if !t.Equal(c.Time) {
+		return t.Before(c.Time)
+	}
+
+	return id < c.ID
+}
+
+func (c Cursor) String() string {
+	var nanos int64
+	if !c.Time.IsZero() {
+		nanos = c.Time.UnixNano()
+	}
+
+	raw := strconv.FormatInt(nanos, 10) + ":" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func ParseCursor(cursor string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	nanosStr, id, ok := strings.Cut(string(raw), ":")
+	if !ok || id == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	nanos, err := strconv.ParseInt(nanosStr, 10, 64)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var t time.Time
+	if nanos != 0 {
+		t = time.Unix(0, nanos)
+	}
+
+	return Cursor{t, id}, nil
+}
+
+// LastActivity is the time of the last loaded conversation, or the creation
+// time of a session without conversations.
+func (s *Session) LastActivity() time.Time {
+	if n := len(s.Conversations); n > 0 {
+		return s.Conversations[n-1].CreatedAt
+	}
+
+	return s.CreatedAt
+}
@@ -4,10 +4,44 @@ import "errors"
 
 var (
 	ErrSessionNotFound = errors.New("session not found")
+	ErrInvalidOffset   = errors.New("conversation offset beyond stored conversations")
+	ErrConflict        = errors.New("session was modified concurrently")
+	ErrThreadNotFound  = errors.New("thread not found")
 )
 
 type Repository interface {
 	Find(id string) (*Session, error)
+
+	// FindRecent loads the session with its last n conversations only, or
+	// its metadata only for n = 0. Save keeps the conversations that were
+	// not loaded.
+	FindRecent(id string, n int) (*Session, error)
+
+	// Save fails with ErrConflict when the session was saved by someone else
+	// since it was loaded, and increments its version otherwise.
 	Save(s *Session) error
+
 	Delete(id string) error
+
+	// UpdateSummary changes the stored summary of a session in place,
+	// without loading or writing its conversations.
+	UpdateSummary(id string, update func(summary *Summary)) error
+
+	// ListByUser returns a page of the sessions of a user, loaded without
+	// their conversations as FindRecent(id, 0) loads them.
+	ListByUser(query SessionQuery) (*SessionPage, error)
+
+	// ListConversations returns a page of the conversations of a session.
+	ListConversations(query ConversationQuery) (*ConversationPage, error)
+}
+
+// ThreadRepository keeps the session of each thread of a channel. A thread
+// has one session, whoever of its members writes, apart from the session
+// they selected.
+type ThreadRepository interface {
+	// FindSessionID fails with ErrThreadNotFound for a thread that has not
+	// started a session yet.
+	FindSessionID(thread string) (string, error)
+
+	SaveSessionID(thread string, sessionID string) error
 }
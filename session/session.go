@@ -2,6 +2,7 @@ package session
 
 import (
 	"encoding/json"
+	"slices"
 	"time"
 
 	"github.com/oklog/ulid/v2"
@@ -14,6 +15,7 @@ func NewSession(userID string) *Session {
 		ID:            ulid.Make().String(),
 		UserID:        userID,
 		Conversations: make([]*Conversation, 0),
+		ToolUsage:     make(message.UsageByTool),
 		CreatedAt:     time.Now(),
 	}
 }
@@ -21,26 +23,52 @@ func NewSession(userID string) *Session {
 type Session struct {
 	ID            string
 	UserID        string
-	Summary       string
+	Summary       Summary
 	Conversations []*Conversation
+	Usage         message.Usage
+	ToolUsage     message.UsageByTool
 	CreatedAt     time.Time
+
+	// ConversationOffset is the number of earlier conversations that were
+	// not loaded with the session, see Repository.FindRecent.
+	ConversationOffset int
+
+	// Version is the revision the session was loaded at, see
+	// Repository.Save.
+	Version int
 }
 
+// AddConversation appends the conversation and adds its usage to the session.
+// The summary is updated separately by a Summarizer.
 func (s *Session) AddConversation(conv *Conversation) {
 	s.Conversations = append(s.Conversations, conv)
 
-	summary, err := GenerateSummary(s.Summary, conv)
-	if err != nil {
-		return
+	s.Usage.Add(conv.Usage)
+
+	if s.ToolUsage == nil {
+		s.ToolUsage = make(message.UsageByTool)
 	}
 
-	s.Summary = summary
+	s.ToolUsage.Add(conv.ToolUsage)
+}
+
+// ConversationCount includes the conversations that were not loaded.
+func (s *Session) ConversationCount() int {
+	return s.ConversationOffset + len(s.Conversations)
+}
+
+// TotalUsage includes the usage of the summaries besides the conversations.
+func (s *Session) TotalUsage() message.Usage {
+	usage := s.Usage
+	usage.Add(s.Summary.Usage)
+	return usage
 }
 
 func NewConversation() *Conversation {
 	return &Conversation{
 		ID:        ulid.Make().String(),
 		Messages:  make([]message.Message, 0),
+		ToolUsage: make(message.UsageByTool),
 		CreatedAt: time.Now(),
 	}
 }
@@ -51,6 +79,8 @@ type Conversation struct {
 	Output    string
 	Format    json.RawMessage
 	Messages  []message.Message
+	Usage     message.Usage
+	ToolUsage message.UsageByTool
 	CreatedAt time.Time
 }
 
@@ -65,45 +95,150 @@ func (c *Conversation) SetFormat(format json.RawMessage) {
 
 func (c *Conversation) AddMessage(message ...message.Message) {
 	c.Messages = append(c.Messages, message...)
+	c.RecordUsage(message...)
 }
 
-func (c *Conversation) TrimMessages(lastN int) []message.Message {
-	type pair struct {
-		h message.Message // Human
-		a message.Message // AI
+// RecordUsage accumulates the usage carried by AI messages, including those
+// that are not stored in the conversation, such as formatter output. A
+// completion that processed tool results is also attributed to those tools.
+func (c *Conversation) RecordUsage(msgs ...message.Message) {
+	if c.ToolUsage == nil {
+		c.ToolUsage = make(message.UsageByTool)
 	}
 
-	var lastHuman *message.Message
+	var tools []string
+	for _, m := range msgs {
+		if m.Role != message.RoleAI {
+			continue
+		}
+
+		if m.Usage != nil {
+			c.Usage.Add(*m.Usage)
+
+			for _, name := range tools {
+				c.ToolUsage.Add(message.UsageByTool{name: *m.Usage})
+			}
+		}
 
-	pairs := make([]pair, 0)
+		tools = tools[:0]
+		for _, tc := range m.ToolCalls {
+			if !slices.Contains(tools, tc.Name) {
+				tools = append(tools, tc.Name)
+			}
+		}
+	}
+}
+
+// TrimMessages returns the last N question and answer pairs as history for a
+// new completion.
+func (c *Conversation) TrimMessages(lastN int) []message.Message {
+	return trimTurns(c.turns(false), lastN)
+}
+
+// TrimMessagesWithTools is like TrimMessages, but also keeps the tool calls
+// and tool results that led to each answer.
+func (c *Conversation) TrimMessagesWithTools(lastN int) []message.Message {
+	return trimTurns(c.turns(true), lastN)
+}
+
+// turns groups the messages into turns, each one starting with a human message
+// and ending with the final AI answer. Unanswered turns are dropped, as are
+// tool calls whose results are incomplete, so that the history always replays.
+func (c *Conversation) turns(includeTools bool) [][]message.Message {
+	turns := make([][]message.Message, 0)
+
+	var current []message.Message
 	for _, m := range c.Messages {
+		// The history is context for a new completion, so the usage
+		// of the original one must not be counted again.
+		m.Model = ""
+		m.Usage = nil
 
 		switch m.Role {
 		case message.RoleHuman:
-			lastHuman = &m
+			current = []message.Message{m}
 
 		case message.RoleAI:
-			if lastHuman == nil {
+			if current == nil {
 				continue
 			}
 
 			if len(m.ToolCalls) > 0 {
+				if includeTools {
+					current = append(current, m)
+				}
+
 				continue
 			}
 
-			pairs = append(pairs, pair{h: *lastHuman, a: m})
-			lastHuman = nil
+			current = append(current, m)
+			turns = append(turns, completeToolCalls(current))
+			current = nil
+
+		case message.RoleTool:
+			if current != nil && includeTools {
+				current = append(current, m)
+			}
+		}
+	}
+
+	return turns
+}
+
+// completeToolCalls removes the tool calls of a turn that did not get all of
+// their results, together with the results they did get.
+func completeToolCalls(turn []message.Message) []message.Message {
+	answered := make(map[string]bool)
+	for _, m := range turn {
+		if m.Role == message.RoleTool {
+			answered[m.ToolCallID] = true
+		}
+	}
+
+	kept := make(map[string]bool)
+	result := make([]message.Message, 0, len(turn))
+	for _, m := range turn {
+		switch {
+		case m.Role == message.RoleAI && len(m.ToolCalls) > 0:
+			complete := true
+			for _, tc := range m.ToolCalls {
+				if !answered[tc.ID] {
+					complete = false
+					break
+				}
+			}
+
+			if !complete {
+				continue
+			}
+
+			for _, tc := range m.ToolCalls {
+				kept[tc.ID] = true
+			}
+
+		case m.Role == message.RoleTool:
+			if !kept[m.ToolCallID] {
+				continue
+			}
+
+			delete(kept, m.ToolCallID)
 		}
+
+		result = append(result, m)
 	}
 
+	return result
+}
+
+func trimTurns(turns [][]message.Message, lastN int) []message.Message {
 	start := 0
-	if lastN > 0 && len(pairs) > lastN {
-		start = len(pairs) - lastN
+	if lastN > 0 && len(turns) > lastN {
+		start = len(turns) - lastN
 	}
 
 	result := make([]message.Message, 0)
-	for _, p := range pairs[start:] {
-		result = append(result, p.h, p.a)
+	for _, turn := range turns[start:] {
+		result = append(result, turn...)
 	}
 
 	return result
@@ -0,0 +1,99 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix/llm/message"
+)
+
+func TestConversationRecordUsage(t *testing.T) {
+	assert := assert.New(t)
+
+	call := message.AIMessage("", message.ToolCall{ID: "call_1", Name: "get_weather"})
+	call.Usage = &message.Usage{PromptTokens: 100, CompletionTokens: 10, TotalTokens: 110, Cost: 0.1}
+
+	answer := message.AIMessage("It is sunny.")
+	answer.Usage = &message.Usage{PromptTokens: 200, CompletionTokens: 20, TotalTokens: 220, Cost: 0.2}
+
+	c := NewConversation()
+	c.AddMessage(
+		message.SystemMessage("You are a helpful assistant."),
+		message.HumanMessage("What is the weather?"),
+		call,
+		message.ToolMessage("sunny", "call_1"),
+		answer,
+	)
+
+	assert.Equal(int64(330), c.Usage.TotalTokens)
+	assert.InDelta(0.3, c.Usage.Cost, 1e-9)
+
+	// only the completion that processed the tool result is attributed
+	assert.Equal(int64(220), c.ToolUsage["get_weather"].TotalTokens)
+
+	s := NewSession("test-user")
+	s.AddConversation(c)
+
+	assert.Equal(c.Usage, s.Usage)
+	assert.Equal(c.ToolUsage, s.ToolUsage)
+}
+
+func TestTrimMessagesDropsUsage(t *testing.T) {
+	assert := assert.New(t)
+
+	answer := message.AIMessage("Paris.")
+	answer.Model = "openai:gpt-4.1-mini"
+	answer.Usage = &message.Usage{TotalTokens: 100}
+
+	c := NewConversation()
+	c.AddMessage(message.HumanMessage("What is the capital of France?"), answer)
+
+	history := c.TrimMessages(5)
+	assert.Len(history, 2)
+	assert.Nil(history[1].Usage)
+	assert.Empty(history[1].Model)
+}
+
+func TestTrimMessagesWithTools(t *testing.T) {
+	assert := assert.New(t)
+
+	c := NewConversation()
+	c.AddMessage(
+		message.SystemMessage("You are a helpful assistant."),
+		message.HumanMessage("What is the weather in Taipei?"),
+		message.AIMessage("", message.ToolCall{ID: "call_1", Name: "get_weather"}),
+		message.ToolMessage("sunny", "call_1"),
+		message.AIMessage("It is sunny in Taipei."),
+		message.HumanMessage("And in Tokyo?"),
+		// the second call never got its result, e.g. a trimmed history
+		message.AIMessage("",
+			message.ToolCall{ID: "call_2", Name: "get_weather"},
+			message.ToolCall{ID: "call_3", Name: "get_weather"},
+		),
+		message.ToolMessage("rainy", "call_2"),
+		message.AIMessage("I could not check Tokyo."),
+		message.HumanMessage("Thanks"), // unanswered
+	)
+
+	history := c.TrimMessagesWithTools(5)
+
+	roles := make([]message.Role, len(history))
+	for i, m := range history {
+		roles[i] = m.Role
+	}
+
+	assert.Equal([]message.Role{
+		message.RoleHuman, message.RoleAI, message.RoleTool, message.RoleAI,
+		message.RoleHuman, message.RoleAI,
+	}, roles)
+
+	assert.Equal("call_1", history[2].ToolCallID)
+
+	history = c.TrimMessagesWithTools(1)
+	assert.Len(history, 2)
+	assert.Equal("And in Tokyo?", history[0].Content)
+
+	history = c.TrimMessages(5)
+	assert.Len(history, 4)
+}
@@ -4,7 +4,12 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"hash/fnv"
 	"html/template"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
 
 	"github.com/flarexio/talkix/llm"
 	"github.com/flarexio/talkix/llm/message"
@@ -32,69 +37,355 @@ const SYSTEM_PROMPT = `你是專業的對話摘要助手。請根據提供的資
 請直接輸出摘要內容，不要額外說明。`
 
 var (
-	instance   *llm.LLM
-	promptTmpl *template.Template
+	ErrSummaryQueueFull = errors.New("summary queue is full")
+	ErrSummarizerClosed = errors.New("summarizer is closed")
+)
+
+type SummaryStatus string
+
+const (
+	SummaryPending SummaryStatus = "pending"
+	SummaryReady   SummaryStatus = "ready"
+	SummaryFailed  SummaryStatus = "failed"
 )
 
-func InitLLM(model string) error {
+// Summary is owned by the summarizer: Repository.Save keeps the stored one,
+// which only changes through Repository.UpdateSummary. The text of the last
+// successful summary is kept when a later update fails.
+type Summary struct {
+	Text      string        `json:"text"`
+	Status    SummaryStatus `json:"status,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Usage     message.Usage `json:"usage"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// Summarizer updates the summary of a session after conversations were added
+// to it. Depending on the implementation, the summary is persisted before
+// Summarize returns or later by a background worker.
+type Summarizer interface {
+	Summarize(ctx context.Context, s *Session, convs ...*Conversation) error
+}
+
+// SummaryGenerator writes a new summary from the previous one and the new
+// conversations.
+type SummaryGenerator interface {
+	Generate(ctx context.Context, previous string, convs []*Conversation) (string, *message.Usage, error)
+}
+
+type SummaryResult struct {
+	SessionID string
+	UserID    string
+	Summary   Summary
+	Usage     message.Usage
+}
+
+// SummaryHook is called after a summary has been persisted, e.g. to account
+// for its usage elsewhere. It may run on a background worker.
+type SummaryHook func(result SummaryResult)
+
+func NewLLMSummaryGenerator(model string, opts ...llm.Option) (SummaryGenerator, error) {
 	tmpl, err := template.New("system_prompt").Parse(SYSTEM_PROMPT)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	llm, err := llm.NewLLM(model)
+	llm, err := llm.NewLLM(model, opts...)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	instance = llm
-	promptTmpl = tmpl
-	return nil
+	return &llmSummaryGenerator{llm, tmpl}, nil
 }
 
-func GenerateSummary(previousSummary string, conversation *Conversation) (string, error) {
-	if instance == nil {
-		return "", errors.New("llm not initialized")
-	}
+type llmSummaryGenerator struct {
+	llm  *llm.LLM
+	tmpl *template.Template
+}
 
+func (gen *llmSummaryGenerator) Generate(ctx context.Context, previous string, convs []*Conversation) (string, *message.Usage, error) {
+	// Each conversation also contains the history replayed to the model,
+	// so only its own turn is new.
 	var currentConversation string
-	for _, msg := range conversation.Messages {
-		if msg.Role == message.RoleHuman || msg.Role == message.RoleAI {
+	for _, conv := range convs {
+		for _, msg := range conv.lastTurn(false) {
 			currentConversation += msg.Role.String() + ": " + msg.Content + "\n"
 		}
 	}
 
 	data := map[string]any{
-		"PreviousSummary":     previousSummary,
+		"PreviousSummary":     previous,
 		"CurrentConversation": currentConversation,
 	}
 
 	var prompt bytes.Buffer
-	if err := promptTmpl.Execute(&prompt, data); err != nil {
-		return "", err
+	if err := gen.tmpl.Execute(&prompt, data); err != nil {
+		return "", nil, err
 	}
 
 	messages := []message.Message{
-		{
-			Role:    message.RoleSystem,
-			Content: prompt.String(),
-		},
+		message.SystemMessage(prompt.String()),
 	}
 
-	ctx := context.Background()
-	messages, err := instance.InvokeWithMessages(ctx, messages)
+	messages, err := gen.llm.InvokeWithMessages(ctx, messages)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	if len(messages) == 0 {
-		return "", errors.New("no messages returned from LLM")
+		return "", nil, errors.New("no messages returned from LLM")
 	}
 
 	summaryMsg := messages[len(messages)-1]
 	if summaryMsg.Role != message.RoleAI {
-		return "", errors.New("last message is not from AI")
+		return "", nil, errors.New("last message is not from AI")
+	}
+
+	return summaryMsg.Content, summaryMsg.Usage, nil
+}
+
+type summaryJob struct {
+	sessionID string
+	userID    string
+	previous  string
+	convs     []*Conversation
+}
+
+// summaryWriter generates a summary and persists it together with its usage.
+type summaryWriter struct {
+	gen      SummaryGenerator
+	sessions Repository
+	hooks    []SummaryHook
+}
+
+func (w *summaryWriter) write(ctx context.Context, job summaryJob) error {
+	text, usage, err := w.gen.Generate(ctx, job.previous, job.convs)
+	if err != nil {
+		saveErr := w.sessions.UpdateSummary(job.sessionID, func(summary *Summary) {
+			summary.Status = SummaryFailed
+			summary.Error = err.Error()
+			summary.UpdatedAt = time.Now()
+		})
+
+		return errors.Join(err, saveErr)
+	}
+
+	var total message.Usage
+	if usage != nil {
+		total = *usage
+	}
+
+	var result Summary
+	err = w.sessions.UpdateSummary(job.sessionID, func(summary *Summary) {
+		summary.Text = text
+		summary.Status = SummaryReady
+		summary.Error = ""
+		summary.Usage.Add(total)
+		summary.UpdatedAt = time.Now()
+
+		result = *summary
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range w.hooks {
+		hook(SummaryResult{
+			SessionID: job.sessionID,
+			UserID:    job.userID,
+			Summary:   result,
+			Usage:     total,
+		})
+	}
+
+	return nil
+}
+
+// NewSyncSummarizer generates and persists the summary before Summarize
+// returns.
+func NewSyncSummarizer(gen SummaryGenerator, sessions Repository, hooks ...SummaryHook) Summarizer {
+	return &syncSummarizer{
+		summaryWriter{gen, sessions, hooks},
+	}
+}
+
+type syncSummarizer struct {
+	summaryWriter
+}
+
+func (s *syncSummarizer) Summarize(ctx context.Context, sess *Session, convs ...*Conversation) error {
+	if len(convs) == 0 {
+		return nil
+	}
+
+	return s.write(ctx, summaryJob{
+		sessionID: sess.ID,
+		userID:    sess.UserID,
+		previous:  sess.Summary.Text,
+		convs:     convs,
+	})
+}
+
+const (
+	DefaultSummaryWorkers   = 2
+	DefaultSummaryQueueSize = 100
+	summaryTimeout          = time.Minute
+)
+
+// NewQueueSummarizer hands the summaries to a pool of background workers.
+// The jobs of one session always go to the same worker, so that they are
+// applied in order. Summarize fails with ErrSummaryQueueFull instead of
+// blocking the reply when the workers fall behind.
+func NewQueueSummarizer(gen SummaryGenerator, sessions Repository, workers int, size int, hooks ...SummaryHook) *QueueSummarizer {
+	if workers <= 0 {
+		workers = DefaultSummaryWorkers
+	}
+
+	if size <= 0 {
+		size = DefaultSummaryQueueSize
+	}
+
+	s := &QueueSummarizer{
+		summaryWriter: summaryWriter{gen, sessions, hooks},
+		queues:        make([]chan summaryJob, workers),
+	}
+
+	for i := range s.queues {
+		queue := make(chan summaryJob, size)
+		s.queues[i] = queue
+
+		s.wg.Add(1)
+		go s.work(queue)
+	}
+
+	return s
+}
+
+type QueueSummarizer struct {
+	summaryWriter
+	queues []chan summaryJob
+	closed bool
+	wg     sync.WaitGroup
+	sync.RWMutex
+}
+
+func (s *QueueSummarizer) Summarize(ctx context.Context, sess *Session, convs ...*Conversation) error {
+	if len(convs) == 0 {
+		return nil
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	if s.closed {
+		return ErrSummarizerClosed
+	}
+
+	job := summaryJob{
+		sessionID: sess.ID,
+		userID:    sess.UserID,
+		convs:     convs,
+	}
+
+	err := s.sessions.UpdateSummary(sess.ID, func(summary *Summary) {
+		summary.Status = SummaryPending
+		summary.Error = ""
+	})
+
+	if err != nil {
+		return err
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(sess.ID))
+	queue := s.queues[h.Sum32()%uint32(len(s.queues))]
+
+	select {
+	case queue <- job:
+		return nil
+
+	default:
+		err := s.sessions.UpdateSummary(sess.ID, func(summary *Summary) {
+			summary.Status = SummaryFailed
+			summary.Error = ErrSummaryQueueFull.Error()
+			summary.UpdatedAt = time.Now()
+		})
+
+		return errors.Join(ErrSummaryQueueFull, err)
+	}
+}
+
+func (s *QueueSummarizer) work(queue <-chan summaryJob) {
+	defer s.wg.Done()
+
+	for job := range queue {
+		// The previous summary is read when the job runs, so that it
+		// includes the jobs of the same session queued before.
+		previous, err := s.sessions.Find(job.sessionID)
+		if err != nil {
+			zap.L().Warn("session of summary not found",
+				zap.String("session", job.sessionID),
+				zap.Error(err),
+			)
+
+			continue
+		}
+
+		job.previous = previous.Summary.Text
+
+		ctx, cancel := context.WithTimeout(context.Background(), summaryTimeout)
+		err = s.write(ctx, job)
+		cancel()
+
+		if err != nil {
+			zap.L().Warn("failed to summarize session",
+				zap.String("session", job.sessionID),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// Close stops accepting jobs and waits for the queued ones to finish.
+func (s *QueueSummarizer) Close() error {
+	s.Lock()
+	if s.closed {
+		s.Unlock()
+		return nil
+	}
+
+	s.closed = true
+	for _, queue := range s.queues {
+		close(queue)
+	}
+	s.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+// NewEveryNSummarizer only summarizes every n conversations, passing the
+// last n conversations of the session to next at once.
+func NewEveryNSummarizer(next Summarizer, n int) Summarizer {
+	if n <= 1 {
+		return next
+	}
+
+	return &everyNSummarizer{next, n}
+}
+
+type everyNSummarizer struct {
+	next Summarizer
+	n    int
+}
+
+func (s *everyNSummarizer) Summarize(ctx context.Context, sess *Session, convs ...*Conversation) error {
+	count := sess.ConversationCount()
+	if count == 0 || count%s.n != 0 {
+		return nil
 	}
 
-	return summaryMsg.Content, nil
+	loaded := sess.Conversations
+	return s.next.Summarize(ctx, sess, loaded[max(len(loaded)-s.n, 0):]...)
 }
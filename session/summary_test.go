@@ -0,0 +1,233 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix/llm/message"
+)
+
+// summaryRepository stores sessions by value, like a database would. The
+// listings are not used by the summarizers.
+type summaryRepository struct {
+	Repository
+	sessions map[string]Session
+	sync.Mutex
+}
+
+func newSummaryRepository(sessions ...*Session) *summaryRepository {
+	repo := &summaryRepository{
+		sessions: make(map[string]Session),
+	}
+
+	for _, s := range sessions {
+		repo.sessions[s.ID] = *s
+	}
+
+	return repo
+}
+
+func (repo *summaryRepository) Find(id string) (*Session, error) {
+	repo.Lock()
+	defer repo.Unlock()
+
+	s, ok := repo.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+
+	return &s, nil
+}
+
+func (repo *summaryRepository) Save(s *Session) error {
+	repo.Lock()
+	defer repo.Unlock()
+
+	repo.sessions[s.ID] = *s
+	return nil
+}
+
+func (repo *summaryRepository) Delete(id string) error {
+	repo.Lock()
+	defer repo.Unlock()
+
+	delete(repo.sessions, id)
+	return nil
+}
+
+func (repo *summaryRepository) UpdateSummary(id string, update func(summary *Summary)) error {
+	repo.Lock()
+	defer repo.Unlock()
+
+	s, ok := repo.sessions[id]
+	if !ok {
+		return ErrSessionNotFound
+	}
+
+	update(&s.Summary)
+	repo.sessions[id] = s
+	return nil
+}
+
+// countingGenerator appends the number of new conversations to the previous
+// summary, and blocks while its gate is held.
+type countingGenerator struct {
+	err   error
+	gate  sync.Mutex
+	calls [][]*Conversation
+	sync.Mutex
+}
+
+func (gen *countingGenerator) Generate(ctx context.Context, previous string, convs []*Conversation) (string, *message.Usage, error) {
+	gen.gate.Lock()
+	defer gen.gate.Unlock()
+
+	gen.Lock()
+	gen.calls = append(gen.calls, convs)
+	gen.Unlock()
+
+	if gen.err != nil {
+		return "", nil, gen.err
+	}
+
+	return fmt.Sprintf("%s+%d", previous, len(convs)), &message.Usage{TotalTokens: 10}, nil
+}
+
+func (gen *countingGenerator) Calls() [][]*Conversation {
+	gen.Lock()
+	defer gen.Unlock()
+
+	return gen.calls
+}
+
+func addConversation(s *Session) *Conversation {
+	c := NewConversation()
+	c.AddMessage(
+		message.HumanMessage("What is the weather?"),
+		message.AIMessage("It is sunny."),
+	)
+
+	s.AddConversation(c)
+	return c
+}
+
+func TestSyncSummarizer(t *testing.T) {
+	assert := assert.New(t)
+
+	s := NewSession("test-user")
+	repo := newSummaryRepository(s)
+	gen := &countingGenerator{}
+
+	var results []SummaryResult
+	summarizer := NewSyncSummarizer(gen, repo, func(result SummaryResult) {
+		results = append(results, result)
+	})
+
+	ctx := context.Background()
+	if err := summarizer.Summarize(ctx, s, addConversation(s)); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	stored, _ := repo.Find(s.ID)
+	assert.Equal("+1", stored.Summary.Text)
+	assert.Equal(SummaryReady, stored.Summary.Status)
+	assert.False(stored.Summary.UpdatedAt.IsZero())
+	assert.Equal(int64(10), stored.Summary.Usage.TotalTokens)
+
+	if assert.Len(results, 1) {
+		assert.Equal("test-user", results[0].UserID)
+		assert.Equal(int64(10), results[0].Usage.TotalTokens)
+	}
+
+	// A failure keeps the last summary and records the error.
+	gen.err = errors.New("model unavailable")
+
+	err := summarizer.Summarize(ctx, stored, addConversation(stored))
+	assert.ErrorContains(err, "model unavailable")
+
+	stored, _ = repo.Find(s.ID)
+	assert.Equal("+1", stored.Summary.Text)
+	assert.Equal(SummaryFailed, stored.Summary.Status)
+	assert.Equal("model unavailable", stored.Summary.Error)
+	assert.Len(results, 1)
+}
+
+func TestQueueSummarizer(t *testing.T) {
+	assert := assert.New(t)
+
+	s := NewSession("test-user")
+	repo := newSummaryRepository(s)
+	gen := &countingGenerator{}
+
+	summarizer := NewQueueSummarizer(gen, repo, 2, 1)
+
+	// Hold the worker, so that the jobs queue up.
+	gen.gate.Lock()
+
+	ctx := context.Background()
+	assert.NoError(summarizer.Summarize(ctx, s, addConversation(s)))
+
+	stored, _ := repo.Find(s.ID)
+	assert.Equal(SummaryPending, stored.Summary.Status)
+
+	// The first job may still be waiting in the queue of one slot.
+	accepted := 1
+
+	var err error
+	for i := 0; i < 3; i++ {
+		err = summarizer.Summarize(ctx, s, addConversation(s))
+		if err != nil {
+			break
+		}
+
+		accepted++
+	}
+
+	assert.ErrorIs(err, ErrSummaryQueueFull)
+
+	gen.gate.Unlock()
+	summarizer.Close()
+
+	// The accepted jobs were applied in order; the rejected one was not.
+	stored, _ = repo.Find(s.ID)
+	assert.Len(gen.Calls(), accepted)
+	assert.Equal(strings.Repeat("+1", accepted), stored.Summary.Text)
+	assert.Equal(SummaryReady, stored.Summary.Status)
+	assert.Equal(int64(10*accepted), stored.Summary.Usage.TotalTokens)
+
+	err = summarizer.Summarize(ctx, s, addConversation(s))
+	assert.ErrorIs(err, ErrSummarizerClosed)
+}
+
+func TestEveryNSummarizer(t *testing.T) {
+	assert := assert.New(t)
+
+	s := NewSession("test-user")
+	repo := newSummaryRepository(s)
+	gen := &countingGenerator{}
+
+	summarizer := NewEveryNSummarizer(NewSyncSummarizer(gen, repo), 3)
+
+	ctx := context.Background()
+	for i := 0; i < 7; i++ {
+		if err := summarizer.Summarize(ctx, s, addConversation(s)); err != nil {
+			assert.Fail(err.Error())
+			return
+		}
+	}
+
+	calls := gen.Calls()
+	if !assert.Len(calls, 2) {
+		return
+	}
+
+	assert.Equal(s.Conversations[0:3], calls[0])
+	assert.Equal(s.Conversations[3:6], calls[1])
+}
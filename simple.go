@@ -1,11 +1,9 @@
 package talkix
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	"text/template"
 	"time"
 
 	"github.com/flarexio/talkix/auth"
@@ -20,27 +18,19 @@ func NewSimpleService(cfg config.Config,
 	otp *auth.OTPStore,
 	users user.Repository, sessions session.Repository,
 ) Service {
-	templates := map[string]*template.Template{
-		"login":        templates.LoginTemplate(cfg.Line.Login.AuthURL),
-		"secure_menu":  templates.SecureMenuTemplate(),
-		"session_menu": templates.SessionMenuTemplate(),
-	}
-
 	return &simpleService{
-		cfg:       cfg,
-		templates: templates,
-		otp:       otp,
-		users:     users,
-		sessions:  sessions,
+		cfg:      cfg,
+		otp:      otp,
+		users:    users,
+		sessions: sessions,
 	}
 }
 
 type simpleService struct {
-	cfg       config.Config
-	templates map[string]*template.Template
-	otp       *auth.OTPStore
-	users     user.Repository
-	sessions  session.Repository
+	cfg      config.Config
+	otp      *auth.OTPStore
+	users    user.Repository
+	sessions session.Repository
 }
 
 func (svc *simpleService) Name() string {
@@ -66,7 +56,7 @@ func (svc *simpleService) ReplyMessage(ctx context.Context, msg Message) (Messag
 		s = session.NewSession(u.ID)
 		u.AddSessionID(s.ID)
 	} else {
-		found, err := svc.sessions.Find(u.SelectedSessionID)
+		found, err := svc.sessions.FindRecent(u.SelectedSessionID, 0)
 		if err != nil {
 			return nil, err
 		}
@@ -98,7 +88,7 @@ func (svc *simpleService) ReplyMessage(ctx context.Context, msg Message) (Messag
 
 	switch m.Text {
 	case "LOGIN":
-		return svc.handleLogin()
+		return svc.handleLogin(ctx)
 
 	case "MENU":
 		return svc.handleSecureMenu(userCtx.ID)
@@ -107,32 +97,40 @@ func (svc *simpleService) ReplyMessage(ctx context.Context, msg Message) (Messag
 		return svc.handleProfileAccess(userCtx.ID)
 
 	case "SESSION":
-		return svc.handleSessionMenu(u)
+		return svc.handleSessionMenu(ctx, u)
 
 	default:
 		return NewTextMessage("Copy cat: " + m.Text), nil
 	}
 }
 
-func (svc *simpleService) handleLogin() (Message, error) {
-	tmpl, ok := svc.templates["login"]
-	if !ok {
-		return nil, errors.New("login template not found")
+func (svc *simpleService) StreamReply(ctx context.Context, msg Message) (<-chan StreamEvent, error) {
+	reply, err := svc.ReplyMessage(ctx, msg)
+	if err != nil {
+		return nil, err
 	}
 
-	values := map[string]string{
-		"Title":       "Please Login to Continue",
-		"Description": "You need to login to access this feature.",
+	events := make(chan StreamEvent, 1)
+	events <- StreamEvent{Type: StreamEventReply, Reply: reply}
+	close(events)
+
+	return events, nil
+}
+
+func (svc *simpleService) handleLogin(ctx context.Context) (Message, error) {
+	login, ok := ctx.Value(LoginKey).(*Login)
+	if !ok {
+		return NewTextMessage("Login is not available on this channel"), nil
 	}
 
-	buf := &bytes.Buffer{}
-	if err := tmpl.Execute(buf, values); err != nil {
-		return nil, err
+	values := templates.LoginValues{
+		Title:       "Please Login to Continue",
+		Description: "You need to login to access this feature.",
 	}
 
-	return NewFlexMessage(
+	return NewCardMessage(
 		"Please Login to Continue",
-		buf.Bytes(),
+		LoginCard(login, values),
 	), nil
 }
 
@@ -158,11 +156,6 @@ func (svc *simpleService) handleSecureMenu(userID string) (Message, error) {
 		return nil, err
 	}
 
-	tmpl, ok := svc.templates["secure_menu"]
-	if !ok {
-		return nil, errors.New("secure_menu template not found")
-	}
-
 	// 準備模板數據
 	values := templates.SecureMenuValues{
 		ViewProfileURL:  fmt.Sprintf("%s/otp/action?token=%s", svc.cfg.BaseURL, viewOTP),
@@ -170,15 +163,9 @@ func (svc *simpleService) handleSecureMenu(userID string) (Message, error) {
 		DeleteDataURL:   fmt.Sprintf("%s/otp/action?token=%s", svc.cfg.BaseURL, deleteOTP),
 	}
 
-	// 執行模板
-	buf := &bytes.Buffer{}
-	if err := tmpl.Execute(buf, values); err != nil {
-		return nil, err
-	}
-
-	return NewFlexMessage(
+	return NewCardMessage(
 		"安全操作選單",
-		buf.Bytes(),
+		SecureMenuCard(values),
 	), nil
 }
 
@@ -192,80 +179,34 @@ func (svc *simpleService) handleProfileAccess(userID string) (Message, error) {
 		return nil, err
 	}
 
-	flexJSON := fmt.Sprintf(`{
-		"type": "bubble",
-		"body": {
-			"type": "box",
-			"layout": "vertical",
-			"contents": [
-				{
-					"type": "text",
-					"text": "📋 個人資料",
-					"weight": "bold",
-					"size": "lg",
-					"align": "center"
-				},
-				{
-					"type": "text",
-					"text": "點擊下方按鈕安全訪問您的個人資料",
-					"size": "sm",
-					"color": "#666666",
-					"align": "center",
-					"wrap": true,
-					"margin": "md"
-				},
-				{
-					"type": "button",
-					"action": {
-						"type": "uri",
-						"label": "🔐 安全訪問個人資料",
-						"uri": "%s/otp/action?token=%s"
-					},
-					"style": "primary",
-					"color": "#1DB446",
-					"margin": "lg"
-				},
-				{
-					"type": "text",
-					"text": "🔒 此連結使用一次性密碼保護\n⏰ 3分鐘內有效",
-					"size": "xs",
-					"color": "#888888",
-					"align": "center",
-					"wrap": true,
-					"margin": "lg"
-				}
-			]
-		}
-	}`, svc.cfg.BaseURL, otp)
-
-	return NewFlexMessage(
-		"個人資料訪問",
-		[]byte(flexJSON),
-	), nil
-}
-
-func (svc *simpleService) handleSessionMenu(u *user.User) (Message, error) {
-	listOTP, err := svc.otp.GenerateOTP(u.ID, "list_sessions", nil)
-	if err != nil {
-		return nil, err
+	card := &Card{
+		Title: "📋 個人資料",
+		Text:  "點擊下方按鈕安全訪問您的個人資料",
+		Buttons: []Button{
+			{
+				Label: "🔐 安全訪問個人資料",
+				URL:   fmt.Sprintf("%s/otp/action?token=%s", svc.cfg.BaseURL, otp),
+				Style: ButtonPrimary,
+			},
+		},
+		Footer: "🔒 此連結使用一次性密碼保護\n⏰ 3分鐘內有效",
 	}
 
-	tmpl, ok := svc.templates["session_menu"]
-	if !ok {
-		return nil, errors.New("session_menu template not found")
-	}
+	return NewCardMessage("個人資料訪問", card), nil
+}
 
-	values := templates.SessionMenuValues{
-		ListSessionsURL: fmt.Sprintf("%s/users/%s/session/list?token=%s", svc.cfg.BaseURL, u.Profile.Username, listOTP),
+func (svc *simpleService) handleSessionMenu(ctx context.Context, u *user.User) (Message, error) {
+	values, err := sessionMenuValues(ctx, svc.cfg, svc.otp, u)
+	if errors.Is(err, errTemplateUnavailable) {
+		return NewTextMessage("Session menu requires a bound account, in a one-to-one chat"), nil
 	}
 
-	buf := &bytes.Buffer{}
-	if err := tmpl.Execute(buf, values); err != nil {
+	if err != nil {
 		return nil, err
 	}
 
-	return NewFlexMessage(
+	return NewCardMessage(
 		"Session 管理選單",
-		buf.Bytes(),
+		SessionMenuCard(values),
 	), nil
 }
@@ -7,16 +7,29 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/flarexio/talkix/auth"
 	"github.com/flarexio/talkix/config"
+	"github.com/flarexio/talkix/persistence/inmem"
+	"github.com/flarexio/talkix/user"
 )
 
 func TestSimpleService(t *testing.T) {
 	assert := assert.New(t)
 
+	users, err := inmem.NewUserRepository()
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	sessions := inmem.NewSessionRepository()
+
 	var cfg config.Config
-	svc := NewSimpleService(cfg, nil, nil, nil)
+	svc := NewSimpleService(cfg, auth.NewOTPStore(), users, sessions)
 
-	ctx := context.Background()
+	ctx := context.WithValue(context.Background(), UserKey, &user.User{
+		ID: "U1234",
+	})
 
 	msg := NewTextMessage("Hello, world!")
 
@@ -33,5 +46,5 @@ func TestSimpleService(t *testing.T) {
 		return
 	}
 
-	assert.Equal("Copy cat: Hello, world!", replyMsg.Content)
+	assert.Equal("Copy cat: Hello, world!", replyMsg.Content())
 }
@@ -0,0 +1,67 @@
+package speech
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/flarexio/talkix/config"
+	"github.com/flarexio/talkix/llm/message"
+)
+
+// NewOpenAITranscriber uses the transcription endpoint of the OpenAI API, or
+// of a compatible server when a base URL is configured. The API key and base
+// URL fall back to the OPENAI_API_KEY and OPENAI_BASE_URL environment
+// variables.
+func NewOpenAITranscriber(model string, cfg config.ProviderConfig, client *http.Client) Transcriber {
+	reqOpts := make([]option.RequestOption, 0)
+
+	if cfg.BaseURL != "" {
+		reqOpts = append(reqOpts, option.WithBaseURL(cfg.BaseURL))
+	}
+
+	if cfg.APIKey != "" {
+		reqOpts = append(reqOpts, option.WithAPIKey(cfg.APIKey))
+	}
+
+	if client != nil {
+		reqOpts = append(reqOpts, option.WithHTTPClient(client))
+	}
+
+	return &openAITranscriber{
+		model:  model,
+		client: openai.NewClient(reqOpts...),
+	}
+}
+
+type openAITranscriber struct {
+	model  string
+	client openai.Client
+}
+
+func (t *openAITranscriber) Transcribe(ctx context.Context, audio message.Attachment) (string, error) {
+	if len(audio.Data) == 0 {
+		return "", errors.New("audio has no data")
+	}
+
+	// The format of the recording is told by the extension of the name.
+	name := audio.Name
+	if name == "" {
+		name = "audio.m4a"
+	}
+
+	transcription, err := t.client.Audio.Transcriptions.New(ctx, openai.AudioTranscriptionNewParams{
+		File:  openai.File(bytes.NewReader(audio.Data), name, audio.MIMEType),
+		Model: openai.AudioModel(t.model),
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return transcription.Text, nil
+}
@@ -0,0 +1,78 @@
+package speech
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix/config"
+	"github.com/flarexio/talkix/llm/message"
+)
+
+func TestOpenAITranscriber(t *testing.T) {
+	assert := assert.New(t)
+
+	var (
+		path  string
+		model string
+		name  string
+		audio []byte
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		model = r.FormValue("model")
+
+		f, header, err := r.FormFile("file")
+		if err == nil {
+			name = header.Filename
+			audio, _ = io.ReadAll(f)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"text":"What is the weather in Taipei?"}`))
+	}))
+	defer server.Close()
+
+	providers := config.ProvidersConfig{
+		"compat": {BaseURL: server.URL, APIKey: "test-key"},
+	}
+
+	transcriber, err := NewTranscriber("compat:whisper-1", providers, nil)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	text, err := transcriber.Transcribe(context.Background(), message.Attachment{
+		Type:     message.AttachmentAudio,
+		MIMEType: "audio/x-m4a",
+		Name:     "1234.m4a",
+		Data:     []byte("voice"),
+	})
+
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("What is the weather in Taipei?", text)
+	assert.Equal("/audio/transcriptions", path)
+	assert.Equal("whisper-1", model)
+	assert.Equal("1234.m4a", name)
+	assert.Equal([]byte("voice"), audio)
+}
+
+func TestNewTranscriberUnsupportedProvider(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := NewTranscriber("anthropic:claude", nil, nil)
+	assert.Error(err)
+
+	_, err = NewTranscriber("compat:whisper-1", nil, nil)
+	assert.Error(err)
+}
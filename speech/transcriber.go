@@ -0,0 +1,43 @@
+package speech
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/flarexio/talkix/config"
+	"github.com/flarexio/talkix/llm/message"
+)
+
+// Transcriber turns recorded speech into text.
+type Transcriber interface {
+	Transcribe(ctx context.Context, audio message.Attachment) (string, error)
+}
+
+// NewTranscriber creates the transcriber of a model given as
+// "<provider>:<model>". The provider settings are shared with the LLMs; the
+// HTTP client is optional.
+func NewTranscriber(model string, providers config.ProvidersConfig, client *http.Client) (Transcriber, error) {
+	provider, name, ok := strings.Cut(model, ":")
+	if !ok || provider == "" || name == "" {
+		return nil, errors.New("model must be in the form '<provider>:<model>'")
+	}
+
+	cfg := providers[provider]
+
+	switch provider {
+	case "openai":
+		return NewOpenAITranscriber(name, cfg, client), nil
+
+	case "compat":
+		if cfg.BaseURL == "" {
+			return nil, errors.New("compat provider requires a base URL")
+		}
+
+		return NewOpenAITranscriber(name, cfg, client), nil
+
+	default:
+		return nil, errors.New("unsupported speech provider: " + provider)
+	}
+}
@@ -0,0 +1,25 @@
+package talkix
+
+import "github.com/flarexio/talkix/llm/message"
+
+type StreamEventType string
+
+const (
+	StreamEventDelta      StreamEventType = "delta"
+	StreamEventToolCall   StreamEventType = "tool_call"
+	StreamEventToolResult StreamEventType = "tool_result"
+	StreamEventReply      StreamEventType = "reply"
+	StreamEventError      StreamEventType = "error"
+)
+
+// StreamEvent is delivered by Service.StreamReply. Deltas and tool events come
+// from the content generation stage, and the stream ends with either the
+// formatted reply or an error.
+type StreamEvent struct {
+	Type       StreamEventType
+	Delta      string
+	ToolCall   *message.ToolCall
+	ToolResult *message.Message
+	Reply      Message
+	Err        error
+}
@@ -0,0 +1,91 @@
+package talkix
+
+import (
+	"context"
+	"strings"
+
+	"github.com/flarexio/talkix/llm/message"
+	"github.com/flarexio/talkix/speech"
+)
+
+// TranscribeMiddleware replaces the audio attachments of a message with their
+// transcript, so that a voice message is answered like a text message.
+func TranscribeMiddleware(transcriber speech.Transcriber) ServiceMiddleware {
+	return func(next Service) Service {
+		return &transcribeMiddleware{
+			transcriber: transcriber,
+			next:        next,
+		}
+	}
+}
+
+type transcribeMiddleware struct {
+	transcriber speech.Transcriber
+	next        Service
+}
+
+func (mw *transcribeMiddleware) Name() string {
+	return mw.next.Name()
+}
+
+func (mw *transcribeMiddleware) ReplyMessage(ctx context.Context, msg Message) (Message, error) {
+	msg, err := mw.transcribe(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return mw.next.ReplyMessage(ctx, msg)
+}
+
+func (mw *transcribeMiddleware) StreamReply(ctx context.Context, msg Message) (<-chan StreamEvent, error) {
+	msg, err := mw.transcribe(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return mw.next.StreamReply(ctx, msg)
+}
+
+// transcribe returns a copy of the message with the transcripts appended to
+// its text. A recording without speech is kept as it is.
+func (mw *transcribeMiddleware) transcribe(ctx context.Context, msg Message) (Message, error) {
+	m, ok := msg.(*TextMessage)
+	if !ok {
+		return msg, nil
+	}
+
+	texts := make([]string, 0)
+	if m.Text != "" {
+		texts = append(texts, m.Text)
+	}
+
+	media := make([]message.Attachment, 0, len(m.Media))
+	for _, a := range m.Media {
+		if a.Type != message.AttachmentAudio {
+			media = append(media, a)
+			continue
+		}
+
+		text, err := mw.transcriber.Transcribe(ctx, a)
+		if err != nil {
+			return nil, err
+		}
+
+		text = strings.TrimSpace(text)
+		if text == "" {
+			media = append(media, a)
+			continue
+		}
+
+		texts = append(texts, text)
+	}
+
+	if len(media) == len(m.Media) {
+		return msg, nil
+	}
+
+	transcribed := *m
+	transcribed.Text = strings.Join(texts, "\n")
+	transcribed.Media = media
+	return &transcribed, nil
+}
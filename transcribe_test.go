@@ -0,0 +1,57 @@
+package talkix
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix/llm/message"
+)
+
+type transcriberFunc func(ctx context.Context, audio message.Attachment) (string, error)
+
+func (f transcriberFunc) Transcribe(ctx context.Context, audio message.Attachment) (string, error) {
+	return f(ctx, audio)
+}
+
+func TestTranscribeMiddleware(t *testing.T) {
+	assert := assert.New(t)
+
+	var received Message
+	next := &funcService{func(ctx context.Context, msg Message) (Message, error) {
+		received = msg
+		return NewTextMessage("ok"), nil
+	}}
+
+	transcriber := transcriberFunc(func(ctx context.Context, audio message.Attachment) (string, error) {
+		return " " + string(audio.Data) + "\n", nil
+	})
+
+	svc := TranscribeMiddleware(transcriber)(next)
+
+	image := message.Attachment{Type: message.AttachmentImage, Data: []byte("png")}
+	audio := message.Attachment{Type: message.AttachmentAudio, Data: []byte("What is in this picture?")}
+
+	msg := NewMediaMessage("", image, audio)
+
+	if _, err := svc.ReplyMessage(context.Background(), msg); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("What is in this picture?", received.Content())
+	assert.Equal([]message.Attachment{image}, received.Attachments())
+
+	// The message of the transport is left untouched.
+	assert.Len(msg.Attachments(), 2)
+
+	// A message without audio is passed on as it is.
+	text := NewTextMessage("hello")
+	if _, err := svc.ReplyMessage(context.Background(), text); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Same(text, received)
+}
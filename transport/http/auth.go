@@ -8,6 +8,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
 
 	"github.com/flarexio/core/policy"
 	"github.com/flarexio/talkix/auth"
@@ -93,10 +94,30 @@ func unauthorized(c *gin.Context, code int, err error) {
 	c.String(code, err.Error())
 }
 
+// A browser cannot set the header of a WebSocket, so the chat page offers
+// its token as a subprotocol, "bearer.<token>", next to ChatProtocol, which
+// the server picks. Unlike the query, the header stays out of the access
+// logs.
+const (
+	ChatProtocol         = "talkix.chat"
+	bearerProtocolPrefix = "bearer."
+)
+
+// ParseToken reads the bearer token of a request, or of a WebSocket its
+// bearer subprotocol.
 func ParseToken(c *gin.Context, claims jwt.Claims) error {
 	bearerToken := c.GetHeader("Authorization")
 
 	tokenStr, ok := strings.CutPrefix(bearerToken, "Bearer ")
+	if !ok && bearerToken == "" && websocket.IsWebSocketUpgrade(c.Request) {
+		for _, protocol := range websocket.Subprotocols(c.Request) {
+			tokenStr, ok = strings.CutPrefix(protocol, bearerProtocolPrefix)
+			if ok {
+				break
+			}
+		}
+	}
+
 	if !ok {
 		return errors.New("invalid authorization header format")
 	}
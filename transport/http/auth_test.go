@@ -0,0 +1,67 @@
+package http
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTokenWebSocket(t *testing.T) {
+	assert := assert.New(t)
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	issuer, audience = "https://auth.example.com", "talkix"
+	keyFn = func(*jwt.Token) (any, error) { return pub, nil }
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.RegisteredClaims{
+		Subject:   "alice",
+		Issuer:    issuer,
+		Audience:  jwt.ClaimStrings{audience},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString(priv)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	gin.SetMode(gin.TestMode)
+
+	parse := func(req *http.Request) (*Claims, error) {
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = req
+
+		var claims Claims
+		err := ParseToken(c, &claims)
+		return &claims, err
+	}
+
+	// The token comes as the bearer subprotocol.
+	req := httptest.NewRequest(http.MethodGet, "/users/alice/chat", nil)
+	req.Header.Set("Sec-WebSocket-Protocol", ChatProtocol+", bearer."+token)
+
+	claims, err := parse(req)
+	if assert.NoError(err) {
+		assert.Equal("alice", claims.Subject)
+	}
+
+	// The query, which ends up in the logs, is not read.
+	req = httptest.NewRequest(http.MethodGet, "/users/alice/chat?access_token="+token, nil)
+
+	_, err = parse(req)
+	assert.Error(err)
+}
@@ -0,0 +1,216 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"github.com/flarexio/core/endpoint"
+	"github.com/flarexio/talkix"
+)
+
+const (
+	// maxChatMessage is the largest message read from a chat connection.
+	maxChatMessage = 64 << 10
+
+	// pongWait is how long a chat connection may stay silent, pingPeriod how
+	// often it is pinged to keep it from doing so.
+	pongWait   = 60 * time.Second
+	pingPeriod = 50 * time.Second
+
+	// writeWait is how long a write to a chat connection may take.
+	writeWait = 10 * time.Second
+
+	// chatBacklog is the number of messages that may wait for a reply in
+	// progress. The ones beyond it are turned down.
+	chatBacklog = 8
+)
+
+// upgrader only accepts the pages of the same host, which is what the
+// default origin check does. It answers with the chat protocol, never with
+// the bearer one that carries the token.
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	Subprotocols:    []string{ChatProtocol},
+}
+
+// ChatHandler chats over a WebSocket. Each text message the client sends is
+// answered through the reply endpoint, one at a time and in order. A reply
+// is sent as the JSON of its message; a failure as an error frame, which
+// leaves the connection open. The connection is read while a reply is made,
+// so that its pongs keep it alive, and a message that finds the backlog full
+// is answered with an error frame at once.
+func ChatHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u, ok := c.Get("user")
+		if !ok {
+			err := errors.New("user not found in context")
+			c.String(http.StatusInternalServerError, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			// The upgrader has answered the request already.
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		defer conn.Close()
+
+		// The request is done with once upgraded, so the replies take a
+		// context of their own, which ends with the connection.
+		ctx, cancel := context.WithCancel(context.Background())
+		ctx = context.WithValue(ctx, talkix.UserKey, u)
+
+		// The replies and the rejections of the reader are written from two
+		// goroutines, which a connection does not allow at the same time.
+		var writeMu sync.Mutex
+		write := func(v any) error {
+			writeMu.Lock()
+			defer writeMu.Unlock()
+
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			return conn.WriteJSON(v)
+		}
+
+		requests := make(chan *talkix.TextMessage, chatBacklog)
+		go readChat(ctx, cancel, conn, requests, write)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pingChat(ctx, conn)
+		}()
+		defer wg.Wait()
+		defer cancel()
+
+		for req := range requests {
+			resp, err := endpoint(ctx, req)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+
+				c.Error(err)
+				resp = gin.H{"type": "error", "error": err.Error()}
+			}
+
+			if err := write(resp); err != nil {
+				zap.L().Debug("failed to write chat reply", zap.Error(err))
+				return
+			}
+		}
+	}
+}
+
+// readChat reads the messages of a chat connection until it is closed, and
+// then cancels the context of its replies. It never waits for the replies:
+// a message that does not fit in the backlog is turned down.
+func readChat(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn, requests chan<- *talkix.TextMessage, write func(v any) error) {
+	defer close(requests)
+	defer cancel()
+
+	conn.SetReadLimit(maxChatMessage)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		var req *talkix.TextMessage
+		if err := conn.ReadJSON(&req); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				zap.L().Debug("chat connection closed", zap.Error(err))
+			}
+
+			return
+		}
+
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+
+		if req == nil || (req.Text == "" && len(req.Media) == 0) {
+			continue
+		}
+
+		select {
+		case requests <- req:
+		default:
+			err := write(gin.H{"type": "error", "error": talkix.ErrQueueFull.Error()})
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// pingChat keeps a chat connection alive while a reply is being made.
+func pingChat(ctx context.Context, conn *websocket.Conn) {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			deadline := time.Now().Add(writeWait)
+			if err := conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// ChatStreamHandler is the fallback of ChatHandler for the clients that
+// cannot open a WebSocket: the message is posted, and its reply comes back
+// as a server-sent event.
+func ChatStreamHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u, ok := c.Get("user")
+		if !ok {
+			err := errors.New("user not found in context")
+			c.String(http.StatusInternalServerError, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		var req *talkix.TextMessage
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		ctx := c.Request.Context()
+		ctx = context.WithValue(ctx, talkix.UserKey, u)
+
+		resp, err := endpoint(ctx, req)
+		if err != nil {
+			code := http.StatusExpectationFailed
+			if errors.Is(err, talkix.ErrQueueFull) {
+				code = http.StatusTooManyRequests
+			}
+
+			c.String(code, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		c.SSEvent(string(talkix.StreamEventReply), resp)
+	}
+}
@@ -0,0 +1,182 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix"
+	"github.com/flarexio/talkix/user"
+)
+
+// echoEndpoint answers a message with its text and the user, and fails the
+// message "fail".
+func echoEndpoint(ctx context.Context, request any) (any, error) {
+	msg := request.(*talkix.TextMessage)
+	if msg.Text == "fail" {
+		return nil, errors.New("failed")
+	}
+
+	u := ctx.Value(talkix.UserKey).(*user.User)
+
+	reply := talkix.NewTextMessage(u.ID + ": " + msg.Text)
+	reply.AddQuickReply("again")
+	return reply, nil
+}
+
+func chatRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user", &user.User{ID: "U1234"})
+	})
+
+	r.GET("/chat", ChatHandler(echoEndpoint))
+	r.POST("/chat", ChatStreamHandler(echoEndpoint))
+	return r
+}
+
+func TestChatHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(chatRouter())
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/chat"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+	defer conn.Close()
+
+	for _, text := range []string{"hello", "fail", "bye"} {
+		if err := conn.WriteJSON(map[string]any{"text": text}); err != nil {
+			assert.Fail(err.Error())
+			return
+		}
+	}
+
+	var replies []map[string]any
+	for range 3 {
+		var reply map[string]any
+		if err := conn.ReadJSON(&reply); err != nil {
+			assert.Fail(err.Error())
+			return
+		}
+
+		replies = append(replies, reply)
+	}
+
+	assert.Equal("text", replies[0]["type"])
+	assert.Equal("U1234: hello", replies[0]["text"])
+	assert.Equal([]any{"again"}, replies[0]["quickReply"])
+
+	// A failed reply does not close the connection.
+	assert.Equal(map[string]any{"type": "error", "error": "failed"}, replies[1])
+	assert.Equal("U1234: bye", replies[2]["text"])
+}
+
+func TestChatStreamHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	r := chatRouter()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(`{"text": "hello"}`))
+	r.ServeHTTP(w, req)
+
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Contains(w.Header().Get("Content-Type"), "text/event-stream")
+	assert.Contains(w.Body.String(), "event:reply\n")
+	assert.Contains(w.Body.String(), `"text":"U1234: hello"`)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(`{"text": "fail"}`))
+	r.ServeHTTP(w, req)
+
+	assert.Equal(http.StatusExpectationFailed, w.Code)
+}
+
+func TestChatHandlerBacklog(t *testing.T) {
+	assert := assert.New(t)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	slowEndpoint := func(ctx context.Context, request any) (any, error) {
+		if request.(*talkix.TextMessage).Text == "slow" {
+			close(started)
+			<-release
+		}
+
+		return echoEndpoint(ctx, request)
+	}
+
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user", &user.User{ID: "U1234"})
+	})
+	r.GET("/chat", ChatHandler(slowEndpoint))
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/chat"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+	defer conn.Close()
+
+	if err := conn.WriteJSON(map[string]any{"text": "slow"}); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	<-started
+
+	// While the reply is made, the backlog fills up and the message after
+	// it is turned down at once.
+	for range chatBacklog + 1 {
+		if err := conn.WriteJSON(map[string]any{"text": "hello"}); err != nil {
+			assert.Fail(err.Error())
+			return
+		}
+	}
+
+	var reply map[string]any
+	if err := conn.ReadJSON(&reply); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal(map[string]any{"type": "error", "error": talkix.ErrQueueFull.Error()}, reply)
+
+	close(release)
+
+	texts := make([]any, 0)
+	for range chatBacklog + 1 {
+		var reply map[string]any
+		if err := conn.ReadJSON(&reply); err != nil {
+			assert.Fail(err.Error())
+			return
+		}
+
+		texts = append(texts, reply["text"])
+	}
+
+	assert.Equal("U1234: slow", texts[0])
+	assert.Equal("U1234: hello", texts[chatBacklog])
+}
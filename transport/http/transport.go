@@ -3,12 +3,17 @@ package http
 import (
 	"context"
 	"errors"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/flarexio/core/endpoint"
 	"github.com/flarexio/talkix"
+	"github.com/flarexio/talkix/session"
+	"github.com/flarexio/talkix/user"
 )
 
 func ListSessionsHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
@@ -22,10 +27,120 @@ func ListSessionsHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
 			return
 		}
 
+		limit, err := queryLimit(c)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		query := session.SessionQuery{
+			OrderBy: session.SessionOrder(c.Query("order")),
+			Cursor:  c.Query("cursor"),
+			Limit:   limit,
+		}
+
 		ctx := c.Request.Context()
 		ctx = context.WithValue(ctx, talkix.UserKey, u)
 
-		resp, err := endpoint(ctx, nil)
+		resp, err := endpoint(ctx, query)
+		if err != nil {
+			c.String(http.StatusExpectationFailed, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		c.JSON(http.StatusOK, &resp)
+	}
+}
+
+func ListConversationsHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u, ok := c.Get("user")
+		if !ok {
+			err := errors.New("user not found in context")
+			c.String(http.StatusInternalServerError, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		sessionID := c.Param("session")
+		if sessionID == "" {
+			err := errors.New("session is required")
+			c.String(http.StatusBadRequest, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		limit, err := queryLimit(c)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		since, err := queryTime(c, "since")
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		until, err := queryTime(c, "until")
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		query := session.ConversationQuery{
+			SessionID: sessionID,
+			Since:     since,
+			Until:     until,
+			Cursor:    c.Query("cursor"),
+			Limit:     limit,
+		}
+
+		ctx := c.Request.Context()
+		ctx = context.WithValue(ctx, talkix.UserKey, u)
+
+		resp, err := endpoint(ctx, query)
+		if err != nil {
+			c.String(http.StatusExpectationFailed, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		c.JSON(http.StatusOK, &resp)
+	}
+}
+
+func ListUsersHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, err := queryLimit(c)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		query := user.Query{
+			Cursor: c.Query("cursor"),
+			Limit:  limit,
+		}
+
+		ctx := c.Request.Context()
+
+		resp, err := endpoint(ctx, query)
 		if err != nil {
 			c.String(http.StatusExpectationFailed, err.Error())
 			c.Error(err)
@@ -37,6 +152,36 @@ func ListSessionsHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
 	}
 }
 
+// queryLimit reads the page size; zero leaves it to the repository.
+func queryLimit(c *gin.Context) (int, error) {
+	value := c.Query("limit")
+	if value == "" {
+		return 0, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		return 0, errors.New("invalid limit: " + value)
+	}
+
+	return limit, nil
+}
+
+// queryTime reads an RFC 3339 time; a missing one is the zero time.
+func queryTime(c *gin.Context, key string) (time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.New("invalid " + key + ": " + value)
+	}
+
+	return t, nil
+}
+
 func SessionHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		u, ok := c.Get("user")
@@ -167,3 +312,102 @@ func DeleteSessionHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
 		c.String(http.StatusOK, "Session deleted successfully")
 	}
 }
+
+func UsageHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u, ok := c.Get("user")
+		if !ok {
+			err := errors.New("user not found in context")
+			c.String(http.StatusInternalServerError, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		ctx := c.Request.Context()
+		ctx = context.WithValue(ctx, talkix.UserKey, u)
+
+		resp, err := endpoint(ctx, nil)
+		if err != nil {
+			c.String(http.StatusExpectationFailed, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		c.JSON(http.StatusOK, &resp)
+	}
+}
+
+func StreamReplyHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u, ok := c.Get("user")
+		if !ok {
+			err := errors.New("user not found in context")
+			c.String(http.StatusInternalServerError, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		var req *talkix.TextMessage
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		ctx := c.Request.Context()
+		ctx = context.WithValue(ctx, talkix.UserKey, u)
+
+		resp, err := endpoint(ctx, req)
+		if err != nil {
+			code := http.StatusExpectationFailed
+			if errors.Is(err, talkix.ErrQueueFull) {
+				code = http.StatusTooManyRequests
+			}
+
+			c.String(code, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		events, ok := resp.(<-chan talkix.StreamEvent)
+		if !ok {
+			err := errors.New("invalid response type")
+			c.String(http.StatusInternalServerError, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		c.Stream(func(w io.Writer) bool {
+			e, ok := <-events
+			if !ok {
+				return false
+			}
+
+			switch e.Type {
+			case talkix.StreamEventDelta:
+				c.SSEvent(string(e.Type), gin.H{"delta": e.Delta})
+
+			case talkix.StreamEventToolCall:
+				c.SSEvent(string(e.Type), e.ToolCall)
+
+			case talkix.StreamEventToolResult:
+				c.SSEvent(string(e.Type), e.ToolResult)
+
+			case talkix.StreamEventReply:
+				c.SSEvent(string(e.Type), e.Reply)
+
+			case talkix.StreamEventError:
+				c.SSEvent(string(e.Type), gin.H{"error": e.Err.Error()})
+				c.Error(e.Err)
+			}
+
+			return true
+		})
+	}
+}
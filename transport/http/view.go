@@ -10,10 +10,13 @@ import (
 	"github.com/flarexio/talkix/user"
 )
 
-//go:embed sessions.html
+//go:embed sessions.html chat.html
 var tmplFS embed.FS
 
-var sessionsPageTmpl = template.Must(template.ParseFS(tmplFS, "sessions.html"))
+var (
+	sessionsPageTmpl = template.Must(template.ParseFS(tmplFS, "sessions.html"))
+	chatPageTmpl     = template.Must(template.ParseFS(tmplFS, "chat.html"))
+)
 
 func SessionViewHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -45,3 +48,36 @@ func SessionViewHandler() gin.HandlerFunc {
 		})
 	}
 }
+
+// ChatViewHandler serves the web chat, which goes on with the selected
+// session of the user.
+func ChatViewHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userCtx, ok := c.Get("user")
+		if !ok {
+			c.String(http.StatusInternalServerError, "user not found in context")
+			c.Abort()
+			return
+		}
+
+		u, ok := userCtx.(*user.User)
+		if !ok {
+			c.String(http.StatusInternalServerError, "invalid user context")
+			c.Abort()
+			return
+		}
+
+		token, ok := c.Get("jwt")
+		if !ok {
+			c.String(http.StatusInternalServerError, "JWT not found in context")
+			c.Abort()
+			return
+		}
+
+		c.Status(http.StatusOK)
+		chatPageTmpl.Execute(c.Writer, gin.H{
+			"JWT":  token,
+			"User": u.Profile.Username,
+		})
+	}
+}
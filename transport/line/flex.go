@@ -0,0 +1,216 @@
+package line
+
+import (
+	"encoding/json"
+
+	line "github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+
+	"github.com/flarexio/talkix"
+)
+
+// maxBubbles is the number of bubbles a carousel can hold.
+const maxBubbles = 12
+
+// flexContainer renders the cards as a flex bubble, or several of them as a
+// carousel.
+func flexContainer(cards []*talkix.Card) (line.FlexContainerInterface, error) {
+	if len(cards) > maxBubbles {
+		cards = cards[:maxBubbles]
+	}
+
+	var root map[string]any
+	if len(cards) == 1 {
+		root = bubble(cards[0])
+	} else {
+		bubbles := make([]any, len(cards))
+		for i, card := range cards {
+			bubbles[i] = bubble(card)
+		}
+
+		root = map[string]any{
+			"type":     "carousel",
+			"contents": bubbles,
+		}
+	}
+
+	data, err := json.Marshal(root)
+	if err != nil {
+		return nil, err
+	}
+
+	return line.UnmarshalFlexContainer(data)
+}
+
+// bubble lays out a card: its image on top, the title, text and rows in the
+// body, and the buttons with the footer below.
+func bubble(card *talkix.Card) map[string]any {
+	b := map[string]any{"type": "bubble"}
+
+	if card.Image != nil && card.Image.URL != "" {
+		b["hero"] = map[string]any{
+			"type":        "image",
+			"url":         card.Image.URL,
+			"size":        "sm",
+			"aspectRatio": "1:1",
+			"aspectMode":  "fit",
+		}
+	}
+
+	body := make([]any, 0)
+
+	if card.Title != "" {
+		body = append(body, map[string]any{
+			"type":   "text",
+			"text":   card.Title,
+			"weight": "bold",
+			"size":   "lg",
+			"wrap":   true,
+		})
+	}
+
+	if card.Subtitle != "" {
+		body = append(body, map[string]any{
+			"type":  "text",
+			"text":  card.Subtitle,
+			"size":  "sm",
+			"color": "#888888",
+			"wrap":  true,
+		})
+	}
+
+	if len(card.Rows) > 0 {
+		rows := make([]any, len(card.Rows))
+		for i, row := range card.Rows {
+			rows[i] = map[string]any{
+				"type":   "box",
+				"layout": "horizontal",
+				"contents": []any{
+					map[string]any{
+						"type":  "text",
+						"text":  nonEmpty(row.Key),
+						"size":  "sm",
+						"color": "#888888",
+					},
+					map[string]any{
+						"type":  "text",
+						"text":  nonEmpty(row.Value),
+						"size":  "sm",
+						"align": "end",
+						"wrap":  true,
+					},
+				},
+			}
+		}
+
+		body = append(body, map[string]any{
+			"type":     "box",
+			"layout":   "vertical",
+			"margin":   "lg",
+			"spacing":  "sm",
+			"contents": rows,
+		})
+	}
+
+	if card.Text != "" {
+		body = append(body, map[string]any{
+			"type":   "text",
+			"text":   card.Text,
+			"size":   "sm",
+			"color":  "#666666",
+			"wrap":   true,
+			"margin": "md",
+		})
+	}
+
+	if len(body) > 0 {
+		b["body"] = map[string]any{
+			"type":     "box",
+			"layout":   "vertical",
+			"spacing":  "sm",
+			"contents": body,
+		}
+	}
+
+	footer := make([]any, 0)
+	for _, button := range card.Buttons {
+		if c, ok := flexButton(button); ok {
+			footer = append(footer, c)
+		}
+	}
+
+	if card.Footer != "" {
+		footer = append(footer, map[string]any{
+			"type":   "text",
+			"text":   card.Footer,
+			"size":   "xs",
+			"color":  "#aaaaaa",
+			"align":  "center",
+			"wrap":   true,
+			"margin": "md",
+		})
+	}
+
+	if len(footer) > 0 {
+		b["footer"] = map[string]any{
+			"type":     "box",
+			"layout":   "vertical",
+			"spacing":  "sm",
+			"contents": footer,
+		}
+	}
+
+	return b
+}
+
+// flexButton opens the page of a button, or sends its text. A button that
+// does neither is left out.
+func flexButton(button talkix.Button) (map[string]any, bool) {
+	var action map[string]any
+	switch {
+	case button.URL != "":
+		action = map[string]any{
+			"type":  "uri",
+			"label": button.Label,
+			"uri":   button.URL,
+		}
+
+	case button.Text != "":
+		action = map[string]any{
+			"type":  "message",
+			"label": button.Label,
+			"text":  button.Text,
+		}
+
+	default:
+		return nil, false
+	}
+
+	c := map[string]any{
+		"type":   "button",
+		"height": "sm",
+		"action": action,
+	}
+
+	switch button.Style {
+	case talkix.ButtonPrimary:
+		c["style"] = "primary"
+		c["color"] = "#1DB446"
+
+	case talkix.ButtonSecondary:
+		c["style"] = "secondary"
+
+	default:
+		c["style"] = "link"
+	}
+
+	return c, true
+}
+
+// nonEmpty keeps a text component from being empty, which LINE rejects.
+func nonEmpty(text string) string {
+	if text == "" {
+		return "-"
+	}
+
+	return text
+}
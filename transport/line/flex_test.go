@@ -0,0 +1,58 @@
+package line
+
+import (
+	"testing"
+
+	line "github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix"
+)
+
+func TestFlexContainer(t *testing.T) {
+	assert := assert.New(t)
+
+	container, err := flexContainer([]*talkix.Card{
+		{
+			Title: "Taichung",
+			Image: &talkix.Image{URL: "https://example.com/sun.png"},
+			Rows:  []talkix.Row{{Key: "溫度", Value: "30.5°C"}},
+			Buttons: []talkix.Button{
+				{Label: "Forecast", URL: "https://example.com/forecast", Style: talkix.ButtonPrimary},
+				{Label: "Nothing"},
+			},
+			Footer: "Last updated 12:00",
+		},
+	})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	bubble, ok := container.(line.FlexBubble)
+	if !assert.True(ok) {
+		return
+	}
+
+	assert.NotNil(bubble.Hero)
+	assert.Len(bubble.Body.Contents, 2)
+
+	if assert.Len(bubble.Footer.Contents, 2) {
+		button, ok := bubble.Footer.Contents[0].(line.FlexButton)
+		if assert.True(ok) {
+			assert.Equal(line.FlexButtonSTYLE_PRIMARY, button.Style)
+			assert.Equal("https://example.com/forecast", button.Action.(line.UriAction).Uri)
+		}
+	}
+
+	container, err = flexContainer([]*talkix.Card{{Title: "Cafe A"}, {Title: "Cafe B"}})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	carousel, ok := container.(line.FlexCarousel)
+	if assert.True(ok) {
+		assert.Len(carousel.Contents, 2)
+	}
+}
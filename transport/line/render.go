@@ -0,0 +1,63 @@
+package line
+
+import (
+	line "github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+
+	"github.com/flarexio/talkix"
+)
+
+// lineMessage renders a reply as a LINE message. The cards become a flex
+// message; cards that LINE cannot read fall back to their alt text.
+func lineMessage(reply talkix.Message) (line.MessageInterface, error) {
+	// Prepare quick replies if available
+	items := make([]line.QuickReplyItem, 0)
+	for _, qr := range reply.QuickReply() {
+		items = append(items, line.QuickReplyItem{
+			Type: "action",
+			Action: line.MessageAction{
+				Label: qr,
+				Text:  qr,
+			},
+		})
+	}
+
+	sender := &line.Sender{
+		Name:    cfg.LLM.Model,
+		IconUrl: "https://openai.com/favicon.ico",
+	}
+
+	switch replyMsg := reply.(type) {
+	case *talkix.TextMessage:
+		return line.TextMessage{
+			Sender: sender,
+			Text:   replyMsg.Text,
+			QuickReply: &line.QuickReply{
+				Items: items,
+			},
+		}, nil
+
+	case *talkix.CardMessage:
+		container, err := flexContainer(replyMsg.Cards)
+		if err != nil || len(replyMsg.Cards) == 0 {
+			return line.TextMessage{
+				Sender: sender,
+				Text:   replyMsg.AltText,
+				QuickReply: &line.QuickReply{
+					Items: items,
+				},
+			}, nil
+		}
+
+		return line.FlexMessage{
+			Sender:   sender,
+			AltText:  replyMsg.AltText,
+			Contents: container,
+			QuickReply: &line.QuickReply{
+				Items: items,
+			},
+		}, nil
+
+	default:
+		return nil, talkix.ErrUnexpectedResponse
+	}
+}
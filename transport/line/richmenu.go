@@ -0,0 +1,367 @@
+package line
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	line "github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+
+	"github.com/flarexio/talkix/config"
+)
+
+const (
+	DefaultRichMenuWidth  = 2500
+	DefaultRichMenuHeight = 1686
+)
+
+var (
+	ErrRichMenuNotFound      = errors.New("rich menu not found")
+	ErrUnsupportedAction     = errors.New("unsupported rich menu action")
+	ErrUnsupportedImage      = errors.New("rich menu image must be a png or jpeg file")
+	ErrRichMenuNotConfigured = errors.New("rich menus not configured")
+)
+
+// richMenuRequest converts a rich menu of the config to a request of the
+// Messaging API.
+func richMenuRequest(name string, m config.RichMenuConfig) (*line.RichMenuRequest, error) {
+	size := &line.RichMenuSize{
+		Width:  m.Size.Width,
+		Height: m.Size.Height,
+	}
+
+	if size.Width <= 0 || size.Height <= 0 {
+		size.Width = DefaultRichMenuWidth
+		size.Height = DefaultRichMenuHeight
+	}
+
+	req := &line.RichMenuRequest{
+		Size:        size,
+		Selected:    m.Selected,
+		Name:        m.Name,
+		ChatBarText: m.ChatBarText,
+		Areas:       make([]line.RichMenuArea, len(m.Areas)),
+	}
+
+	if req.Name == "" {
+		req.Name = name
+	}
+
+	if req.ChatBarText == "" {
+		req.ChatBarText = "Menu"
+	}
+
+	for i, area := range m.Areas {
+		action, err := richMenuAction(area.Action)
+		if err != nil {
+			return nil, fmt.Errorf("%s area %d: %w", name, i, err)
+		}
+
+		req.Areas[i] = line.RichMenuArea{
+			Bounds: &line.RichMenuBounds{
+				X:      area.Bounds.X,
+				Y:      area.Bounds.Y,
+				Width:  area.Bounds.Width,
+				Height: area.Bounds.Height,
+			},
+			Action: action,
+		}
+	}
+
+	return req, nil
+}
+
+func richMenuAction(a config.RichMenuAction) (line.ActionInterface, error) {
+	switch a.Type {
+	case "message":
+		return line.MessageAction{Label: a.Label, Text: a.Text}, nil
+
+	case "postback":
+		return line.PostbackAction{Label: a.Label, Data: a.Data, DisplayText: a.DisplayText}, nil
+
+	case "uri":
+		return line.UriAction{Label: a.Label, Uri: a.URI}, nil
+
+	case "richmenuswitch":
+		return line.RichMenuSwitchAction{Label: a.Label, Data: a.Data, RichMenuAliasId: a.Menu}, nil
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAction, a.Type)
+	}
+}
+
+// imageType is the content type of a rich menu image, by its extension.
+func imageType(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		return "image/png", nil
+
+	case ".jpg", ".jpeg":
+		return "image/jpeg", nil
+
+	default:
+		return "", ErrUnsupportedImage
+	}
+}
+
+// CreateRichMenus creates the rich menus of the config with their images,
+// read relative to dir, and points the alias of each menu at it. The menus
+// that were behind the aliases before are deleted. The guest menu becomes the
+// default menu. It returns the IDs of the new menus by their aliases.
+func CreateRichMenus(menus config.RichMenusConfig, dir string) (map[string]string, error) {
+	if len(menus.Menus) == 0 {
+		return nil, ErrRichMenuNotConfigured
+	}
+
+	aliases := make([]string, 0, len(menus.Menus))
+	for alias := range menus.Menus {
+		aliases = append(aliases, alias)
+	}
+
+	slices.Sort(aliases)
+
+	ids := make(map[string]string, len(aliases))
+	for _, alias := range aliases {
+		id, err := createRichMenu(alias, menus.Menus[alias], dir)
+		if err != nil {
+			return ids, err
+		}
+
+		ids[alias] = id
+	}
+
+	if menus.Guest != "" {
+		id, ok := ids[menus.Guest]
+		if !ok {
+			return ids, fmt.Errorf("%w: %s", ErrRichMenuNotFound, menus.Guest)
+		}
+
+		if _, err := bot.SetDefaultRichMenu(id); err != nil {
+			return ids, err
+		}
+	}
+
+	return ids, nil
+}
+
+func createRichMenu(alias string, m config.RichMenuConfig, dir string) (string, error) {
+	req, err := richMenuRequest(alias, m)
+	if err != nil {
+		return "", err
+	}
+
+	path := m.Image
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	contentType, err := imageType(path)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	res, err := bot.CreateRichMenu(req)
+	if err != nil {
+		return "", err
+	}
+
+	id := res.RichMenuId
+
+	if _, err := blob.SetRichMenuImage(id, contentType, f); err != nil {
+		bot.DeleteRichMenu(id)
+		return "", err
+	}
+
+	previous, err := richMenuID(alias)
+	switch {
+	case err == nil:
+		_, err = bot.UpdateRichMenuAlias(alias, &line.UpdateRichMenuAliasRequest{
+			RichMenuId: id,
+		})
+
+	case errors.Is(err, ErrRichMenuNotFound):
+		_, err = bot.CreateRichMenuAlias(&line.CreateRichMenuAliasRequest{
+			RichMenuAliasId: alias,
+			RichMenuId:      id,
+		})
+	}
+
+	if err != nil {
+		bot.DeleteRichMenu(id)
+		return "", err
+	}
+
+	if previous != "" {
+		if _, err := bot.DeleteRichMenu(previous); err != nil {
+			zap.L().Warn("failed to delete previous rich menu",
+				zap.String("alias", alias),
+				zap.String("richmenu", previous),
+				zap.Error(err),
+			)
+		}
+	}
+
+	return id, nil
+}
+
+// richMenuID looks up the rich menu behind an alias.
+func richMenuID(alias string) (string, error) {
+	res, found, err := bot.GetRichMenuAliasWithHttpInfo(alias)
+	if err != nil {
+		if res != nil && res.StatusCode == http.StatusNotFound {
+			return "", ErrRichMenuNotFound
+		}
+
+		return "", err
+	}
+
+	return found.RichMenuId, nil
+}
+
+// ListRichMenus returns the rich menus of the bot.
+func ListRichMenus() ([]line.RichMenuResponse, error) {
+	res, err := bot.GetRichMenuList()
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Richmenus, nil
+}
+
+// DeleteRichMenus deletes the rich menus of the config and their aliases.
+// A menu that was never created is skipped.
+func DeleteRichMenus(menus config.RichMenusConfig) error {
+	for alias := range menus.Menus {
+		id, err := richMenuID(alias)
+		if errors.Is(err, ErrRichMenuNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := bot.DeleteRichMenuAlias(alias); err != nil {
+			return err
+		}
+
+		if _, err := bot.DeleteRichMenu(id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LinkRichMenu shows the menu of an alias to the user.
+func LinkRichMenu(userID string, alias string) error {
+	id, err := richMenuID(alias)
+	if err != nil {
+		return err
+	}
+
+	_, err = bot.LinkRichMenuIdToUser(userID, id)
+	return err
+}
+
+// UnlinkRichMenu shows the default menu to the user again.
+func UnlinkRichMenu(userID string) error {
+	_, err := bot.UnlinkRichMenuIdFromUser(userID)
+	return err
+}
+
+// richMenuSwitcher links each user to the guest or the member menu, by
+// whether the account is bound. The menu of each user is remembered, so that
+// the API is only called when the binding changed, or once after a restart.
+type richMenuSwitcher struct {
+	guest  string
+	member string
+	ids    map[string]string
+	linked map[string]string
+	sync.Mutex
+}
+
+func newRichMenuSwitcher(menus config.RichMenusConfig) *richMenuSwitcher {
+	if menus.Member == "" {
+		return nil
+	}
+
+	return &richMenuSwitcher{
+		guest:  menus.Guest,
+		member: menus.Member,
+		ids:    make(map[string]string),
+		linked: make(map[string]string),
+	}
+}
+
+// Switch links the menu that fits the user. A user without a bound account
+// gets the default menu, unless a guest menu is set.
+func (s *richMenuSwitcher) Switch(userID string, verified bool) error {
+	alias := s.guest
+	if verified {
+		alias = s.member
+	}
+
+	s.Lock()
+	linked, ok := s.linked[userID]
+	id := s.ids[alias]
+	s.Unlock()
+
+	if ok && linked == alias {
+		return nil
+	}
+
+	if alias == "" {
+		if err := UnlinkRichMenu(userID); err != nil {
+			return err
+		}
+
+		s.remember(userID, alias, "")
+		return nil
+	}
+
+	if id == "" {
+		found, err := richMenuID(alias)
+		if err != nil {
+			return err
+		}
+
+		id = found
+	}
+
+	if _, err := bot.LinkRichMenuIdToUser(userID, id); err != nil {
+		// The menus can have been created again in the meantime, so the
+		// alias is looked up again next time.
+		s.Lock()
+		delete(s.ids, alias)
+		s.Unlock()
+
+		return err
+	}
+
+	s.remember(userID, alias, id)
+	return nil
+}
+
+func (s *richMenuSwitcher) remember(userID, alias, id string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.linked[userID] = alias
+	if id != "" {
+		s.ids[alias] = id
+	}
+}
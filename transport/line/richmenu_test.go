@@ -0,0 +1,353 @@
+package line
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix/config"
+	"github.com/flarexio/talkix/identity"
+	"github.com/flarexio/talkix/persistence/inmem"
+	"github.com/flarexio/talkix/user"
+)
+
+type richMenu struct {
+	Request     map[string]any
+	ContentType string
+	Image       []byte
+}
+
+// richMenuServer stands in for the rich menu part of the Messaging API. It
+// keeps the menus, aliases and links, and accepts any message sent.
+type richMenuServer struct {
+	*httptest.Server
+
+	menus       map[string]*richMenu
+	aliases     map[string]string
+	links       map[string]string
+	linkCalls   int
+	defaultMenu string
+	count       int
+	sync.Mutex
+}
+
+func newRichMenuServer() *richMenuServer {
+	s := &richMenuServer{
+		menus:   make(map[string]*richMenu),
+		aliases: make(map[string]string),
+		links:   make(map[string]string),
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *richMenuServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/v2/bot/"), "/")
+
+	reply := func(v any) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+
+	notFound := func() {
+		w.WriteHeader(http.StatusNotFound)
+		reply(map[string]any{"message": "Not found"})
+	}
+
+	switch {
+	// POST /v2/bot/richmenu
+	case r.Method == http.MethodPost && len(path) == 1 && path[0] == "richmenu":
+		s.count++
+		id := fmt.Sprintf("richmenu-%d", s.count)
+
+		m := &richMenu{}
+		json.Unmarshal(body, &m.Request)
+		s.menus[id] = m
+
+		reply(map[string]any{"richMenuId": id})
+
+	// GET /v2/bot/richmenu/list
+	case r.Method == http.MethodGet && len(path) == 2 && path[1] == "list":
+		menus := make([]map[string]any, 0)
+		for id, m := range s.menus {
+			menus = append(menus, map[string]any{"richMenuId": id, "name": m.Request["name"]})
+		}
+
+		reply(map[string]any{"richmenus": menus})
+
+	// POST /v2/bot/richmenu/alias
+	case r.Method == http.MethodPost && len(path) == 2 && path[1] == "alias":
+		var req map[string]string
+		json.Unmarshal(body, &req)
+
+		s.aliases[req["richMenuAliasId"]] = req["richMenuId"]
+		reply(map[string]any{})
+
+	// GET, POST, DELETE /v2/bot/richmenu/alias/{alias}
+	case len(path) == 3 && path[1] == "alias":
+		id, ok := s.aliases[path[2]]
+		if !ok {
+			notFound()
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			reply(map[string]any{"richMenuAliasId": path[2], "richMenuId": id})
+
+		case http.MethodPost:
+			var req map[string]string
+			json.Unmarshal(body, &req)
+
+			s.aliases[path[2]] = req["richMenuId"]
+			reply(map[string]any{})
+
+		case http.MethodDelete:
+			delete(s.aliases, path[2])
+			reply(map[string]any{})
+		}
+
+	// POST /v2/bot/richmenu/{id}/content
+	case r.Method == http.MethodPost && len(path) == 3 && path[2] == "content":
+		m, ok := s.menus[path[1]]
+		if !ok {
+			notFound()
+			return
+		}
+
+		m.ContentType = r.Header.Get("Content-Type")
+		m.Image = body
+		reply(map[string]any{})
+
+	// DELETE /v2/bot/richmenu/{id}
+	case r.Method == http.MethodDelete && len(path) == 2 && path[0] == "richmenu":
+		if _, ok := s.menus[path[1]]; !ok {
+			notFound()
+			return
+		}
+
+		delete(s.menus, path[1])
+		reply(map[string]any{})
+
+	// POST /v2/bot/user/all/richmenu/{id}
+	case r.Method == http.MethodPost && len(path) == 4 && path[1] == "all":
+		s.defaultMenu = path[3]
+		reply(map[string]any{})
+
+	// POST /v2/bot/user/{user}/richmenu/{id}
+	case r.Method == http.MethodPost && len(path) == 4 && path[0] == "user":
+		s.linkCalls++
+		if _, ok := s.menus[path[3]]; !ok {
+			notFound()
+			return
+		}
+
+		s.links[path[1]] = path[3]
+		reply(map[string]any{})
+
+	// DELETE /v2/bot/user/{user}/richmenu
+	case r.Method == http.MethodDelete && len(path) == 3 && path[0] == "user":
+		s.linkCalls++
+		delete(s.links, path[1])
+		reply(map[string]any{})
+
+	default:
+		reply(map[string]any{"sentMessages": []any{}})
+	}
+}
+
+func testRichMenus(t *testing.T) (config.RichMenusConfig, string) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"guest.png", "member.jpg"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	area := func(action config.RichMenuAction) []config.RichMenuArea {
+		return []config.RichMenuArea{
+			{
+				Bounds: config.RichMenuBounds{Width: 2500, Height: 843},
+				Action: action,
+			},
+		}
+	}
+
+	menus := config.RichMenusConfig{
+		Guest:  "guest",
+		Member: "member",
+		Menus: map[string]config.RichMenuConfig{
+			"guest": {
+				Image: "guest.png",
+				Size:  config.RichMenuSize{Width: 2500, Height: 843},
+				Areas: area(config.RichMenuAction{Type: "uri", URI: "https://example.com/login"}),
+			},
+			"member": {
+				Name:  "Member",
+				Image: "member.jpg",
+				Areas: area(config.RichMenuAction{Type: "postback", Data: "action=new_session"}),
+			},
+		},
+	}
+
+	return menus, dir
+}
+
+func TestCreateRichMenus(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newRichMenuServer()
+	defer server.Close()
+
+	useLineAPI(t, server.URL)
+
+	cfg, dir := testRichMenus(t)
+
+	ids, err := CreateRichMenus(cfg, dir)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Len(server.menus, 2)
+	assert.Equal(ids, server.aliases)
+	assert.Equal(ids["guest"], server.defaultMenu)
+
+	guest := server.menus[ids["guest"]]
+	assert.Equal("image/png", guest.ContentType)
+	assert.Equal([]byte("guest.png"), guest.Image)
+	assert.Equal("guest", guest.Request["name"])
+	assert.Equal("Menu", guest.Request["chatBarText"])
+
+	member := server.menus[ids["member"]]
+	assert.Equal("image/jpeg", member.ContentType)
+	assert.Equal(map[string]any{"width": float64(DefaultRichMenuWidth), "height": float64(DefaultRichMenuHeight)}, member.Request["size"])
+
+	areas := member.Request["areas"].([]any)
+	action := areas[0].(map[string]any)["action"].(map[string]any)
+	assert.Equal("postback", action["type"])
+	assert.Equal("action=new_session", action["data"])
+
+	// Creating the menus again replaces the previous ones.
+	again, err := CreateRichMenus(cfg, dir)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Len(server.menus, 2)
+	assert.Equal(again, server.aliases)
+	assert.NotEqual(ids["guest"], again["guest"])
+
+	if err := DeleteRichMenus(cfg); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Empty(server.menus)
+	assert.Empty(server.aliases)
+}
+
+func TestCreateRichMenusInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newRichMenuServer()
+	defer server.Close()
+
+	useLineAPI(t, server.URL)
+
+	cfg, dir := testRichMenus(t)
+
+	guest := cfg.Menus["guest"]
+	guest.Areas[0].Action.Type = "dance"
+	cfg.Menus["guest"] = guest
+
+	_, err := CreateRichMenus(cfg, dir)
+	assert.ErrorIs(err, ErrUnsupportedAction)
+
+	guest.Areas[0].Action.Type = "uri"
+	guest.Image = "guest.gif"
+	cfg.Menus["guest"] = guest
+
+	_, err = CreateRichMenus(cfg, dir)
+	assert.ErrorIs(err, ErrUnsupportedImage)
+
+	_, err = CreateRichMenus(config.RichMenusConfig{}, dir)
+	assert.ErrorIs(err, ErrRichMenuNotConfigured)
+}
+
+func TestWorkerSwitchesRichMenu(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newRichMenuServer()
+	defer server.Close()
+
+	useLineAPI(t, server.URL)
+
+	richMenus, dir := testRichMenus(t)
+
+	ids, err := CreateRichMenus(richMenus, dir)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	menus = newRichMenuSwitcher(richMenus)
+
+	var (
+		mu    sync.Mutex
+		bound bool
+	)
+
+	directUser := func(subject string) (*user.UserProfile, *identity.Token, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !bound {
+			return unboundUser(subject)
+		}
+
+		return &user.UserProfile{ID: "user-1", Name: "Alice"}, nil, nil
+	}
+
+	send := func(text string) {
+		worker := NewWorker(echoEndpoint, eventEndpoint, directUser, config.LineWorkerConfig{})
+
+		w := postWebhook(MessageHandler(worker, inmem.NewDedupStore(0)), textEvent(text, time.Now()))
+		assert.Equal(http.StatusOK, w.Code)
+
+		worker.Close()
+	}
+
+	send("hello")
+	send("again")
+
+	// The guest menu is linked once.
+	assert.Equal(ids["guest"], server.links["U1234"])
+	assert.Equal(1, server.linkCalls)
+
+	mu.Lock()
+	bound = true
+	mu.Unlock()
+
+	send("bound")
+
+	assert.Equal(ids["member"], server.links["U1234"])
+	assert.Equal(2, server.linkCalls)
+}
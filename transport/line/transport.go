@@ -1,27 +1,35 @@
 package line
 
 import (
-	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf16"
 
 	"github.com/gin-gonic/gin"
 	"github.com/line/line-bot-sdk-go/v8/linebot/webhook"
+	"go.uber.org/zap"
 
 	line "github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
 
-	"github.com/flarexio/core/endpoint"
 	"github.com/flarexio/talkix"
 	"github.com/flarexio/talkix/config"
-	"github.com/flarexio/talkix/identity"
-	"github.com/flarexio/talkix/user"
+	"github.com/flarexio/talkix/dedup"
+	"github.com/flarexio/talkix/llm/message"
 )
 
+// maxContentSize is the largest image, recording or file read from LINE.
+const maxContentSize = 10 << 20
+
 var (
-	cfg config.Config
-	bot *line.MessagingApiAPI
+	cfg   config.Config
+	bot   *line.MessagingApiAPI
+	blob  *line.MessagingApiBlobAPI
+	menus *richMenuSwitcher
 )
 
 func Init(config config.Config) error {
@@ -30,12 +38,25 @@ func Init(config config.Config) error {
 		return err
 	}
 
+	blobAPI, err := line.NewMessagingApiBlobAPI(config.Line.Messaging.ChannelToken)
+	if err != nil {
+		return err
+	}
+
 	cfg = config
 	bot = api
+	blob = blobAPI
+	menus = newRichMenuSwitcher(config.Line.RichMenus)
 	return nil
 }
 
-func MessageHandler(endpoint endpoint.Endpoint, directUser identity.DirectUser) gin.HandlerFunc {
+// MessageHandler verifies the webhook and hands its events to the worker. It
+// answers right away, the events are replied to in the background. An event
+// that is not supported is skipped, without holding up the others.
+//
+// The events are recorded in the dedup store. A redelivered event that was
+// already received is acknowledged without being processed again.
+func MessageHandler(worker *Worker, events dedup.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		cb, err := webhook.ParseRequest(cfg.Line.Messaging.ChannelSecret, c.Request)
 		if err != nil {
@@ -53,182 +74,225 @@ func MessageHandler(endpoint endpoint.Endpoint, directUser identity.DirectUser)
 		}
 
 		for _, event := range cb.Events {
-			switch e := event.(type) {
-			case webhook.MessageEvent:
-				u := new(user.User)
-
-				// Determine the source of the event
-				switch source := e.Source.(type) {
-				case webhook.UserSource:
-					u.ID = source.UserId
-
-					profile, _, err := directUser(source.UserId)
-					if err == nil {
-						u.ID = profile.ID
-						u.Profile = profile
-						u.Verified = true
-					}
-
-					go bot.ShowLoadingAnimation(&line.ShowLoadingAnimationRequest{
-						ChatId:         source.UserId,
-						LoadingSeconds: 20,
-					})
-
-				default:
-					err := errors.New("unsupported source type")
-					c.String(http.StatusBadRequest, err.Error())
-					c.Error(err)
-					c.Abort()
-					return
-				}
+			j, err := newJob(event)
+			if err != nil {
+				zap.L().Warn(err.Error(),
+					zap.String("type", fmt.Sprintf("%T", event)),
+				)
 
-				// Handle the message based on its type
-				var reply talkix.Message
-				switch msg := e.Message.(type) {
-				case webhook.TextMessageContent:
-					req := talkix.NewTextMessage(msg.Text)
-					req.SetTimestamp(time.UnixMilli(e.Timestamp))
-
-					ctx := context.Background()
-					ctx = context.WithValue(ctx, talkix.UserKey, u)
-
-					resp, err := endpoint(ctx, req)
-					if err != nil {
-						c.String(http.StatusInternalServerError, err.Error())
-						c.Error(err)
-						c.Abort()
-						return
-					}
-
-					r, ok := resp.(talkix.Message)
-					if !ok {
-						err := errors.New("expected message type in response")
-						c.String(http.StatusInternalServerError, err.Error())
-						c.Error(err)
-						c.Abort()
-						return
-					}
-
-					reply = r
-
-				case webhook.LocationMessageContent:
-					locationText := fmt.Sprintf("Title: %s\nAddress: %s\nLatitude: %.6f\nLongitude: %.6f",
-						msg.Title, msg.Address, msg.Latitude, msg.Longitude)
-
-					req := talkix.NewTextMessage(locationText)
-					req.SetTimestamp(time.UnixMilli(e.Timestamp))
-
-					ctx := context.Background()
-					ctx = context.WithValue(ctx, talkix.UserKey, u)
-
-					resp, err := endpoint(ctx, req)
-					if err != nil {
-						c.String(http.StatusInternalServerError, err.Error())
-						c.Error(err)
-						c.Abort()
-						return
-					}
-
-					r, ok := resp.(talkix.Message)
-					if !ok {
-						err := errors.New("expected message type in response")
-						c.String(http.StatusInternalServerError, err.Error())
-						c.Error(err)
-						c.Abort()
-						return
-					}
-
-					reply = r
-
-				default:
-					err := errors.New("unsupported message type")
-					c.String(http.StatusBadRequest, err.Error())
-					c.Error(err)
-					c.Abort()
-					return
-				}
+				continue
+			}
 
-				// Reply to the message
-
-				// Prepare quick replies if available
-				items := make([]line.QuickReplyItem, 0)
-				for _, qr := range reply.QuickReply() {
-					items = append(items, line.QuickReplyItem{
-						Type: "action",
-						Action: line.MessageAction{
-							Label: qr,
-							Text:  qr,
-						},
-					})
-				}
+			added, err := events.Add(j.eventID)
+			if err != nil {
+				c.String(http.StatusInternalServerError, err.Error())
+				c.Error(err)
+				c.Abort()
+				return
+			}
 
-				// Send the reply based on the message type
-				var lineMsg line.MessageInterface
-				switch replyMsg := reply.(type) {
-				case *talkix.TextMessage:
-					lineMsg = line.TextMessage{
-						Sender: &line.Sender{
-							Name:    cfg.LLM.Model,
-							IconUrl: "https://openai.com/favicon.ico",
-						},
-						Text: replyMsg.Text,
-						QuickReply: &line.QuickReply{
-							Items: items,
-						},
-					}
-
-				case *talkix.FlexMessage:
-					container, err := line.UnmarshalFlexContainer(replyMsg.Flex)
-					if err != nil {
-						lineMsg = line.TextMessage{
-							Sender: &line.Sender{
-								Name:    cfg.LLM.Model,
-								IconUrl: "https://openai.com/favicon.ico",
-							},
-							Text: replyMsg.AltText,
-							QuickReply: &line.QuickReply{
-								Items: items,
-							},
-						}
-					} else {
-						lineMsg = line.FlexMessage{
-							Sender: &line.Sender{
-								Name:    cfg.LLM.Model,
-								IconUrl: "https://openai.com/favicon.ico",
-							},
-							AltText:  replyMsg.AltText,
-							Contents: container,
-							QuickReply: &line.QuickReply{
-								Items: items,
-							},
-						}
-					}
-
-				default:
-					err := errors.New("expected message type in response")
-					c.String(http.StatusInternalServerError, err.Error())
-					c.Error(err)
-					c.Abort()
-					return
-				}
+			// Only a redelivered event can have been received before, so a
+			// first delivery is processed in any case.
+			if !added && j.redelivery {
+				zap.L().Info("skipping redelivered event",
+					zap.String("event", j.eventID),
+				)
 
-				if _, err := bot.ReplyMessage(&line.ReplyMessageRequest{
-					ReplyToken: e.ReplyToken,
-					Messages:   []line.MessageInterface{lineMsg},
-				}); err != nil {
-					c.String(http.StatusInternalServerError, err.Error())
+				continue
+			}
+
+			if err := worker.enqueue(j); err != nil {
+				// LINE redelivers the webhook when the worker cannot take
+				// the events now, and the event is processed then.
+				if err := events.Remove(j.eventID); err != nil {
 					c.Error(err)
-					c.Abort()
-					return
 				}
 
-			default:
-				err := errors.New("unsupported event type")
-				c.String(http.StatusBadRequest, err.Error())
+				c.String(http.StatusServiceUnavailable, err.Error())
 				c.Error(err)
 				c.Abort()
 				return
 			}
 		}
+
+		c.Status(http.StatusOK)
+	}
+}
+
+func isRedelivery(ctx *webhook.DeliveryContext) bool {
+	return ctx != nil && ctx.IsRedelivery
+}
+
+// request converts the content of a message event to a talkix message.
+func request(e webhook.MessageEvent) (talkix.Message, error) {
+	var req talkix.Message
+
+	switch msg := e.Message.(type) {
+	case webhook.TextMessageContent:
+		text, _ := selfMentions(msg)
+		req = talkix.NewTextMessage(text)
+
+	case webhook.LocationMessageContent:
+		locationText := fmt.Sprintf("Title: %s\nAddress: %s\nLatitude: %.6f\nLongitude: %.6f",
+			msg.Title, msg.Address, msg.Latitude, msg.Longitude)
+
+		req = talkix.NewTextMessage(locationText)
+
+	case webhook.StickerMessageContent:
+		stickerText := "Sticker"
+		if len(msg.Keywords) > 0 {
+			stickerText += ": " + strings.Join(msg.Keywords, ", ")
+		}
+
+		if msg.Text != "" {
+			stickerText += "\nText: " + msg.Text
+		}
+
+		req = talkix.NewTextMessage(stickerText)
+
+	case webhook.ImageMessageContent:
+		image, err := content(msg.Id, msg.ContentProvider, message.AttachmentImage)
+		if err != nil {
+			return nil, err
+		}
+
+		req = talkix.NewMediaMessage("", image)
+
+	case webhook.AudioMessageContent:
+		audio, err := content(msg.Id, msg.ContentProvider, message.AttachmentAudio)
+		if err != nil {
+			return nil, err
+		}
+
+		// LINE records voice messages as M4A.
+		audio.Name = msg.Id + ".m4a"
+
+		req = talkix.NewMediaMessage("", audio)
+
+	case webhook.FileMessageContent:
+		file := message.Attachment{
+			Type: message.AttachmentFile,
+			Name: msg.FileName,
+		}
+
+		// A file too large to read is passed on by its name only.
+		if msg.FileSize <= maxContentSize {
+			f, err := content(msg.Id, nil, message.AttachmentFile)
+			if err != nil {
+				return nil, err
+			}
+
+			file.MIMEType = f.MIMEType
+			file.Data = f.Data
+		}
+
+		req = talkix.NewMediaMessage("", file)
+
+	default:
+		return nil, ErrUnsupportedMessage
+	}
+
+	req.SetTimestamp(time.UnixMilli(e.Timestamp))
+	return req, nil
+}
+
+// selfMentions removes the mentions of the bot from a text, and tells
+// whether there were any. The mentions are indexed in UTF-16 code units.
+func selfMentions(msg webhook.TextMessageContent) (string, bool) {
+	if msg.Mention == nil {
+		return msg.Text, false
+	}
+
+	text := utf16.Encode([]rune(msg.Text))
+
+	var mentions []webhook.UserMentionee
+	for _, m := range msg.Mention.Mentionees {
+		if m, ok := m.(webhook.UserMentionee); ok && m.IsSelf {
+			mentions = append(mentions, m)
+		}
+	}
+
+	if len(mentions) == 0 {
+		return msg.Text, false
+	}
+
+	// The later mentions are removed first, so that the earlier indexes
+	// still hold.
+	slices.SortFunc(mentions, func(a, b webhook.UserMentionee) int {
+		return int(b.Index - a.Index)
+	})
+
+	for _, m := range mentions {
+		start, end := int(m.Index), int(m.Index+m.Length)
+		if start < 0 || end > len(text) || start > end {
+			continue
+		}
+
+		text = slices.Delete(text, start, end)
+	}
+
+	return strings.TrimSpace(string(utf16.Decode(text))), true
+}
+
+// chatEvent converts an event other than a message to a talkix event. The
+// chat is the group or room of a join or leave event.
+func chatEvent(e webhook.EventInterface, chatID string) (talkix.Event, error) {
+	var event talkix.Event
+
+	switch e := e.(type) {
+	case webhook.FollowEvent:
+		event = talkix.Event{Type: talkix.EventFollow, Timestamp: time.UnixMilli(e.Timestamp)}
+
+	case webhook.UnfollowEvent:
+		event = talkix.Event{Type: talkix.EventUnfollow, Timestamp: time.UnixMilli(e.Timestamp)}
+
+	case webhook.PostbackEvent:
+		event = talkix.Event{Type: talkix.EventPostback, Timestamp: time.UnixMilli(e.Timestamp)}
+
+		if e.Postback != nil {
+			event.Data = e.Postback.Data
+			event.Params = e.Postback.Params
+		}
+
+	case webhook.JoinEvent:
+		event = talkix.Event{Type: talkix.EventJoin, ChatID: chatID, Timestamp: time.UnixMilli(e.Timestamp)}
+
+	case webhook.LeaveEvent:
+		event = talkix.Event{Type: talkix.EventLeave, ChatID: chatID, Timestamp: time.UnixMilli(e.Timestamp)}
+
+	default:
+		return event, ErrUnsupportedEvent
 	}
+
+	return event, nil
+}
+
+// content downloads the content of a message from LINE, unless it is hosted
+// elsewhere and can be passed on by its URL.
+func content(messageID string, provider *webhook.ContentProvider, kind message.AttachmentType) (message.Attachment, error) {
+	a := message.Attachment{Type: kind}
+
+	if provider != nil && provider.Type == webhook.ContentProviderTYPE_EXTERNAL {
+		a.URL = provider.OriginalContentUrl
+		return a, nil
+	}
+
+	res, err := blob.GetMessageContent(messageID)
+	if err != nil {
+		return a, err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxContentSize+1))
+	if err != nil {
+		return a, err
+	}
+
+	if len(data) > maxContentSize {
+		return a, ErrContentTooLarge
+	}
+
+	a.MIMEType = res.Header.Get("Content-Type")
+	a.Data = data
+	return a, nil
 }
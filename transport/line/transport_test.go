@@ -0,0 +1,167 @@
+package line
+
+import (
+	"testing"
+	"time"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/webhook"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix/llm/message"
+)
+
+func messageEvent(content webhook.MessageContentInterface) webhook.MessageEvent {
+	return webhook.MessageEvent{
+		Timestamp: time.Now().UnixMilli(),
+		Source:    webhook.UserSource{UserId: "U1234"},
+		Message:   content,
+	}
+}
+
+func TestRequestImage(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newLineServer()
+	defer server.Close()
+
+	useLineServer(t, server)
+
+	server.contents["1001"] = []byte("png")
+
+	req, err := request(messageEvent(webhook.ImageMessageContent{
+		Id:              "1001",
+		ContentProvider: &webhook.ContentProvider{Type: webhook.ContentProviderTYPE_LINE},
+	}))
+
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Empty(req.Content())
+	assert.Equal([]message.Attachment{{
+		Type:     message.AttachmentImage,
+		MIMEType: "image/png",
+		Data:     []byte("png"),
+	}}, req.Attachments())
+
+	// An image hosted elsewhere is passed on by its URL.
+	req, err = request(messageEvent(webhook.ImageMessageContent{
+		Id: "1002",
+		ContentProvider: &webhook.ContentProvider{
+			Type:               webhook.ContentProviderTYPE_EXTERNAL,
+			OriginalContentUrl: "https://example.com/cat.jpg",
+		},
+	}))
+
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	if assert.Len(req.Attachments(), 1) {
+		assert.Equal("https://example.com/cat.jpg", req.Attachments()[0].URL)
+		assert.Empty(req.Attachments()[0].Data)
+	}
+
+	// Content that LINE does not have is an error, which is retried.
+	_, err = request(messageEvent(webhook.ImageMessageContent{
+		Id:              "1003",
+		ContentProvider: &webhook.ContentProvider{Type: webhook.ContentProviderTYPE_LINE},
+	}))
+
+	assert.Error(err)
+}
+
+func TestRequestAudio(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newLineServer()
+	defer server.Close()
+
+	useLineServer(t, server)
+
+	server.contents["2001"] = []byte("m4a")
+
+	req, err := request(messageEvent(webhook.AudioMessageContent{
+		Id:              "2001",
+		ContentProvider: &webhook.ContentProvider{Type: webhook.ContentProviderTYPE_LINE},
+		Duration:        3000,
+	}))
+
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	if assert.Len(req.Attachments(), 1) {
+		audio := req.Attachments()[0]
+		assert.Equal(message.AttachmentAudio, audio.Type)
+		assert.Equal("2001.m4a", audio.Name)
+		assert.Equal([]byte("m4a"), audio.Data)
+	}
+}
+
+func TestRequestFile(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newLineServer()
+	defer server.Close()
+
+	useLineServer(t, server)
+
+	server.contents["3001"] = []byte("milk\neggs")
+
+	req, err := request(messageEvent(webhook.FileMessageContent{
+		Id:       "3001",
+		FileName: "list.txt",
+		FileSize: 9,
+	}))
+
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	if assert.Len(req.Attachments(), 1) {
+		file := req.Attachments()[0]
+		assert.Equal(message.AttachmentFile, file.Type)
+		assert.Equal("list.txt", file.Name)
+		assert.Equal([]byte("milk\neggs"), file.Data)
+	}
+
+	// A large file is not downloaded.
+	req, err = request(messageEvent(webhook.FileMessageContent{
+		Id:       "3002",
+		FileName: "video.mp4",
+		FileSize: maxContentSize + 1,
+	}))
+
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	if assert.Len(req.Attachments(), 1) {
+		assert.Equal("video.mp4", req.Attachments()[0].Name)
+		assert.Empty(req.Attachments()[0].Data)
+	}
+}
+
+func TestRequestSticker(t *testing.T) {
+	assert := assert.New(t)
+
+	req, err := request(messageEvent(webhook.StickerMessageContent{
+		PackageId: "446",
+		StickerId: "1988",
+		Keywords:  []string{"happy", "thumbs up"},
+	}))
+
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("Sticker: happy, thumbs up", req.Content())
+	assert.Empty(req.Attachments())
+}
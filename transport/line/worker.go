@@ -0,0 +1,711 @@
+package line
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/line/line-bot-sdk-go/v8/linebot/webhook"
+	"go.uber.org/zap"
+
+	line "github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+
+	"github.com/flarexio/core/endpoint"
+	"github.com/flarexio/talkix"
+	"github.com/flarexio/talkix/config"
+	"github.com/flarexio/talkix/group"
+	"github.com/flarexio/talkix/identity"
+	"github.com/flarexio/talkix/user"
+)
+
+const (
+	DefaultWorkers   = 4
+	DefaultQueueSize = 100
+	DefaultRetries   = 3
+	DefaultBackoff   = time.Second
+
+	// replyTokenTTL is how long after an event its reply token is still
+	// used. LINE only accepts a reply token for about a minute.
+	replyTokenTTL = 50 * time.Second
+
+	// maxFailures is the number of recent failures kept by a worker.
+	maxFailures = 100
+)
+
+var (
+	ErrQueueFull          = errors.New("webhook queue full")
+	ErrWorkerClosed       = errors.New("webhook worker closed")
+	ErrUnsupportedEvent   = errors.New("unsupported event type")
+	ErrUnsupportedSource  = errors.New("unsupported source type")
+	ErrUnsupportedMessage = errors.New("unsupported message type")
+	ErrContentTooLarge    = errors.New("message content too large")
+	ErrInvalidMessage     = errors.New("reply cannot be sent as a line message")
+)
+
+// Failure is an event that could not be answered, not even after retrying.
+type Failure struct {
+	EventID  string    `json:"event_id"`
+	ChatID   string    `json:"chat_id"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+type job struct {
+	event      webhook.EventInterface
+	eventID    string
+	timestamp  int64
+	replyToken string
+	redelivery bool
+
+	// userID is the LINE user of the event, the speaker in a group or room,
+	// and can be empty there. chatID is where the replies are pushed to, and
+	// chatType is empty for a chat with a single user.
+	userID    string
+	chatID    string
+	chatType  talkix.ChatType
+	mentioned bool
+
+	retryKey string
+	attempts int
+
+	// The replies are kept between the attempts, so that a failed delivery
+	// is retried without generating the replies again.
+	replies  []talkix.Message
+	answered bool
+
+	// replyTokenRejected makes the next attempts push the replies.
+	replyTokenRejected bool
+}
+
+// newJob reads what the worker needs from an event. Messages and postbacks
+// are supported from a user, a group or a room; follows only from a user,
+// joins and leaves only from a group or room.
+func newJob(e webhook.EventInterface) (*job, error) {
+	j := &job{
+		event:    e,
+		retryKey: uuid.NewString(),
+	}
+
+	var (
+		source   webhook.SourceInterface
+		delivery *webhook.DeliveryContext
+		direct   = true
+		chat     = true
+	)
+
+	switch e := e.(type) {
+	case webhook.MessageEvent:
+		j.eventID, j.timestamp, j.replyToken = e.WebhookEventId, e.Timestamp, e.ReplyToken
+		source, delivery = e.Source, e.DeliveryContext
+
+		if msg, ok := e.Message.(webhook.TextMessageContent); ok {
+			_, j.mentioned = selfMentions(msg)
+		}
+
+	case webhook.FollowEvent:
+		j.eventID, j.timestamp, j.replyToken = e.WebhookEventId, e.Timestamp, e.ReplyToken
+		source, delivery, chat = e.Source, e.DeliveryContext, false
+
+	case webhook.UnfollowEvent:
+		j.eventID, j.timestamp = e.WebhookEventId, e.Timestamp
+		source, delivery, chat = e.Source, e.DeliveryContext, false
+
+	case webhook.PostbackEvent:
+		j.eventID, j.timestamp, j.replyToken = e.WebhookEventId, e.Timestamp, e.ReplyToken
+		source, delivery = e.Source, e.DeliveryContext
+
+	case webhook.JoinEvent:
+		j.eventID, j.timestamp, j.replyToken = e.WebhookEventId, e.Timestamp, e.ReplyToken
+		source, delivery, direct = e.Source, e.DeliveryContext, false
+
+	case webhook.LeaveEvent:
+		j.eventID, j.timestamp = e.WebhookEventId, e.Timestamp
+		source, delivery, direct = e.Source, e.DeliveryContext, false
+
+	default:
+		return nil, ErrUnsupportedEvent
+	}
+
+	j.redelivery = isRedelivery(delivery)
+
+	switch source := source.(type) {
+	case webhook.UserSource:
+		if !direct {
+			return nil, ErrUnsupportedSource
+		}
+
+		j.userID = source.UserId
+		j.chatID = source.UserId
+
+	case webhook.GroupSource:
+		if !chat {
+			return nil, ErrUnsupportedSource
+		}
+
+		j.userID = source.UserId
+		j.chatID = source.GroupId
+		j.chatType = talkix.ChatGroup
+
+	case webhook.RoomSource:
+		if !chat {
+			return nil, ErrUnsupportedSource
+		}
+
+		j.userID = source.UserId
+		j.chatID = source.RoomId
+		j.chatType = talkix.ChatRoom
+
+	default:
+		return nil, ErrUnsupportedSource
+	}
+
+	return j, nil
+}
+
+// NewWorker answers the events in the background: the messages through the
+// reply endpoint, every other event through the event endpoint. The events
+// of a chat always go to the same worker, so that they are answered in order.
+func NewWorker(replies endpoint.Endpoint, events endpoint.Endpoint, directUser identity.DirectUser, cfg config.LineWorkerConfig) *Worker {
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	size := cfg.QueueSize
+	if size <= 0 {
+		size = DefaultQueueSize
+	}
+
+	retries := cfg.Retries
+	if retries <= 0 {
+		retries = DefaultRetries
+	}
+
+	backoff := cfg.Backoff
+	if backoff <= 0 {
+		backoff = DefaultBackoff
+	}
+
+	w := &Worker{
+		replies:    replies,
+		events:     events,
+		directUser: directUser,
+		retries:    retries,
+		backoff:    backoff,
+		queues:     make([]chan *job, workers),
+		retrying:   make(map[string]*retry),
+		failures:   make([]Failure, 0),
+	}
+
+	for i := range w.queues {
+		queue := make(chan *job, size)
+		w.queues[i] = queue
+
+		w.wg.Add(1)
+		go w.work(queue)
+	}
+
+	return w
+}
+
+type Worker struct {
+	replies    endpoint.Endpoint
+	events     endpoint.Endpoint
+	directUser identity.DirectUser
+	retries    int
+	backoff    time.Duration
+
+	queues []chan *job
+	closed bool
+	wg     sync.WaitGroup
+	sync.RWMutex
+
+	// retrying holds back the chats with an event waiting to be retried.
+	// Once closing, the events are retried without waiting.
+	retrying map[string]*retry
+	closing  bool
+	requeues sync.WaitGroup
+	retryMu  sync.Mutex
+
+	failures   []Failure
+	failuresMu sync.Mutex
+}
+
+// Enqueue hands an event to the worker of its chat. It fails with
+// ErrQueueFull instead of blocking the webhook when the worker falls behind.
+func (w *Worker) Enqueue(e webhook.EventInterface) error {
+	j, err := newJob(e)
+	if err != nil {
+		return err
+	}
+
+	return w.enqueue(j)
+}
+
+func (w *Worker) enqueue(j *job) error {
+	w.RLock()
+	defer w.RUnlock()
+
+	if w.closed {
+		return ErrWorkerClosed
+	}
+
+	select {
+	case w.queue(j.chatID) <- j:
+		return nil
+
+	default:
+		return ErrQueueFull
+	}
+}
+
+// queue is the queue of the worker that answers a chat.
+func (w *Worker) queue(chatID string) chan *job {
+	h := fnv.New32a()
+	h.Write([]byte(chatID))
+	return w.queues[h.Sum32()%uint32(len(w.queues))]
+}
+
+// Failures returns the recent events that could not be answered, the oldest
+// first.
+func (w *Worker) Failures() []Failure {
+	w.failuresMu.Lock()
+	defer w.failuresMu.Unlock()
+
+	return slices.Clone(w.failures)
+}
+
+// Close stops taking events and waits for the queued ones to be answered.
+// The events waiting to be retried are retried at once.
+func (w *Worker) Close() {
+	w.Lock()
+	if w.closed {
+		w.Unlock()
+		return
+	}
+
+	w.closed = true
+	w.Unlock()
+
+	w.retryMu.Lock()
+	w.closing = true
+
+	due := make([]*job, 0)
+	for _, r := range w.retrying {
+		if r.timer != nil && r.timer.Stop() {
+			due = append(due, r.job)
+		}
+	}
+	w.retryMu.Unlock()
+
+	for _, j := range due {
+		w.queue(j.chatID) <- j
+		w.requeues.Done()
+	}
+
+	w.requeues.Wait()
+
+	w.Lock()
+	for _, queue := range w.queues {
+		close(queue)
+	}
+	w.Unlock()
+
+	w.wg.Wait()
+}
+
+func (w *Worker) work(queue chan *job) {
+	defer w.wg.Done()
+
+	for j := range queue {
+		w.process(j)
+	}
+}
+
+// retry is an event waiting out its backoff, and the events of its chat
+// that came after it.
+type retry struct {
+	job     *job
+	timer   *time.Timer
+	waiting []*job
+}
+
+// process answers an event, and retries it with an exponential backoff
+// while it fails. The event is queued again after its backoff, so that the
+// worker goes on with the other chats; the next events of its own chat wait
+// behind it, to be answered in order.
+func (w *Worker) process(j *job) {
+	if w.holdBack(j) {
+		return
+	}
+
+	for j != nil {
+		if !w.attempt(j) {
+			return
+		}
+
+		j = w.next(j.chatID)
+	}
+}
+
+// attempt answers an event. It reports false when the event is to be
+// retried later.
+func (w *Worker) attempt(j *job) bool {
+	for {
+		j.attempts++
+
+		err := w.handle(j)
+		if err == nil {
+			return true
+		}
+
+		if permanent(err) || j.attempts > w.retries {
+			w.fail(j, err)
+			return true
+		}
+
+		backoff := w.backoff << (j.attempts - 1)
+
+		zap.L().Warn("failed to answer line event, retrying",
+			zap.String("event", j.eventID),
+			zap.String("chat", j.chatID),
+			zap.Int("attempt", j.attempts),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+
+		if w.retryLater(j, backoff) {
+			return false
+		}
+	}
+}
+
+// holdBack keeps an event behind the one of its chat waiting to be retried.
+func (w *Worker) holdBack(j *job) bool {
+	w.retryMu.Lock()
+	defer w.retryMu.Unlock()
+
+	r, ok := w.retrying[j.chatID]
+	if !ok || r.job == j {
+		return false
+	}
+
+	r.waiting = append(r.waiting, j)
+	return true
+}
+
+// retryLater queues an event again once its backoff is over. A closing
+// worker does not wait, and the event is retried right away instead.
+func (w *Worker) retryLater(j *job, backoff time.Duration) bool {
+	w.retryMu.Lock()
+	defer w.retryMu.Unlock()
+
+	if w.closing {
+		return false
+	}
+
+	r, ok := w.retrying[j.chatID]
+	if !ok {
+		r = new(retry)
+		w.retrying[j.chatID] = r
+	}
+
+	w.requeues.Add(1)
+
+	r.job = j
+	r.timer = time.AfterFunc(backoff, func() {
+		w.queue(j.chatID) <- j
+		w.requeues.Done()
+	})
+
+	return true
+}
+
+// next takes the event that waited behind an answered one of its chat, and
+// lets the chat go once none is left.
+func (w *Worker) next(chatID string) *job {
+	w.retryMu.Lock()
+	defer w.retryMu.Unlock()
+
+	r, ok := w.retrying[chatID]
+	if !ok {
+		return nil
+	}
+
+	if len(r.waiting) == 0 {
+		delete(w.retrying, chatID)
+		return nil
+	}
+
+	j := r.waiting[0]
+	r.waiting = r.waiting[1:]
+	r.job, r.timer = j, nil
+	return j
+}
+
+// permanent tells the errors that fail again however often they are retried.
+func permanent(err error) bool {
+	return errors.Is(err, ErrUnsupportedMessage) ||
+		errors.Is(err, ErrContentTooLarge) ||
+		errors.Is(err, ErrInvalidMessage) ||
+		errors.Is(err, talkix.ErrUnknownEvent) ||
+		errors.Is(err, talkix.ErrUnknownAction) ||
+		errors.Is(err, talkix.ErrNotInChat) ||
+		errors.Is(err, group.ErrInvalidTrigger) ||
+		errors.Is(err, group.ErrNoKeywords)
+}
+
+func (w *Worker) handle(j *job) error {
+	if !j.answered {
+		replies, err := w.answer(j)
+		if err != nil {
+			return err
+		}
+
+		j.replies = replies
+		j.answered = true
+	}
+
+	if len(j.replies) == 0 {
+		return nil
+	}
+
+	msgs, err := talkix.Render(j.replies, lineMessage)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidMessage, err)
+	}
+
+	return w.send(j, msgs)
+}
+
+// answer runs a message through the reply endpoint, and any other event
+// through the event endpoint.
+func (w *Worker) answer(j *job) ([]talkix.Message, error) {
+	ctx := w.context(j)
+
+	e, ok := j.event.(webhook.MessageEvent)
+	if !ok {
+		req, err := chatEvent(j.event, j.chatID)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := w.events(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		replies, ok := resp.([]talkix.Message)
+		if !ok {
+			return nil, errors.New("expected messages in response")
+		}
+
+		return replies, nil
+	}
+
+	req, err := request(e)
+	if err != nil {
+		return nil, err
+	}
+
+	// The loading animation is only shown in a chat with a single user.
+	if j.attempts == 1 && j.chatType == "" {
+		go bot.ShowLoadingAnimation(&line.ShowLoadingAnimationRequest{
+			ChatId:         j.userID,
+			LoadingSeconds: 20,
+		})
+	}
+
+	return talkix.Replies(ctx, w.replies, req)
+}
+
+// context identifies who the event is from. In a group or room the sessions
+// belong to the chat, which acts with the profile of the speaker. The users
+// bind their account with LINE Login.
+func (w *Worker) context(j *job) context.Context {
+	ctx := context.Background()
+
+	if authURL := cfg.Line.Login.AuthURL; authURL != "" {
+		ctx = context.WithValue(ctx, talkix.LoginKey, &talkix.Login{
+			Label: "Login with LINE",
+			URL:   authURL,
+		})
+	}
+
+	if j.chatType == "" {
+		if j.userID != "" {
+			u := w.user(j.userID)
+			w.switchRichMenu(j, u)
+
+			ctx = context.WithValue(ctx, talkix.UserKey, u)
+		}
+
+		return ctx
+	}
+
+	u := &user.User{ID: j.chatID}
+	chat := &talkix.Chat{
+		ID:        j.chatID,
+		Type:      j.chatType,
+		Mentioned: j.mentioned,
+	}
+
+	if j.userID != "" {
+		speaker := w.user(j.userID)
+
+		u.Profile = speaker.Profile
+		u.Verified = speaker.Verified
+		chat.Speaker = speakerName(j, speaker)
+	}
+
+	ctx = context.WithValue(ctx, talkix.UserKey, u)
+	ctx = context.WithValue(ctx, talkix.ChatKey, chat)
+	return ctx
+}
+
+// speakerName is the name of the bound account, or else the display name of
+// the member in the group or room.
+func speakerName(j *job, speaker *user.User) string {
+	if speaker.Profile != nil && speaker.Profile.Name != "" {
+		return speaker.Profile.Name
+	}
+
+	var (
+		name string
+		err  error
+	)
+
+	switch j.chatType {
+	case talkix.ChatGroup:
+		var profile *line.GroupUserProfileResponse
+		profile, err = bot.GetGroupMemberProfile(j.chatID, j.userID)
+		if err == nil {
+			name = profile.DisplayName
+		}
+
+	case talkix.ChatRoom:
+		var profile *line.RoomUserProfileResponse
+		profile, err = bot.GetRoomMemberProfile(j.chatID, j.userID)
+		if err == nil {
+			name = profile.DisplayName
+		}
+	}
+
+	if err != nil {
+		zap.L().Warn("failed to get member profile",
+			zap.String("chat", j.chatID),
+			zap.String("user", j.userID),
+			zap.Error(err),
+		)
+	}
+
+	if name == "" {
+		return j.userID
+	}
+
+	return name
+}
+
+// switchRichMenu shows the member menu once the user has bound the account.
+// A failure is only logged, the event is answered all the same.
+func (w *Worker) switchRichMenu(j *job, u *user.User) {
+	if menus == nil {
+		return
+	}
+
+	// A user who blocked the bot has no menu to show.
+	if _, ok := j.event.(webhook.UnfollowEvent); ok {
+		return
+	}
+
+	if err := menus.Switch(j.userID, u.Verified); err != nil {
+		zap.L().Warn("failed to switch rich menu",
+			zap.String("user", j.userID),
+			zap.Bool("verified", u.Verified),
+			zap.Error(err),
+		)
+	}
+}
+
+// user identifies the LINE user, verified when it is bound to an account.
+func (w *Worker) user(lineUserID string) *user.User {
+	u := &user.User{ID: lineUserID}
+
+	profile, _, err := w.directUser(lineUserID)
+	if err == nil {
+		u.ID = profile.ID
+		u.Profile = profile
+		u.Verified = true
+	}
+
+	return u
+}
+
+// send replies with the reply token while it is still valid, and pushes the
+// messages otherwise. The retry key makes LINE ignore a push it has already
+// delivered.
+func (w *Worker) send(j *job, msgs []line.MessageInterface) error {
+	issuedAt := time.UnixMilli(j.timestamp)
+	if j.replyToken != "" && !j.replyTokenRejected && time.Since(issuedAt) < replyTokenTTL {
+		res, _, err := bot.ReplyMessageWithHttpInfo(&line.ReplyMessageRequest{
+			ReplyToken: j.replyToken,
+			Messages:   msgs,
+		})
+
+		if err == nil {
+			return nil
+		}
+
+		// An expired or used reply token is rejected as a bad request,
+		// any other error is retried as it is.
+		if res == nil || res.StatusCode != http.StatusBadRequest {
+			return err
+		}
+
+		j.replyTokenRejected = true
+	}
+
+	res, _, err := bot.PushMessageWithHttpInfo(&line.PushMessageRequest{
+		To:       j.chatID,
+		Messages: msgs,
+	}, j.retryKey)
+
+	// A push is only rejected as a bad request for its messages, which are
+	// rejected again however often they are sent.
+	if err != nil && res != nil && res.StatusCode == http.StatusBadRequest {
+		return fmt.Errorf("%w: %w", ErrInvalidMessage, err)
+	}
+
+	return err
+}
+
+func (w *Worker) fail(j *job, err error) {
+	zap.L().Error("failed to answer line event",
+		zap.String("event", j.eventID),
+		zap.String("chat", j.chatID),
+		zap.Int("attempts", j.attempts),
+		zap.Error(err),
+	)
+
+	w.failuresMu.Lock()
+	defer w.failuresMu.Unlock()
+
+	w.failures = append(w.failures, Failure{
+		EventID:  j.eventID,
+		ChatID:   j.chatID,
+		Attempts: j.attempts,
+		Error:    err.Error(),
+		FailedAt: time.Now(),
+	})
+
+	if len(w.failures) > maxFailures {
+		w.failures = slices.Delete(w.failures, 0, len(w.failures)-maxFailures)
+	}
+}
@@ -0,0 +1,667 @@
+package line
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	line "github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+
+	"github.com/flarexio/talkix"
+	"github.com/flarexio/talkix/config"
+	"github.com/flarexio/talkix/identity"
+	"github.com/flarexio/talkix/persistence/inmem"
+	"github.com/flarexio/talkix/user"
+)
+
+const testChannelSecret = "test-secret"
+
+type apiRequest struct {
+	Path     string
+	RetryKey string
+	Body     map[string]any
+}
+
+// lineServer stands in for the LINE Messaging API and records the messages
+// sent to it. The reply endpoint answers with replyStatus when it is set.
+// The content of a message is served from contents, as a PNG image, and
+// every member of a group or room is called Alice.
+type lineServer struct {
+	*httptest.Server
+
+	replyStatus int
+	pushStatus  int
+	requests    []apiRequest
+	contents    map[string][]byte
+	sync.Mutex
+}
+
+func newLineServer() *lineServer {
+	s := &lineServer{
+		contents: make(map[string][]byte),
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := strings.CutPrefix(r.URL.Path, "/v2/bot/message/"); ok && strings.HasSuffix(id, "/content") {
+			s.Lock()
+			data, ok := s.contents[strings.TrimSuffix(id, "/content")]
+			s.Unlock()
+
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(data)
+			return
+		}
+
+		if strings.Contains(r.URL.Path, "/member/") {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"displayName":"Alice"}`))
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+
+		req := apiRequest{
+			Path:     r.URL.Path,
+			RetryKey: r.Header.Get("X-Line-Retry-Key"),
+		}
+
+		json.Unmarshal(body, &req.Body)
+
+		s.Lock()
+		defer s.Unlock()
+
+		if r.URL.Path == "/v2/bot/chat/loading/start" {
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{}`))
+			return
+		}
+
+		s.requests = append(s.requests, req)
+
+		if r.URL.Path == "/v2/bot/message/reply" && s.replyStatus != 0 {
+			w.WriteHeader(s.replyStatus)
+			w.Write([]byte(`{"message":"Invalid reply token"}`))
+			return
+		}
+
+		if r.URL.Path == "/v2/bot/message/push" && s.pushStatus != 0 {
+			w.WriteHeader(s.pushStatus)
+			w.Write([]byte(`{"message":"The request body has 1 error(s)"}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"sentMessages":[]}`))
+	}))
+
+	return s
+}
+
+func (s *lineServer) Requests() []apiRequest {
+	s.Lock()
+	defer s.Unlock()
+
+	return append([]apiRequest(nil), s.requests...)
+}
+
+// useLineServer points the package at the stub API.
+func useLineServer(t *testing.T, server *lineServer) {
+	useLineAPI(t, server.URL)
+}
+
+func useLineAPI(t *testing.T, url string) {
+	api, err := line.NewMessagingApiAPI("test-token", line.WithEndpoint(url))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blobAPI, err := line.NewMessagingApiBlobAPI("test-token", line.WithBlobEndpoint(url))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Line.Messaging.ChannelSecret = testChannelSecret
+	bot = api
+	blob = blobAPI
+	menus = nil
+}
+
+func unboundUser(subject string) (*user.UserProfile, *identity.Token, error) {
+	return nil, nil, errors.New("user not bound")
+}
+
+func textEvent(text string, issuedAt time.Time) map[string]any {
+	return map[string]any{
+		"type":           "message",
+		"mode":           "active",
+		"timestamp":      issuedAt.UnixMilli(),
+		"webhookEventId": "01H0000000000000000000" + text,
+		"replyToken":     "reply-token-" + text,
+		"source": map[string]any{
+			"type":   "user",
+			"userId": "U1234",
+		},
+		"deliveryContext": map[string]any{
+			"isRedelivery": false,
+		},
+		"message": map[string]any{
+			"type":       "text",
+			"id":         "1",
+			"quoteToken": "quote",
+			"text":       text,
+		},
+	}
+}
+
+func postWebhook(handler gin.HandlerFunc, events ...map[string]any) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]any{
+		"destination": "Ubot",
+		"events":      events,
+	})
+
+	mac := hmac.New(sha256.New, []byte(testChannelSecret))
+	mac.Write(body)
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook/line", bytes.NewReader(body))
+	req.Header.Set("X-Line-Signature", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+
+	w := httptest.NewRecorder()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/webhook/line", handler)
+	r.ServeHTTP(w, req)
+
+	return w
+}
+
+func echoEndpoint(ctx context.Context, request any) (any, error) {
+	msg := request.(talkix.Message)
+	return talkix.NewTextMessage("echo: " + msg.Content()), nil
+}
+
+func eventEndpoint(ctx context.Context, request any) (any, error) {
+	e := request.(talkix.Event)
+
+	switch e.Type {
+	case talkix.EventUnfollow, talkix.EventLeave:
+		return []talkix.Message(nil), nil
+
+	default:
+		return []talkix.Message{
+			talkix.NewTextMessage("event: " + string(e.Type)),
+			talkix.NewTextMessage("chat: " + e.ChatID),
+		}, nil
+	}
+}
+
+func TestMessageHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newLineServer()
+	defer server.Close()
+
+	useLineServer(t, server)
+
+	release := make(chan struct{})
+	endpoint := func(ctx context.Context, request any) (any, error) {
+		<-release
+		return echoEndpoint(ctx, request)
+	}
+
+	worker := NewWorker(endpoint, eventEndpoint, unboundUser, config.LineWorkerConfig{})
+	defer worker.Close()
+
+	// The webhook is acknowledged before the reply is generated.
+	w := postWebhook(MessageHandler(worker, inmem.NewDedupStore(0)), textEvent("hello", time.Now()))
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Empty(server.Requests())
+
+	close(release)
+	worker.Close()
+
+	requests := server.Requests()
+	if !assert.Len(requests, 1) {
+		return
+	}
+
+	assert.Equal("/v2/bot/message/reply", requests[0].Path)
+	assert.Equal("reply-token-hello", requests[0].Body["replyToken"])
+
+	msgs := requests[0].Body["messages"].([]any)
+	assert.Equal("echo: hello", msgs[0].(map[string]any)["text"])
+}
+
+func chatEventOf(eventType string, source map[string]any, issuedAt time.Time) map[string]any {
+	return map[string]any{
+		"type":           eventType,
+		"mode":           "active",
+		"timestamp":      issuedAt.UnixMilli(),
+		"webhookEventId": "01H0000000000000000000" + eventType,
+		"replyToken":     "reply-token-" + eventType,
+		"source":         source,
+		"deliveryContext": map[string]any{
+			"isRedelivery": false,
+		},
+	}
+}
+
+func TestMessageHandlerEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newLineServer()
+	defer server.Close()
+
+	useLineServer(t, server)
+
+	worker := NewWorker(echoEndpoint, eventEndpoint, unboundUser, config.LineWorkerConfig{})
+
+	user := map[string]any{"type": "user", "userId": "U1234"}
+	group := map[string]any{"type": "group", "groupId": "C1234", "userId": "U1234"}
+
+	groupMessage := textEvent("hello", time.Now())
+	groupMessage["source"] = group
+
+	// The unsupported event is skipped, the others are still handled.
+	w := postWebhook(MessageHandler(worker, inmem.NewDedupStore(0)),
+		chatEventOf("follow", user, time.Now()),
+		chatEventOf("videoPlayComplete", user, time.Now()),
+		groupMessage,
+		chatEventOf("join", group, time.Now()),
+		chatEventOf("unfollow", user, time.Now()),
+	)
+
+	assert.Equal(http.StatusOK, w.Code)
+
+	worker.Close()
+
+	requests := server.Requests()
+	if !assert.Len(requests, 3) {
+		return
+	}
+
+	// The chats are answered in parallel, so the replies are told apart by
+	// their reply tokens.
+	replies := make(map[string][]string)
+	for _, req := range requests {
+		token := req.Body["replyToken"].(string)
+		for _, msg := range req.Body["messages"].([]any) {
+			replies[token] = append(replies[token], msg.(map[string]any)["text"].(string))
+		}
+	}
+
+	assert.Equal(map[string][]string{
+		"reply-token-follow": {"event: follow", "chat: "},
+		"reply-token-hello":  {"echo: hello"},
+		"reply-token-join":   {"event: join", "chat: C1234"},
+	}, replies)
+
+	assert.Empty(worker.Failures())
+}
+
+func TestMessageHandlerGroup(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newLineServer()
+	defer server.Close()
+
+	useLineServer(t, server)
+
+	var users []string
+	endpoint := func(ctx context.Context, request any) (any, error) {
+		u := ctx.Value(talkix.UserKey).(*user.User)
+		chat := ctx.Value(talkix.ChatKey).(*talkix.Chat)
+
+		users = append(users, u.ID)
+
+		if !chat.Mentioned {
+			return nil, talkix.ErrNotAddressed
+		}
+
+		msg := request.(talkix.Message)
+		return talkix.NewTextMessage(chat.Speaker + ": " + msg.Content()), nil
+	}
+
+	worker := NewWorker(endpoint, eventEndpoint, unboundUser, config.LineWorkerConfig{})
+
+	source := map[string]any{"type": "group", "groupId": "C1234", "userId": "U1234"}
+
+	chatter := textEvent("lunch", time.Now())
+	chatter["source"] = source
+
+	question := textEvent("question", time.Now())
+	question["source"] = source
+	question["message"] = map[string]any{
+		"type":       "text",
+		"id":         "2",
+		"quoteToken": "quote",
+		"text":       "@Talkix where to eat?",
+		"mention": map[string]any{
+			"mentionees": []map[string]any{
+				{"type": "user", "index": 0, "length": 7, "isSelf": true},
+			},
+		},
+	}
+
+	w := postWebhook(MessageHandler(worker, inmem.NewDedupStore(0)), chatter, question)
+	assert.Equal(http.StatusOK, w.Code)
+
+	worker.Close()
+
+	// Both messages belong to the group, but only the mention is answered.
+	assert.Equal([]string{"C1234", "C1234"}, users)
+
+	requests := server.Requests()
+	if !assert.Len(requests, 1) {
+		return
+	}
+
+	assert.Equal("reply-token-question", requests[0].Body["replyToken"])
+
+	msgs := requests[0].Body["messages"].([]any)
+	assert.Equal("Alice: where to eat?", msgs[0].(map[string]any)["text"])
+
+	assert.Empty(worker.Failures())
+}
+
+func TestMessageHandlerInvalidSignature(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newLineServer()
+	defer server.Close()
+
+	useLineServer(t, server)
+
+	worker := NewWorker(echoEndpoint, eventEndpoint, unboundUser, config.LineWorkerConfig{})
+	defer worker.Close()
+
+	cfg.Line.Messaging.ChannelSecret = "another-secret"
+
+	w := postWebhook(MessageHandler(worker, inmem.NewDedupStore(0)), textEvent("hello", time.Now()))
+	assert.Equal(http.StatusBadRequest, w.Code)
+}
+
+func TestMessageHandlerSkipsRedeliveredEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newLineServer()
+	defer server.Close()
+
+	useLineServer(t, server)
+
+	var calls int
+	endpoint := func(ctx context.Context, request any) (any, error) {
+		calls++
+		return echoEndpoint(ctx, request)
+	}
+
+	events := inmem.NewDedupStore(0)
+
+	closed := NewWorker(endpoint, eventEndpoint, unboundUser, config.LineWorkerConfig{})
+	closed.Close()
+
+	// The event is not recorded when the worker turns it away.
+	event := textEvent("hello", time.Now())
+
+	w := postWebhook(MessageHandler(closed, events), event)
+	assert.Equal(http.StatusServiceUnavailable, w.Code)
+
+	worker := NewWorker(endpoint, eventEndpoint, unboundUser, config.LineWorkerConfig{})
+	handler := MessageHandler(worker, events)
+
+	event["deliveryContext"] = map[string]any{"isRedelivery": true}
+
+	w = postWebhook(handler, event)
+	assert.Equal(http.StatusOK, w.Code)
+
+	// The same event again is acknowledged but not processed.
+	w = postWebhook(handler, event)
+	assert.Equal(http.StatusOK, w.Code)
+
+	worker.Close()
+
+	assert.Equal(1, calls)
+	assert.Len(server.Requests(), 1)
+}
+
+func TestWorkerPushesAfterReplyTokenExpired(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newLineServer()
+	defer server.Close()
+
+	useLineServer(t, server)
+
+	worker := NewWorker(echoEndpoint, eventEndpoint, unboundUser, config.LineWorkerConfig{})
+
+	w := postWebhook(MessageHandler(worker, inmem.NewDedupStore(0)), textEvent("late", time.Now().Add(-2*time.Minute)))
+	assert.Equal(http.StatusOK, w.Code)
+
+	worker.Close()
+
+	requests := server.Requests()
+	if !assert.Len(requests, 1) {
+		return
+	}
+
+	assert.Equal("/v2/bot/message/push", requests[0].Path)
+	assert.Equal("U1234", requests[0].Body["to"])
+	assert.NotEmpty(requests[0].RetryKey)
+}
+
+func TestWorkerPushesWhenReplyTokenRejected(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newLineServer()
+	defer server.Close()
+
+	server.replyStatus = http.StatusBadRequest
+
+	useLineServer(t, server)
+
+	worker := NewWorker(echoEndpoint, eventEndpoint, unboundUser, config.LineWorkerConfig{})
+
+	w := postWebhook(MessageHandler(worker, inmem.NewDedupStore(0)), textEvent("hello", time.Now()))
+	assert.Equal(http.StatusOK, w.Code)
+
+	worker.Close()
+
+	requests := server.Requests()
+	if !assert.Len(requests, 2) {
+		return
+	}
+
+	assert.Equal("/v2/bot/message/reply", requests[0].Path)
+	assert.Equal("/v2/bot/message/push", requests[1].Path)
+	assert.Empty(worker.Failures())
+}
+
+func TestWorkerRetries(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newLineServer()
+	defer server.Close()
+
+	useLineServer(t, server)
+
+	var calls int
+	endpoint := func(ctx context.Context, request any) (any, error) {
+		calls++
+		if calls == 1 {
+			return nil, talkix.ErrQueueFull
+		}
+
+		return echoEndpoint(ctx, request)
+	}
+
+	worker := NewWorker(endpoint, eventEndpoint, unboundUser, config.LineWorkerConfig{
+		Retries: 2,
+		Backoff: time.Millisecond,
+	})
+
+	postWebhook(MessageHandler(worker, inmem.NewDedupStore(0)), textEvent("hello", time.Now()))
+	worker.Close()
+
+	assert.Equal(2, calls)
+	assert.Len(server.Requests(), 1)
+	assert.Empty(worker.Failures())
+}
+
+func TestWorkerRetriesWithoutHoldingUpOtherChats(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newLineServer()
+	defer server.Close()
+
+	useLineServer(t, server)
+
+	failed := false
+	endpoint := func(ctx context.Context, request any) (any, error) {
+		if request.(talkix.Message).Content() == "first" && !failed {
+			failed = true
+			return nil, errors.New("llm unavailable")
+		}
+
+		return echoEndpoint(ctx, request)
+	}
+
+	worker := NewWorker(endpoint, eventEndpoint, unboundUser, config.LineWorkerConfig{
+		Workers: 1,
+		Retries: 2,
+		Backoff: time.Hour,
+	})
+
+	other := textEvent("other", time.Now())
+	other["source"] = map[string]any{"type": "user", "userId": "U5678"}
+
+	handler := MessageHandler(worker, inmem.NewDedupStore(0))
+	postWebhook(handler, textEvent("first", time.Now()), textEvent("second", time.Now()), other)
+
+	// The other chat is answered while the first event waits to be retried,
+	// and the second event of its chat waits behind it.
+	assert.Eventually(func() bool {
+		return len(server.Requests()) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	assert.Equal("reply-token-other", server.Requests()[0].Body["replyToken"])
+
+	// Closing does not wait out the backoff.
+	start := time.Now()
+	worker.Close()
+	assert.Less(time.Since(start), time.Second)
+
+	requests := server.Requests()
+	if !assert.Len(requests, 3) {
+		return
+	}
+
+	assert.Equal("reply-token-first", requests[1].Body["replyToken"])
+	assert.Equal("reply-token-second", requests[2].Body["replyToken"])
+	assert.Empty(worker.Failures())
+}
+
+// otherMessage is a message that LINE has no rendering for.
+type otherMessage struct {
+	talkix.TextMessage
+}
+
+func TestWorkerDropsInvalidMessages(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newLineServer()
+	defer server.Close()
+
+	server.pushStatus = http.StatusBadRequest
+
+	useLineServer(t, server)
+
+	worker := NewWorker(echoEndpoint, eventEndpoint, unboundUser, config.LineWorkerConfig{
+		Retries: 2,
+		Backoff: time.Millisecond,
+	})
+
+	// A message LINE rejects is not sent again.
+	postWebhook(MessageHandler(worker, inmem.NewDedupStore(0)), textEvent("late", time.Now().Add(-2*time.Minute)))
+	worker.Close()
+
+	assert.Len(server.Requests(), 1)
+
+	failures := worker.Failures()
+	if assert.Len(failures, 1) {
+		assert.Equal(1, failures[0].Attempts)
+	}
+
+	// Nor is a reply that cannot be rendered.
+	server.pushStatus = 0
+
+	unrenderable := func(ctx context.Context, request any) (any, error) {
+		return &otherMessage{}, nil
+	}
+
+	worker = NewWorker(unrenderable, eventEndpoint, unboundUser, config.LineWorkerConfig{
+		Retries: 2,
+		Backoff: time.Millisecond,
+	})
+
+	postWebhook(MessageHandler(worker, inmem.NewDedupStore(0)), textEvent("hello", time.Now()))
+	worker.Close()
+
+	failures = worker.Failures()
+	if assert.Len(failures, 1) {
+		assert.Equal(1, failures[0].Attempts)
+	}
+}
+
+func TestWorkerTracksFailures(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newLineServer()
+	defer server.Close()
+
+	useLineServer(t, server)
+
+	endpoint := func(ctx context.Context, request any) (any, error) {
+		return nil, errors.New("llm unavailable")
+	}
+
+	worker := NewWorker(endpoint, eventEndpoint, unboundUser, config.LineWorkerConfig{
+		Retries: 2,
+		Backoff: time.Millisecond,
+	})
+
+	postWebhook(MessageHandler(worker, inmem.NewDedupStore(0)), textEvent("hello", time.Now()))
+	worker.Close()
+
+	failures := worker.Failures()
+	if !assert.Len(failures, 1) {
+		return
+	}
+
+	assert.Equal("U1234", failures[0].ChatID)
+	assert.Equal(3, failures[0].Attempts)
+	assert.Equal("llm unavailable", failures[0].Error)
+	assert.Empty(server.Requests())
+
+	// A closed worker turns the webhook away, so that LINE redelivers it.
+	w := postWebhook(MessageHandler(worker, inmem.NewDedupStore(0)), textEvent("again", time.Now()))
+	assert.Equal(http.StatusServiceUnavailable, w.Code)
+}
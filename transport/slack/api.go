@@ -0,0 +1,222 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const DefaultAPIURL = "https://slack.com/api"
+
+// APIError is an error answered by the Web API.
+type APIError struct {
+	Method string
+	Code   string
+}
+
+func (e *APIError) Error() string {
+	return "slack: " + e.Method + ": " + e.Code
+}
+
+// EventEnvelope wraps an event of the Events API, or the challenge sent when
+// the request URL is set. The authorizations name the bot user the event is
+// delivered to.
+type EventEnvelope struct {
+	Type           string          `json:"type"`
+	Challenge      string          `json:"challenge,omitempty"`
+	TeamID         string          `json:"team_id,omitempty"`
+	EventID        string          `json:"event_id,omitempty"`
+	Event          *Event          `json:"event,omitempty"`
+	Authorizations []Authorization `json:"authorizations,omitempty"`
+}
+
+type Authorization struct {
+	UserID string `json:"user_id"`
+	IsBot  bool   `json:"is_bot"`
+}
+
+type Event struct {
+	Type        string `json:"type"`
+	Subtype     string `json:"subtype,omitempty"`
+	User        string `json:"user,omitempty"`
+	BotID       string `json:"bot_id,omitempty"`
+	Text        string `json:"text,omitempty"`
+	TS          string `json:"ts,omitempty"`
+	ThreadTS    string `json:"thread_ts,omitempty"`
+	Channel     string `json:"channel,omitempty"`
+	ChannelType string `json:"channel_type,omitempty"`
+}
+
+// InteractionPayload is posted when a button of a message is pressed.
+type InteractionPayload struct {
+	Type    string   `json:"type"`
+	User    User     `json:"user"`
+	Team    Team     `json:"team"`
+	Channel Channel  `json:"channel"`
+	Message *Event   `json:"message,omitempty"`
+	Actions []Action `json:"actions"`
+}
+
+type User struct {
+	ID      string      `json:"id"`
+	Name    string      `json:"name,omitempty"`
+	Profile UserProfile `json:"profile"`
+}
+
+type UserProfile struct {
+	DisplayName string `json:"display_name,omitempty"`
+	RealName    string `json:"real_name,omitempty"`
+}
+
+type Team struct {
+	ID string `json:"id"`
+}
+
+type Channel struct {
+	ID string `json:"id"`
+}
+
+type Action struct {
+	ActionID string `json:"action_id"`
+	Type     string `json:"type"`
+	Value    string `json:"value,omitempty"`
+}
+
+// Block is a Block Kit block. Only the fields of its type are set.
+type Block struct {
+	Type      string   `json:"type"`
+	Text      *Text    `json:"text,omitempty"`
+	Fields    []*Text  `json:"fields,omitempty"`
+	Accessory *Element `json:"accessory,omitempty"`
+	Elements  []any    `json:"elements,omitempty"`
+	ImageURL  string   `json:"image_url,omitempty"`
+	AltText   string   `json:"alt_text,omitempty"`
+}
+
+// Text is a text object, plain_text or mrkdwn.
+type Text struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Element is a button or an image inside a block.
+type Element struct {
+	Type     string `json:"type"`
+	Text     *Text  `json:"text,omitempty"`
+	ActionID string `json:"action_id,omitempty"`
+	Value    string `json:"value,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Style    string `json:"style,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+	AltText  string `json:"alt_text,omitempty"`
+}
+
+// PostMessageRequest posts a message. The text is shown in the
+// notifications, and in place of the blocks where they cannot be shown.
+type PostMessageRequest struct {
+	Channel  string   `json:"channel"`
+	ThreadTS string   `json:"thread_ts,omitempty"`
+	Text     string   `json:"text"`
+	Blocks   []*Block `json:"blocks,omitempty"`
+}
+
+// Client calls the methods of the Slack Web API.
+type Client struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func NewClient(token string, apiURL string) *Client {
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+
+	return &Client{
+		url:    strings.TrimSuffix(apiURL, "/") + "/",
+		token:  token,
+		client: &http.Client{},
+	}
+}
+
+// post calls a method with a JSON body.
+func (c *Client) post(ctx context.Context, method string, params any, result any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	return c.do(req, method, result)
+}
+
+// get calls a method with the arguments in the query, which the read
+// methods expect instead of a JSON body.
+func (c *Client) get(ctx context.Context, method string, query url.Values, result any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+method+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	return c.do(req, method, result)
+}
+
+func (c *Client) do(req *http.Request, method string, result any) error {
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var res struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+
+	if err := json.Unmarshal(body, &res); err != nil {
+		return err
+	}
+
+	if !res.OK {
+		return &APIError{Method: method, Code: res.Error}
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(body, result)
+}
+
+func (c *Client) PostMessage(ctx context.Context, req *PostMessageRequest) error {
+	return c.post(ctx, "chat.postMessage", req, nil)
+}
+
+func (c *Client) UserInfo(ctx context.Context, userID string) (*User, error) {
+	var res struct {
+		User *User `json:"user"`
+	}
+
+	if err := c.get(ctx, "users.info", url.Values{"user": {userID}}, &res); err != nil {
+		return nil, err
+	}
+
+	return res.User, nil
+}
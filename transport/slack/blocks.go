@@ -0,0 +1,247 @@
+package slack
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/flarexio/talkix"
+)
+
+const (
+	// maxSectionText is the size limit of the text of a section.
+	maxSectionText = 3000
+
+	// maxButtonText is the size limit of the label of a button.
+	maxButtonText = 75
+
+	// maxButtonValue is the size limit of the value of a button.
+	maxButtonValue = 2000
+
+	// maxHeaderText is the size limit of the text of a header.
+	maxHeaderText = 150
+
+	// maxSectionFields is the number of fields a section can hold.
+	maxSectionFields = 10
+)
+
+// slackMessage renders a reply as Block Kit. Cards get blocks of their own,
+// and fall back to their alt text when there is nothing to show. The quick
+// replies become buttons, which send their text.
+func slackMessage(channel string, threadTS string, reply talkix.Message) (*PostMessageRequest, error) {
+	req := &PostMessageRequest{
+		Channel:  channel,
+		ThreadTS: threadTS,
+	}
+
+	switch reply := reply.(type) {
+	case *talkix.TextMessage:
+		req.Text = reply.Text
+		req.Blocks = sections(escape(reply.Text))
+
+	case *talkix.CardMessage:
+		req.Text = reply.AltText
+
+		blocks := cardBlocks(reply.Cards)
+		if len(blocks) == 0 {
+			blocks = sections(escape(reply.AltText))
+		}
+
+		req.Blocks = blocks
+
+	default:
+		return nil, talkix.ErrUnexpectedResponse
+	}
+
+	if qrs := reply.QuickReply(); len(qrs) > 0 {
+		buttons := make([]any, 0, len(qrs))
+		for i, qr := range qrs {
+			if qr == "" || len(qr) > maxButtonValue {
+				continue
+			}
+
+			buttons = append(buttons, &Element{
+				Type:     "button",
+				Text:     plain(truncate(qr, maxButtonText)),
+				ActionID: "quick_reply_" + strconv.Itoa(i),
+				Value:    qr,
+			})
+		}
+
+		if len(buttons) > 0 {
+			req.Blocks = append(req.Blocks, &Block{Type: "actions", Elements: buttons})
+		}
+	}
+
+	return req, nil
+}
+
+// cardBlocks renders the cards one after another, with a divider between
+// them.
+func cardBlocks(cards []*talkix.Card) []*Block {
+	blocks := make([]*Block, 0)
+	for i, card := range cards {
+		rendered := cardBlock(i, card)
+		if len(rendered) == 0 {
+			continue
+		}
+
+		if len(blocks) > 0 {
+			blocks = append(blocks, &Block{Type: "divider"})
+		}
+
+		blocks = append(blocks, rendered...)
+	}
+
+	return blocks
+}
+
+// cardBlock lays out a card: the title as a header, the subtitle with the
+// image beside it, the rows as fields, the text, the buttons and the footer.
+func cardBlock(n int, card *talkix.Card) []*Block {
+	blocks := make([]*Block, 0)
+
+	if card.Title != "" {
+		blocks = append(blocks, &Block{Type: "header", Text: plain(truncate(card.Title, maxHeaderText))})
+	}
+
+	hasImage := card.Image != nil && card.Image.URL != ""
+
+	switch {
+	case card.Subtitle != "":
+		subtitle := &Block{Type: "section", Text: mrkdwn("*" + escape(card.Subtitle) + "*")}
+		if hasImage {
+			subtitle.Accessory = &Element{
+				Type:     "image",
+				ImageURL: card.Image.URL,
+				AltText:  altText(card),
+			}
+		}
+
+		blocks = append(blocks, subtitle)
+
+	case hasImage:
+		blocks = append(blocks, &Block{
+			Type:     "image",
+			ImageURL: card.Image.URL,
+			AltText:  altText(card),
+		})
+	}
+
+	for i := 0; i < len(card.Rows); i += maxSectionFields {
+		rows := card.Rows[i:min(i+maxSectionFields, len(card.Rows))]
+
+		fields := make([]*Text, len(rows))
+		for j, row := range rows {
+			fields[j] = field(row.Key, row.Value)
+		}
+
+		blocks = append(blocks, &Block{Type: "section", Fields: fields})
+	}
+
+	if card.Text != "" {
+		blocks = append(blocks, sections(escape(card.Text))...)
+	}
+
+	buttons := make([]any, 0, len(card.Buttons))
+	for i, b := range card.Buttons {
+		e := &Element{
+			Type:     "button",
+			Text:     plain(truncate(b.Label, maxButtonText)),
+			ActionID: "card_" + strconv.Itoa(n) + "_button_" + strconv.Itoa(i),
+		}
+
+		switch {
+		case b.URL != "":
+			e.URL = b.URL
+
+		case b.Text != "" && len(b.Text) <= maxButtonValue:
+			e.Value = b.Text
+
+		default:
+			continue
+		}
+
+		if b.Style == talkix.ButtonPrimary {
+			e.Style = "primary"
+		}
+
+		buttons = append(buttons, e)
+	}
+
+	if len(buttons) > 0 {
+		blocks = append(blocks, &Block{Type: "actions", Elements: buttons})
+	}
+
+	if card.Footer != "" {
+		blocks = append(blocks, &Block{
+			Type:     "context",
+			Elements: []any{mrkdwn(escape(card.Footer))},
+		})
+	}
+
+	return blocks
+}
+
+// altText describes the image of a card, which Slack requires.
+func altText(card *talkix.Card) string {
+	if card.Image.AltText != "" {
+		return card.Image.AltText
+	}
+
+	if card.Title != "" {
+		return card.Title
+	}
+
+	return "image"
+}
+
+// sections splits a text into sections, each within the size limit.
+func sections(text string) []*Block {
+	blocks := make([]*Block, 0, 1)
+	for text != "" {
+		chunk := truncate(text, maxSectionText)
+		text = text[len(chunk):]
+
+		blocks = append(blocks, &Block{Type: "section", Text: mrkdwn(chunk)})
+	}
+
+	return blocks
+}
+
+// truncate cuts a text down to a number of characters.
+func truncate(text string, n int) string {
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+
+	i := 0
+	for j := range text {
+		if i == n {
+			return text[:j]
+		}
+
+		i++
+	}
+
+	return text
+}
+
+func plain(text string) *Text {
+	return &Text{Type: "plain_text", Text: text}
+}
+
+func mrkdwn(text string) *Text {
+	return &Text{Type: "mrkdwn", Text: text}
+}
+
+func field(label string, value string) *Text {
+	return mrkdwn("*" + label + "*\n" + escape(value))
+}
+
+var escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// escape escapes the characters that Slack reserves for links and mentions.
+func escape(text string) string {
+	return escaper.Replace(text)
+}
@@ -0,0 +1,109 @@
+package slack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix"
+)
+
+func TestSlackMessageCard(t *testing.T) {
+	assert := assert.New(t)
+
+	reply := talkix.NewCardMessage("台中天氣", &talkix.Card{
+		Title:    "Taichung",
+		Subtitle: "Sunny",
+		Image:    &talkix.Image{URL: "https://example.com/sun.png", AltText: "Sunny"},
+		Rows: []talkix.Row{
+			{Key: "溫度", Value: "30.5°C"},
+			{Key: "濕度", Value: "65%"},
+		},
+		Text:   "UV < 5",
+		Footer: "Last updated 12:00",
+	})
+	reply.AddQuickReply("Tomorrow")
+
+	msg, err := slackMessage("C1", "100.1", reply)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("C1", msg.Channel)
+	assert.Equal("100.1", msg.ThreadTS)
+	assert.Equal("台中天氣", msg.Text)
+
+	if !assert.Len(msg.Blocks, 6) {
+		return
+	}
+
+	assert.Equal(&Block{Type: "header", Text: plain("Taichung")}, msg.Blocks[0])
+	assert.Equal("*Sunny*", msg.Blocks[1].Text.Text)
+	assert.Equal("https://example.com/sun.png", msg.Blocks[1].Accessory.ImageURL)
+	assert.Equal("*溫度*\n30.5°C", msg.Blocks[2].Fields[0].Text)
+	assert.Equal("UV &lt; 5", msg.Blocks[3].Text.Text)
+	assert.Equal("context", msg.Blocks[4].Type)
+
+	assert.Equal(&Block{
+		Type: "actions",
+		Elements: []any{&Element{
+			Type:     "button",
+			Text:     plain("Tomorrow"),
+			ActionID: "quick_reply_0",
+			Value:    "Tomorrow",
+		}},
+	}, msg.Blocks[5])
+}
+
+func TestSlackMessageCarousel(t *testing.T) {
+	assert := assert.New(t)
+
+	reply := talkix.NewCardMessage("Cafes",
+		&talkix.Card{
+			Title: "Cafe A",
+			Buttons: []talkix.Button{
+				{Label: "在地圖上查看", URL: "https://www.google.com/maps/place/?q=place_id:p1", Style: talkix.ButtonLink},
+				{Label: "More", Text: "More cafes", Style: talkix.ButtonPrimary},
+				{Label: "Nothing"},
+			},
+		},
+		&talkix.Card{Title: "Cafe B"},
+	)
+
+	msg, err := slackMessage("C1", "", reply)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	if assert.Len(msg.Blocks, 4) {
+		assert.Equal([]any{
+			&Element{
+				Type:     "button",
+				Text:     plain("在地圖上查看"),
+				ActionID: "card_0_button_0",
+				URL:      "https://www.google.com/maps/place/?q=place_id:p1",
+			},
+			&Element{
+				Type:     "button",
+				Text:     plain("More"),
+				ActionID: "card_0_button_1",
+				Value:    "More cafes",
+				Style:    "primary",
+			},
+		}, msg.Blocks[1].Elements)
+
+		assert.Equal("divider", msg.Blocks[2].Type)
+		assert.Equal(plain("Cafe B"), msg.Blocks[3].Text)
+	}
+
+	// Cards with nothing to show fall back to the alt text.
+	msg, err = slackMessage("C1", "", talkix.NewCardMessage("Fish & chips", &talkix.Card{}))
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal([]*Block{{Type: "section", Text: mrkdwn("Fish &amp; chips")}}, msg.Blocks)
+}
@@ -0,0 +1,453 @@
+package slack
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/flarexio/core/endpoint"
+	"github.com/flarexio/talkix"
+	"github.com/flarexio/talkix/config"
+	"github.com/flarexio/talkix/user"
+)
+
+const (
+	// maxRequestAge is how old a signed request can be, so that a request
+	// that was caught cannot be replayed later.
+	maxRequestAge = 5 * time.Minute
+
+	// maxBodySize is the largest request read from Slack.
+	maxBodySize = 1 << 20
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid slack signature")
+	ErrStaleRequest     = errors.New("slack request too old")
+	ErrUnsupportedEvent = errors.New("unsupported event type")
+)
+
+var (
+	cfg config.SlackConfig
+	api *Client
+)
+
+func Init(config config.Config) error {
+	if config.Slack.BotToken == "" {
+		return errors.New("slack bot token required")
+	}
+
+	if config.Slack.SigningSecret == "" {
+		return errors.New("slack signing secret required")
+	}
+
+	cfg = config.Slack
+	api = NewClient(cfg.BotToken, cfg.APIURL)
+	return nil
+}
+
+// verify checks the signature Slack computes over the timestamp and the body
+// with the signing secret.
+func verify(header http.Header, body []byte, now time.Time) error {
+	ts := header.Get("X-Slack-Request-Timestamp")
+
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	if age := now.Sub(time.Unix(sec, 0)); age > maxRequestAge || age < -maxRequestAge {
+		return ErrStaleRequest
+	}
+
+	mac := hmac.New(sha256.New, []byte(cfg.SigningSecret))
+	mac.Write([]byte("v0:" + ts + ":"))
+	mac.Write(body)
+
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(header.Get("X-Slack-Signature"))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// request is a message to answer in a thread. In a channel every mention
+// starts a thread of its own; in a direct message the messages outside a
+// thread are answered outside a thread too.
+type request struct {
+	teamID    string
+	channel   string
+	threadTS  string
+	userID    string
+	direct    bool
+	mentioned bool
+	msg       talkix.Message
+}
+
+// user keeps the Slack users apart from the users of other channels and
+// other workspaces.
+func (r *request) user() string {
+	return "slack:" + r.teamID + ":" + r.userID
+}
+
+// thread identifies the thread, whose messages are answered in order. A
+// direct message outside a thread is in no thread of its own, and is
+// answered in the session its user selected.
+func (r *request) thread() string {
+	id := "slack:" + r.teamID + ":" + r.channel
+	if r.threadTS != "" {
+		id += ":" + r.threadTS
+	}
+
+	return id
+}
+
+// eventRequest reads a message from an event. The bot is answered when it is
+// mentioned in a channel, or sent a direct message; the messages of bots and
+// the edits are left alone.
+func eventRequest(env EventEnvelope) (*request, error) {
+	e := env.Event
+	if e == nil || e.BotID != "" || e.Subtype != "" || e.User == "" {
+		return nil, ErrUnsupportedEvent
+	}
+
+	r := &request{
+		teamID:   env.TeamID,
+		channel:  e.Channel,
+		threadTS: e.ThreadTS,
+		userID:   e.User,
+	}
+
+	switch {
+	case e.Type == "app_mention":
+		r.mentioned = true
+		if r.threadTS == "" {
+			r.threadTS = e.TS
+		}
+
+	case e.Type == "message" && e.ChannelType == "im":
+		r.direct = true
+
+	default:
+		return nil, ErrUnsupportedEvent
+	}
+
+	text := e.Text
+	for _, auth := range env.Authorizations {
+		if auth.IsBot {
+			text = strings.ReplaceAll(text, "<@"+auth.UserID+">", "")
+		}
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, ErrUnsupportedEvent
+	}
+
+	r.msg = talkix.NewTextMessage(text)
+	if t, ok := timestamp(e.TS); ok {
+		r.msg.SetTimestamp(t)
+	}
+
+	return r, nil
+}
+
+// actionRequests reads the buttons pressed in an interaction. A button with a
+// value sends it as a message into the thread of its message; a button that
+// opens a page has no value and is left alone.
+func actionRequests(p InteractionPayload) ([]*request, error) {
+	if p.Type != "block_actions" || p.Message == nil {
+		return nil, ErrUnsupportedEvent
+	}
+
+	requests := make([]*request, 0, len(p.Actions))
+	for _, action := range p.Actions {
+		if action.Value == "" {
+			continue
+		}
+
+		requests = append(requests, &request{
+			teamID:    p.Team.ID,
+			channel:   p.Channel.ID,
+			threadTS:  p.Message.ThreadTS,
+			userID:    p.User.ID,
+			direct:    strings.HasPrefix(p.Channel.ID, "D"),
+			mentioned: true,
+			msg:       talkix.NewTextMessage(action.Value),
+		})
+	}
+
+	if len(requests) == 0 {
+		return nil, ErrUnsupportedEvent
+	}
+
+	return requests, nil
+}
+
+// timestamp reads the time of a Slack message, whose ts is the Unix time with
+// the microseconds after the dot.
+func timestamp(ts string) (time.Time, bool) {
+	sec, usec, _ := strings.Cut(ts, ".")
+
+	s, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	us, _ := strconv.ParseInt(usec, 10, 64)
+	return time.Unix(s, us*int64(time.Microsecond)), true
+}
+
+// NewBot answers the messages through the reply endpoint. The messages of a
+// thread are answered one at a time and in order, different threads in
+// parallel.
+func NewBot(replies endpoint.Endpoint) *Bot {
+	b := &Bot{
+		replies: replies,
+		names:   make(map[string]string),
+	}
+
+	b.queue = talkix.NewKeyedQueue(talkix.DefaultKeyedQueueSize, b.run)
+	return b
+}
+
+type Bot struct {
+	replies endpoint.Endpoint
+	queue   *talkix.KeyedQueue[string, *request]
+
+	// names caches the display names of the speakers.
+	names   map[string]string
+	namesMu sync.Mutex
+}
+
+// enqueue queues a message behind the others of its thread. A thread with too
+// many messages waiting fails with talkix.ErrQueueFull.
+func (b *Bot) enqueue(r *request) error {
+	return b.queue.Enqueue(r.thread(), r)
+}
+
+func (b *Bot) run(thread string, r *request) {
+	if err := b.answer(context.Background(), r); err != nil {
+		zap.L().Error("failed to answer slack message",
+			zap.String("thread", thread),
+			zap.Error(err),
+		)
+	}
+}
+
+func (b *Bot) answer(ctx context.Context, r *request) error {
+	ctx = b.context(ctx, r)
+
+	replies, err := talkix.Replies(ctx, b.replies, r.msg)
+	if err != nil {
+		return err
+	}
+
+	msgs, err := talkix.Render(replies, func(reply talkix.Message) (*PostMessageRequest, error) {
+		return slackMessage(r.channel, r.threadTS, reply)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range msgs {
+		if err := api.PostMessage(ctx, msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// context puts the Slack user and the thread of a message in the context,
+// the thread keeping a session that its members share. In a channel the
+// thread is part of a chat, whose settings decide when the bot answers.
+func (b *Bot) context(ctx context.Context, r *request) context.Context {
+	ctx = context.WithValue(ctx, talkix.UserKey, &user.User{ID: r.user()})
+
+	if r.threadTS != "" {
+		ctx = context.WithValue(ctx, talkix.ThreadKey, r.thread())
+	}
+
+	if r.direct {
+		return ctx
+	}
+
+	return context.WithValue(ctx, talkix.ChatKey, &talkix.Chat{
+		ID:        "slack:" + r.teamID + ":" + r.channel,
+		Type:      talkix.ChatChannel,
+		Speaker:   b.speakerName(ctx, r.userID),
+		Mentioned: r.mentioned,
+	})
+}
+
+// speakerName is the display name of a Slack user, or else the user ID.
+func (b *Bot) speakerName(ctx context.Context, userID string) string {
+	b.namesMu.Lock()
+	name, ok := b.names[userID]
+	b.namesMu.Unlock()
+
+	if ok {
+		return name
+	}
+
+	u, err := api.UserInfo(ctx, userID)
+	if err != nil {
+		zap.L().Warn("failed to get slack user",
+			zap.String("user", userID),
+			zap.Error(err),
+		)
+
+		return userID
+	}
+
+	for _, name = range []string{u.Profile.DisplayName, u.Profile.RealName, u.Name, userID} {
+		if name != "" {
+			break
+		}
+	}
+
+	b.namesMu.Lock()
+	b.names[userID] = name
+	b.namesMu.Unlock()
+
+	return name
+}
+
+// Close stops taking messages and waits for the queued ones to be answered.
+func (b *Bot) Close() {
+	b.queue.Close()
+}
+
+// verifiedBody reads the body of a request from Slack, and aborts the
+// request unless it is signed with the signing secret.
+func verifiedBody(c *gin.Context) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBodySize))
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		c.Error(err)
+		c.Abort()
+		return nil, false
+	}
+
+	if err := verify(c.Request.Header, body, time.Now()); err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Error(err)
+		c.Abort()
+		return nil, false
+	}
+
+	return body, true
+}
+
+// EventHandler takes the requests of the Events API: it answers the challenge
+// of the request URL, and hands the messages to the bot. It answers right
+// away, the messages are replied to in the background.
+func EventHandler(b *Bot) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, ok := verifiedBody(c)
+		if !ok {
+			return
+		}
+
+		var env EventEnvelope
+		if err := json.Unmarshal(body, &env); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		if env.Type == "url_verification" {
+			c.JSON(http.StatusOK, gin.H{"challenge": env.Challenge})
+			return
+		}
+
+		// Slack retries an event it had no answer to in time, which was
+		// taken all the same.
+		if c.GetHeader("X-Slack-Retry-Reason") == "http_timeout" {
+			c.Status(http.StatusOK)
+			return
+		}
+
+		if env.Type != "event_callback" {
+			c.Status(http.StatusOK)
+			return
+		}
+
+		r, err := eventRequest(env)
+		if err != nil {
+			zap.L().Debug(err.Error(), zap.String("event", env.EventID))
+			c.Status(http.StatusOK)
+			return
+		}
+
+		if err := b.enqueue(r); err != nil {
+			// Slack delivers the event again later.
+			c.String(http.StatusServiceUnavailable, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		c.Status(http.StatusOK)
+	}
+}
+
+// InteractionHandler takes the buttons pressed in the messages of the bot,
+// which Slack posts as a form with the payload in JSON.
+func InteractionHandler(b *Bot) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, ok := verifiedBody(c)
+		if !ok {
+			return
+		}
+
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		var p InteractionPayload
+		if err := json.Unmarshal([]byte(form.Get("payload")), &p); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		requests, err := actionRequests(p)
+		if err != nil {
+			zap.L().Debug(err.Error(), zap.String("type", p.Type))
+			c.Status(http.StatusOK)
+			return
+		}
+
+		for _, r := range requests {
+			if err := b.enqueue(r); err != nil {
+				zap.L().Warn("skipping slack action",
+					zap.String("thread", r.thread()),
+					zap.Error(err),
+				)
+			}
+		}
+
+		c.Status(http.StatusOK)
+	}
+}
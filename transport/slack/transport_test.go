@@ -0,0 +1,285 @@
+package slack
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix"
+	"github.com/flarexio/talkix/config"
+	"github.com/flarexio/talkix/user"
+)
+
+const testSigningSecret = "test-secret"
+
+// apiServer stands in for the Web API, and records the messages posted.
+type apiServer struct {
+	*httptest.Server
+
+	posted []PostMessageRequest
+	sync.Mutex
+}
+
+func newAPIServer() *apiServer {
+	s := &apiServer{}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+		var result map[string]any
+		switch method {
+		case "chat.postMessage":
+			var req PostMessageRequest
+			json.NewDecoder(r.Body).Decode(&req)
+
+			s.Lock()
+			s.posted = append(s.posted, req)
+			s.Unlock()
+
+			result = map[string]any{"ok": true}
+
+		case "users.info":
+			result = map[string]any{
+				"ok": true,
+				"user": map[string]any{
+					"id":      r.URL.Query().Get("user"),
+					"profile": map[string]any{"display_name": "Alice"},
+				},
+			}
+
+		default:
+			result = map[string]any{"ok": false, "error": "unknown_method"}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}))
+
+	return s
+}
+
+func (s *apiServer) Posted() []PostMessageRequest {
+	s.Lock()
+	defer s.Unlock()
+
+	return append([]PostMessageRequest{}, s.posted...)
+}
+
+func useAPIServer(t *testing.T, server *apiServer) {
+	var c config.Config
+	c.Slack.BotToken = "xoxb-test"
+	c.Slack.SigningSecret = testSigningSecret
+	c.Slack.APIURL = server.URL
+
+	if err := Init(c); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// echoEndpoint answers with the user and the thread, and the speaker in a
+// channel.
+func echoEndpoint(ctx context.Context, request any) (any, error) {
+	u := ctx.Value(talkix.UserKey).(*user.User)
+	msg := request.(talkix.Message)
+
+	text := u.ID
+	if thread, ok := ctx.Value(talkix.ThreadKey).(string); ok {
+		text += " in " + thread
+	}
+
+	text += ": " + msg.Content()
+	if chat, ok := ctx.Value(talkix.ChatKey).(*talkix.Chat); ok {
+		text = chat.Speaker + "@" + text
+	}
+
+	return talkix.NewTextMessage(text), nil
+}
+
+func sign(req *http.Request, body string, ts time.Time) {
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(testSigningSecret))
+	mac.Write([]byte("v0:" + timestamp + ":" + body))
+
+	req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+}
+
+func post(handler gin.HandlerFunc, body string, modify func(*http.Request)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/webhook/slack", strings.NewReader(body))
+	sign(req, body, time.Now())
+
+	if modify != nil {
+		modify(req)
+	}
+
+	w := httptest.NewRecorder()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/webhook/slack", handler)
+	r.ServeHTTP(w, req)
+
+	return w
+}
+
+func eventBody(e Event) string {
+	body, _ := json.Marshal(EventEnvelope{
+		Type:           "event_callback",
+		TeamID:         "T1",
+		EventID:        "Ev1",
+		Event:          &e,
+		Authorizations: []Authorization{{UserID: "UBOT", IsBot: true}},
+	})
+
+	return string(body)
+}
+
+func TestEventHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newAPIServer()
+	defer server.Close()
+
+	useAPIServer(t, server)
+
+	bot := NewBot(echoEndpoint)
+	handler := EventHandler(bot)
+
+	mention := eventBody(Event{
+		Type:    "app_mention",
+		User:    "U1",
+		Text:    "<@UBOT> hello",
+		TS:      "100.1",
+		Channel: "C1",
+	})
+
+	w := post(handler, mention, func(req *http.Request) {
+		req.Header.Set("X-Slack-Signature", "v0=invalid")
+	})
+	assert.Equal(http.StatusUnauthorized, w.Code)
+
+	w = post(handler, mention, func(req *http.Request) {
+		sign(req, mention, time.Now().Add(-10*time.Minute))
+	})
+	assert.Equal(http.StatusUnauthorized, w.Code)
+
+	w = post(handler, `{"type": "url_verification", "challenge": "abc"}`, nil)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.JSONEq(`{"challenge": "abc"}`, w.Body.String())
+
+	// A mention starts a thread of its own.
+	w = post(handler, mention, nil)
+	assert.Equal(http.StatusOK, w.Code)
+
+	// A reply in the thread mentions the bot again.
+	w = post(handler, eventBody(Event{
+		Type:     "app_mention",
+		User:     "U1",
+		Text:     "<@UBOT> and tomorrow?",
+		TS:       "100.2",
+		ThreadTS: "100.1",
+		Channel:  "C1",
+	}), nil)
+	assert.Equal(http.StatusOK, w.Code)
+
+	// A retry of an event that was taken is not answered again.
+	w = post(handler, mention, func(req *http.Request) {
+		req.Header.Set("X-Slack-Retry-Num", "1")
+		req.Header.Set("X-Slack-Retry-Reason", "http_timeout")
+	})
+	assert.Equal(http.StatusOK, w.Code)
+
+	w = post(handler, eventBody(Event{
+		Type:        "message",
+		User:        "U1",
+		Text:        "hi",
+		TS:          "200.1",
+		Channel:     "D1",
+		ChannelType: "im",
+	}), nil)
+	assert.Equal(http.StatusOK, w.Code)
+
+	// The messages of bots are left alone.
+	w = post(handler, eventBody(Event{
+		Type:        "message",
+		BotID:       "B1",
+		Text:        "hi",
+		TS:          "200.2",
+		Channel:     "D1",
+		ChannelType: "im",
+	}), nil)
+	assert.Equal(http.StatusOK, w.Code)
+
+	bot.Close()
+
+	posted := server.Posted()
+	if !assert.Len(posted, 3) {
+		return
+	}
+
+	byThread := make(map[string][]string)
+	for _, msg := range posted {
+		byThread[msg.Channel+"/"+msg.ThreadTS] = append(byThread[msg.Channel+"/"+msg.ThreadTS], msg.Text)
+	}
+
+	assert.Equal([]string{
+		"Alice@slack:T1:U1 in slack:T1:C1:100.1: hello",
+		"Alice@slack:T1:U1 in slack:T1:C1:100.1: and tomorrow?",
+	}, byThread["C1/100.1"])
+
+	// A direct message outside a thread goes to the session the user
+	// selected.
+	assert.Equal([]string{"slack:T1:U1: hi"}, byThread["D1/"])
+}
+
+func TestInteractionHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newAPIServer()
+	defer server.Close()
+
+	useAPIServer(t, server)
+
+	bot := NewBot(echoEndpoint)
+	handler := InteractionHandler(bot)
+
+	payload, _ := json.Marshal(InteractionPayload{
+		Type:    "block_actions",
+		User:    User{ID: "U1"},
+		Team:    Team{ID: "T1"},
+		Channel: Channel{ID: "C1"},
+		Message: &Event{TS: "100.3", ThreadTS: "100.1"},
+		Actions: []Action{
+			{ActionID: "open_map_0", Type: "button"},
+			{ActionID: "quick_reply_0", Type: "button", Value: "Weather tomorrow"},
+		},
+	})
+
+	body := url.Values{"payload": {string(payload)}}.Encode()
+
+	w := post(handler, body, nil)
+	assert.Equal(http.StatusOK, w.Code)
+
+	bot.Close()
+
+	posted := server.Posted()
+	if assert.Len(posted, 1) {
+		assert.Equal("C1", posted[0].Channel)
+		assert.Equal("100.1", posted[0].ThreadTS)
+		assert.Equal("Alice@slack:T1:U1 in slack:T1:C1:100.1: Weather tomorrow", posted[0].Text)
+	}
+}
@@ -0,0 +1,205 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const DefaultAPIURL = "https://api.telegram.org"
+
+// APIError is an error answered by the Bot API.
+type APIError struct {
+	Code        int
+	Description string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("telegram: %d %s", e.Code, e.Description)
+}
+
+type Update struct {
+	UpdateID      int64          `json:"update_id"`
+	Message       *Message       `json:"message,omitempty"`
+	CallbackQuery *CallbackQuery `json:"callback_query,omitempty"`
+}
+
+type Message struct {
+	MessageID int64     `json:"message_id"`
+	From      *User     `json:"from,omitempty"`
+	Chat      Chat      `json:"chat"`
+	Date      int64     `json:"date"`
+	Text      string    `json:"text,omitempty"`
+	Location  *Location `json:"location,omitempty"`
+	Venue     *Venue    `json:"venue,omitempty"`
+}
+
+type User struct {
+	ID        int64  `json:"id"`
+	IsBot     bool   `json:"is_bot"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name,omitempty"`
+	Username  string `json:"username,omitempty"`
+}
+
+type Chat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+}
+
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type Venue struct {
+	Location Location `json:"location"`
+	Title    string   `json:"title"`
+	Address  string   `json:"address"`
+}
+
+// CallbackQuery is sent when an inline keyboard button is pressed.
+type CallbackQuery struct {
+	ID      string   `json:"id"`
+	From    User     `json:"from"`
+	Message *Message `json:"message,omitempty"`
+	Data    string   `json:"data,omitempty"`
+}
+
+type SendMessageRequest struct {
+	ChatID      int64  `json:"chat_id"`
+	Text        string `json:"text"`
+	ParseMode   string `json:"parse_mode,omitempty"`
+	ReplyMarkup any    `json:"reply_markup,omitempty"`
+}
+
+type InlineKeyboardMarkup struct {
+	InlineKeyboard [][]InlineKeyboardButton `json:"inline_keyboard"`
+}
+
+type InlineKeyboardButton struct {
+	Text         string `json:"text"`
+	URL          string `json:"url,omitempty"`
+	CallbackData string `json:"callback_data,omitempty"`
+}
+
+type ReplyKeyboardMarkup struct {
+	Keyboard        [][]KeyboardButton `json:"keyboard"`
+	ResizeKeyboard  bool               `json:"resize_keyboard"`
+	OneTimeKeyboard bool               `json:"one_time_keyboard"`
+}
+
+type KeyboardButton struct {
+	Text string `json:"text"`
+}
+
+// Client calls the methods of the Telegram Bot API.
+type Client struct {
+	url    string
+	client *http.Client
+}
+
+func NewClient(token string, apiURL string) *Client {
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+
+	return &Client{
+		url:    strings.TrimSuffix(apiURL, "/") + "/bot" + token + "/",
+		client: &http.Client{},
+	}
+}
+
+func (c *Client) call(ctx context.Context, method string, params any, result any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var res struct {
+		OK          bool            `json:"ok"`
+		Result      json.RawMessage `json:"result"`
+		ErrorCode   int             `json:"error_code"`
+		Description string          `json:"description"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return err
+	}
+
+	if !res.OK {
+		return &APIError{Code: res.ErrorCode, Description: res.Description}
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(res.Result, result)
+}
+
+// GetUpdates waits up to the timeout for the updates from the offset on.
+func (c *Client) GetUpdates(ctx context.Context, offset int64, timeout time.Duration) ([]Update, error) {
+	params := map[string]any{
+		"offset":          offset,
+		"timeout":         int(timeout.Seconds()),
+		"allowed_updates": []string{"message", "callback_query"},
+	}
+
+	var updates []Update
+	if err := c.call(ctx, "getUpdates", params, &updates); err != nil {
+		return nil, err
+	}
+
+	return updates, nil
+}
+
+func (c *Client) SendMessage(ctx context.Context, req *SendMessageRequest) error {
+	return c.call(ctx, "sendMessage", req, nil)
+}
+
+// SendChatAction shows the bot as typing until the next message is sent.
+func (c *Client) SendChatAction(ctx context.Context, chatID int64, action string) error {
+	return c.call(ctx, "sendChatAction", map[string]any{
+		"chat_id": chatID,
+		"action":  action,
+	}, nil)
+}
+
+// AnswerCallbackQuery stops the progress shown on a pressed button.
+func (c *Client) AnswerCallbackQuery(ctx context.Context, id string) error {
+	return c.call(ctx, "answerCallbackQuery", map[string]any{
+		"callback_query_id": id,
+	}, nil)
+}
+
+func (c *Client) SetWebhook(ctx context.Context, url string, secretToken string) error {
+	return c.call(ctx, "setWebhook", map[string]any{
+		"url":             url,
+		"secret_token":    secretToken,
+		"allowed_updates": []string{"message", "callback_query"},
+	}, nil)
+}
+
+// DeleteWebhook is needed before polling, the updates cannot be fetched
+// while a webhook is set.
+func (c *Client) DeleteWebhook(ctx context.Context) error {
+	return c.call(ctx, "deleteWebhook", map[string]any{}, nil)
+}
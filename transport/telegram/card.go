@@ -0,0 +1,108 @@
+package telegram
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/flarexio/talkix"
+)
+
+// maxCallbackData is the size limit of the data of an inline button.
+const maxCallbackData = 64
+
+// cardMarkdown renders cards as MarkdownV2 text, with their buttons as an
+// inline keyboard. The cards of a carousel follow one another, separated by
+// a blank line.
+func cardMarkdown(cards []*talkix.Card) (string, [][]InlineKeyboardButton) {
+	blocks := make([]string, 0, len(cards))
+	buttons := make([][]InlineKeyboardButton, 0)
+
+	for _, card := range cards {
+		lines := make([]string, 0)
+
+		if card.Image != nil && card.Image.URL != "" {
+			lines = append(lines, "[Image]("+escapeURL(card.Image.URL)+")")
+		}
+
+		if card.Title != "" {
+			lines = append(lines, "*"+escapeMarkdown(card.Title)+"*")
+		}
+
+		if card.Subtitle != "" {
+			lines = append(lines, "_"+escapeMarkdown(card.Subtitle)+"_")
+		}
+
+		for _, row := range card.Rows {
+			lines = append(lines, escapeMarkdown(row.Key)+" "+escapeMarkdown(row.Value))
+		}
+
+		if card.Text != "" {
+			lines = append(lines, escapeMarkdown(card.Text))
+		}
+
+		if card.Footer != "" {
+			lines = append(lines, escapeMarkdown(card.Footer))
+		}
+
+		if len(lines) > 0 {
+			blocks = append(blocks, strings.Join(lines, "\n"))
+		}
+
+		for _, button := range card.Buttons {
+			if b, ok := inlineButton(button); ok {
+				buttons = append(buttons, []InlineKeyboardButton{b})
+			}
+		}
+	}
+
+	return strings.Join(blocks, "\n\n"), buttons
+}
+
+// inlineButton maps a button of a card to an inline button. A page opens in
+// the browser; a text is sent back as the user would have sent it, which is
+// answered like any other message.
+func inlineButton(button talkix.Button) (InlineKeyboardButton, bool) {
+	b := InlineKeyboardButton{Text: button.Label}
+
+	switch {
+	case button.URL != "":
+		u, err := url.Parse(button.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return b, false
+		}
+
+		b.URL = button.URL
+
+	case button.Text != "":
+		if len(button.Text) > maxCallbackData {
+			return b, false
+		}
+
+		b.CallbackData = button.Text
+
+	default:
+		return b, false
+	}
+
+	if b.Text == "" {
+		b.Text = b.CallbackData
+	}
+
+	return b, b.Text != ""
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+	"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+	"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// escapeMarkdown escapes the characters that MarkdownV2 reserves.
+func escapeMarkdown(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+// escapeURL escapes a URL inside a MarkdownV2 link.
+func escapeURL(u string) string {
+	return strings.NewReplacer(`\`, `\\`, ")", `\)`).Replace(u)
+}
@@ -0,0 +1,52 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix"
+)
+
+func TestCardMarkdown(t *testing.T) {
+	assert := assert.New(t)
+
+	card := &talkix.Card{
+		Title:    "Taichung",
+		Subtitle: "Sunny",
+		Image:    &talkix.Image{URL: "https://example.com/sun.png"},
+		Rows:     []talkix.Row{{Key: "Temperature", Value: "30.5°C"}},
+		Text:     "UV index (high)!",
+		Buttons: []talkix.Button{
+			{Label: "Forecast", URL: "https://example.com/forecast"},
+			{Label: "Tomorrow", Text: "Weather tomorrow"},
+			{Label: "LINE", URL: "line://nv/profile"},
+			{Label: "Nothing"},
+		},
+	}
+
+	text, buttons := cardMarkdown([]*talkix.Card{card})
+
+	assert.Equal("[Image](https://example.com/sun.png)\n"+
+		"*Taichung*\n"+
+		"_Sunny_\n"+
+		"Temperature 30\\.5°C\n"+
+		"UV index \\(high\\)\\!", text)
+
+	assert.Equal([][]InlineKeyboardButton{
+		{{Text: "Forecast", URL: "https://example.com/forecast"}},
+		{{Text: "Tomorrow", CallbackData: "Weather tomorrow"}},
+	}, buttons)
+}
+
+func TestCardMarkdownCarousel(t *testing.T) {
+	assert := assert.New(t)
+
+	text, buttons := cardMarkdown([]*talkix.Card{
+		{Title: "Cafe A"},
+		{Title: "Cafe B"},
+	})
+
+	assert.Equal("*Cafe A*\n\n*Cafe B*", text)
+	assert.Empty(buttons)
+}
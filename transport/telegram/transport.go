@@ -0,0 +1,357 @@
+package telegram
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/flarexio/core/endpoint"
+	"github.com/flarexio/talkix"
+	"github.com/flarexio/talkix/config"
+	"github.com/flarexio/talkix/user"
+)
+
+const DefaultTimeout = 30
+
+// retryBackoff is the wait after the updates could not be fetched, or not
+// all of them queued.
+var retryBackoff = 5 * time.Second
+
+var (
+	ErrUnsupportedUpdate = errors.New("unsupported update type")
+	ErrUnsupportedChat   = errors.New("unsupported chat type")
+)
+
+var (
+	cfg config.TelegramConfig
+	api *Client
+)
+
+func Init(config config.Config) error {
+	if config.Telegram.Token == "" {
+		return errors.New("telegram token required")
+	}
+
+	if isWebhook(config.Telegram) && config.Telegram.SecretToken == "" {
+		return errors.New("telegram secret token required in webhook mode")
+	}
+
+	cfg = config.Telegram
+	api = NewClient(cfg.Token, cfg.APIURL)
+	return nil
+}
+
+// isWebhook tells whether the updates are posted to the webhook, which then
+// needs the secret token: without it, anyone who knows the URL could post
+// updates as any user. Polling needs none.
+func isWebhook(c config.TelegramConfig) bool {
+	return c.Mode == "" || c.Mode == config.TelegramWebhook
+}
+
+// SetWebhook registers the webhook URL of the config with Telegram.
+func SetWebhook(ctx context.Context) error {
+	if cfg.WebhookURL == "" {
+		return errors.New("telegram webhook url required")
+	}
+
+	return api.SetWebhook(ctx, cfg.WebhookURL, cfg.SecretToken)
+}
+
+// userID keeps the Telegram users apart from the users of other channels.
+func userID(u User) string {
+	return "telegram:" + strconv.FormatInt(u.ID, 10)
+}
+
+// request converts an update from a private chat to a talkix message. A
+// pressed button sends its data as the text.
+func request(u Update) (int64, *User, talkix.Message, error) {
+	switch {
+	case u.Message != nil:
+		m := u.Message
+		if m.Chat.Type != "private" {
+			return 0, nil, nil, ErrUnsupportedChat
+		}
+
+		if m.From == nil {
+			return 0, nil, nil, ErrUnsupportedUpdate
+		}
+
+		var req talkix.Message
+		switch {
+		case m.Venue != nil:
+			req = talkix.NewTextMessage(fmt.Sprintf("Title: %s\nAddress: %s\nLatitude: %.6f\nLongitude: %.6f",
+				m.Venue.Title, m.Venue.Address, m.Venue.Location.Latitude, m.Venue.Location.Longitude))
+
+		case m.Location != nil:
+			req = talkix.NewTextMessage(fmt.Sprintf("Latitude: %.6f\nLongitude: %.6f",
+				m.Location.Latitude, m.Location.Longitude))
+
+		case m.Text != "":
+			req = talkix.NewTextMessage(m.Text)
+
+		default:
+			return 0, nil, nil, ErrUnsupportedUpdate
+		}
+
+		req.SetTimestamp(time.Unix(m.Date, 0))
+		return m.Chat.ID, m.From, req, nil
+
+	case u.CallbackQuery != nil:
+		q := u.CallbackQuery
+		if q.Message == nil || q.Message.Chat.Type != "private" {
+			return 0, nil, nil, ErrUnsupportedChat
+		}
+
+		if q.Data == "" {
+			return 0, nil, nil, ErrUnsupportedUpdate
+		}
+
+		return q.Message.Chat.ID, &q.From, talkix.NewTextMessage(q.Data), nil
+
+	default:
+		return 0, nil, nil, ErrUnsupportedUpdate
+	}
+}
+
+// telegramMessage renders a reply. Cards are rendered as Markdown with an
+// inline keyboard, whose buttons also take the quick replies; a text
+// message shows the quick replies as a reply keyboard.
+func telegramMessage(chatID int64, reply talkix.Message) (*SendMessageRequest, error) {
+	req := &SendMessageRequest{ChatID: chatID}
+
+	switch reply := reply.(type) {
+	case *talkix.TextMessage:
+		req.Text = reply.Text
+
+		if qrs := reply.QuickReply(); len(qrs) > 0 {
+			keyboard := make([][]KeyboardButton, len(qrs))
+			for i, qr := range qrs {
+				keyboard[i] = []KeyboardButton{{Text: qr}}
+			}
+
+			req.ReplyMarkup = &ReplyKeyboardMarkup{
+				Keyboard:        keyboard,
+				ResizeKeyboard:  true,
+				OneTimeKeyboard: true,
+			}
+		}
+
+	case *talkix.CardMessage:
+		text, buttons := cardMarkdown(reply.Cards)
+		if text == "" {
+			req.Text = reply.AltText
+			break
+		}
+
+		req.Text = text
+		req.ParseMode = "MarkdownV2"
+
+		for _, qr := range reply.QuickReply() {
+			if len(qr) <= maxCallbackData {
+				buttons = append(buttons, []InlineKeyboardButton{{Text: qr, CallbackData: qr}})
+			}
+		}
+
+		if len(buttons) > 0 {
+			req.ReplyMarkup = &InlineKeyboardMarkup{InlineKeyboard: buttons}
+		}
+
+	default:
+		return nil, talkix.ErrUnexpectedResponse
+	}
+
+	return req, nil
+}
+
+// NewBot answers the updates through the reply endpoint. The updates of a
+// chat are answered one at a time and in order, different chats in parallel.
+func NewBot(replies endpoint.Endpoint) *Bot {
+	b := &Bot{replies: replies}
+	b.queue = talkix.NewKeyedQueue(talkix.DefaultKeyedQueueSize, b.run)
+	return b
+}
+
+type Bot struct {
+	replies endpoint.Endpoint
+	queue   *talkix.KeyedQueue[int64, Update]
+}
+
+// Handle queues an update behind the others of its chat. An update that
+// cannot be answered is rejected, a chat with too many updates waiting fails
+// with talkix.ErrQueueFull.
+func (b *Bot) Handle(u Update) error {
+	chatID, _, _, err := request(u)
+	if err != nil {
+		return err
+	}
+
+	return b.queue.Enqueue(chatID, u)
+}
+
+func (b *Bot) run(chatID int64, u Update) {
+	if err := b.answer(context.Background(), u); err != nil {
+		zap.L().Error("failed to answer telegram update",
+			zap.Int64("update", u.UpdateID),
+			zap.Int64("chat", chatID),
+			zap.Error(err),
+		)
+	}
+}
+
+func (b *Bot) answer(ctx context.Context, u Update) error {
+	chatID, from, req, err := request(u)
+	if err != nil {
+		return err
+	}
+
+	if q := u.CallbackQuery; q != nil {
+		if err := api.AnswerCallbackQuery(ctx, q.ID); err != nil {
+			zap.L().Warn("failed to answer callback query", zap.Error(err))
+		}
+	}
+
+	if err := api.SendChatAction(ctx, chatID, "typing"); err != nil {
+		zap.L().Warn("failed to send chat action", zap.Error(err))
+	}
+
+	ctx = context.WithValue(ctx, talkix.UserKey, &user.User{ID: userID(*from)})
+
+	replies, err := talkix.Replies(ctx, b.replies, req)
+	if err != nil {
+		return err
+	}
+
+	msgs, err := talkix.Render(replies, func(reply talkix.Message) (*SendMessageRequest, error) {
+		return telegramMessage(chatID, reply)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range msgs {
+		if err := api.SendMessage(ctx, msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close stops taking updates and waits for the queued ones to be answered.
+func (b *Bot) Close() {
+	b.queue.Close()
+}
+
+// Poll fetches the updates with long polling until the context is done.
+// The webhook is removed first, Telegram holds back the updates otherwise.
+// An update that was not queued is fetched again after a while; only the
+// updates that cannot be answered are skipped.
+func (b *Bot) Poll(ctx context.Context) error {
+	if err := api.DeleteWebhook(ctx); err != nil {
+		return err
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	var offset int64
+	for {
+		updates, err := api.GetUpdates(ctx, offset, time.Duration(timeout)*time.Second)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			zap.L().Warn("failed to get telegram updates", zap.Error(err))
+
+			select {
+			case <-time.After(retryBackoff):
+				continue
+
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		for _, u := range updates {
+			err := b.Handle(u)
+			if errors.Is(err, talkix.ErrQueueClosed) {
+				return nil
+			}
+
+			if errors.Is(err, talkix.ErrQueueFull) {
+				zap.L().Warn("telegram chat queue full, retrying",
+					zap.Int64("update", u.UpdateID),
+				)
+
+				break
+			}
+
+			if err != nil {
+				zap.L().Warn("skipping telegram update",
+					zap.Int64("update", u.UpdateID),
+					zap.Error(err),
+				)
+			}
+
+			offset = u.UpdateID + 1
+		}
+
+		if len(updates) > 0 && offset <= updates[len(updates)-1].UpdateID {
+			select {
+			case <-time.After(retryBackoff):
+
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+// WebhookHandler verifies the secret token of the webhook and hands the
+// update to the bot. It answers right away, the update is replied to in the
+// background.
+func WebhookHandler(b *Bot) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.GetHeader("X-Telegram-Bot-Api-Secret-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.SecretToken)) != 1 {
+			err := errors.New("invalid secret token")
+			c.String(http.StatusUnauthorized, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		var u Update
+		if err := c.ShouldBindJSON(&u); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		err := b.Handle(u)
+		switch {
+		case errors.Is(err, ErrUnsupportedUpdate), errors.Is(err, ErrUnsupportedChat):
+			zap.L().Warn(err.Error(), zap.Int64("update", u.UpdateID))
+
+		case err != nil:
+			// Telegram delivers the update again later.
+			c.String(http.StatusServiceUnavailable, err.Error())
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		c.Status(http.StatusOK)
+	}
+}
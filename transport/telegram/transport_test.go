@@ -0,0 +1,333 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flarexio/talkix"
+	"github.com/flarexio/talkix/config"
+	"github.com/flarexio/talkix/user"
+)
+
+const testSecretToken = "test-secret"
+
+type apiCall struct {
+	Method string
+	Params map[string]any
+}
+
+// botServer stands in for the Bot API and records the calls made to it.
+// getUpdates answers with the batches in updates, one per call, and then
+// waits for the request to be given up.
+type botServer struct {
+	*httptest.Server
+
+	calls   []apiCall
+	updates [][]Update
+	sync.Mutex
+}
+
+func newBotServer() *botServer {
+	s := &botServer{}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+		var params map[string]any
+		json.NewDecoder(r.Body).Decode(&params)
+
+		s.Lock()
+		s.calls = append(s.calls, apiCall{method, params})
+
+		var result any = true
+		if method == "getUpdates" {
+			if len(s.updates) == 0 {
+				s.Unlock()
+				<-r.Context().Done()
+				return
+			}
+
+			result = s.updates[0]
+			s.updates = s.updates[1:]
+		}
+		s.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+	}))
+
+	return s
+}
+
+// Calls returns the calls of a method.
+func (s *botServer) Calls(method string) []apiCall {
+	s.Lock()
+	defer s.Unlock()
+
+	calls := make([]apiCall, 0)
+	for _, call := range s.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+func useBotServer(t *testing.T, server *botServer, mode config.TelegramMode) {
+	var c config.Config
+	c.Telegram.Token = "test-token"
+	c.Telegram.Mode = mode
+	c.Telegram.SecretToken = testSecretToken
+	c.Telegram.APIURL = server.URL
+
+	if err := Init(c); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func echoEndpoint(ctx context.Context, request any) (any, error) {
+	u := ctx.Value(talkix.UserKey).(*user.User)
+	msg := request.(talkix.Message)
+
+	reply := talkix.NewTextMessage(u.ID + ": " + msg.Content())
+	reply.AddQuickReply("Thanks")
+	return reply, nil
+}
+
+func textUpdate(id int64, chatType string, text string) Update {
+	return Update{
+		UpdateID: id,
+		Message: &Message{
+			MessageID: id,
+			From:      &User{ID: 42, FirstName: "Alice"},
+			Chat:      Chat{ID: 42, Type: chatType},
+			Date:      time.Now().Unix(),
+			Text:      text,
+		},
+	}
+}
+
+func postUpdate(handler gin.HandlerFunc, secret string, u Update) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(u)
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook/telegram", bytes.NewReader(body))
+	req.Header.Set("X-Telegram-Bot-Api-Secret-Token", secret)
+
+	w := httptest.NewRecorder()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/webhook/telegram", handler)
+	r.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestInitSecretToken(t *testing.T) {
+	assert := assert.New(t)
+
+	var c config.Config
+	c.Telegram.Token = "test-token"
+
+	// A webhook, the default, cannot go without its secret.
+	assert.Error(Init(c))
+
+	c.Telegram.Mode = config.TelegramWebhook
+	assert.Error(Init(c))
+
+	c.Telegram.Mode = config.TelegramPolling
+	assert.NoError(Init(c))
+}
+
+func TestWebhookHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newBotServer()
+	defer server.Close()
+
+	useBotServer(t, server, config.TelegramWebhook)
+
+	bot := NewBot(echoEndpoint)
+	handler := WebhookHandler(bot)
+
+	w := postUpdate(handler, "wrong-secret", textUpdate(1, "private", "hello"))
+	assert.Equal(http.StatusUnauthorized, w.Code)
+
+	// A group chat is acknowledged but not answered.
+	w = postUpdate(handler, testSecretToken, textUpdate(2, "group", "hello"))
+	assert.Equal(http.StatusOK, w.Code)
+
+	w = postUpdate(handler, testSecretToken, textUpdate(3, "private", "hello"))
+	assert.Equal(http.StatusOK, w.Code)
+
+	w = postUpdate(handler, testSecretToken, Update{
+		UpdateID: 4,
+		CallbackQuery: &CallbackQuery{
+			ID:      "query-1",
+			From:    User{ID: 42},
+			Message: &Message{Chat: Chat{ID: 42, Type: "private"}},
+			Data:    "Weather tomorrow",
+		},
+	})
+	assert.Equal(http.StatusOK, w.Code)
+
+	bot.Close()
+
+	sent := server.Calls("sendMessage")
+	if !assert.Len(sent, 2) {
+		return
+	}
+
+	assert.Equal("telegram:42: hello", sent[0].Params["text"])
+	assert.Equal(map[string]any{
+		"keyboard":          []any{[]any{map[string]any{"text": "Thanks"}}},
+		"resize_keyboard":   true,
+		"one_time_keyboard": true,
+	}, sent[0].Params["reply_markup"])
+
+	assert.Equal("telegram:42: Weather tomorrow", sent[1].Params["text"])
+
+	answered := server.Calls("answerCallbackQuery")
+	if assert.Len(answered, 1) {
+		assert.Equal("query-1", answered[0].Params["callback_query_id"])
+	}
+}
+
+func TestTelegramMessageCard(t *testing.T) {
+	assert := assert.New(t)
+
+	reply := talkix.NewCardMessage("Weather", &talkix.Card{Text: "Sunny!"})
+	reply.AddQuickReply("Tomorrow")
+
+	msg, err := telegramMessage(42, reply)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("Sunny\\!", msg.Text)
+	assert.Equal("MarkdownV2", msg.ParseMode)
+	assert.Equal(&InlineKeyboardMarkup{
+		InlineKeyboard: [][]InlineKeyboardButton{{{Text: "Tomorrow", CallbackData: "Tomorrow"}}},
+	}, msg.ReplyMarkup)
+
+	// Cards with nothing to show fall back to their alt text.
+	msg, err = telegramMessage(42, talkix.NewCardMessage("Weather", &talkix.Card{}))
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("Weather", msg.Text)
+	assert.Empty(msg.ParseMode)
+}
+
+func TestPoll(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newBotServer()
+	defer server.Close()
+
+	server.updates = [][]Update{
+		{textUpdate(7, "private", "first"), textUpdate(8, "private", "second")},
+	}
+
+	useBotServer(t, server, config.TelegramPolling)
+
+	bot := NewBot(echoEndpoint)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- bot.Poll(ctx)
+	}()
+
+	assert.Eventually(func() bool {
+		return len(server.Calls("sendMessage")) == 2 && len(server.Calls("getUpdates")) == 2
+	}, time.Second, 5*time.Millisecond)
+
+	cancel()
+	assert.NoError(<-done)
+
+	bot.Close()
+
+	assert.Len(server.Calls("deleteWebhook"), 1)
+
+	// The next poll asks for the updates after the last one.
+	polls := server.Calls("getUpdates")
+	if assert.Len(polls, 2) {
+		assert.Equal(float64(9), polls[1].Params["offset"])
+	}
+
+	// The updates of a chat are answered in order.
+	sent := server.Calls("sendMessage")
+	assert.Equal("telegram:42: first", sent[0].Params["text"])
+	assert.Equal("telegram:42: second", sent[1].Params["text"])
+}
+
+func TestPollQueueFull(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newBotServer()
+	defer server.Close()
+
+	// More updates of a chat than its queue holds.
+	batch := make([]Update, talkix.DefaultKeyedQueueSize+2)
+	for i := range batch {
+		batch[i] = textUpdate(int64(i+1), "private", "hi")
+	}
+
+	server.updates = [][]Update{batch}
+
+	useBotServer(t, server, config.TelegramPolling)
+
+	backoff := retryBackoff
+	retryBackoff = 10 * time.Millisecond
+	defer func() { retryBackoff = backoff }()
+
+	release := make(chan struct{})
+	bot := NewBot(func(ctx context.Context, request any) (any, error) {
+		<-release
+		return echoEndpoint(ctx, request)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- bot.Poll(ctx)
+	}()
+
+	assert.Eventually(func() bool {
+		return len(server.Calls("getUpdates")) == 2
+	}, time.Second, 5*time.Millisecond)
+
+	cancel()
+	assert.NoError(<-done)
+
+	close(release)
+	bot.Close()
+
+	// The updates that were not queued are fetched again, the others are
+	// answered.
+	polls := server.Calls("getUpdates")
+	if !assert.Len(polls, 2) {
+		return
+	}
+
+	offset := int(polls[1].Params["offset"].(float64))
+	assert.LessOrEqual(offset, talkix.DefaultKeyedQueueSize+1)
+	assert.Len(server.Calls("sendMessage"), offset-1)
+}
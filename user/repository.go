@@ -3,10 +3,44 @@ package user
 import "errors"
 
 var (
-	ErrUserNotFound = errors.New("user not found")
+	ErrUserNotFound  = errors.New("user not found")
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrConflict      = errors.New("user was modified concurrently")
 )
 
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// PageSize returns the default page size for a missing limit and caps the
+// others.
+func PageSize(limit int) int {
+	if limit <= 0 {
+		return DefaultPageSize
+	}
+
+	return min(limit, MaxPageSize)
+}
+
+// Query lists the users ordered by ID. The cursor is the ID of the last user
+// of the previous page.
+type Query struct {
+	Cursor string
+	Limit  int
+}
+
+type Page struct {
+	Users      []*User
+	NextCursor string
+}
+
 type Repository interface {
 	Find(id string) (*User, error)
+
+	// Save fails with ErrConflict when the user was saved by someone else
+	// since it was loaded, and increments its version otherwise.
 	Save(u *User) error
+
+	List(query Query) (*Page, error)
 }
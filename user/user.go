@@ -3,6 +3,8 @@ package user
 import (
 	"errors"
 	"time"
+
+	"github.com/flarexio/talkix/llm/message"
 )
 
 type User struct {
@@ -12,6 +14,25 @@ type User struct {
 
 	SessionIDs        []string `json:"session_ids"`
 	SelectedSessionID string   `json:"selected_session_id"`
+
+	Usage     message.Usage       `json:"usage"`
+	ToolUsage message.UsageByTool `json:"tool_usage"`
+
+	// Inactive is set while the user has unfollowed the bot.
+	Inactive bool `json:"inactive,omitempty"`
+
+	// Version is the revision the user was loaded at, see Repository.Save.
+	Version int `json:"version"`
+}
+
+func (u *User) AddUsage(usage message.Usage, toolUsage message.UsageByTool) {
+	u.Usage.Add(usage)
+
+	if u.ToolUsage == nil {
+		u.ToolUsage = make(message.UsageByTool)
+	}
+
+	u.ToolUsage.Add(toolUsage)
 }
 
 func (u *User) AddSessionID(id string) {
@@ -74,10 +74,17 @@ type WeatherData struct {
 }
 
 func NewWeatherTool(cfg config.WeatherAPIConfig) llm.Tool {
+	return NewWeatherToolWithTransport(cfg, nil)
+}
+
+// NewWeatherToolWithTransport sends the API requests through the given
+// transport, e.g. a cassette. A nil transport uses http.DefaultTransport.
+func NewWeatherToolWithTransport(cfg config.WeatherAPIConfig, transport http.RoundTripper) llm.Tool {
 	return &weatherTool{
 		cfg: cfg,
 		client: &http.Client{
-			Timeout: cfg.Timeout,
+			Transport: transport,
+			Timeout:   cfg.Timeout,
 		},
 	}
 }
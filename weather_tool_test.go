@@ -3,6 +3,8 @@ package talkix
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
@@ -39,3 +41,73 @@ func TestWeatherTool(t *testing.T) {
 
 	fmt.Println(data)
 }
+
+func newWeatherServer() *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/geo/1.0/direct", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"name":"Taichung","lat":24.1477,"lon":120.6736,"country":"TW"}]`))
+	})
+
+	mux.HandleFunc("/data/3.0/onecall", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"lat": 24.1477,
+			"lon": 120.6736,
+			"timezone": "Asia/Taipei",
+			"current": {
+				"dt": 1750000000,
+				"sunrise": 1749937200,
+				"sunset": 1749986400,
+				"temp": 30.5,
+				"feels_like": 35.2,
+				"pressure": 1008,
+				"humidity": 70,
+				"wind_speed": 2.5,
+				"weather": [{"id": 803, "main": "Clouds", "description": "多雲", "icon": "04d"}]
+			}
+		}`))
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestWeatherToolWithStubServer(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newWeatherServer()
+	defer server.Close()
+
+	cfg := config.WeatherAPIConfig{
+		APIKey:  "test",
+		BaseURL: server.URL,
+		Timeout: 10 * time.Second,
+	}
+
+	tool := NewWeatherTool(cfg)
+	weatherTool := tool.(*weatherTool)
+
+	ctx := context.Background()
+	data, err := weatherTool.FetchWeatherData(ctx, "Taichung", "metric")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("Taichung", data.Location)
+	assert.Equal(30.5, data.Temperature)
+	assert.Equal("多雲", data.Condition)
+
+	result, err := tool.Call(ctx, map[string]any{
+		"latitude":  24.1477,
+		"longitude": 120.6736,
+	})
+
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Contains(result, `"temperature":30.5`)
+}